@@ -36,6 +36,9 @@ func New(timeout time.Duration, datastore dataservices.DataStore, shutdownCtx co
 }
 
 // Starts starts the monitor. Active monitor could be stopped or shuttted down by cancelling the shutdown context.
+// Every instance runs its own monitor instead of the leader only: the timeout
+// disables the API of the instance it runs in, and each instance checks the
+// administrators of the shared store.
 func (m *Monitor) Start() {
 	m.mu.Lock()
 	defer m.mu.Unlock()
@@ -8,10 +8,14 @@ import (
 type APIKeyService interface {
 	HashRaw(rawKey string) string
 	GenerateApiKey(user portainer.User, description string) (string, *portainer.APIKey, error)
+	GenerateScopedApiKey(user portainer.User, description string, expiresAt int64, scope *portainer.APIKeyScope) (string, *portainer.APIKey, error)
 	GetAPIKey(apiKeyID portainer.APIKeyID) (*portainer.APIKey, error)
 	GetAPIKeys(userID portainer.UserID) ([]portainer.APIKey, error)
 	GetDigestUserAndKey(digest string) (portainer.User, portainer.APIKey, error)
 	UpdateAPIKey(apiKey *portainer.APIKey) error
 	DeleteAPIKey(apiKeyID portainer.APIKeyID) error
+	DeleteExpiredAPIKeys() (int, error)
 	InvalidateUserKeyCache(userId portainer.UserID) bool
+	InvalidateKeyCache(apiKeyID portainer.APIKeyID) bool
+	InvalidateCache()
 }
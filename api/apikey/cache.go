@@ -77,3 +77,22 @@ func (c *ApiKeyCache[T]) InvalidateUserKeyCache(userId portainer.UserID) bool {
 
 	return present
 }
+
+// InvalidateKeyCache removes the entry of an api-key from the cache
+func (c *ApiKeyCache[T]) InvalidateKeyCache(apiKeyID portainer.APIKeyID) bool {
+	present := false
+
+	for _, k := range c.cache.Keys() {
+		_, apiKey, _ := c.Get(k.(string))
+		if apiKey.ID == apiKeyID {
+			present = c.cache.Remove(k)
+		}
+	}
+
+	return present
+}
+
+// Purge removes every entry from the cache
+func (c *ApiKeyCache[T]) Purge() {
+	c.cache.Purge()
+}
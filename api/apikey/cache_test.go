@@ -179,3 +179,21 @@ func Test_apiKeyCacheInvalidateUserKeyCache(t *testing.T) {
 		is.True(ok)
 	})
 }
+
+func Test_apiKeyCacheInvalidateKeyCache(t *testing.T) {
+	is := assert.New(t)
+
+	keyCache := NewAPIKeyCache(10, compareUser)
+
+	keyCache.cache.Add(string("foo"), entry[portainer.User]{user: portainer.User{ID: 1}, apiKey: portainer.APIKey{ID: 1}})
+	keyCache.cache.Add(string("bar"), entry[portainer.User]{user: portainer.User{ID: 1}, apiKey: portainer.APIKey{ID: 2}})
+
+	is.True(keyCache.InvalidateKeyCache(1))
+	is.False(keyCache.InvalidateKeyCache(1))
+
+	_, ok := keyCache.cache.Get(string("foo"))
+	is.False(ok)
+
+	_, ok = keyCache.cache.Get(string("bar"))
+	is.True(ok)
+}
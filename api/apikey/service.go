@@ -57,6 +57,12 @@ func (a *apiKeyService) HashRaw(rawKey string) string {
 // GenerateApiKey generates a raw API key for a user (for one-time display).
 // The generated API key is stored in the cache and database.
 func (a *apiKeyService) GenerateApiKey(user portainer.User, description string) (string, *portainer.APIKey, error) {
+	return a.GenerateScopedApiKey(user, description, 0, nil)
+}
+
+// GenerateScopedApiKey generates a raw API key for a user that expires at the
+// specified Unix timestamp (never when 0) and is restricted to a scope.
+func (a *apiKeyService) GenerateScopedApiKey(user portainer.User, description string, expiresAt int64, scope *portainer.APIKeyScope) (string, *portainer.APIKey, error) {
 	randKey := GenerateRandomKey(32)
 	encodedRawAPIKey := base64.StdEncoding.EncodeToString(randKey)
 	prefixedAPIKey := portainerAPIKeyPrefix + encodedRawAPIKey
@@ -68,6 +74,8 @@ func (a *apiKeyService) GenerateApiKey(user portainer.User, description string)
 		Prefix:      prefixedAPIKey[:7],
 		DateCreated: time.Now().Unix(),
 		Digest:      hashDigest,
+		ExpiresAt:   expiresAt,
+		Scope:       scope,
 	}
 
 	if err := a.apiKeyRepository.Create(apiKey); err != nil {
@@ -138,6 +146,30 @@ func (a *apiKeyService) DeleteAPIKey(apiKeyID portainer.APIKeyID) error {
 	return a.apiKeyRepository.Delete(apiKeyID)
 }
 
+// DeleteExpiredAPIKeys deletes the API keys that have expired and returns how many were deleted.
+func (a *apiKeyService) DeleteExpiredAPIKeys() (int, error) {
+	apiKeys, err := a.apiKeyRepository.GetExpiredAPIKeys(time.Now().Unix())
+	if err != nil {
+		return 0, errors.Wrap(err, "Unable to retrieve the expired API keys")
+	}
+
+	for _, apiKey := range apiKeys {
+		if err := a.DeleteAPIKey(apiKey.ID); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(apiKeys), nil
+}
+
 func (a *apiKeyService) InvalidateUserKeyCache(userId portainer.UserID) bool {
 	return a.cache.InvalidateUserKeyCache(userId)
 }
+
+func (a *apiKeyService) InvalidateKeyCache(apiKeyID portainer.APIKeyID) bool {
+	return a.cache.InvalidateKeyCache(apiKeyID)
+}
+
+func (a *apiKeyService) InvalidateCache() {
+	a.cache.Purge()
+}
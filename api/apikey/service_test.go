@@ -301,3 +301,32 @@ func Test_InvalidateUserKeyCache(t *testing.T) {
 		is.True(ok)
 	})
 }
+
+func Test_DeleteExpiredAPIKeys(t *testing.T) {
+	is := assert.New(t)
+
+	_, store := datastore.MustNewTestStore(t, true, true)
+
+	service := NewAPIKeyService(store.APIKeyRepository(), store.User())
+
+	user := portainer.User{ID: 1}
+	_, permanentKey, err := service.GenerateApiKey(user, "permanent")
+	is.NoError(err)
+
+	_, validKey, err := service.GenerateScopedApiKey(user, "valid", time.Now().Add(time.Hour).Unix(), nil)
+	is.NoError(err)
+
+	_, expiredKey, err := service.GenerateScopedApiKey(user, "expired", time.Now().Add(-time.Hour).Unix(), nil)
+	is.NoError(err)
+
+	count, err := service.DeleteExpiredAPIKeys()
+	is.NoError(err)
+	is.Equal(1, count)
+
+	apiKeys, err := service.GetAPIKeys(user.ID)
+	is.NoError(err)
+	is.ElementsMatch([]portainer.APIKeyID{permanentKey.ID, validKey.ID}, []portainer.APIKeyID{apiKeys[0].ID, apiKeys[1].ID})
+
+	_, _, ok := service.cache.Get(expiredKey.Digest)
+	is.False(ok, "the expired key is removed from the cache")
+}
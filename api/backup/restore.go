@@ -13,6 +13,7 @@ import (
 	"github.com/portainer/portainer/api/archive"
 	"github.com/portainer/portainer/api/crypto"
 	"github.com/portainer/portainer/api/database/boltdb"
+	"github.com/portainer/portainer/api/database/postgres"
 	"github.com/portainer/portainer/api/dataservices"
 	"github.com/portainer/portainer/api/filesystem"
 	"github.com/portainer/portainer/api/http/offlinegate"
@@ -20,6 +21,11 @@ import (
 
 var filesToRestore = append(filesToBackup, "portainer.db")
 
+var (
+	ErrDatabaseMissing = errors.New("Portainer database missing from backup file")
+	ErrBackendMismatch = errors.New("the backup archive was created with a different database backend")
+)
+
 // Restores system state from backup archive, will trigger system shutdown, when finished.
 func RestoreArchive(archive io.Reader, password string, filestorePath string, gate *offlinegate.OfflineGate, datastore dataservices.DataStore, shutdownTrigger context.CancelFunc) error {
 	var err error
@@ -41,16 +47,34 @@ func RestoreArchive(archive io.Reader, password string, filestorePath string, ga
 	unlock := gate.Lock()
 	defer unlock()
 
-	if err = datastore.Close(); err != nil {
-		return errors.Wrap(err, "Failed to stop db")
-	}
-
 	// At some point, backups were created containing a subdirectory, now we need to handle both
 	restorePath, err = getRestoreSourcePath(restorePath)
 	if err != nil {
 		return errors.Wrap(err, "failed to restore from backup. Portainer database missing from backup file")
 	}
 
+	archivePostgres, err := isPostgresArchive(restorePath)
+	if err != nil {
+		return err
+	}
+
+	// A BoltDB archive cannot be restored on PostgreSQL and vice versa, the
+	// stores are converted with the migrate-store command instead
+	if storePostgres := datastore.Connection().GetDatabaseFileName() == postgres.DatabaseFileName; archivePostgres != storePostgres {
+		return ErrBackendMismatch
+	}
+
+	// A PostgreSQL store is restored from its dump while it is still open
+	if archivePostgres {
+		if err := datastore.RestoreFromFile(filepath.Join(restorePath, postgres.DatabaseFileName)); err != nil {
+			return errors.Wrap(err, "failed to restore the database")
+		}
+	}
+
+	if err = datastore.Close(); err != nil {
+		return errors.Wrap(err, "Failed to stop db")
+	}
+
 	if err = restoreFiles(restorePath, filestorePath); err != nil {
 		return errors.Wrap(err, "failed to restore the system state")
 	}
@@ -68,8 +92,8 @@ func extractArchive(r io.Reader, destinationDirPath string) error {
 }
 
 func getRestoreSourcePath(dir string) (string, error) {
-	// find portainer.db or portainer.edb file. Return the parent directory
-	var portainerdbRegex = regexp.MustCompile(`^portainer.e?db$`)
+	// find portainer.db, portainer.edb or portainer.pgdump file. Return the parent directory
+	var portainerdbRegex = regexp.MustCompile(`^portainer\.(e?db|pgdump)$`)
 
 	backupDirPath := dir
 	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
@@ -87,6 +111,25 @@ func getRestoreSourcePath(dir string) (string, error) {
 	return backupDirPath, err
 }
 
+// isPostgresArchive returns whether the database of an archive is a PostgreSQL
+// dump rather than a BoltDB file
+func isPostgresArchive(restorePath string) (bool, error) {
+	for _, file := range []struct {
+		name     string
+		postgres bool
+	}{
+		{postgres.DatabaseFileName, true},
+		{boltdb.DatabaseFileName, false},
+		{boltdb.EncryptedDatabaseFileName, false},
+	} {
+		if _, err := os.Stat(filepath.Join(restorePath, file.name)); err == nil {
+			return file.postgres, nil
+		}
+	}
+
+	return false, ErrDatabaseMissing
+}
+
 func restoreFiles(srcDir string, destinationDir string) error {
 	for _, filename := range filesToRestore {
 		err := filesystem.CopyPath(filepath.Join(srcDir, filename), destinationDir)
@@ -0,0 +1,80 @@
+package backup
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	portainer "github.com/portainer/portainer/api"
+	"github.com/portainer/portainer/api/archive"
+	"github.com/portainer/portainer/api/database/boltdb"
+	"github.com/portainer/portainer/api/database/postgres"
+	"github.com/portainer/portainer/api/dataservices"
+	"github.com/portainer/portainer/api/http/offlinegate"
+
+	"github.com/stretchr/testify/require"
+)
+
+// restoreStore records the restores of the store it is standing for
+type restoreStore struct {
+	dataservices.DataStore
+	connection portainer.Connection
+	restored   bool
+	closed     bool
+}
+
+func (s *restoreStore) Connection() portainer.Connection { return s.connection }
+func (s *restoreStore) RestoreFromFile(string) error     { s.restored = true; return nil }
+func (s *restoreStore) Close() error                     { s.closed = true; return nil }
+
+// archiveWith creates an archive holding an empty database file
+func archiveWith(t *testing.T, databaseFileName string) *os.File {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, databaseFileName), []byte("{}"), 0o600))
+
+	archivePath, err := archive.TarGzDir(dir)
+	require.NoError(t, err)
+
+	f, err := os.Open(archivePath)
+	require.NoError(t, err)
+	t.Cleanup(func() { f.Close() })
+
+	return f
+}
+
+func TestRestoreArchive_BackendMismatch(t *testing.T) {
+	for _, tc := range []struct {
+		name             string
+		databaseFileName string
+		connection       portainer.Connection
+	}{
+		{name: "PostgreSQL archive on BoltDB", databaseFileName: postgres.DatabaseFileName, connection: &boltdb.DbConnection{}},
+		{name: "BoltDB archive on PostgreSQL", databaseFileName: boltdb.DatabaseFileName, connection: &postgres.DbConnection{}},
+		{name: "encrypted BoltDB archive on PostgreSQL", databaseFileName: boltdb.EncryptedDatabaseFileName, connection: &postgres.DbConnection{}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			store := &restoreStore{connection: tc.connection}
+			filestorePath := t.TempDir()
+
+			shutdown := false
+			err := RestoreArchive(archiveWith(t, tc.databaseFileName), "", filestorePath, offlinegate.NewOfflineGate(), store, func() { shutdown = true })
+			require.ErrorIs(t, err, ErrBackendMismatch)
+
+			require.False(t, store.restored, "the store is not restored")
+			require.False(t, store.closed, "the store is left open")
+			require.False(t, shutdown)
+			require.NoFileExists(t, filepath.Join(filestorePath, tc.databaseFileName))
+		})
+	}
+}
+
+func TestRestoreArchive_PostgresArchive(t *testing.T) {
+	store := &restoreStore{connection: &postgres.DbConnection{}}
+	filestorePath := t.TempDir()
+
+	err := RestoreArchive(archiveWith(t, postgres.DatabaseFileName), "", filestorePath, offlinegate.NewOfflineGate(), store, func() {})
+	require.NoError(t, err)
+
+	require.True(t, store.restored, "the store is restored from the dump")
+	require.NoFileExists(t, filepath.Join(filestorePath, postgres.DatabaseFileName), "the dump is not copied to the filestore")
+}
@@ -0,0 +1,456 @@
+package chisel
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	portainer "github.com/portainer/portainer/api"
+	"github.com/portainer/portainer/api/database/changefeed"
+	"github.com/portainer/portainer/api/internal/edge/cache"
+	"github.com/portainer/portainer/pkg/libcrypto"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// routeRefreshInterval is how often the owner of a tunnel refreshes its entry
+	routeRefreshInterval = time.Minute
+	// staleRouteTimeout is the age at which an entry whose owner stopped
+	// refreshing it is removed by the other instances
+	staleRouteTimeout = 3 * time.Minute
+)
+
+// ErrRemoteTunnel is returned by TunnelAddr when the agent holds its chisel
+// session with another instance, the requests must be forwarded to it
+var ErrRemoteTunnel = errors.New("the tunnel is served by another Portainer instance")
+
+// TunnelRegistry is implemented by the connections whose database can be
+// shared by several instances. Every instance adopts the tunnels registered by
+// the others so that the agent can open its session with any of them, and the
+// instance holding the session records itself as the owner of the tunnel.
+type TunnelRegistry interface {
+	SaveTunnelRoute(route portainer.TunnelRoute) error
+	DeleteTunnelRoute(endpointID portainer.EndpointID) error
+	LoadTunnelRoute(endpointID portainer.EndpointID) (*portainer.TunnelRoute, error)
+	LoadTunnelRoutes() ([]portainer.TunnelRoute, error)
+	// TunnelRoutesBucket is the bucket of the change feed notifying the
+	// changes of the registry
+	TunnelRoutesBucket() string
+}
+
+// tunnelRoute is the registry entry of a local tunnel as last seen by this instance
+type tunnelRoute struct {
+	owner     string
+	updatedAt time.Time
+}
+
+// TunnelOwner returns the address of the instance holding the session of the
+// agent when it is not this instance, and an empty string otherwise
+func (s *Service) TunnelOwner(endpointID portainer.EndpointID) string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	route, ok := s.routes[endpointID]
+	if !ok {
+		return ""
+	}
+
+	return s.remoteOwner(route)
+}
+
+func (s *Service) remoteOwner(route *tunnelRoute) string {
+	if route.owner == s.InstanceAddr {
+		return ""
+	}
+
+	return route.owner
+}
+
+// registerTunnel records a tunnel opened by this instance, the agent has not
+// connected yet so the tunnel has no owner. The registry is written without
+// the lock held, the tunnel is removed when it cannot be registered.
+func (s *Service) registerTunnel(endpointID portainer.EndpointID, tun *portainer.TunnelDetails) error {
+	if s.registry == nil {
+		return nil
+	}
+
+	route := portainer.TunnelRoute{
+		EndpointID:  endpointID,
+		Port:        tun.Port,
+		Credentials: tun.Credentials,
+		UpdatedAt:   time.Now().Unix(),
+	}
+
+	saveErr := s.registry.SaveTunnelRoute(route)
+
+	s.mu.Lock()
+
+	// The tunnel may have been closed or replaced while it was registered
+	current, open := s.activeTunnels[endpointID]
+	switch {
+	case current != tun:
+	case saveErr != nil:
+		s.removeTunnel(endpointID)
+	default:
+		s.routes[endpointID] = &tunnelRoute{updatedAt: time.Unix(route.UpdatedAt, 0)}
+	}
+
+	s.mu.Unlock()
+
+	if saveErr != nil {
+		return fmt.Errorf("unable to register the tunnel: %w", saveErr)
+	}
+
+	if !open {
+		s.unregisterTunnel(endpointID)
+	}
+
+	return nil
+}
+
+// NOTE: it needs to be called with the lock acquired
+// unregistersTunnel returns true when this instance removes the registry entry
+// of a tunnel it closes. The tunnels held by another instance are closed by
+// their owner, unless it stopped refreshing them.
+func (s *Service) unregistersTunnel(endpointID portainer.EndpointID) bool {
+	route, ok := s.routes[endpointID]
+	if s.registry == nil || !ok {
+		return false
+	}
+
+	return s.remoteOwner(route) == "" || time.Since(route.updatedAt) >= staleRouteTimeout
+}
+
+// unregisterTunnel removes the registry entry of a tunnel closed by this instance
+func (s *Service) unregisterTunnel(endpointID portainer.EndpointID) {
+	if err := s.registry.DeleteTunnelRoute(endpointID); err != nil {
+		log.Error().Err(err).Int("endpoint_id", int(endpointID)).Msg("unable to unregister the tunnel")
+	}
+}
+
+// routeChanged applies a change of the registry made by another instance
+func (s *Service) routeChanged(change changefeed.Change) {
+	id, ok := change.ID()
+	if !ok {
+		s.syncTunnels()
+
+		return
+	}
+
+	endpointID := portainer.EndpointID(id)
+
+	route, err := s.registry.LoadTunnelRoute(endpointID)
+	if err != nil {
+		log.Error().Err(err).Int("endpoint_id", id).Msg("unable to load the tunnel route")
+
+		return
+	}
+
+	s.mu.Lock()
+
+	if route == nil {
+		if _, ok := s.routes[endpointID]; ok {
+			s.removeTunnel(endpointID)
+		}
+
+		s.mu.Unlock()
+
+		return
+	}
+
+	action := s.applyRoute(*route)
+
+	s.mu.Unlock()
+
+	s.completeRoute(*route, action)
+}
+
+// syncTunnels adopts the tunnels registered by the other instances, removes
+// the ones they closed and records the tunnels whose agent holds its session
+// with this instance. It catches up with the changes that were not notified.
+func (s *Service) syncTunnels() {
+	if s.registry == nil {
+		return
+	}
+
+	loadedAt := time.Now()
+
+	routes, err := s.registry.LoadTunnelRoutes()
+	if err != nil {
+		log.Error().Err(err).Msg("unable to load the tunnel routes")
+
+		return
+	}
+
+	s.mu.Lock()
+
+	registered := make(map[portainer.EndpointID]bool, len(routes))
+	actions := make([]routeAction, len(routes))
+	for i, route := range routes {
+		registered[route.EndpointID] = true
+		actions[i] = s.applyRoute(route)
+	}
+
+	for endpointID, route := range s.routes {
+		// A tunnel registered after the routes were loaded is kept
+		if !registered[endpointID] && route.updatedAt.Before(loadedAt.Truncate(time.Second)) {
+			s.removeTunnel(endpointID)
+		}
+	}
+
+	ports := make(map[portainer.EndpointID]int, len(s.activeTunnels))
+	for endpointID, tun := range s.activeTunnels {
+		ports[endpointID] = tun.Port
+	}
+
+	s.mu.Unlock()
+
+	for i, route := range routes {
+		s.completeRoute(route, actions[i])
+	}
+
+	for endpointID, port := range ports {
+		s.claimTunnel(endpointID, isListening(port))
+	}
+}
+
+// routeAction is the work left once a registry entry is applied, it reads or
+// writes the store and is done without the lock held
+type routeAction int
+
+const (
+	routeApplied routeAction = iota
+	// routeStale removes the entry whose owner stopped refreshing it
+	routeStale
+	// routeAdopt adds the tunnel of the entry to the local chisel server
+	routeAdopt
+)
+
+// NOTE: it needs to be called with the lock acquired
+// applyRoute adopts the registry entry of a tunnel in memory and returns the
+// work completeRoute does once the lock is released. A tunnel replaced by
+// another instance is removed first, the agent reconnects with the credentials
+// of the new entry.
+func (s *Service) applyRoute(route portainer.TunnelRoute) routeAction {
+	updatedAt := time.Unix(route.UpdatedAt, 0)
+
+	if route.Owner != "" && route.Owner != s.InstanceAddr && time.Since(updatedAt) >= staleRouteTimeout {
+		if _, ok := s.activeTunnels[route.EndpointID]; ok {
+			s.removeTunnel(route.EndpointID)
+		}
+
+		return routeStale
+	}
+
+	if tun, ok := s.activeTunnels[route.EndpointID]; ok {
+		if tun.Port == route.Port && tun.Credentials == route.Credentials {
+			s.updateRoute(route.EndpointID, route.Owner, updatedAt)
+
+			return routeApplied
+		}
+
+		s.removeTunnel(route.EndpointID)
+	}
+
+	// The tunnels are adopted once the tunnel server is started
+	if s.chiselServer == nil {
+		return routeApplied
+	}
+
+	return routeAdopt
+}
+
+// completeRoute does the work returned by applyRoute, without the lock held
+func (s *Service) completeRoute(route portainer.TunnelRoute, action routeAction) {
+	switch action {
+	case routeStale:
+		log.Debug().
+			Int("endpoint_id", int(route.EndpointID)).
+			Str("owner", route.Owner).
+			Msg("removing the stale tunnel route")
+
+		if err := s.registry.DeleteTunnelRoute(route.EndpointID); err != nil {
+			log.Error().Err(err).Int("endpoint_id", int(route.EndpointID)).Msg("unable to remove the stale tunnel route")
+		}
+	case routeAdopt:
+		s.adoptTunnel(route)
+	}
+}
+
+// adoptTunnel adds the tunnel registered by another instance to the local
+// chisel server, unless a tunnel was opened for the environment meanwhile
+func (s *Service) adoptTunnel(route portainer.TunnelRoute) {
+	endpoint, err := s.dataStore.Endpoint().Endpoint(route.EndpointID)
+	if err != nil {
+		log.Debug().Err(err).Int("endpoint_id", int(route.EndpointID)).Msg("unable to adopt the tunnel of an unknown environment")
+
+		return
+	}
+
+	username, password, err := decryptCredentials(route.Credentials, endpoint.EdgeID)
+	if err != nil {
+		log.Error().Err(err).Int("endpoint_id", int(route.EndpointID)).Msg("unable to read the tunnel credentials")
+
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.activeTunnels[route.EndpointID]; ok {
+		return
+	}
+
+	if err := s.chiselServer.AddUser(username, password, fmt.Sprintf("^R:0.0.0.0:%d$", route.Port)); err != nil {
+		log.Error().Err(err).Int("endpoint_id", int(route.EndpointID)).Msg("unable to adopt the tunnel")
+
+		return
+	}
+
+	s.activeTunnels[route.EndpointID] = &portainer.TunnelDetails{
+		Status:       portainer.EdgeAgentManagementRequired,
+		Port:         route.Port,
+		Credentials:  route.Credentials,
+		LastActivity: time.Now(),
+	}
+	s.routes[route.EndpointID] = &tunnelRoute{owner: route.Owner, updatedAt: time.Unix(route.UpdatedAt, 0)}
+
+	cache.Del(route.EndpointID)
+}
+
+// NOTE: it needs to be called with the lock acquired
+// updateRoute records the owner of a local tunnel, the proxy of the
+// environment targets the previous owner and is recreated
+func (s *Service) updateRoute(endpointID portainer.EndpointID, owner string, updatedAt time.Time) {
+	route, ok := s.routes[endpointID]
+	if !ok {
+		route = &tunnelRoute{}
+		s.routes[endpointID] = route
+	}
+
+	if route.owner != owner && s.ProxyManager != nil {
+		s.ProxyManager.DeleteEndpointProxy(endpointID)
+	}
+
+	route.owner = owner
+	route.updatedAt = updatedAt
+}
+
+// NOTE: it needs to be called with the lock acquired
+// removeTunnel forgets a tunnel without touching the registry
+func (s *Service) removeTunnel(endpointID portainer.EndpointID) {
+	if tun, ok := s.activeTunnels[endpointID]; ok && len(tun.Credentials) > 0 && s.chiselServer != nil {
+		user, _, _ := strings.Cut(tun.Credentials, ":")
+		s.chiselServer.DeleteUser(user)
+	}
+
+	if s.ProxyManager != nil {
+		s.ProxyManager.DeleteEndpointProxy(endpointID)
+	}
+
+	delete(s.activeTunnels, endpointID)
+	delete(s.routes, endpointID)
+
+	cache.Del(endpointID)
+}
+
+// claimTunnel records this instance as the owner of a tunnel while the agent
+// holds its session with it, and refreshes the entry so that the other
+// instances keep forwarding to it
+func (s *Service) claimTunnel(endpointID portainer.EndpointID, connected bool) {
+	if s.registry == nil || s.InstanceAddr == "" {
+		return
+	}
+
+	tun, updated := s.claimedRoute(endpointID, connected)
+	if updated == nil {
+		return
+	}
+
+	if err := s.registry.SaveTunnelRoute(*updated); err != nil {
+		log.Error().Err(err).Int("endpoint_id", int(endpointID)).Msg("unable to record the owner of the tunnel")
+
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// The tunnel may have been closed or replaced while the entry was saved
+	if s.activeTunnels[endpointID] != tun {
+		return
+	}
+
+	s.updateRoute(endpointID, updated.Owner, time.Unix(updated.UpdatedAt, 0))
+}
+
+// claimedRoute returns the registry entry of a tunnel with its new owner, or
+// nil when the entry does not need to be saved again
+func (s *Service) claimedRoute(endpointID portainer.EndpointID, connected bool) (*portainer.TunnelDetails, *portainer.TunnelRoute) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tun, ok := s.activeTunnels[endpointID]
+	route, registered := s.routes[endpointID]
+	if !ok || !registered {
+		return nil, nil
+	}
+
+	owner := route.owner
+	switch {
+	case connected && (owner != s.InstanceAddr || time.Since(route.updatedAt) >= routeRefreshInterval):
+		owner = s.InstanceAddr
+	case !connected && owner == s.InstanceAddr:
+		owner = ""
+	default:
+		return nil, nil
+	}
+
+	return tun, &portainer.TunnelRoute{
+		EndpointID:  endpointID,
+		Owner:       owner,
+		Port:        tun.Port,
+		Credentials: tun.Credentials,
+		UpdatedAt:   time.Now().Unix(),
+	}
+}
+
+func isListening(port int) bool {
+	conn, err := net.DialTCP("tcp", nil, &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: port})
+	if err != nil {
+		return false
+	}
+
+	conn.Close()
+
+	return true
+}
+
+func decryptCredentials(credentials, key string) (string, string, error) {
+	encryptedCredentials, err := base64.RawStdEncoding.DecodeString(credentials)
+	if err != nil {
+		return "", "", err
+	}
+
+	decrypted, err := libcrypto.Decrypt(encryptedCredentials, []byte(key))
+	if err != nil {
+		return "", "", err
+	}
+
+	username, password, ok := strings.Cut(string(decrypted), ":")
+	if !ok {
+		return "", "", errors.New("invalid tunnel credentials")
+	}
+
+	return username, password, nil
+}
+
+// subscribeToRoutes keeps the local tunnels in sync with the registry entries
+// written by the other instances
+func (s *Service) subscribeToRoutes(source changefeed.Source) {
+	source.ChangeFeed().Subscribe(s.registry.TunnelRoutesBucket(), s.routeChanged)
+}
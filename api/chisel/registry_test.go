@@ -0,0 +1,258 @@
+package chisel
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	portainer "github.com/portainer/portainer/api"
+	"github.com/portainer/portainer/api/database/changefeed"
+	"github.com/portainer/portainer/api/datastore"
+
+	chserver "github.com/jpillora/chisel/server"
+	"github.com/stretchr/testify/require"
+)
+
+type memoryRegistry struct {
+	mu     sync.Mutex
+	routes map[portainer.EndpointID]portainer.TunnelRoute
+}
+
+func (r *memoryRegistry) SaveTunnelRoute(route portainer.TunnelRoute) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.routes[route.EndpointID] = route
+
+	return nil
+}
+
+func (r *memoryRegistry) DeleteTunnelRoute(endpointID portainer.EndpointID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.routes, endpointID)
+
+	return nil
+}
+
+func (r *memoryRegistry) LoadTunnelRoute(endpointID portainer.EndpointID) (*portainer.TunnelRoute, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	route, ok := r.routes[endpointID]
+	if !ok {
+		return nil, nil
+	}
+
+	return &route, nil
+}
+
+func (r *memoryRegistry) LoadTunnelRoutes() ([]portainer.TunnelRoute, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	routes := make([]portainer.TunnelRoute, 0, len(r.routes))
+	for _, route := range r.routes {
+		routes = append(routes, route)
+	}
+
+	return routes, nil
+}
+
+func (r *memoryRegistry) TunnelRoutesBucket() string {
+	return "tunnel_routes"
+}
+
+func TestTunnelRegistrySharesTunnelsAcrossInstances(t *testing.T) {
+	is := require.New(t)
+
+	_, store := datastore.MustNewTestStore(t, true, true)
+
+	endpoint := &portainer.Endpoint{
+		ID:          1,
+		EdgeID:      "test-edge-id",
+		Type:        portainer.EdgeAgentOnDockerEnvironment,
+		UserTrusted: true,
+	}
+	is.NoError(store.Endpoint().Create(endpoint))
+
+	registry := &memoryRegistry{routes: make(map[portainer.EndpointID]portainer.TunnelRoute)}
+
+	newInstance := func(addr string) *Service {
+		chiselServer, err := chserver.NewServer(&chserver.Config{Reverse: true})
+		is.NoError(err)
+
+		s := NewService(store, nil, nil)
+		s.registry = registry
+		s.chiselServer = chiselServer
+		s.InstanceAddr = addr
+
+		return s
+	}
+
+	a := newInstance("http://a:9000")
+	b := newInstance("http://b:9000")
+
+	// The agent polls b after a opened the tunnel
+	is.NoError(a.Open(endpoint))
+	b.syncTunnels()
+
+	tun, adopted := a.Config(endpoint.ID), b.Config(endpoint.ID)
+	is.Equal(portainer.EdgeAgentManagementRequired, adopted.Status, "b adopts the tunnel")
+	is.Equal(tun.Port, adopted.Port)
+	is.Equal(tun.Credentials, adopted.Credentials)
+	is.Empty(b.TunnelOwner(endpoint.ID), "the agent has not connected yet")
+
+	// The agent opens its session with a
+	ln, err := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", tun.Port))
+	is.NoError(err)
+
+	a.syncTunnels()
+	is.Equal("http://a:9000", registry.routes[endpoint.ID].Owner)
+	is.Empty(a.TunnelOwner(endpoint.ID))
+
+	ln.Close()
+
+	b.routeChanged(changefeed.Change{Bucket: registry.TunnelRoutesBucket(), Key: strconv.Itoa(int(endpoint.ID))})
+	is.Equal("http://a:9000", b.TunnelOwner(endpoint.ID))
+
+	_, err = b.TunnelAddr(endpoint)
+	is.ErrorIs(err, ErrRemoteTunnel)
+
+	// b does not close a tunnel held by a
+	b.close(endpoint.ID)
+	is.Contains(registry.routes, endpoint.ID)
+
+	a.close(endpoint.ID)
+	is.NotContains(registry.routes, endpoint.ID)
+
+	b.syncTunnels()
+	is.Equal(portainer.EdgeAgentIdle, b.Config(endpoint.ID).Status)
+}
+
+// blockingRegistry holds the saves until it is released
+type blockingRegistry struct {
+	*memoryRegistry
+	saving  chan struct{}
+	release chan struct{}
+	err     error
+}
+
+func (r *blockingRegistry) SaveTunnelRoute(route portainer.TunnelRoute) error {
+	r.saving <- struct{}{}
+	<-r.release
+
+	if r.err != nil {
+		return r.err
+	}
+
+	return r.memoryRegistry.SaveTunnelRoute(route)
+}
+
+func TestTunnelRegistryIsWrittenWithoutTheLock(t *testing.T) {
+	is := require.New(t)
+
+	_, store := datastore.MustNewTestStore(t, true, true)
+
+	endpoint := &portainer.Endpoint{
+		ID:          1,
+		EdgeID:      "test-edge-id",
+		Type:        portainer.EdgeAgentOnDockerEnvironment,
+		UserTrusted: true,
+	}
+	is.NoError(store.Endpoint().Create(endpoint))
+
+	for _, saveErr := range []error{nil, errors.New("unavailable")} {
+		registry := &blockingRegistry{
+			memoryRegistry: &memoryRegistry{routes: make(map[portainer.EndpointID]portainer.TunnelRoute)},
+			saving:         make(chan struct{}),
+			release:        make(chan struct{}),
+			err:            saveErr,
+		}
+
+		s := NewService(store, nil, nil)
+		s.registry = registry
+
+		opened := make(chan error)
+		go func() { opened <- s.Open(endpoint) }()
+
+		<-registry.saving
+
+		// The tunnel is served while it is registered
+		is.Equal(portainer.EdgeAgentManagementRequired, s.Config(endpoint.ID).Status)
+		is.Empty(s.TunnelOwner(endpoint.ID))
+
+		close(registry.release)
+
+		if saveErr == nil {
+			is.NoError(<-opened)
+			is.Contains(registry.routes, endpoint.ID)
+			is.Equal(portainer.EdgeAgentManagementRequired, s.Config(endpoint.ID).Status)
+
+			continue
+		}
+
+		is.ErrorIs(<-opened, saveErr)
+		is.Equal(portainer.EdgeAgentIdle, s.Config(endpoint.ID).Status, "the tunnel that cannot be registered is removed")
+	}
+}
+
+// deletingRegistry holds the deletions until it is released
+type deletingRegistry struct {
+	*memoryRegistry
+	deleting chan struct{}
+	release  chan struct{}
+}
+
+func (r *deletingRegistry) DeleteTunnelRoute(endpointID portainer.EndpointID) error {
+	r.deleting <- struct{}{}
+	<-r.release
+
+	return r.memoryRegistry.DeleteTunnelRoute(endpointID)
+}
+
+func TestTunnelRegistryIsSyncedWithoutTheLock(t *testing.T) {
+	is := require.New(t)
+
+	_, store := datastore.MustNewTestStore(t, true, true)
+
+	registry := &deletingRegistry{
+		memoryRegistry: &memoryRegistry{routes: map[portainer.EndpointID]portainer.TunnelRoute{
+			1: {EndpointID: 1, Owner: "http://c:9000", Port: 49152, UpdatedAt: time.Now().Add(-2 * staleRouteTimeout).Unix()},
+		}},
+		deleting: make(chan struct{}),
+		release:  make(chan struct{}),
+	}
+
+	s := NewService(store, nil, nil)
+	s.registry = registry
+
+	synced := make(chan struct{})
+	go func() {
+		s.syncTunnels()
+		close(synced)
+	}()
+
+	<-registry.deleting
+
+	// The tunnels are served while the stale route is removed
+	read := make(chan string)
+	go func() { read <- s.TunnelOwner(1) }()
+
+	select {
+	case owner := <-read:
+		is.Empty(owner)
+	case <-time.After(5 * time.Second):
+		t.Fatal("the lock is held while the stale route is removed")
+	}
+
+	close(registry.release)
+	<-synced
+
+	is.NotContains(registry.routes, portainer.EndpointID(1))
+}
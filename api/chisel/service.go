@@ -9,6 +9,7 @@ import (
 	"time"
 
 	portainer "github.com/portainer/portainer/api"
+	"github.com/portainer/portainer/api/database/changefeed"
 	"github.com/portainer/portainer/api/dataservices"
 	"github.com/portainer/portainer/api/http/proxy"
 
@@ -25,7 +26,9 @@ const (
 
 // Service represents a service to manage the state of multiple reverse tunnels.
 // It is used to start a reverse tunnel server and to manage the connection status of each tunnel
-// connected to the tunnel server.
+// connected to the tunnel server. InstanceAddr is the internal address the other
+// instances sharing the store use to forward the requests of the tunnels held
+// by this instance.
 type Service struct {
 	serverFingerprint      string
 	serverPort             string
@@ -36,6 +39,9 @@ type Service struct {
 	chiselServer           *chserver.Server
 	shutdownCtx            context.Context
 	ProxyManager           *proxy.Manager
+	InstanceAddr           string
+	registry               TunnelRegistry
+	routes                 map[portainer.EndpointID]*tunnelRoute
 	mu                     sync.RWMutex
 	fileService            portainer.FileService
 	defaultCheckinInterval int
@@ -52,14 +58,25 @@ func NewService(dataStore dataservices.DataStore, shutdownCtx context.Context, f
 		log.Error().Err(err).Msg("unable to retrieve the settings from the database")
 	}
 
-	return &Service{
+	s := &Service{
 		activeTunnels:          make(map[portainer.EndpointID]*portainer.TunnelDetails),
 		edgeJobs:               make(map[portainer.EndpointID][]portainer.EdgeJob),
+		routes:                 make(map[portainer.EndpointID]*tunnelRoute),
 		dataStore:              dataStore,
 		shutdownCtx:            shutdownCtx,
 		fileService:            fileService,
 		defaultCheckinInterval: defaultCheckinInterval,
 	}
+
+	if registry, ok := dataStore.Connection().(TunnelRegistry); ok {
+		s.registry = registry
+
+		if source, ok := registry.(changefeed.Source); ok {
+			s.subscribeToRoutes(source)
+		}
+	}
+
+	return s
 }
 
 // pingAgent ping the given agent so that the agent can keep the tunnel alive
@@ -181,6 +198,8 @@ func (service *Service) StartTunnelServer(addr, port string, snapshotService por
 
 	service.snapshotService = snapshotService
 
+	service.syncTunnels()
+
 	go service.startTunnelVerificationLoop()
 
 	return nil
@@ -230,6 +249,9 @@ func (service *Service) retrievePrivateKeyFile() (string, error) {
 	return privateKeyFile, nil
 }
 
+// startTunnelVerificationLoop runs on every instance instead of the leader
+// only, the tunnels and the chisel sessions it checks are the ones of the
+// instance it runs in
 func (service *Service) startTunnelVerificationLoop() {
 	log.Debug().
 		Float64("check_interval_seconds", tunnelCleanupInterval.Seconds()).
@@ -240,6 +262,7 @@ func (service *Service) startTunnelVerificationLoop() {
 	for {
 		select {
 		case <-ticker.C:
+			service.syncTunnels()
 			service.checkTunnels()
 		case <-service.shutdownCtx.Done():
 			log.Debug().Msg("shutting down tunnel service")
@@ -270,6 +293,11 @@ func (service *Service) checkTunnels() {
 			continue
 		}
 
+		// The owner of the tunnel snapshots and closes it
+		if route, ok := service.routes[endpointID]; ok && service.remoteOwner(route) != "" {
+			continue
+		}
+
 		tunnelPort := tunnel.Port
 
 		service.mu.RUnlock()
@@ -6,7 +6,6 @@ import (
 	"fmt"
 	"math/rand"
 	"net"
-	"strings"
 	"time"
 
 	portainer "github.com/portainer/portainer/api"
@@ -44,15 +43,26 @@ func (s *Service) Open(endpoint *portainer.Endpoint) error {
 		return ErrInvalidEnv
 	}
 
+	tun, err := s.newTunnel(endpoint)
+	if err != nil || tun == nil {
+		return err
+	}
+
+	defer cache.Del(endpoint.ID)
+
+	return s.registerTunnel(endpoint.ID, tun)
+}
+
+// newTunnel adds a tunnel for the environment, it returns nil when the
+// environment already has one
+func (s *Service) newTunnel(endpoint *portainer.Endpoint) (*portainer.TunnelDetails, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
 	if _, ok := s.activeTunnels[endpoint.ID]; ok {
-		return nil
+		return nil, nil
 	}
 
-	defer cache.Del(endpoint.ID)
-
 	tun := &portainer.TunnelDetails{
 		Status:       portainer.EdgeAgentManagementRequired,
 		Port:         s.getUnusedPort(),
@@ -65,44 +75,40 @@ func (s *Service) Open(endpoint *portainer.Endpoint) error {
 		authorizedRemote := fmt.Sprintf("^R:0.0.0.0:%d$", tun.Port)
 
 		if err := s.chiselServer.AddUser(username, password, authorizedRemote); err != nil {
-			return err
+			return nil, err
 		}
 	}
 
 	credentials, err := encryptCredentials(username, password, endpoint.EdgeID)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	tun.Credentials = credentials
 
 	s.activeTunnels[endpoint.ID] = tun
 
-	return nil
+	return tun, nil
 }
 
 // close removes the tunnel from the map so the agent will close it
 func (s *Service) close(endpointID portainer.EndpointID) {
 	s.mu.Lock()
-	defer s.mu.Unlock()
 
-	tun, ok := s.activeTunnels[endpointID]
-	if !ok {
-		return
-	}
+	if _, ok := s.activeTunnels[endpointID]; !ok {
+		s.mu.Unlock()
 
-	if len(tun.Credentials) > 0 && s.chiselServer != nil {
-		user, _, _ := strings.Cut(tun.Credentials, ":")
-		s.chiselServer.DeleteUser(user)
+		return
 	}
 
-	if s.ProxyManager != nil {
-		s.ProxyManager.DeleteEndpointProxy(endpointID)
-	}
+	unregister := s.unregistersTunnel(endpointID)
+	s.removeTunnel(endpointID)
 
-	delete(s.activeTunnels, endpointID)
+	s.mu.Unlock()
 
-	cache.Del(endpointID)
+	if unregister {
+		s.unregisterTunnel(endpointID)
+	}
 }
 
 // Config returns the tunnel details needed for the agent to connect
@@ -118,7 +124,8 @@ func (s *Service) Config(endpointID portainer.EndpointID) portainer.TunnelDetail
 }
 
 // TunnelAddr returns the address of the local tunnel, including the port, it
-// will block until the tunnel is ready
+// will block until the tunnel is ready. It returns ErrRemoteTunnel when the
+// agent opened its session with another instance.
 func (s *Service) TunnelAddr(endpoint *portainer.Endpoint) (string, error) {
 	if err := s.Open(endpoint); err != nil {
 		return "", err
@@ -137,6 +144,10 @@ func (s *Service) TunnelAddr(endpoint *portainer.Endpoint) (string, error) {
 		// Check if the tunnel is established
 		conn, err := net.DialTCP("tcp", nil, &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: tun.Port})
 		if err != nil {
+			if s.TunnelOwner(endpoint.ID) != "" {
+				return "", ErrRemoteTunnel
+			}
+
 			time.Sleep(checkinInterval / 100)
 
 			continue
@@ -147,6 +158,7 @@ func (s *Service) TunnelAddr(endpoint *portainer.Endpoint) (string, error) {
 		break
 	}
 
+	s.claimTunnel(endpoint.ID, true)
 	s.UpdateLastActivity(endpoint.ID)
 
 	return fmt.Sprintf("127.0.0.1:%d", tun.Port), nil
@@ -2,8 +2,10 @@ package cli
 
 import (
 	"errors"
+	"net/url"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
@@ -21,9 +23,20 @@ var (
 	ErrSocketOrNamedPipeNotFound     = errors.New("Unable to locate Unix socket or named pipe")
 	ErrInvalidSnapshotInterval       = errors.New("Invalid snapshot interval")
 	ErrAdminPassExcludeAdminPassFile = errors.New("Cannot use --admin-password with --admin-password-file")
+	ErrDBDSNExcludeDBParameters      = errors.New("Cannot use --db-dsn with --db-host, --db-user, --db-name or --db-password-file")
+	ErrDBHostRequired                = errors.New("The --db-host flag is required when --db-type is postgres and --db-dsn is not set")
+	ErrDBUserRequired                = errors.New("The --db-user flag is required when --db-type is postgres and --db-dsn is not set")
+	ErrDBNameRequired                = errors.New("The --db-name flag is required when --db-type is postgres and --db-dsn is not set")
+	ErrDBFlagsRequirePostgres        = errors.New("The --db-* connection flags can only be used when --db-type is postgres")
+	ErrInvalidDBPort                 = errors.New("Invalid database port")
+	ErrMigrateStoreSameLocation      = errors.New("The --from and --to stores of migrate-store must be different")
+	ErrInvalidClusterAddr            = errors.New("Invalid cluster address: Portainer only supports http:// or https:// URLs")
 )
 
 func CLIFlags() *portainer.CLIFlags {
+	kingpin.Command("serve", "Run the Portainer server").Default()
+	migrateStore := kingpin.Command("migrate-store", "Copy a store into an empty store of another database backend, then exit")
+
 	return &portainer.CLIFlags{
 		Addr:                      kingpin.Flag("bind", "Address and port to serve Portainer").Default(defaultBindAddress).Short('p').String(),
 		AddrHTTPS:                 kingpin.Flag("bind-https", "Address and port to serve Portainer via https").Default(defaultHTTPSBindAddress).String(),
@@ -59,6 +72,28 @@ func CLIFlags() *portainer.CLIFlags {
 		SecretKeyName:             kingpin.Flag("secret-key-name", "Secret key name for encryption and will be used as /run/secrets/<secret-key-name>.").Default(defaultSecretKeyName).String(),
 		LogLevel:                  kingpin.Flag("log-level", "Set the minimum logging level to show").Default("INFO").Enum("DEBUG", "INFO", "WARN", "ERROR"),
 		LogMode:                   kingpin.Flag("log-mode", "Set the logging output mode").Default("PRETTY").Enum("NOCOLOR", "PRETTY", "JSON"),
+		DBType:                    kingpin.Flag("db-type", "Database backend used to store Portainer data").Envar("PORTAINER_DB_TYPE").Default(defaultDBType).Enum("boltdb", "postgres"),
+		DBHost:                    kingpin.Flag("db-host", "PostgreSQL server host").Envar("PORTAINER_DB_HOST").String(),
+		DBPort:                    kingpin.Flag("db-port", "PostgreSQL server port").Envar("PORTAINER_DB_PORT").Default(defaultDBPort).String(),
+		DBUser:                    kingpin.Flag("db-user", "PostgreSQL user").Envar("PORTAINER_DB_USER").String(),
+		DBName:                    kingpin.Flag("db-name", "PostgreSQL database name").Envar("PORTAINER_DB_NAME").String(),
+		DBSSLMode:                 kingpin.Flag("db-sslmode", "PostgreSQL sslmode").Envar("PORTAINER_DB_SSLMODE").Default(defaultDBSSLMode).Enum("disable", "allow", "prefer", "require", "verify-ca", "verify-full"),
+		DBPasswordFile:            kingpin.Flag("db-password-file", "Path to the file containing the PostgreSQL password. A bare name is looked up as /run/secrets/<db-password-file>.").Envar("PORTAINER_DB_PASSWORD_FILE").String(),
+		DBDSN:                     kingpin.Flag("db-dsn", "Full PostgreSQL connection string, cannot be used with the other --db-* connection flags").Envar("PORTAINER_DB_DSN").String(),
+		JWTKeyRotationInterval:    kingpin.Flag("jwt-key-rotation-interval", "Age at which the key signing the user sessions is replaced, the sessions signed by the previous key remain valid").Envar("PORTAINER_JWT_KEY_ROTATION_INTERVAL").Default("720h").Duration(),
+		DBStatementTimeout:        kingpin.Flag("db-statement-timeout", "Maximum duration of a PostgreSQL statement, 0 disables the limit").Envar("PORTAINER_DB_STATEMENT_TIMEOUT").Duration(),
+		DBIsolation:               kingpin.Flag("db-isolation", "Isolation level of the PostgreSQL write transactions").Envar("PORTAINER_DB_ISOLATION").Default("serializable").Enum("serializable", "repeatable-read", "read-committed"),
+		DBReplicaDSNs:             kingpin.Flag("db-replica-dsn", "Connection string of a PostgreSQL read replica serving the heavy read paths, can be repeated").Envar("PORTAINER_DB_REPLICA_DSN").Strings(),
+		DBReplicaMaxLag:           kingpin.Flag("db-replica-max-lag", "Replication delay above which the reads go back to the PostgreSQL primary").Envar("PORTAINER_DB_REPLICA_MAX_LAG").Default("10s").Duration(),
+		DBMaxOpenConns:            kingpin.Flag("db-max-open-conns", "Maximum number of connections opened to the PostgreSQL server").Envar("PORTAINER_DB_MAX_OPEN_CONNS").Default("25").Int(),
+		DBMaxIdleConns:            kingpin.Flag("db-max-idle-conns", "Maximum number of unused connections kept open to the PostgreSQL server").Envar("PORTAINER_DB_MAX_IDLE_CONNS").Default("25").Int(),
+		DBConnMaxLifetime:         kingpin.Flag("db-conn-max-lifetime", "Age at which a connection to the PostgreSQL server is replaced").Envar("PORTAINER_DB_CONN_MAX_LIFETIME").Default("5m").Duration(),
+		DBConnMaxIdleTime:         kingpin.Flag("db-conn-max-idle-time", "Duration after which an unused connection to the PostgreSQL server is closed, 0 keeps it open").Envar("PORTAINER_DB_CONN_MAX_IDLE_TIME").Duration(),
+		DBHealthCheckInterval:     kingpin.Flag("db-health-check-interval", "How often the PostgreSQL server is probed, the write requests are held while it does not answer").Envar("PORTAINER_DB_HEALTH_CHECK_INTERVAL").Default("10s").Duration(),
+		ClusterAddr:               kingpin.Flag("cluster-addr", "Internal URL the other Portainer instances sharing the PostgreSQL store use to reach this one, such as http://10.0.0.2:9000. The requests to an Edge environment are forwarded to the instance holding its tunnel").Envar("PORTAINER_CLUSTER_ADDR").String(),
+		AuditLogRetention:         kingpin.Flag("audit-log-retention", "How long the audit log records are kept, 0 keeps them forever").Envar("PORTAINER_AUDIT_LOG_RETENTION").Default(portainer.DefaultAuditLogRetention).Duration(),
+		MigrateStoreFrom:          migrateStore.Flag("from", "Store to copy, boltdb:<data directory> or a postgres:// connection string").Required().String(),
+		MigrateStoreTo:            migrateStore.Flag("to", "Empty store to copy into, boltdb:<data directory> or a postgres:// connection string").Required().String(),
 	}
 }
 
@@ -98,7 +133,15 @@ func (*Service) ValidateFlags(flags *portainer.CLIFlags) error {
 		return ErrAdminPassExcludeAdminPassFile
 	}
 
-	return nil
+	if *flags.MigrateStoreFrom != "" && *flags.MigrateStoreFrom == *flags.MigrateStoreTo {
+		return ErrMigrateStoreSameLocation
+	}
+
+	if err := validateClusterAddr(*flags.ClusterAddr); err != nil {
+		return err
+	}
+
+	return validateDatabaseFlags(flags)
 }
 
 func displayDeprecationWarnings(flags *portainer.CLIFlags) {
@@ -136,6 +179,19 @@ func validateEndpointURL(endpointURL string) error {
 	return nil
 }
 
+func validateClusterAddr(clusterAddr string) error {
+	if clusterAddr == "" {
+		return nil
+	}
+
+	u, err := url.Parse(clusterAddr)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return ErrInvalidClusterAddr
+	}
+
+	return nil
+}
+
 func validateSnapshotInterval(snapshotInterval string) error {
 	if snapshotInterval == "" {
 		return nil
@@ -147,3 +203,38 @@ func validateSnapshotInterval(snapshotInterval string) error {
 
 	return nil
 }
+
+func validateDatabaseFlags(flags *portainer.CLIFlags) error {
+	hasParameters := *flags.DBHost != "" || *flags.DBUser != "" || *flags.DBName != "" || *flags.DBPasswordFile != ""
+
+	if *flags.DBType != "postgres" {
+		if hasParameters || *flags.DBDSN != "" || len(*flags.DBReplicaDSNs) > 0 {
+			return ErrDBFlagsRequirePostgres
+		}
+
+		return nil
+	}
+
+	if *flags.DBDSN != "" {
+		if hasParameters {
+			return ErrDBDSNExcludeDBParameters
+		}
+
+		return nil
+	}
+
+	switch {
+	case *flags.DBHost == "":
+		return ErrDBHostRequired
+	case *flags.DBUser == "":
+		return ErrDBUserRequired
+	case *flags.DBName == "":
+		return ErrDBNameRequired
+	}
+
+	if port, err := strconv.Atoi(*flags.DBPort); err != nil || port < 1 || port > 65535 {
+		return ErrInvalidDBPort
+	}
+
+	return nil
+}
@@ -0,0 +1,56 @@
+package cli
+
+import (
+	"testing"
+
+	portainer "github.com/portainer/portainer/api"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_validateDatabaseFlags(t *testing.T) {
+	type dbFlags struct {
+		dbType, host, port, user, name, passwordFile, dsn string
+		replicaDSNs                                      []string
+	}
+
+	valid := dbFlags{dbType: "postgres", host: "db", port: "5432", user: "portainer", name: "portainer"}
+
+	for _, tc := range []struct {
+		name     string
+		flags    func(f *dbFlags)
+		expected error
+	}{
+		{name: "boltdb without database flags", flags: func(f *dbFlags) { *f = dbFlags{dbType: "boltdb", port: "5432"} }},
+		{name: "postgres with connection flags", flags: func(f *dbFlags) {}},
+		{name: "postgres with a dsn", flags: func(f *dbFlags) { *f = dbFlags{dbType: "postgres", port: "5432", dsn: "postgres://db/portainer"} }},
+		{name: "boltdb with a host", flags: func(f *dbFlags) { f.dbType = "boltdb" }, expected: ErrDBFlagsRequirePostgres},
+		{name: "boltdb with a password file", flags: func(f *dbFlags) { *f = dbFlags{dbType: "boltdb", passwordFile: "/run/secrets/db"} }, expected: ErrDBFlagsRequirePostgres},
+		{name: "boltdb with a dsn", flags: func(f *dbFlags) { *f = dbFlags{dbType: "boltdb", dsn: "postgres://db/portainer"} }, expected: ErrDBFlagsRequirePostgres},
+		{name: "boltdb with replicas", flags: func(f *dbFlags) { *f = dbFlags{dbType: "boltdb", replicaDSNs: []string{"postgres://replica/portainer"}} }, expected: ErrDBFlagsRequirePostgres},
+		{name: "postgres with a dsn and a host", flags: func(f *dbFlags) { f.dsn = "postgres://db/portainer" }, expected: ErrDBDSNExcludeDBParameters},
+		{name: "postgres without a host", flags: func(f *dbFlags) { f.host = "" }, expected: ErrDBHostRequired},
+		{name: "postgres without a user", flags: func(f *dbFlags) { f.user = "" }, expected: ErrDBUserRequired},
+		{name: "postgres without a name", flags: func(f *dbFlags) { f.name = "" }, expected: ErrDBNameRequired},
+		{name: "postgres with a non numeric port", flags: func(f *dbFlags) { f.port = "pg" }, expected: ErrInvalidDBPort},
+		{name: "postgres with a port out of range", flags: func(f *dbFlags) { f.port = "65536" }, expected: ErrInvalidDBPort},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			f := valid
+			tc.flags(&f)
+
+			err := validateDatabaseFlags(&portainer.CLIFlags{
+				DBType:         &f.dbType,
+				DBHost:         &f.host,
+				DBPort:         &f.port,
+				DBUser:         &f.user,
+				DBName:         &f.name,
+				DBPasswordFile: &f.passwordFile,
+				DBDSN:          &f.dsn,
+				DBReplicaDSNs:  &f.replicaDSNs,
+			})
+
+			require.ErrorIs(t, err, tc.expected)
+		})
+	}
+}
@@ -20,4 +20,7 @@ const (
 	defaultSSL                 = "false"
 	defaultBaseURL             = "/"
 	defaultSecretKeyName       = "portainer"
+	defaultDBType              = "boltdb"
+	defaultDBPort              = "5432"
+	defaultDBSSLMode           = "disable"
 )
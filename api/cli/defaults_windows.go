@@ -18,4 +18,7 @@ const (
 	defaultSnapshotInterval    = "5m"
 	defaultBaseURL             = "/"
 	defaultSecretKeyName       = "portainer"
+	defaultDBType              = "boltdb"
+	defaultDBPort              = "5432"
+	defaultDBSSLMode           = "disable"
 )
@@ -0,0 +1,81 @@
+package main
+
+import (
+	portainer "github.com/portainer/portainer/api"
+	"github.com/portainer/portainer/api/apikey"
+	"github.com/portainer/portainer/api/database/changefeed"
+	"github.com/portainer/portainer/api/dataservices"
+	"github.com/portainer/portainer/api/dataservices/apikeyrepository"
+	"github.com/portainer/portainer/api/dataservices/edgegroup"
+	"github.com/portainer/portainer/api/dataservices/edgejob"
+	"github.com/portainer/portainer/api/dataservices/edgestack"
+	"github.com/portainer/portainer/api/dataservices/stack"
+	"github.com/portainer/portainer/api/dataservices/user"
+	"github.com/portainer/portainer/api/git"
+	kubeproxy "github.com/portainer/portainer/api/http/proxy/factory/kubernetes"
+	"github.com/portainer/portainer/api/internal/edge/cache"
+	"github.com/portainer/portainer/api/scheduler"
+	"github.com/portainer/portainer/api/stacks/deployments"
+
+	"github.com/rs/zerolog/log"
+)
+
+// subscribeToChanges keeps the caches of this instance in sync with the
+// changes committed by the other instances sharing the database. The data
+// services keep their own state in sync.
+func subscribeToChanges(feed *changefeed.Feed, apiKeyService apikey.APIKeyService, tokenCacheManager *kubeproxy.TokenCacheManager, gitService *git.Service) {
+	feed.Subscribe(user.BucketName, func(change changefeed.Change) {
+		userID, ok := change.ID()
+		if !ok {
+			apiKeyService.InvalidateCache()
+			tokenCacheManager.Clear()
+
+			return
+		}
+
+		apiKeyService.InvalidateUserKeyCache(portainer.UserID(userID))
+		tokenCacheManager.RemoveUserFromCache(portainer.UserID(userID))
+	})
+
+	feed.Subscribe(apikeyrepository.BucketName, func(change changefeed.Change) {
+		if apiKeyID, ok := change.ID(); ok {
+			apiKeyService.InvalidateKeyCache(portainer.APIKeyID(apiKeyID))
+
+			return
+		}
+
+		apiKeyService.InvalidateCache()
+	})
+
+	// The edge jobs and groups of a deleted object cannot be read anymore to
+	// find the environments they applied to
+	for _, bucketName := range []string{edgejob.BucketName, edgegroup.BucketName} {
+		feed.Subscribe(bucketName, func(changefeed.Change) {
+			cache.Clear()
+		})
+	}
+
+	// Another instance may have deployed a newer commit than the cached references
+	for _, bucketName := range []string{stack.BucketName, edgestack.BucketName} {
+		feed.Subscribe(bucketName, func(changefeed.Change) {
+			gitService.PurgeCache()
+		})
+	}
+}
+
+// subscribeToStackChanges schedules the polling of the stacks created or
+// changed by the other instances, the leader runs it
+func subscribeToStackChanges(feed *changefeed.Feed, scheduler *scheduler.Scheduler, stackDeployer deployments.StackDeployer, dataStore dataservices.DataStore, gitService *git.Service) {
+	feed.Subscribe(stack.BucketName, func(change changefeed.Change) {
+		stackID, ok := change.ID()
+		if !ok {
+			if err := deployments.SyncStackSchedules(scheduler, stackDeployer, dataStore, gitService); err != nil {
+				log.Warn().Err(err).Msg("unable to synchronize the stack schedules")
+			}
+
+			return
+		}
+
+		deployments.SyncAutoupdate(portainer.StackID(stackID), scheduler, stackDeployer, dataStore, gitService)
+	})
+}
@@ -7,6 +7,7 @@ import (
 	"os"
 	"path"
 	"strings"
+	"time"
 
 	portainer "github.com/portainer/portainer/api"
 	"github.com/portainer/portainer/api/apikey"
@@ -15,8 +16,10 @@ import (
 	"github.com/portainer/portainer/api/cli"
 	"github.com/portainer/portainer/api/crypto"
 	"github.com/portainer/portainer/api/database"
-	// "github.com/portainer/portainer/api/database/boltdb"
+	"github.com/portainer/portainer/api/database/boltdb"
+	"github.com/portainer/portainer/api/database/changefeed"
 	"github.com/portainer/portainer/api/database/models"
+	"github.com/portainer/portainer/api/database/postgres"
 	"github.com/portainer/portainer/api/dataservices"
 	"github.com/portainer/portainer/api/datastore"
 	"github.com/portainer/portainer/api/datastore/migrator"
@@ -28,7 +31,9 @@ import (
 	"github.com/portainer/portainer/api/git"
 	"github.com/portainer/portainer/api/hostmanagement/openamt"
 	"github.com/portainer/portainer/api/http"
+	"github.com/portainer/portainer/api/http/audit"
 	"github.com/portainer/portainer/api/http/proxy"
+	"github.com/portainer/portainer/api/http/proxy/factory/forward"
 	kubeproxy "github.com/portainer/portainer/api/http/proxy/factory/kubernetes"
 	"github.com/portainer/portainer/api/internal/authorization"
 	"github.com/portainer/portainer/api/internal/edge/edgestacks"
@@ -40,6 +45,7 @@ import (
 	"github.com/portainer/portainer/api/kubernetes"
 	kubecli "github.com/portainer/portainer/api/kubernetes/cli"
 	"github.com/portainer/portainer/api/ldap"
+	"github.com/portainer/portainer/api/leader"
 	"github.com/portainer/portainer/api/oauth"
 	"github.com/portainer/portainer/api/pendingactions"
 	"github.com/portainer/portainer/api/pendingactions/actions"
@@ -56,6 +62,25 @@ import (
 	"github.com/rs/zerolog/log"
 )
 
+const (
+	defaultDBPasswordSecretName = "portainer_db_password"
+
+	// signingKeyCheckInterval is how often the age of the JWT signing key is checked
+	signingKeyCheckInterval = time.Hour
+
+	// pendingActionsInterval is how often the leader retries the pending actions
+	pendingActionsInterval = 5 * time.Minute
+
+	// auditLogPurgeInterval is how often the leader removes the audit log records past the retention
+	auditLogPurgeInterval = time.Hour
+
+	// apiKeySweepInterval is how often the leader deletes the expired API keys
+	apiKeySweepInterval = time.Hour
+
+	// leaderLockName names the advisory lock held by the leader instance
+	leaderLockName = "portainer-leader"
+)
+
 func initCLI() *portainer.CLIFlags {
 	cliService := &cli.Service{}
 
@@ -81,23 +106,72 @@ func initFileService(dataStorePath string) portainer.FileService {
 }
 
 func initDataStore(flags *portainer.CLIFlags, secretKey []byte, fileService portainer.FileService, shutdownCtx context.Context) dataservices.DataStore {
+	config := database.Config{
+		Type:          *flags.DBType,
+		StorePath:     *flags.Data,
+		EncryptionKey: secretKey,
+	}
+
+	if config.Type == database.StoreTypePostgres {
+		config.Postgres = postgres.Config{DSN: *flags.DBDSN}
+
+		if *flags.DBDSN == "" {
+			config.Postgres = postgres.Config{
+				Host:     *flags.DBHost,
+				Port:     *flags.DBPort,
+				User:     *flags.DBUser,
+				Password: loadDatabasePassword(*flags.DBPasswordFile),
+				Name:     *flags.DBName,
+				SSLMode:  *flags.DBSSLMode,
+			}
+		}
+	}
 
-	connection, err := database.NewDatabase("postgres", *flags.Data, secretKey)
+	connection, err := database.NewDatabaseFromConfig(config)
 	if err != nil {
-		log.Fatal().Err(err).Msg("failed creating PostgreSQL database connection")
+		log.Fatal().Err(err).Str("type", config.Type).Msg("failed creating database connection")
 	}
-	// connection, err := database.NewDatabase("boltdb", *flags.Data, secretKey)
-	// if err != nil {
-	// 	log.Fatal().Err(err).Msg("failed creating database connection")
-	// }
 
-	// if bconn, ok := connection.(*boltdb.DbConnection); ok {
-	// 	bconn.MaxBatchSize = *flags.MaxBatchSize
-	// 	bconn.MaxBatchDelay = *flags.MaxBatchDelay
-	// 	bconn.InitialMmapSize = *flags.InitialMmapSize
-	// } else {
-	// 	log.Fatal().Msg("failed creating database connection: expecting a boltdb database type but a different one was received")
-	// }
+	if bconn, ok := connection.(*boltdb.DbConnection); ok {
+		bconn.MaxBatchSize = *flags.MaxBatchSize
+		bconn.MaxBatchDelay = *flags.MaxBatchDelay
+		bconn.InitialMmapSize = *flags.InitialMmapSize
+	}
+
+	if pconn, ok := connection.(*postgres.DbConnection); ok {
+		pconn.StatementTimeout = *flags.DBStatementTimeout
+		pconn.MaxReplicaLag = *flags.DBReplicaMaxLag
+
+		if err := pconn.ConfigurePool(postgres.PoolConfig{
+			MaxOpen:     *flags.DBMaxOpenConns,
+			MaxIdle:     *flags.DBMaxIdleConns,
+			MaxLifetime: *flags.DBConnMaxLifetime,
+			MaxIdleTime: *flags.DBConnMaxIdleTime,
+		}); err != nil {
+			log.Fatal().Err(err).Msg("invalid database pool configuration")
+		}
+
+		if pconn.Isolation, err = postgres.ParseIsolation(*flags.DBIsolation); err != nil {
+			log.Fatal().Err(err).Msg("invalid database isolation level")
+		}
+
+		replicas := make([]postgres.Config, 0, len(*flags.DBReplicaDSNs))
+		for _, dsn := range *flags.DBReplicaDSNs {
+			replicas = append(replicas, postgres.Config{DSN: dsn})
+		}
+
+		if err := pconn.ConnectReplicas(replicas); err != nil {
+			log.Fatal().Err(err).Msg("failed connecting to the read replicas")
+		}
+
+		if err := pconn.ListenForChanges(); err != nil {
+			log.Fatal().Err(err).Msg("failed listening for database changes")
+		}
+
+		if err := pconn.StartHealthProbe(*flags.DBHealthCheckInterval); err != nil {
+			log.Fatal().Err(err).Msg("failed starting the database health probe")
+		}
+	}
 
 	store := datastore.NewStore(*flags.Data, fileService, connection)
 
@@ -129,12 +203,18 @@ func initDataStore(flags *portainer.CLIFlags, secretKey []byte, fileService port
 		migratorInstance := migrator.NewMigrator(&migrator.MigratorParameters{})
 		migratorCount := migratorInstance.GetMigratorCountOfCurrentAPIVersion()
 
+		storeSchemaVersion, err := store.SchemaVersion()
+		if err != nil {
+			log.Fatal().Err(err).Msg("failed reading the database schema version")
+		}
+
 		// from MigrateData
 		v := models.Version{
-			SchemaVersion: portainer.APIVersion,
-			Edition:       int(portainer.PortainerCE),
-			InstanceID:    instanceId.String(),
-			MigratorCount: migratorCount,
+			SchemaVersion:      portainer.APIVersion,
+			Edition:            int(portainer.PortainerCE),
+			InstanceID:         instanceId.String(),
+			MigratorCount:      migratorCount,
+			StoreSchemaVersion: storeSchemaVersion,
 		}
 		store.VersionService.UpdateVersion(&v)
 
@@ -151,6 +231,8 @@ func initDataStore(flags *portainer.CLIFlags, secretKey []byte, fileService port
 		log.Fatal().Err(err).Msg("failed updating settings from flags")
 	}
 
+	store.EndpointService.StartHeartbeatSync(shutdownCtx)
+
 	// this is for the db restore functionality - needs more tests.
 	go func() {
 		<-shutdownCtx.Done()
@@ -203,7 +285,23 @@ func initAPIKeyService(datastore dataservices.DataStore) apikey.APIKeyService {
 	return apikey.NewAPIKeyService(datastore.APIKeyRepository(), datastore.User())
 }
 
-func initJWTService(userSessionTimeout string, dataStore dataservices.DataStore) (portainer.JWTService, error) {
+// initLeaderElector elects the instance running the singleton background jobs
+// with an advisory lock on PostgreSQL, and with a lease stored in the database
+// on BoltDB
+func initLeaderElector(dataStore dataservices.DataStore) (*leader.Elector, error) {
+	if pconn, ok := dataStore.Connection().(*postgres.DbConnection); ok {
+		return leader.NewElector(pconn.AdvisoryLock(leaderLockName)), nil
+	}
+
+	lease, err := leader.NewLease(dataStore.Connection())
+	if err != nil {
+		return nil, err
+	}
+
+	return leader.NewElector(lease), nil
+}
+
+func initJWTService(userSessionTimeout string, dataStore dataservices.DataStore) (*jwt.Service, error) {
 	if userSessionTimeout == "" {
 		userSessionTimeout = portainer.DefaultUserSessionTimeout
 	}
@@ -239,6 +337,7 @@ func initSnapshotService(
 	kubernetesClientFactory *kubecli.ClientFactory,
 	shutdownCtx context.Context,
 	pendingActionsService *pendingactions.PendingActionsService,
+	leaderElector portainer.LeaderElector,
 ) (portainer.SnapshotService, error) {
 	dockerSnapshotter := docker.NewSnapshotter(dockerClientFactory)
 	kubernetesSnapshotter := kubernetes.NewSnapshotter(kubernetesClientFactory)
@@ -248,6 +347,8 @@ func initSnapshotService(
 		return nil, err
 	}
 
+	snapshotService.SetLeaderElector(leaderElector)
+
 	return snapshotService, nil
 }
 
@@ -347,6 +448,32 @@ func loadEncryptionSecretKey(keyfilename string) []byte {
 	return hash[:]
 }
 
+// loadDatabasePassword reads the PostgreSQL password from filename. A bare
+// filename is treated as the name of a Docker secret under /run/secrets and
+// when no filename is given the default portainer_db_password secret is used
+// if it exists.
+func loadDatabasePassword(filename string) string {
+	optional := filename == ""
+	if optional {
+		filename = defaultDBPasswordSecretName
+	}
+
+	if !strings.ContainsAny(filename, `/\`) {
+		filename = path.Join("/run/secrets", filename)
+	}
+
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		if optional && os.IsNotExist(err) {
+			return ""
+		}
+
+		log.Fatal().Err(err).Str("filename", filename).Msg("failed reading the database password file")
+	}
+
+	return strings.TrimRight(string(content), "\r\n")
+}
+
 func buildServer(flags *portainer.CLIFlags) portainer.Server {
 	shutdownCtx, shutdownTrigger := context.WithCancel(context.Background())
 
@@ -376,6 +503,13 @@ func buildServer(flags *portainer.CLIFlags) portainer.Server {
 		log.Fatal().Err(err).Msg("failed getting instance id")
 	}
 
+	leaderElector, err := initLeaderElector(dataStore)
+	if err != nil {
+		log.Fatal().Err(err).Msg("failed initializing leader election")
+	}
+
+	leaderElector.Start(shutdownCtx)
+
 	apiKeyService := initAPIKeyService(dataStore)
 
 	settings, err := dataStore.Settings().Settings()
@@ -417,6 +551,9 @@ func buildServer(flags *portainer.CLIFlags) portainer.Server {
 	}
 
 	reverseTunnelService := chisel.NewService(dataStore, shutdownCtx, fileService)
+	reverseTunnelService.InstanceAddr = *flags.ClusterAddr
+
+	tunnelForwarder := forward.NewForwarder(dataStore)
 
 	dockerClientFactory := dockerclient.NewClientFactory(signatureService, reverseTunnelService)
 
@@ -430,6 +567,10 @@ func buildServer(flags *portainer.CLIFlags) portainer.Server {
 
 	kubernetesTokenCacheManager := kubeproxy.NewTokenCacheManager()
 
+	if source, ok := dataStore.Connection().(changefeed.Source); ok {
+		subscribeToChanges(source.ChangeFeed(), apiKeyService, kubernetesTokenCacheManager, gitService)
+	}
+
 	kubeClusterAccessService := kubernetes.NewKubeClusterAccessService(*flags.BaseURL, *flags.AddrHTTPS, sslSettings.CertPath)
 
 	proxyManager := proxy.NewManager(kubernetesClientFactory)
@@ -453,18 +594,19 @@ func buildServer(flags *portainer.CLIFlags) portainer.Server {
 	kubernetesDeployer := initKubernetesDeployer(kubernetesTokenCacheManager, kubernetesClientFactory, dataStore, reverseTunnelService, signatureService, proxyManager, *flags.Assets)
 
 	pendingActionsService := pendingactions.NewService(dataStore, kubernetesClientFactory)
+	pendingActionsService.SetLeaderElector(leaderElector)
 	pendingActionsService.RegisterHandler(actions.CleanNAPWithOverridePolicies, handlers.NewHandlerCleanNAPWithOverridePolicies(authorizationService, dataStore))
 	pendingActionsService.RegisterHandler(actions.DeletePortainerK8sRegistrySecrets, handlers.NewHandlerDeleteRegistrySecrets(authorizationService, dataStore, kubernetesClientFactory))
 	pendingActionsService.RegisterHandler(actions.PostInitMigrateEnvironment, handlers.NewHandlerPostInitMigrateEnvironment(authorizationService, dataStore, kubernetesClientFactory, dockerClientFactory, *flags.Assets, kubernetesDeployer))
 
-	snapshotService, err := initSnapshotService(*flags.SnapshotInterval, dataStore, dockerClientFactory, kubernetesClientFactory, shutdownCtx, pendingActionsService)
+	snapshotService, err := initSnapshotService(*flags.SnapshotInterval, dataStore, dockerClientFactory, kubernetesClientFactory, shutdownCtx, pendingActionsService, leaderElector)
 	if err != nil {
 		log.Fatal().Err(err).Msg("failed initializing snapshot service")
 	}
 
 	snapshotService.Start()
 
-	proxyManager.NewProxyFactory(dataStore, signatureService, reverseTunnelService, dockerClientFactory, kubernetesClientFactory, kubernetesTokenCacheManager, gitService, snapshotService)
+	proxyManager.NewProxyFactory(dataStore, signatureService, reverseTunnelService, dockerClientFactory, kubernetesClientFactory, kubernetesTokenCacheManager, gitService, snapshotService, tunnelForwarder)
 
 	helmPackageManager, err := initHelmPackageManager(*flags.Assets)
 	if err != nil {
@@ -525,9 +667,40 @@ func buildServer(flags *portainer.CLIFlags) portainer.Server {
 	}
 
 	scheduler := scheduler.NewScheduler(shutdownCtx)
+	scheduler.SetLeaderElector(leaderElector)
+
 	stackDeployer := deployments.NewStackDeployer(swarmStackManager, composeStackManager, kubernetesDeployer, dockerClientFactory, dataStore)
 	deployments.StartStackSchedules(scheduler, stackDeployer, dataStore, gitService)
 
+	if source, ok := dataStore.Connection().(changefeed.Source); ok {
+		subscribeToStackChanges(source.ChangeFeed(), scheduler, stackDeployer, dataStore, gitService)
+	}
+
+	// The leader rotates the signing key, the other instances pick it up
+	scheduler.StartJobEvery(signingKeyCheckInterval, func() error {
+		if !leaderElector.IsLeader() {
+			return jwtService.ReloadSigningKeys()
+		}
+
+		return jwtService.RotateSigningKeyIfDue(*flags.JWTKeyRotationInterval)
+	})
+
+	scheduler.StartSingletonJobEvery(pendingActionsInterval, pendingActionsService.ExecuteAll)
+
+	auditLogger := audit.NewLogger(dataStore)
+	scheduler.StartSingletonJobEvery(auditLogPurgeInterval, func() error {
+		return auditLogger.Purge(*flags.AuditLogRetention)
+	})
+
+	scheduler.StartSingletonJobEvery(apiKeySweepInterval, func() error {
+		count, err := apiKeyService.DeleteExpiredAPIKeys()
+		if count > 0 {
+			log.Debug().Int("count", count).Msg("deleted the expired API keys")
+		}
+
+		return err
+	})
+
 	sslDBSettings, err := dataStore.SSLSettings().Settings()
 	if err != nil {
 		log.Fatal().Msg("failed to fetch SSL settings from DB")
@@ -568,6 +741,7 @@ func buildServer(flags *portainer.CLIFlags) portainer.Server {
 
 	return &http.Server{
 		AuthorizationService:        authorizationService,
+		AuditLogger:                 auditLogger,
 		ReverseTunnelService:        reverseTunnelService,
 		Status:                      applicationStatus,
 		BindAddress:                 *flags.Addr,
@@ -589,6 +763,7 @@ func buildServer(flags *portainer.CLIFlags) portainer.Server {
 		GitService:                  gitService,
 		OpenAMTService:              openAMTService,
 		ProxyManager:                proxyManager,
+		TunnelForwarder:             tunnelForwarder,
 		KubernetesTokenCacheManager: kubernetesTokenCacheManager,
 		KubeClusterAccessService:    kubeClusterAccessService,
 		SignatureService:            signatureService,
@@ -616,6 +791,11 @@ func main() {
 	setLoggingLevel(*flags.LogLevel)
 	setLoggingMode(*flags.LogMode)
 
+	if *flags.MigrateStoreFrom != "" {
+		migrateStore(flags)
+		os.Exit(0)
+	}
+
 	for {
 		server := buildServer(flags)
 
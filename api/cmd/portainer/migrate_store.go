@@ -0,0 +1,99 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	portainer "github.com/portainer/portainer/api"
+	"github.com/portainer/portainer/api/database"
+	"github.com/portainer/portainer/api/database/boltdb"
+	"github.com/portainer/portainer/api/datastore"
+
+	"github.com/rs/zerolog/log"
+)
+
+// migrateStore copies the store at --from into the empty store at --to. Both
+// stores use the secret key loaded from --secret-key-name when they are
+// encrypted, and the target is encrypted with it when it is loaded.
+func migrateStore(flags *portainer.CLIFlags) {
+	encryptionKey := loadEncryptionSecretKey(*flags.SecretKeyName)
+
+	sourceConfig := migrateStoreConfig(*flags.MigrateStoreFrom, flags, encryptionKey)
+	targetConfig := migrateStoreConfig(*flags.MigrateStoreTo, flags, encryptionKey)
+
+	source, err := openSourceStore(sourceConfig)
+	if err != nil {
+		log.Fatal().Err(err).Str("type", sourceConfig.Type).Msg("failed opening the source store")
+	}
+	defer source.Close()
+
+	connection, err := database.NewDatabaseFromConfig(targetConfig)
+	if err != nil {
+		log.Fatal().Err(err).Str("type", targetConfig.Type).Msg("failed creating the target database connection")
+	}
+
+	target := datastore.NewStore(targetConfig.StorePath, initFileService(targetConfig.StorePath), connection)
+	if _, err := target.Open(); err != nil {
+		log.Fatal().Err(err).Msg("failed opening the target store")
+	}
+	defer target.Close()
+
+	log.Info().
+		Str("from", sourceConfig.Type).
+		Str("to", targetConfig.Type).
+		Msg("copying store")
+
+	if err := target.CopyFrom(source); err != nil {
+		log.Fatal().Err(err).Msg("failed copying store")
+	}
+
+	log.Info().Msg("store copied and verified")
+}
+
+func migrateStoreConfig(location string, flags *portainer.CLIFlags, encryptionKey []byte) database.Config {
+	config, err := database.ParseLocation(location)
+	if err != nil {
+		log.Fatal().Err(err).Msg("failed parsing the store location")
+	}
+
+	// PostgreSQL stores keep their backups in the data directory
+	if config.StorePath == "" {
+		config.StorePath = *flags.Data
+	}
+
+	config.EncryptionKey = encryptionKey
+
+	return config
+}
+
+// openSourceStore opens the connection of an existing store without migrating
+// or initializing it
+func openSourceStore(config database.Config) (portainer.Connection, error) {
+	connection, err := database.NewDatabaseFromConfig(config)
+	if err != nil {
+		return nil, err
+	}
+
+	needsEncryption, err := connection.NeedsEncryptionMigration()
+	if err != nil {
+		return nil, err
+	}
+
+	// An unencrypted store is read as is, even when a secret key is loaded
+	if needsEncryption {
+		connection.SetEncrypted(false)
+	}
+
+	// Opening a BoltDB file that does not exist would create it
+	if _, ok := connection.(*boltdb.DbConnection); ok {
+		if _, err := os.Stat(connection.GetDatabaseFilePath()); err != nil {
+			return nil, fmt.Errorf("no store found in %s: %w", config.StorePath, err)
+		}
+	}
+
+	if err := connection.Open(); err != nil {
+		return nil, err
+	}
+
+	return connection, nil
+}
@@ -1,9 +1,35 @@
 package portainer
 
 import (
+	"context"
 	"io"
 )
 
+// KeyKind describes how the keys of a bucket are encoded
+type KeyKind int
+
+const (
+	// KeyKindInteger is used by buckets keyed by 8-byte big endian integers, see Connection.ConvertToKey
+	KeyKindInteger KeyKind = iota
+	// KeyKindString is used by buckets keyed by arbitrary strings
+	KeyKindString
+)
+
+type readReplicaContextKey struct{}
+
+// WithReadReplica marks ctx so that the read-only transactions using it can be
+// served by a read replica, whose data may be slightly behind the primary
+func WithReadReplica(ctx context.Context) context.Context {
+	return context.WithValue(ctx, readReplicaContextKey{}, true)
+}
+
+// ReadReplicaAllowed returns true when ctx was marked by WithReadReplica
+func ReadReplicaAllowed(ctx context.Context) bool {
+	allowed, _ := ctx.Value(readReplicaContextKey{}).(bool)
+
+	return allowed
+}
+
 type ReadTransaction interface {
 	GetObject(bucketName string, key []byte, object any) error
 	GetAll(bucketName string, obj any, append func(o any) (any, error)) error
@@ -13,7 +39,7 @@ type ReadTransaction interface {
 type Transaction interface {
 	ReadTransaction
 
-	SetServiceName(bucketName string) error
+	SetServiceName(bucketName string, keyKind KeyKind) error
 	UpdateObject(bucketName string, key []byte, object any) error
 	DeleteObject(bucketName string, key []byte) error
 	CreateObject(bucketName string, fn func(uint64) (int, any)) error
@@ -32,6 +58,16 @@ type Connection interface {
 	UpdateTx(fn func(Transaction) error) error
 	ViewTx(fn func(Transaction) error) error
 
+	// UpdateTxCtx and ViewTxCtx abort the transaction when ctx is done. PostgreSQL
+	// cancels the running statements, BoltDB only checks ctx before and after fn.
+	// ViewTxCtx may use a read replica when ctx is marked by WithReadReplica
+	UpdateTxCtx(ctx context.Context, fn func(Transaction) error) error
+	ViewTxCtx(ctx context.Context, fn func(Transaction) error) error
+	// UpdateTxRetryable is UpdateTxCtx, executed again when PostgreSQL aborts
+	// it with a serialization failure or a deadlock. fn must not have effects
+	// outside of the transaction.
+	UpdateTxRetryable(ctx context.Context, fn func(Transaction) error) error
+
 	// write the db contents to filename as json (the schema needs defining)
 	ExportRaw(filename string) error
 
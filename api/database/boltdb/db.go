@@ -1,6 +1,7 @@
 package boltdb
 
 import (
+	"context"
 	"encoding/binary"
 	"errors"
 	"fmt"
@@ -173,6 +174,46 @@ func (connection *DbConnection) ViewTx(fn func(portainer.Transaction) error) err
 	return connection.View(connection.txFn(fn))
 }
 
+// UpdateTxCtx executes the given function inside a read-write transaction. BoltDB
+// cannot interrupt a running transaction, it is rolled back instead of being
+// committed when ctx is done by the time fn returns.
+func (connection *DbConnection) UpdateTxCtx(ctx context.Context, fn func(portainer.Transaction) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	return connection.UpdateTx(func(tx portainer.Transaction) error {
+		if err := fn(tx); err != nil {
+			return err
+		}
+
+		return ctx.Err()
+	})
+}
+
+// UpdateTxRetryable is UpdateTxCtx, BoltDB has a single writer and never
+// aborts a transaction to execute it again
+func (connection *DbConnection) UpdateTxRetryable(ctx context.Context, fn func(portainer.Transaction) error) error {
+	return connection.UpdateTxCtx(ctx, fn)
+}
+
+// ViewTxCtx executes the given function inside a read-only transaction, unless
+// ctx is already done. A running transaction is not interrupted, its error is
+// ctx.Err() when ctx is done by the time fn returns.
+func (connection *DbConnection) ViewTxCtx(ctx context.Context, fn func(portainer.Transaction) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	return connection.ViewTx(func(tx portainer.Transaction) error {
+		if err := fn(tx); err != nil {
+			return err
+		}
+
+		return ctx.Err()
+	})
+}
+
 // BackupTo backs up db to a provided writer.
 // It does hot backup and doesn't block other database reads and writes
 func (connection *DbConnection) BackupTo(w io.Writer) error {
@@ -222,9 +263,9 @@ func keyToString(b []byte) string {
 }
 
 // CreateBucket is a generic function used to create a bucket inside a database.
-func (connection *DbConnection) SetServiceName(bucketName string) error {
+func (connection *DbConnection) SetServiceName(bucketName string, keyKind portainer.KeyKind) error {
 	return connection.UpdateTx(func(tx portainer.Transaction) error {
-		return tx.SetServiceName(bucketName)
+		return tx.SetServiceName(bucketName, keyKind)
 	})
 }
 
@@ -1,6 +1,7 @@
 package boltdb
 
 import (
+	"fmt"
 	"time"
 
 	"github.com/rs/zerolog/log"
@@ -110,3 +111,44 @@ func (c *DbConnection) ExportJSON(databasePath string, metadata bool) ([]byte, e
 
 	return json.MarshalIndent(backup, "", "  ")
 }
+
+// BucketNames returns the names of the buckets of the store
+func (connection *DbConnection) BucketNames() ([]string, error) {
+	var names []string
+
+	err := connection.View(func(tx *bolt.Tx) error {
+		return tx.ForEach(func(name []byte, _ *bolt.Bucket) error {
+			names = append(names, string(name))
+
+			return nil
+		})
+	})
+
+	return names, err
+}
+
+// ForEachRaw calls fn for every object of a bucket, in key order, with its
+// decrypted value. The key and value are only valid during the call to fn.
+func (connection *DbConnection) ForEachRaw(bucketName string, fn func(key, value []byte) error) error {
+	return connection.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(bucketName))
+		if bucket == nil {
+			return nil
+		}
+
+		return bucket.ForEach(func(k, v []byte) error {
+			if v == nil {
+				return nil
+			}
+
+			if key := connection.getEncryptionKey(); key != nil {
+				var err error
+				if v, err = decrypt(v, key); err != nil {
+					return fmt.Errorf("failed decrypting object %s in bucket %s: %w", keyToString(k), bucketName, err)
+				}
+			}
+
+			return fn(k, v)
+		})
+	})
+}
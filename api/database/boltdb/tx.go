@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"fmt"
 
+	portainer "github.com/portainer/portainer/api"
 	dserrors "github.com/portainer/portainer/api/dataservices/errors"
 
 	"github.com/rs/zerolog/log"
@@ -15,7 +16,7 @@ type DbTransaction struct {
 	tx   *bolt.Tx
 }
 
-func (tx *DbTransaction) SetServiceName(bucketName string) error {
+func (tx *DbTransaction) SetServiceName(bucketName string, keyKind portainer.KeyKind) error {
 	_, err := tx.tx.CreateBucketIfNotExists([]byte(bucketName))
 	return err
 }
@@ -1,6 +1,7 @@
 package boltdb
 
 import (
+	"context"
 	"errors"
 	"testing"
 
@@ -42,7 +43,7 @@ func TestTxs(t *testing.T) {
 	}
 
 	err = conn.UpdateTx(func(tx portainer.Transaction) error {
-		err = tx.SetServiceName(testBucketName)
+		err = tx.SetServiceName(testBucketName, portainer.KeyKindInteger)
 		if err != nil {
 			return err
 		}
@@ -124,3 +125,62 @@ func TestTxs(t *testing.T) {
 		t.Fatal("an error was expected, got nil instead")
 	}
 }
+
+func TestTxCtxCancelled(t *testing.T) {
+	conn := DbConnection{
+		Path: t.TempDir(),
+	}
+
+	err := conn.Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	err = conn.SetServiceName(testBucketName, portainer.KeyKindInteger)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A context done before the transaction starts
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	called := false
+	err = conn.ViewTxCtx(ctx, func(tx portainer.Transaction) error {
+		called = true
+		return nil
+	})
+	if !errors.Is(err, context.Canceled) || called {
+		t.Fatalf("expected the transaction to be skipped, got %v", err)
+	}
+
+	// A context done while the transaction runs rolls it back
+	ctx, cancel = context.WithCancel(context.Background())
+	err = conn.UpdateTxCtx(ctx, func(tx portainer.Transaction) error {
+		defer cancel()
+
+		return tx.CreateObjectWithId(testBucketName, testId, testStruct{Key: "key", Value: "value"})
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected %v, got %v", context.Canceled, err)
+	}
+
+	// A context done while a read runs fails it
+	ctx, cancel = context.WithCancel(context.Background())
+	err = conn.ViewTxCtx(ctx, func(tx portainer.Transaction) error {
+		cancel()
+		return nil
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected %v, got %v", context.Canceled, err)
+	}
+
+	obj := testStruct{}
+	err = conn.ViewTx(func(tx portainer.Transaction) error {
+		return tx.GetObject(testBucketName, conn.ConvertToKey(testId), &obj)
+	})
+	if !dataservices.IsErrObjectNotFound(err) {
+		t.Fatalf("expected the object not to be committed, got %v", err)
+	}
+}
@@ -0,0 +1,90 @@
+package changefeed
+
+import (
+	"strconv"
+	"sync"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Change names an object written or deleted by a transaction committed by
+// another instance sharing the database. An empty Key means that any object
+// of the bucket may have changed.
+type Change struct {
+	Bucket string
+	Key    string
+}
+
+// ID returns the identifier of the changed object of an integer keyed bucket
+func (change Change) ID() (int, bool) {
+	if change.Key == "" {
+		return 0, false
+	}
+
+	id, err := strconv.Atoi(change.Key)
+
+	return id, err == nil
+}
+
+// Source is implemented by the connections whose database can be shared by
+// several instances
+type Source interface {
+	ChangeFeed() *Feed
+}
+
+// Feed fans the changes out to the subscribers of their bucket. The
+// subscribers keep the in-memory state derived from the database in sync.
+type Feed struct {
+	mu          sync.RWMutex
+	subscribers map[string][]func(Change)
+}
+
+// Subscribe registers fn to be called with every change of bucket
+func (feed *Feed) Subscribe(bucket string, fn func(Change)) {
+	feed.mu.Lock()
+	defer feed.mu.Unlock()
+
+	if feed.subscribers == nil {
+		feed.subscribers = make(map[string][]func(Change))
+	}
+
+	feed.subscribers[bucket] = append(feed.subscribers[bucket], fn)
+}
+
+// Publish calls the subscribers of the bucket of change
+func (feed *Feed) Publish(change Change) {
+	feed.mu.RLock()
+	subscribers := feed.subscribers[change.Bucket]
+	feed.mu.RUnlock()
+
+	for _, fn := range subscribers {
+		notify(fn, change)
+	}
+}
+
+// Reset tells every subscriber that any object of its bucket may have
+// changed, it is used when changes may have been missed
+func (feed *Feed) Reset() {
+	feed.mu.RLock()
+	buckets := make([]string, 0, len(feed.subscribers))
+	for bucket := range feed.subscribers {
+		buckets = append(buckets, bucket)
+	}
+	feed.mu.RUnlock()
+
+	for _, bucket := range buckets {
+		feed.Publish(Change{Bucket: bucket})
+	}
+}
+
+// notify calls a subscriber, a failing subscriber does not prevent the
+// others from being notified
+func notify(fn func(Change), change Change) {
+	defer func() {
+		if err := recover(); err != nil {
+			log.Error().Any("error", err).Str("bucket", change.Bucket).Str("key", change.Key).Msg("change subscriber failed")
+		}
+	}()
+
+	fn(change)
+}
@@ -0,0 +1,43 @@
+package changefeed
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFeedPublish(t *testing.T) {
+	is := assert.New(t)
+
+	var feed Feed
+	var endpoints, users []Change
+
+	feed.Subscribe("endpoints", func(change Change) { endpoints = append(endpoints, change) })
+	feed.Subscribe("users", func(change Change) { users = append(users, change) })
+	feed.Subscribe("users", func(change Change) { panic("failing subscriber") })
+
+	feed.Publish(Change{Bucket: "endpoints", Key: "1"})
+	feed.Publish(Change{Bucket: "teams", Key: "1"})
+
+	is.Equal([]Change{{Bucket: "endpoints", Key: "1"}}, endpoints)
+	is.Empty(users)
+
+	feed.Reset()
+
+	is.Equal([]Change{{Bucket: "endpoints", Key: "1"}, {Bucket: "endpoints"}}, endpoints)
+	is.Equal([]Change{{Bucket: "users"}}, users)
+}
+
+func TestChangeID(t *testing.T) {
+	is := assert.New(t)
+
+	id, ok := Change{Bucket: "endpoints", Key: "12"}.ID()
+	is.True(ok)
+	is.Equal(12, id)
+
+	_, ok = Change{Bucket: "endpoints"}.ID()
+	is.False(ok)
+
+	_, ok = Change{Bucket: "settings", Key: "SETTINGS"}.ID()
+	is.False(ok)
+}
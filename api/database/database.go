@@ -3,74 +3,98 @@ package database
 import (
 	"errors"
 	"fmt"
-	// "os"
+	"strings"
 
 	portainer "github.com/portainer/portainer/api"
 	"github.com/portainer/portainer/api/database/boltdb"
 	"github.com/portainer/portainer/api/database/postgres"
 )
 
+const (
+	StoreTypeBoltDB   = "boltdb"
+	StoreTypePostgres = "postgres"
+)
+
 var (
 	ErrUnknownStoreType = errors.New("unknown database store type")
 	ErrEmptyStorePath   = errors.New("store path cannot be empty")
 	ErrConnectionFailed = errors.New("failed to establish database connection")
+	ErrInvalidLocation  = errors.New("invalid store location, expected boltdb:<data directory> or a postgres:// connection string")
 )
 
-// NewDatabase initializes and returns a connection to the specified database.
+// Config describes which database backend to use and how to reach it
+type Config struct {
+	Type          string
+	StorePath     string
+	EncryptionKey []byte
+	Postgres      postgres.Config
+}
+
+// NewDatabase should use config options to return a connection to the requested database.
+// Postgres connections created through this function rely on an empty configuration and
+// will fail validation, use NewDatabaseFromConfig instead.
 func NewDatabase(storeType string, storePath string, encryptionKey []byte) (portainer.Connection, error) {
-	switch storeType {
-	case "boltdb":
-		if storePath == "" {
+	return NewDatabaseFromConfig(Config{
+		Type:          storeType,
+		StorePath:     storePath,
+		EncryptionKey: encryptionKey,
+	})
+}
+
+// NewDatabaseFromConfig initializes and returns a connection to the database described by config.
+func NewDatabaseFromConfig(config Config) (portainer.Connection, error) {
+	switch config.Type {
+	case StoreTypeBoltDB:
+		if config.StorePath == "" {
 			return nil, ErrEmptyStorePath
 		}
-		connection := &boltdb.DbConnection{
-			Path:          storePath,
-			EncryptionKey: encryptionKey,
-		}
-		return connection, nil
 
-	case "postgres":
+		return &boltdb.DbConnection{
+			Path:          config.StorePath,
+			EncryptionKey: config.EncryptionKey,
+		}, nil
 
-		postgresHost := "192.168.218.216"
-		postgresPort := "5432"
-		postgresUser := "postgres"
-		postgresPassword := "s152001s"
-		postgresDB := "portainerDB"
-		postgresSSLMode := "disable"
-
-		// Validate mandatory fields
-		if postgresHost == "" || postgresUser == "" || postgresPassword == "" || postgresDB == "" {
-			return nil, errors.New("missing required PostgreSQL environment variables")
+	case StoreTypePostgres:
+		connectionString, err := config.Postgres.ConnectionString()
+		if err != nil {
+			return nil, err
 		}
 
-		// Build the connection string
-		connectionString := fmt.Sprintf(
-			"host=%s port=%s user=%s password=%s dbname=%s sslmode=%s",
-			postgresHost,
-			defaultIfEmpty(postgresPort, "5432"),  // Default to port 5432 if not specified
-			postgresUser,
-			postgresPassword,
-			postgresDB,
-			defaultIfEmpty(postgresSSLMode, "disable"), // Default to "disable" SSL mode
-		)
-
-		// Establish the connection
-		connection, err := postgres.NewConnection(connectionString, encryptionKey)
+		connection, err := postgres.NewConnection(connectionString, config.StorePath, config.EncryptionKey)
 		if err != nil {
-			return nil, fmt.Errorf("%w: %v", ErrConnectionFailed, err)
+			return nil, fmt.Errorf("%w (%s): %w", ErrConnectionFailed, config.Postgres.Redacted(), err)
 		}
 
 		return connection, nil
-
-	default:
-		return nil, fmt.Errorf("%w: %s", ErrUnknownStoreType, storeType)
 	}
+
+	return nil, fmt.Errorf("%w: %s", ErrUnknownStoreType, config.Type)
 }
 
-// defaultIfEmpty returns the fallback value if the input is empty.
-func defaultIfEmpty(input, fallback string) string {
-	if input == "" {
-		return fallback
+// ParseLocation returns the configuration of the store at location. A BoltDB
+// store is located by boltdb:<data directory>, a PostgreSQL store by a
+// postgres:// or postgresql:// URL, or by postgres:<key=value connection string>.
+func ParseLocation(location string) (Config, error) {
+	switch {
+	case strings.HasPrefix(location, StoreTypeBoltDB+":"):
+		storePath := strings.TrimPrefix(location, StoreTypeBoltDB+":")
+		if storePath == "" {
+			return Config{}, ErrEmptyStorePath
+		}
+
+		return Config{Type: StoreTypeBoltDB, StorePath: storePath}, nil
+
+	case strings.HasPrefix(location, "postgres://"), strings.HasPrefix(location, "postgresql://"):
+		return Config{Type: StoreTypePostgres, Postgres: postgres.Config{DSN: location}}, nil
+
+	case strings.HasPrefix(location, StoreTypePostgres+":"):
+		dsn := strings.TrimPrefix(location, StoreTypePostgres+":")
+		if dsn == "" {
+			return Config{}, ErrInvalidLocation
+		}
+
+		return Config{Type: StoreTypePostgres, Postgres: postgres.Config{DSN: dsn}}, nil
 	}
-	return input
+
+	return Config{}, ErrInvalidLocation
 }
@@ -0,0 +1,41 @@
+package database
+
+import (
+	"testing"
+
+	"github.com/portainer/portainer/api/database/postgres"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_ParseLocation(t *testing.T) {
+	is := assert.New(t)
+
+	tests := []struct {
+		location string
+		expected Config
+		err      error
+	}{
+		{location: "boltdb:/data", expected: Config{Type: StoreTypeBoltDB, StorePath: "/data"}},
+		{location: "postgres://u:p@db/portainer", expected: Config{Type: StoreTypePostgres, Postgres: postgres.Config{DSN: "postgres://u:p@db/portainer"}}},
+		{location: "postgresql://db/portainer", expected: Config{Type: StoreTypePostgres, Postgres: postgres.Config{DSN: "postgresql://db/portainer"}}},
+		{location: "postgres:host=db dbname=portainer", expected: Config{Type: StoreTypePostgres, Postgres: postgres.Config{DSN: "host=db dbname=portainer"}}},
+		{location: "boltdb:", err: ErrEmptyStorePath},
+		{location: "postgres:", err: ErrInvalidLocation},
+		{location: "/data", err: ErrInvalidLocation},
+	}
+
+	for _, test := range tests {
+		t.Run(test.location, func(t *testing.T) {
+			config, err := ParseLocation(test.location)
+			if test.err != nil {
+				require.ErrorIs(t, err, test.err)
+				return
+			}
+
+			require.NoError(t, err)
+			is.Equal(test.expected, config)
+		})
+	}
+}
@@ -5,4 +5,6 @@ type Version struct {
 	MigratorCount int
 	Edition       int
 	InstanceID    string
+	// StoreSchemaVersion is the version of the SQL schema, stores without one keep 0
+	StoreSchemaVersion int `json:",omitempty"`
 }
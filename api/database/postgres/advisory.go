@@ -0,0 +1,107 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"hash/fnv"
+	"sync"
+)
+
+// AdvisoryLock is a session level advisory lock. It is held by a dedicated
+// connection, the server releases it when that connection is lost, including
+// when the process holding it dies.
+type AdvisoryLock struct {
+	connection *DbConnection
+	key        int64
+	mu         sync.Mutex
+	conn       *sql.Conn
+}
+
+// AdvisoryLock returns the advisory lock identified by name
+func (connection *DbConnection) AdvisoryLock(name string) *AdvisoryLock {
+	h := fnv.New64a()
+	h.Write([]byte(name))
+
+	return &AdvisoryLock{
+		connection: connection,
+		key:        int64(h.Sum64()),
+	}
+}
+
+// TryAcquire acquires the lock without waiting, or checks that the connection
+// holding it is still alive
+func (lock *AdvisoryLock) TryAcquire(ctx context.Context) (bool, error) {
+	lock.mu.Lock()
+	defer lock.mu.Unlock()
+
+	if lock.conn != nil {
+		if _, err := lock.conn.ExecContext(ctx, "SELECT 1"); err != nil {
+			lock.discard()
+
+			return false, fmt.Errorf("lost the connection holding the advisory lock: %w", err)
+		}
+
+		return true, nil
+	}
+
+	if lock.connection.DB == nil {
+		return false, ErrNoConnection
+	}
+
+	conn, err := lock.connection.DB.Conn(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	var acquired bool
+	if err := conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1)", lock.key).Scan(&acquired); err != nil {
+		conn.Close()
+
+		return false, fmt.Errorf("failed to acquire the advisory lock: %w", err)
+	}
+
+	if !acquired {
+		conn.Close()
+
+		return false, nil
+	}
+
+	lock.conn = conn
+
+	return true, nil
+}
+
+// Release unlocks the lock and returns its connection to the pool
+func (lock *AdvisoryLock) Release(ctx context.Context) error {
+	lock.mu.Lock()
+	defer lock.mu.Unlock()
+
+	if lock.conn == nil {
+		return nil
+	}
+
+	if _, err := lock.conn.ExecContext(ctx, "SELECT pg_advisory_unlock($1)", lock.key); err != nil {
+		// The session must not go back to the pool while it may hold the lock
+		lock.discard()
+
+		return fmt.Errorf("failed to release the advisory lock: %w", err)
+	}
+
+	err := lock.conn.Close()
+	lock.conn = nil
+
+	return err
+}
+
+// discard closes the connection holding the lock instead of returning it to
+// the pool, which releases the lock if the server still holds it
+func (lock *AdvisoryLock) discard() {
+	_ = lock.conn.Raw(func(any) error {
+		return driver.ErrBadConn
+	})
+
+	lock.conn.Close()
+	lock.conn = nil
+}
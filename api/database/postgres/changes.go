@@ -0,0 +1,207 @@
+package postgres
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/portainer/portainer/api/database/changefeed"
+
+	"github.com/lib/pq"
+	"github.com/rs/zerolog/log"
+	"github.com/segmentio/encoding/json"
+)
+
+const (
+	// changesChannel is the channel notified with the objects written by the committed transactions
+	changesChannel = "portainer_changes"
+
+	// maxNotificationPayload stays under the 8000 bytes limit of a notification,
+	// the keys of larger changes are not sent and the whole bucket is invalidated
+	maxNotificationPayload = 7900
+
+	listenerMinReconnect = time.Second
+	listenerMaxReconnect = time.Minute
+	listenerPingInterval = 90 * time.Second
+)
+
+// changeNotification is the payload of a notification. Origin identifies the
+// connection that committed the transaction, it ignores its own changes.
+type changeNotification struct {
+	Origin string   `json:"origin"`
+	Bucket string   `json:"bucket"`
+	Keys   []string `json:"keys,omitempty"`
+}
+
+// ChangeFeed returns the feed publishing the changes committed by the other
+// instances sharing the database, once ListenForChanges has been called
+func (connection *DbConnection) ChangeFeed() *changefeed.Feed {
+	return &connection.feed
+}
+
+// ListenForChanges publishes the notifications sent by the transactions of
+// the other instances on the change feed. The listener reconnects on its own,
+// the feed is reset after a reconnection since notifications may have been lost.
+func (connection *DbConnection) ListenForChanges() error {
+	if err := connection.connect(); err != nil {
+		return err
+	}
+
+	listener := pq.NewListener(connection.ConnectionString, listenerMinReconnect, listenerMaxReconnect, func(event pq.ListenerEventType, err error) {
+		switch event {
+		case pq.ListenerEventDisconnected:
+			log.Warn().Err(err).Msg("change listener disconnected from the database")
+		case pq.ListenerEventReconnected:
+			log.Info().Msg("change listener reconnected to the database")
+		case pq.ListenerEventConnectionAttemptFailed:
+			log.Debug().Err(err).Msg("change listener failed to reconnect to the database")
+		}
+	})
+
+	if err := listener.Listen(changesChannel); err != nil {
+		listener.Close()
+
+		return fmt.Errorf("failed to listen for database changes: %w", err)
+	}
+
+	go connection.receiveChanges(listener)
+
+	return nil
+}
+
+func (connection *DbConnection) receiveChanges(listener *pq.Listener) {
+	defer listener.Close()
+
+	ticker := time.NewTicker(listenerPingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-connection.ctx.Done():
+			return
+
+		case n := <-listener.Notify:
+			// A nil notification is sent after the listener reconnected
+			if n == nil {
+				connection.feed.Reset()
+
+				continue
+			}
+
+			connection.publishChanges(n.Extra)
+
+		case <-ticker.C:
+			// Detects a broken connection when no notification is received
+			go listener.Ping()
+		}
+	}
+}
+
+// publishChanges publishes the changes of a notification sent by another instance
+func (connection *DbConnection) publishChanges(payload string) {
+	var notification changeNotification
+	if err := json.Unmarshal([]byte(payload), &notification); err != nil {
+		log.Warn().Err(err).Msg("invalid database change notification, resetting the caches")
+		connection.feed.Reset()
+
+		return
+	}
+
+	if notification.Origin == connection.origin {
+		return
+	}
+
+	if len(notification.Keys) == 0 {
+		connection.feed.Publish(changefeed.Change{Bucket: notification.Bucket})
+
+		return
+	}
+
+	for _, key := range notification.Keys {
+		connection.feed.Publish(changefeed.Change{Bucket: notification.Bucket, Key: key})
+	}
+}
+
+// recordChange remembers an object written or deleted by the transaction
+func (tx *DbTransaction) recordChange(bucketName string, keyValue any) {
+	if tx.changes == nil {
+		tx.changes = make(map[string][]string)
+	}
+
+	tx.changes[bucketName] = append(tx.changes[bucketName], fmt.Sprint(keyValue))
+}
+
+// notifyChanges sends a notification for every bucket written by the
+// transaction, they are delivered when it commits
+func (tx *DbTransaction) notifyChanges() error {
+	buckets := make([]string, 0, len(tx.changes))
+	for bucketName := range tx.changes {
+		buckets = append(buckets, bucketName)
+	}
+	slices.Sort(buckets)
+
+	for _, bucketName := range buckets {
+		if err := notifyBucketChanges(tx.ctx, tx.tx, tx.conn.origin, bucketName, tx.changes[bucketName]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// notifyBucketChanges notifies the changed keys of a bucket, all of its
+// objects when keys is empty
+func notifyBucketChanges(ctx context.Context, tx *sql.Tx, origin, bucketName string, keys []string) error {
+	payload, err := changePayload(origin, bucketName, keys)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, "SELECT pg_notify($1, $2)", changesChannel, payload); err != nil {
+		return fmt.Errorf("failed to notify the changes of bucket %s: %w", bucketName, err)
+	}
+
+	return nil
+}
+
+// changePayload encodes the changed keys of a bucket, or the bucket alone
+// when there are too many of them
+func changePayload(origin, bucketName string, keys []string) (string, error) {
+	slices.Sort(keys)
+
+	notification := changeNotification{
+		Origin: origin,
+		Bucket: bucketName,
+		Keys:   slices.Compact(keys),
+	}
+
+	b, err := json.Marshal(notification)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode the changes of bucket %s: %w", bucketName, err)
+	}
+
+	if len(b) <= maxNotificationPayload {
+		return string(b), nil
+	}
+
+	notification.Keys = nil
+
+	b, err = json.Marshal(notification)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode the changes of bucket %s: %w", bucketName, err)
+	}
+
+	return string(b), nil
+}
+
+// newOrigin returns a random identifier for the notifications of a connection
+func newOrigin() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,49 @@
+package postgres
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/portainer/portainer/api/database/changefeed"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChangePayload(t *testing.T) {
+	is := assert.New(t)
+
+	payload, err := changePayload("origin", "endpoints", []string{"2", "1", "2"})
+	is.NoError(err)
+	is.JSONEq(`{"origin":"origin","bucket":"endpoints","keys":["1","2"]}`, payload)
+
+	keys := make([]string, 2000)
+	for i := range keys {
+		keys[i] = strconv.Itoa(i)
+	}
+
+	payload, err = changePayload("origin", "endpoints", keys)
+	is.NoError(err)
+	is.JSONEq(`{"origin":"origin","bucket":"endpoints"}`, payload, "too many keys, the whole bucket is notified")
+}
+
+func TestPublishChangesIgnoresOwnChanges(t *testing.T) {
+	is := assert.New(t)
+
+	connection := &DbConnection{origin: "local"}
+
+	var changes []changefeed.Change
+	connection.ChangeFeed().Subscribe("endpoints", func(change changefeed.Change) {
+		changes = append(changes, change)
+	})
+
+	connection.publishChanges(`{"origin":"local","bucket":"endpoints","keys":["1"]}`)
+	is.Empty(changes)
+
+	connection.publishChanges(`{"origin":"remote","bucket":"endpoints","keys":["1","3"]}`)
+	connection.publishChanges(`{"origin":"remote","bucket":"endpoints"}`)
+	is.Equal([]changefeed.Change{
+		{Bucket: "endpoints", Key: "1"},
+		{Bucket: "endpoints", Key: "3"},
+		{Bucket: "endpoints"},
+	}, changes)
+}
@@ -0,0 +1,151 @@
+package postgres
+
+import (
+	"database/sql"
+	"errors"
+	"net"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+const (
+	DefaultPort    = "5432"
+	DefaultSSLMode = "disable"
+)
+
+var (
+	ErrMissingHost         = errors.New("the PostgreSQL host is required")
+	ErrMissingUser         = errors.New("the PostgreSQL user is required")
+	ErrMissingDatabaseName = errors.New("the PostgreSQL database name is required")
+	ErrDSNWithParameters   = errors.New("a PostgreSQL DSN cannot be combined with individual connection parameters")
+	ErrInvalidSSLMode      = errors.New("invalid PostgreSQL sslmode, expected one of disable, allow, prefer, require, verify-ca or verify-full")
+	ErrInvalidIsolation    = errors.New("invalid PostgreSQL isolation level, expected one of serializable, repeatable-read or read-committed")
+)
+
+var validSSLModes = []string{"disable", "allow", "prefer", "require", "verify-ca", "verify-full"}
+
+// Config holds the parameters used to reach a PostgreSQL server. Either DSN
+// or the individual parameters must be provided, but not both.
+type Config struct {
+	DSN      string
+	Host     string
+	Port     string
+	User     string
+	Password string
+	Name     string
+	SSLMode  string
+}
+
+// Validate returns an error when the configuration is incomplete or ambiguous
+func (c Config) Validate() error {
+	if c.DSN != "" {
+		if c.Host != "" || c.User != "" || c.Name != "" || c.Password != "" {
+			return ErrDSNWithParameters
+		}
+
+		return nil
+	}
+
+	if c.Host == "" {
+		return ErrMissingHost
+	}
+
+	if c.User == "" {
+		return ErrMissingUser
+	}
+
+	if c.Name == "" {
+		return ErrMissingDatabaseName
+	}
+
+	return ValidateSSLMode(c.SSLMode)
+}
+
+// ConnectionString returns the connection string understood by lib/pq. When a
+// DSN is configured it is returned as-is, otherwise a postgres:// URL is built
+// so that special characters in the credentials are escaped properly.
+func (c Config) ConnectionString() (string, error) {
+	if err := c.Validate(); err != nil {
+		return "", err
+	}
+
+	if c.DSN != "" {
+		return c.DSN, nil
+	}
+
+	u := url.URL{
+		Scheme: "postgres",
+		Host:   net.JoinHostPort(c.Host, defaultIfEmpty(c.Port, DefaultPort)),
+		Path:   "/" + c.Name,
+	}
+
+	if c.Password != "" {
+		u.User = url.UserPassword(c.User, c.Password)
+	} else {
+		u.User = url.User(c.User)
+	}
+
+	q := url.Values{}
+	q.Set("sslmode", defaultIfEmpty(c.SSLMode, DefaultSSLMode))
+	u.RawQuery = q.Encode()
+
+	return u.String(), nil
+}
+
+// Redacted returns a description of the target server that is safe to log
+func (c Config) Redacted() string {
+	if c.DSN == "" {
+		return net.JoinHostPort(c.Host, defaultIfEmpty(c.Port, DefaultPort)) + "/" + c.Name
+	}
+
+	if u, err := url.Parse(c.DSN); err == nil && u.Scheme != "" {
+		return u.Redacted()
+	}
+
+	// key=value DSN, strip the password pair
+	fields := strings.Fields(c.DSN)
+	for i, f := range fields {
+		if strings.HasPrefix(f, "password=") {
+			fields[i] = "password=xxxxx"
+		}
+	}
+
+	return strings.Join(fields, " ")
+}
+
+// ValidateSSLMode checks that mode is one of the sslmode values supported by lib/pq
+func ValidateSSLMode(mode string) error {
+	if mode == "" {
+		return nil
+	}
+
+	if !slices.Contains(validSSLModes, mode) {
+		return ErrInvalidSSLMode
+	}
+
+	return nil
+}
+
+// ParseIsolation converts an isolation level name into the level of the write
+// transactions, an empty name selects serializable
+func ParseIsolation(level string) (sql.IsolationLevel, error) {
+	switch level {
+	case "", "serializable":
+		return sql.LevelSerializable, nil
+	case "repeatable-read":
+		return sql.LevelRepeatableRead, nil
+	case "read-committed":
+		return sql.LevelReadCommitted, nil
+	}
+
+	return sql.LevelDefault, ErrInvalidIsolation
+}
+
+func defaultIfEmpty(value, fallback string) string {
+	if value == "" {
+		return fallback
+	}
+
+	return value
+}
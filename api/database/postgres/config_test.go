@@ -0,0 +1,100 @@
+package postgres
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_ConfigConnectionString(t *testing.T) {
+	is := assert.New(t)
+
+	tests := []struct {
+		name     string
+		config   Config
+		expected string
+		err      error
+	}{
+		{
+			name:     "parameters with defaults",
+			config:   Config{Host: "db", User: "portainer", Name: "portainer"},
+			expected: "postgres://portainer@db:5432/portainer?sslmode=disable",
+		},
+		{
+			name:     "credentials are escaped",
+			config:   Config{Host: "db", Port: "6543", User: "port ainer", Password: "p@ss/w:rd", Name: "portainer", SSLMode: "require"},
+			expected: "postgres://port%20ainer:p%40ss%2Fw%3Ard@db:6543/portainer?sslmode=require",
+		},
+		{
+			name:     "dsn is passed through",
+			config:   Config{DSN: "host=db user=portainer dbname=portainer"},
+			expected: "host=db user=portainer dbname=portainer",
+		},
+		{
+			name:   "dsn with parameters",
+			config: Config{DSN: "host=db", Host: "db"},
+			err:    ErrDSNWithParameters,
+		},
+		{
+			name:   "missing host",
+			config: Config{User: "portainer", Name: "portainer"},
+			err:    ErrMissingHost,
+		},
+		{
+			name:   "missing user",
+			config: Config{Host: "db", Name: "portainer"},
+			err:    ErrMissingUser,
+		},
+		{
+			name:   "missing database name",
+			config: Config{Host: "db", User: "portainer"},
+			err:    ErrMissingDatabaseName,
+		},
+		{
+			name:   "invalid sslmode",
+			config: Config{Host: "db", User: "portainer", Name: "portainer", SSLMode: "maybe"},
+			err:    ErrInvalidSSLMode,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s, err := test.config.ConnectionString()
+			if test.err != nil {
+				require.ErrorIs(t, err, test.err)
+				return
+			}
+
+			require.NoError(t, err)
+			is.Equal(test.expected, s)
+		})
+	}
+}
+
+func Test_ConfigRedacted(t *testing.T) {
+	is := assert.New(t)
+
+	is.Equal("db:5432/portainer", Config{Host: "db", User: "u", Password: "secret", Name: "portainer"}.Redacted())
+	is.Equal("postgres://u:xxxxx@db/portainer", Config{DSN: "postgres://u:secret@db/portainer"}.Redacted())
+	is.Equal("host=db password=xxxxx", Config{DSN: "host=db password=secret"}.Redacted())
+}
+
+func Test_ParseIsolation(t *testing.T) {
+	is := assert.New(t)
+
+	for name, expected := range map[string]sql.IsolationLevel{
+		"":                sql.LevelSerializable,
+		"serializable":    sql.LevelSerializable,
+		"repeatable-read": sql.LevelRepeatableRead,
+		"read-committed":  sql.LevelReadCommitted,
+	} {
+		level, err := ParseIsolation(name)
+		is.NoError(err)
+		is.Equal(expected, level, "isolation %q", name)
+	}
+
+	_, err := ParseIsolation("read-uncommitted")
+	is.ErrorIs(err, ErrInvalidIsolation)
+}
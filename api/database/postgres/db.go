@@ -6,68 +6,94 @@ import (
 	"encoding/binary"
 	"errors"
 	"fmt"
-	"io"
-	"time"
-	"encoding/json"
 	"os"
 	"path"
+	"sync"
+	"sync/atomic"
+	"time"
 
-	// "github.com/jmoiron/sqlx"
 	_ "github.com/lib/pq"
 	portainer "github.com/portainer/portainer/api"
+	"github.com/portainer/portainer/api/database/changefeed"
 	dserrors "github.com/portainer/portainer/api/dataservices/errors"
 	"github.com/rs/zerolog/log"
 )
 
 const (
-	// Database configuration constants
+	// Database configuration constants, the pool limits are the defaults of PoolConfig
 	DatabaseDriverName = "postgres"
-	DatabaseMaxOpen   = 25
-	DatabaseMaxIdle   = 25
-	DatabaseTimeout   = 5 * time.Minute
+	DatabaseMaxOpen    = 25
+	DatabaseMaxIdle    = 25
+	DatabaseTimeout    = 5 * time.Minute
 
 	// Metadata table names
 	EncryptedMetadataTable   = "encrypted_metadata"
 	UnencryptedMetadataTable = "unencrypted_metadata"
+
+	// legacyStoreTable exists in every store, including the ones created
+	// before the metadata tables were introduced
+	legacyStoreTable = "version"
 )
 
+// The store lives on the server, these name the dump written by Store.Backup
 const (
-	DatabaseFileName          = "portainer"
-	EncryptedDatabaseFileName = "portainer"
+	DatabaseFileName          = "portainer.pgdump"
+	EncryptedDatabaseFileName = "portainer.pgdump"
 )
 
 var (
 	ErrHaveEncryptedAndUnencrypted = errors.New("portainer has detected both an encrypted and un-encrypted database and cannot start")
 	ErrHaveEncryptedWithNoKey      = errors.New("the portainer database is encrypted, but no secret was loaded")
-	ErrNoConnection               = errors.New("database connection is not initialized")
+	ErrNoConnection                = errors.New("database connection is not initialized")
+	ErrInvalidKey                  = errors.New("invalid key for an integer keyed bucket")
+
+	errBeginTx = errors.New("failed to begin transaction")
 )
 
 // DbConnection represents a PostgreSQL database connection
 type DbConnection struct {
 	ConnectionString string
-	Path            string
-	EncryptionKey   []byte
-	isEncrypted     bool
-	ctx             context.Context
-	cancelFunc      context.CancelFunc
+	Path             string
+	EncryptionKey    []byte
+	isEncrypted      bool
+	keyKinds         sync.Map
+	ctx              context.Context
+	cancelFunc       context.CancelFunc
+
+	// StatementTimeout aborts the statements of a transaction running for longer, 0 disables it
+	StatementTimeout time.Duration
+	// Isolation is the isolation level of the write transactions, serializable
+	// when it is not set so that they behave as the single writer of BoltDB
+	Isolation sql.IsolationLevel
+	// MaxReplicaLag is the replication delay above which the read replicas are not used
+	MaxReplicaLag time.Duration
+
+	replicas    []*replica
+	nextReplica atomic.Uint64
+
+	txMetrics txMetrics
+
+	pool   PoolConfig
+	health healthState
+
+	// origin identifies the notifications sent by this connection
+	origin string
+	feed   changefeed.Feed
 
 	DB *sql.DB
 }
 
-// NewConnection creates a new database connection
-func NewConnection(connectionString string, encryptionKey []byte) (*DbConnection, error) {
-	ctx, cancel := context.WithCancel(context.Background())
-	
+// NewConnection creates a new database connection. storePath is the Portainer
+// data directory, it is used for backups and never contains credentials.
+func NewConnection(connectionString string, storePath string, encryptionKey []byte) (*DbConnection, error) {
 	conn := &DbConnection{
 		ConnectionString: connectionString,
-		Path:            connectionString,
-		EncryptionKey:   encryptionKey,
-		ctx:             ctx,
-		cancelFunc:      cancel,
+		Path:             storePath,
+		EncryptionKey:    encryptionKey,
 	}
 
-	if err := conn.Open(); err != nil {
-		cancel()
+	// Fail early when the server cannot be reached
+	if err := conn.connect(); err != nil {
 		return nil, err
 	}
 
@@ -82,7 +108,7 @@ func (connection *DbConnection) GetDatabaseFilePath() string {
 	return path.Join(connection.Path, DatabaseFileName)
 }
 
-// GetStorePath returns the connection string path
+// GetStorePath returns the Portainer data directory
 func (connection *DbConnection) GetStorePath() string {
 	return connection.Path
 }
@@ -95,107 +121,56 @@ func (connection *DbConnection) SetEncrypted(flag bool) {
 func (connection *DbConnection) IsEncryptedStore() bool {
 	return connection.getEncryptionKey() != nil
 }
-func (connection *DbConnection) ExportRaw(filename string) error {
-	// Validate that the database file path exists
-	databasePath := connection.GetDatabaseFilePath()
-	if _, err := os.Stat(databasePath); err != nil {
-		return fmt.Errorf("failed to access database file %s: %w", databasePath, err)
-	}
 
-	// Export data as JSON
-	exportedData, err := connection.ExportDatabaseAsJSON()
+// ExportRaw writes a JSON export of the database to filename. Unlike BoltDB
+// there is no database file, the data is read from the server.
+func (connection *DbConnection) ExportRaw(filename string) error {
+	b, err := connection.ExportJSON(connection.GetDatabaseFilePath(), true)
 	if err != nil {
 		return fmt.Errorf("failed to export database to JSON: %w", err)
 	}
 
-	// Write the exported data to the specified file
-	err = os.WriteFile(filename, exportedData, 0600)
-	if err != nil {
-		return fmt.Errorf("failed to write data to file %s: %w", filename, err)
-	}
-
-	return nil
-}
-func (connection *DbConnection) ExportDatabaseAsJSON() ([]byte, error) {
-	// Query all tables and export their data as JSON
-	rows, err := connection.DB.Query(`SELECT tablename FROM pg_tables WHERE schemaname = 'public'`)
-	if err != nil {
-		return nil, fmt.Errorf("failed to query tables: %w", err)
-	}
-	defer rows.Close()
-
-	export := make(map[string][]map[string]any)
-
-	for rows.Next() {
-		var tableName string
-		if err := rows.Scan(&tableName); err != nil {
-			return nil, fmt.Errorf("failed to scan table name: %w", err)
-		}
-
-		// Query table contents
-		query := fmt.Sprintf("SELECT * FROM %s", tableName)
-		tableRows, err := connection.DB.Query(query)
-		if err != nil {
-			return nil, fmt.Errorf("failed to query table %s: %w", tableName, err)
-		}
-
-		columns, _ := tableRows.Columns()
-		for tableRows.Next() {
-			values := make([]any, len(columns))
-			valuePtrs := make([]any, len(columns))
-			for i := range values {
-				valuePtrs[i] = &values[i]
-			}
-
-			err := tableRows.Scan(valuePtrs...)
-			if err != nil {
-				return nil, fmt.Errorf("failed to scan row for table %s: %w", tableName, err)
-			}
-
-			rowData := make(map[string]any)
-			for i, col := range columns {
-				rowData[col] = values[i]
-			}
-			export[tableName] = append(export[tableName], rowData)
-		}
-	}
-
-	return json.MarshalIndent(export, "", "  ")
+	return os.WriteFile(filename, b, 0600)
 }
 
 func (connection *DbConnection) ConvertToKey(key int) []byte {
 	b := make([]byte, 8)
 	binary.BigEndian.PutUint64(b, uint64(key))
-    return b
+	return b
 }
-// NeedsEncryptionMigration checks if database needs encryption migration
+
+// NeedsEncryptionMigration returns true if database encryption is enabled and
+// we have an un-encrypted store that requires migration to an encrypted store.
+// The encrypted_metadata and unencrypted_metadata marker tables play the role of
+// the portainer.edb and portainer.db files of the BoltDB backend.
 func (connection *DbConnection) NeedsEncryptionMigration() (bool, error) {
-	if connection.DB == nil {
-		return false, ErrNoConnection
+	if err := connection.connect(); err != nil {
+		return false, err
 	}
 
-	checkTableExists := func(tableName string) (bool, error) {
-		var exists bool
-		query := `
-			SELECT EXISTS (
-				SELECT FROM information_schema.tables 
-				WHERE table_schema = 'public' 
-				AND table_name = $1
-			);`
-			err := connection.DB.QueryRowContext(connection.ctx, query, tableName).Scan(&exists)
-			return exists, err
+	// If we have a loaded encryption key, always set encrypted
+	if connection.EncryptionKey != nil {
+		connection.SetEncrypted(true)
 	}
 
-	haveUnencrypted, err := checkTableExists(UnencryptedMetadataTable)
+	haveUnencrypted, err := connection.tableExists(UnencryptedMetadataTable)
 	if err != nil {
 		return false, fmt.Errorf("failed to check unencrypted table: %w", err)
 	}
 
-	haveEncrypted, err := checkTableExists(EncryptedMetadataTable)
+	haveEncrypted, err := connection.tableExists(EncryptedMetadataTable)
 	if err != nil {
 		return false, fmt.Errorf("failed to check encrypted table: %w", err)
 	}
 
+	if !haveUnencrypted && !haveEncrypted {
+		// Stores created before the marker tables existed were never encrypted
+		haveUnencrypted, err = connection.tableExists(legacyStoreTable)
+		if err != nil {
+			return false, fmt.Errorf("failed to check for an existing store: %w", err)
+		}
+	}
+
 	switch {
 	case haveUnencrypted && haveEncrypted:
 		return false, ErrHaveEncryptedAndUnencrypted
@@ -203,65 +178,87 @@ func (connection *DbConnection) NeedsEncryptionMigration() (bool, error) {
 		return true, nil
 	case haveEncrypted && connection.EncryptionKey == nil:
 		return false, ErrHaveEncryptedWithNoKey
-	default:
-		return false, nil
 	}
+
+	return false, nil
 }
 
-// Open opens and initializes the PostgreSQL database connection
+// Open opens the PostgreSQL database connection and migrates its schema
 func (connection *DbConnection) Open() error {
-	log.Info().Str("connection", connection.ConnectionString).Msg("connecting to PostgreSQL database")
+	if err := connection.MigrateSchema(); err != nil {
+		return err
+	}
+
+	return connection.ensureEncryptionMarker()
+}
+
+// connect creates the connection pool if it does not exist yet
+func (connection *DbConnection) connect() error {
+	if connection.DB != nil {
+		return nil
+	}
+
+	log.Info().Msg("connecting to PostgreSQL database")
+
+	connection.ctx, connection.cancelFunc = context.WithCancel(context.Background())
+	connection.origin = newOrigin()
 
 	db, err := sql.Open(DatabaseDriverName, connection.ConnectionString)
 	if err != nil {
+		connection.cancelFunc()
 		return fmt.Errorf("failed to connect to database: %w", err)
 	}
-	
-	err = db.Ping()
-	if err != nil {
-		return fmt.Errorf("failed to verify database connection: %w", err)
-	}
-	fmt.Println("Successfully connected to the database!")
-	// defer func() {
-	// 	if err != nil && db != nil {
-	// 		_ = db.Close() // Close the database connection in case of an error
-	// 	}
-	// }()
-
-	// Create the "users" table if it doesn't exist
-	// createTable := `CREATE TABLE IF NOT EXISTS users (
-	// 	id SERIAL PRIMARY KEY,
-	// 	name VARCHAR(100) NOT NULL,
-	// 	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
-	// )`
-	// _, err = db.Exec(createTable)
-	// if err != nil {
-	// 	return fmt.Errorf("failed to create table: %w", err)
-	// }
-
-	// // Insert a test user into the "users" table
-	// insertUser := `INSERT INTO users (name) VALUES($1) RETURNING id`
-	// var userID int
-	// err = db.QueryRow(insertUser, "test").Scan(&userID)
-	// if err != nil {
-	// 	return fmt.Errorf("failed to insert user: %w", err)
-	// }
-	// log.Info().Int("user_id", userID).Msg("inserted user")
-
-	db.SetMaxOpenConns(DatabaseMaxOpen)
-	db.SetMaxIdleConns(DatabaseMaxIdle)
-	db.SetConnMaxLifetime(DatabaseTimeout)
-
-	// Verify connection
+
+	connection.applyPoolConfig(db)
+
 	if err := db.PingContext(connection.ctx); err != nil {
+		connection.cancelFunc()
+		db.Close()
 		return fmt.Errorf("failed to verify database connection: %w", err)
 	}
 
 	connection.DB = db
+
+	now := time.Now()
+	connection.health.mu.Lock()
+	connection.health.state = DatabaseHealth{Healthy: true, Since: now, LastCheck: now}
+	connection.health.mu.Unlock()
+
 	return nil
 }
 
-// Close closes the PostgreSQL database connection
+// ensureEncryptionMarker flags a new store as encrypted or not, the same way
+// BoltDB picks portainer.edb or portainer.db when it creates the file
+func (connection *DbConnection) ensureEncryptionMarker() error {
+	haveUnencrypted, err := connection.tableExists(UnencryptedMetadataTable)
+	if err != nil {
+		return err
+	}
+
+	haveEncrypted, err := connection.tableExists(EncryptedMetadataTable)
+	if err != nil || haveUnencrypted || haveEncrypted {
+		return err
+	}
+
+	marker := UnencryptedMetadataTable
+	if connection.IsEncryptedStore() {
+		marker = EncryptedMetadataTable
+	}
+
+	return createMarker(connection.ctx, connection.DB, marker)
+}
+
+func (connection *DbConnection) tableExists(tableName string) (bool, error) {
+	var exists bool
+	err := connection.DB.QueryRowContext(connection.ctx, `SELECT EXISTS (
+		SELECT FROM information_schema.tables WHERE table_schema = current_schema() AND table_name = $1
+	)`, tableName).Scan(&exists)
+
+	return exists, err
+}
+
+// Close closes the PostgreSQL database connection.
+// Safe to being called multiple times.
 func (connection *DbConnection) Close() error {
 	log.Info().Msg("closing PostgreSQL connection")
 
@@ -269,24 +266,127 @@ func (connection *DbConnection) Close() error {
 		connection.cancelFunc()
 	}
 
-	if connection.DB != nil {
-		return connection.DB.Close()
+	connection.closeReplicas()
+
+	if connection.DB == nil {
+		return nil
 	}
 
-	return nil
+	err := connection.DB.Close()
+	connection.DB = nil
+
+	return err
 }
 
 // UpdateTx executes the given function within a transaction
 func (connection *DbConnection) UpdateTx(fn func(portainer.Transaction) error) error {
+	return connection.UpdateTxCtx(context.Background(), fn)
+}
+
+// UpdateTxCtx executes the given function within a transaction. The statements
+// of the transaction are cancelled when ctx is done or the connection is closed.
+func (connection *DbConnection) UpdateTxCtx(ctx context.Context, fn func(portainer.Transaction) error) error {
 	// Check if the connection is initialized
 	if connection.DB == nil {
 		return ErrNoConnection
 	}
 
+	return connection.runTx(ctx, connection.DB, &sql.TxOptions{Isolation: connection.writeIsolation()}, fn)
+}
+
+// UpdateTxRetryable is UpdateTxCtx, a transaction aborted by a serialization
+// failure or a deadlock is executed again. fn must not have effects outside of
+// the transaction.
+func (connection *DbConnection) UpdateTxRetryable(ctx context.Context, fn func(portainer.Transaction) error) error {
+	if connection.DB == nil {
+		return ErrNoConnection
+	}
+
+	opts := &sql.TxOptions{Isolation: connection.writeIsolation()}
+
+	for attempt := 1; ; attempt++ {
+		err := connection.runTx(ctx, connection.DB, opts, fn)
+
+		code, retryable := retryableCode(err)
+		if !retryable {
+			return err
+		}
+
+		if attempt == txRetries {
+			connection.txMetrics.exhausted.Add(1)
+
+			return err
+		}
+
+		if code == deadlockDetected {
+			connection.txMetrics.deadlocks.Add(1)
+		} else {
+			connection.txMetrics.serializationFailures.Add(1)
+		}
+
+		log.Debug().Err(err).Str("sqlstate", string(code)).Int("attempt", attempt).Msg("transaction aborted, retrying")
+
+		if sleepErr := sleepCtx(ctx, retryDelay(attempt)); sleepErr != nil {
+			return err
+		}
+	}
+}
+
+func (connection *DbConnection) writeIsolation() sql.IsolationLevel {
+	if connection.Isolation == sql.LevelDefault {
+		return sql.LevelSerializable
+	}
+
+	return connection.Isolation
+}
+
+// ViewTx executes a read-only transaction
+func (connection *DbConnection) ViewTx(fn func(portainer.Transaction) error) error {
+	return connection.ViewTxCtx(context.Background(), fn)
+}
+
+// ViewTxCtx executes a read-only transaction, cancelled when ctx is done. It
+// is served by a read replica when ctx allows it and one is up to date.
+func (connection *DbConnection) ViewTxCtx(ctx context.Context, fn func(portainer.Transaction) error) error {
+	if connection.DB == nil {
+		return ErrNoConnection
+	}
+
+	// A read-only transaction only needs a consistent snapshot, serializable is
+	// not available on the replicas
+	readOnly := &sql.TxOptions{
+		Isolation: min(connection.writeIsolation(), sql.LevelRepeatableRead),
+		ReadOnly:  true,
+	}
+
+	if portainer.ReadReplicaAllowed(ctx) {
+		if r := connection.pickReplica(); r != nil {
+			err := connection.runTx(ctx, r.db, readOnly, fn)
+			if !errors.Is(err, errBeginTx) {
+				return err
+			}
+
+			// The replica is not reachable anymore, wait for the next check to use it again
+			r.healthy.Store(false)
+			log.Warn().Err(err).Str("replica", r.name).Msg("read replica unavailable, reading from the primary")
+		}
+	}
+
+	return connection.runTx(ctx, connection.DB, readOnly, fn)
+}
+
+// runTx executes fn within a transaction of db
+func (connection *DbConnection) runTx(ctx context.Context, db *sql.DB, opts *sql.TxOptions, fn func(portainer.Transaction) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	stop := context.AfterFunc(connection.ctx, cancel)
+	defer stop()
+
 	// Begin transaction
-	tx, err := connection.DB.BeginTx(connection.ctx, nil)
+	tx, err := db.BeginTx(ctx, opts)
 	if err != nil {
-		return fmt.Errorf("failed to begin transaction: %w", err)
+		return fmt.Errorf("%w: %w", errBeginTx, err)
 	}
 	if tx == nil {
 		return fmt.Errorf("transaction object is nil")
@@ -296,24 +396,35 @@ func (connection *DbConnection) UpdateTx(fn func(portainer.Transaction) error) e
 	defer func() {
 		if p := recover(); p != nil {
 			tx.Rollback()
-			// panic(p) // Re-throw panic after rollback
+			panic(p) // Re-throw panic after rollback
 		}
 	}()
 
+	if err := connection.setStatementTimeout(ctx, tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+
 	// Wrap transaction in DbTransaction
 	pgTx := &DbTransaction{
 		conn: connection,
 		tx:   tx,
+		ctx:  ctx,
 	}
 
 	// Execute the function
 	if err := fn(pgTx); err != nil {
-		if rbErr := tx.Rollback(); rbErr != nil {
+		if rbErr := tx.Rollback(); rbErr != nil && !errors.Is(rbErr, sql.ErrTxDone) {
 			log.Error().Err(rbErr).Msg("failed to rollback transaction")
 		}
 		return fmt.Errorf("transaction function failed: %w", err)
 	}
 
+	if err := pgTx.notifyChanges(); err != nil {
+		tx.Rollback()
+		return err
+	}
+
 	// Commit the transaction
 	if err := tx.Commit(); err != nil {
 		return fmt.Errorf("failed to commit transaction: %w", err)
@@ -322,11 +433,19 @@ func (connection *DbConnection) UpdateTx(fn func(portainer.Transaction) error) e
 	return nil
 }
 
+// setStatementTimeout bounds the duration of every statement of the transaction
+func (connection *DbConnection) setStatementTimeout(ctx context.Context, tx *sql.Tx) error {
+	if connection.StatementTimeout <= 0 {
+		return nil
+	}
 
+	// SET does not accept parameters
+	query := fmt.Sprintf("SET LOCAL statement_timeout = %d", connection.StatementTimeout.Milliseconds())
+	if _, err := tx.ExecContext(ctx, query); err != nil {
+		return fmt.Errorf("failed to set the statement timeout: %w", err)
+	}
 
-// ViewTx executes a read-only transaction
-func (connection *DbConnection) ViewTx(fn func(portainer.Transaction) error) error {
-	return connection.UpdateTx(fn) // PostgreSQL doesn't require special handling for read-only transactions
+	return nil
 }
 
 // GetNextIdentifier retrieves the next available ID for a table
@@ -348,117 +467,57 @@ func (connection *DbConnection) GetDatabaseFileName() string {
 
 	return DatabaseFileName
 }
-func (connection *DbConnection) SetServiceName(bucketName string) error {
+
+// SetServiceName creates the table backing a bucket and registers its key kind
+func (connection *DbConnection) SetServiceName(bucketName string, keyKind portainer.KeyKind) error {
 	return connection.UpdateTx(func(tx portainer.Transaction) error {
-		// Ensure the SetServiceName method exists on the Transaction interface
-		return tx.SetServiceName(bucketName)
+		return tx.SetServiceName(bucketName, keyKind)
 	})
 }
+
+// UpdateObjectFunc is a generic function used to update an object safely without race conditions.
+// The object is read again and updateFn is called again when another transaction
+// updated it in the meantime, updateFn must only change object.
 func (connection *DbConnection) UpdateObjectFunc(bucketName string, key []byte, object any, updateFn func()) error {
-    // Start a database transaction
-    tx, err := connection.DB.Begin()
-    if err != nil {
-        return fmt.Errorf("failed to begin transaction: %w", err)
-    }
-    defer func() {
-        if err != nil {
-            tx.Rollback()
-        } else {
-            tx.Commit()
-        }
-    }()
-
-    // Fetch the existing data from the database
-    query := fmt.Sprintf("SELECT data FROM %s WHERE id = $1", bucketName)
-    var data []byte
-    err = tx.QueryRow(query, string(key)).Scan(&data)
-    if err == sql.ErrNoRows {
-        return fmt.Errorf("%w (bucket=%s, key=%s)", dserrors.ErrObjectNotFound, bucketName, string(key))
-    } else if err != nil {
-        return fmt.Errorf("failed to retrieve object: %w", err)
-    }
-
-    // Unmarshal the data into the provided object
-    err = connection.UnmarshalObject(data, object)
-    if err != nil {
-        return fmt.Errorf("failed to unmarshal object: %w", err)
-    }
-
-    // Apply the provided update function to modify the object
-    // if err := updateFn(); err != nil {
-    //     return fmt.Errorf("update function failed: %w", err)
-    // }
-
-    // Marshal the updated object
-    updatedData, err := connection.MarshalObject(object)
-    if err != nil {
-        return fmt.Errorf("failed to marshal object: %w", err)
-    }
-
-    // Update the object in the database
-    updateQuery := fmt.Sprintf("UPDATE %s SET data = $1 WHERE id = $2", bucketName)
-    _, err = tx.Exec(updateQuery, updatedData, string(key))
-    if err != nil {
-        return fmt.Errorf("failed to update object: %w", err)
-    }
-
-    return nil
-}
+	for attempt := 1; ; attempt++ {
+		err := connection.UpdateTxRetryable(context.Background(), func(tx portainer.Transaction) error {
+			// Decoding merges the maps and keeps the fields missing from the
+			// stored value, every attempt starts from a zero value
+			resetObject(object)
+
+			if err := tx.GetObject(bucketName, key, object); err != nil {
+				return err
+			}
 
+			updateFn()
 
-func (connection *DbConnection) GetAllWithKeyPrefix(bucketName string, keyPrefix []byte, obj any, appendFn func(o any) (any, error)) error {
-	// Start a transaction view
-	return connection.ViewTx(func(tx portainer.Transaction) error {
-		// Ensure that the transaction implements the method GetAllWithKeyPrefix
-		// Forward the call to the transaction method to handle the database interaction
-		return tx.GetAllWithKeyPrefix(bucketName, keyPrefix, obj, appendFn)
-	})
-}
+			return tx.UpdateObject(bucketName, key, object)
+		})
+		if !errors.Is(err, dserrors.ErrConflict) {
+			return err
+		}
 
+		if attempt == conflictRetries {
+			connection.txMetrics.exhausted.Add(1)
 
-// BackupTo exports the database to a writer
-func (connection *DbConnection) BackupTo(w io.Writer) error {
-	if connection.DB == nil {
-		return ErrNoConnection
-	}
+			return err
+		}
 
-	rows, err := connection.DB.QueryContext(connection.ctx, `
-		SELECT 
-			table_schema,
-			table_name,
-			column_name,
-			data_type
-		FROM 
-			information_schema.columns
-		WHERE 
-			table_schema = 'public'
-		ORDER BY 
-			table_name, ordinal_position
-	`)
-	if err != nil {
-		return fmt.Errorf("failed to query schema: %w", err)
-	}
-	defer rows.Close()
+		connection.txMetrics.conflicts.Add(1)
 
-	schemas := make(map[string][]string)
-	for rows.Next() {
-		var schema, table, column, dataType string
-		if err := rows.Scan(&schema, &table, &column, &dataType); err != nil {
-			return fmt.Errorf("failed to scan schema row: %w", err)
-		}
-		schemas[table] = append(schemas[table], fmt.Sprintf("%s %s", column, dataType))
-	}
+		log.Debug().Err(err).Int("attempt", attempt).Msg("update conflict, retrying")
 
-	// Write schema information
-	for table, columns := range schemas {
-		fmt.Fprintf(w, "Table: %s\nColumns:\n", table)
-		for _, col := range columns {
-			fmt.Fprintf(w, "  %s\n", col)
-		}
-		fmt.Fprintln(w, "---")
+		time.Sleep(retryDelay(attempt))
 	}
+}
 
-	return nil
+func (connection *DbConnection) GetAllWithKeyPrefix(bucketName string, keyPrefix []byte, obj any, appendFn func(o any) (any, error)) error {
+	// Start a transaction view
+	return connection.ViewTx(func(tx portainer.Transaction) error {
+		// Ensure that the transaction implements the method GetAllWithKeyPrefix
+		// Forward the call to the transaction method to handle the database interaction
+		return tx.GetAllWithKeyPrefix(bucketName, keyPrefix, obj, appendFn)
+	})
 }
 
 func (connection *DbConnection) getEncryptionKey() []byte {
@@ -468,41 +527,27 @@ func (connection *DbConnection) getEncryptionKey() []byte {
 	return connection.EncryptionKey
 }
 
-// CreateObject creates a new object in the specified table
 // CreateObject creates an object and inserts it with the next ID for the given bucket
 func (connection *DbConnection) CreateObject(bucketName string, fn func(uint64) (int, any)) error {
-	return connection.UpdateTx(func(tx portainer.Transaction) error {
+	return connection.UpdateTxRetryable(context.Background(), func(tx portainer.Transaction) error {
 		return tx.CreateObject(bucketName, fn)
 	})
 }
 
 // CreateObjectWithId creates a new object in the bucket, using the specified id
 func (connection *DbConnection) CreateObjectWithId(bucketName string, id int, obj any) error {
-	return connection.UpdateTx(func(tx portainer.Transaction) error {
+	return connection.UpdateTxRetryable(context.Background(), func(tx portainer.Transaction) error {
 		return tx.CreateObjectWithId(bucketName, id, obj)
 	})
 }
 
 // CreateObjectWithStringId creates a new object in the bucket, using the specified id
 func (connection *DbConnection) CreateObjectWithStringId(bucketName string, id []byte, obj any) error {
-	return connection.UpdateTx(func(tx portainer.Transaction) error {
+	return connection.UpdateTxRetryable(context.Background(), func(tx portainer.Transaction) error {
 		return tx.CreateObjectWithStringId(bucketName, id, obj)
 	})
 }
 
-
-// MarshalObject converts an object to JSON
-// func (connection *DbConnection) MarshalObject(obj any) ([]byte, error) {
-// 	return json.Marshal(obj)
-// }
-
-// // UnmarshalObject converts JSON to an object
-// func (connection *DbConnection) UnmarshalObject(data []byte, obj any) error {
-// 	return json.Unmarshal(data, obj)
-// }
-
-// Other methods would be implemented similarly...
-
 // GetObject retrieves an object from a table
 func (connection *DbConnection) GetObject(bucketName string, key []byte, object any) error {
 	return connection.ViewTx(func(tx portainer.Transaction) error {
@@ -512,14 +557,14 @@ func (connection *DbConnection) GetObject(bucketName string, key []byte, object
 
 // UpdateObject updates an object in a table
 func (connection *DbConnection) UpdateObject(bucketName string, key []byte, object any) error {
-	return connection.UpdateTx(func(tx portainer.Transaction) error {
+	return connection.UpdateTxRetryable(context.Background(), func(tx portainer.Transaction) error {
 		return tx.UpdateObject(bucketName, key, object)
 	})
 }
 
 // DeleteObject removes an object from a table
 func (connection *DbConnection) DeleteObject(bucketName string, key []byte) error {
-	return connection.UpdateTx(func(tx portainer.Transaction) error {
+	return connection.UpdateTxRetryable(context.Background(), func(tx portainer.Transaction) error {
 		return tx.DeleteObject(bucketName, key)
 	})
 }
@@ -533,70 +578,64 @@ func (connection *DbConnection) GetAll(bucketName string, obj any, appendFn func
 
 // DeleteAllObjects deletes all objects from a specific bucket (table) in the database that match a given condition.
 func (connection *DbConnection) DeleteAllObjects(bucketName string, obj any, matching func(o any) (id int, ok bool)) error {
-	return connection.UpdateTx(func(tx portainer.Transaction) error {
+	return connection.UpdateTxRetryable(context.Background(), func(tx portainer.Transaction) error {
 		return tx.DeleteAllObjects(bucketName, obj, matching)
 	})
 }
 
-// BackupMetadata retrieves sequence/identity information
+// BackupMetadata will return a copy of the sequence values for all buckets.
 func (connection *DbConnection) BackupMetadata() (map[string]any, error) {
-	metadata := make(map[string]any)
-
-	// Query to fetch all table names and serial sequences in the public schema
-	rows, err := connection.DB.Query(`
-		SELECT tablename, pg_get_serial_sequence(tablename, 'id') as seq
-		FROM pg_tables 
-		WHERE schemaname = 'public'
-	`)
+	if connection.DB == nil {
+		return nil, ErrNoConnection
+	}
+
+	buckets := map[string]any{}
+
+	tx, err := connection.DB.BeginTx(connection.ctx, &sql.TxOptions{ReadOnly: true})
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
+	defer tx.Rollback()
 
-	for rows.Next() {
-		var tableName, seqName string
+	names, err := bucketTables(connection.ctx, tx)
+	if err != nil {
+		return nil, err
+	}
 
-		// Scan each row for table name and sequence name
-		if err := rows.Scan(&tableName, &seqName); err != nil {
-			return nil, err
+	for _, bucketName := range names {
+		seqID, err := currentSequence(connection.ctx, tx, bucketName)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the sequence of bucket %s: %w", bucketName, err)
 		}
 
-		if seqName != "" {
-			var seqValue sql.NullInt64
-
-			// Query to fetch the last value of the sequence
-			err := connection.DB.QueryRow(fmt.Sprintf("SELECT last_value FROM %s", seqName)).Scan(&seqValue)
-			if err != nil {
-				// Log the error and continue processing other tables
-				continue
-			}
-
-			// Add the sequence value to the metadata map if valid
-			if seqValue.Valid {
-				metadata[tableName] = seqValue.Int64
-			}
-		}
+		buckets[bucketName] = int(seqID)
 	}
 
-	return metadata, nil
+	return buckets, nil
 }
 
-
-// RestoreMetadata sets sequence/identity values for tables
+// RestoreMetadata will restore the sequence values for all buckets.
 func (connection *DbConnection) RestoreMetadata(s map[string]any) error {
-	for tableName, v := range s {
-		id, ok := v.(float64)
-		if !ok {
-			log.Error().Str("table", tableName).Msg("failed to restore metadata")
-			continue
-		}
+	return connection.UpdateTx(func(tx portainer.Transaction) error {
+		pgTx := tx.(*DbTransaction)
 
-		seqName := fmt.Sprintf("%s_id_seq", tableName)
-		_, err := connection.DB.Exec(fmt.Sprintf("ALTER SEQUENCE %s RESTART WITH %d", seqName, int64(id)))
-		if err != nil {
-			log.Error().Err(err).Str("table", tableName).Msg("failed to restore sequence")
+		for bucketName, v := range s {
+			id, ok := sequenceValue(v)
+			if !ok {
+				log.Error().Str("bucket", bucketName).Msg("failed to restore metadata to bucket, skipped")
+
+				continue
+			}
+
+			if err := pgTx.SetServiceName(bucketName, pgTx.keyKind(bucketName)); err != nil {
+				return err
+			}
+
+			if err := setSequence(pgTx.ctx, pgTx.tx, bucketName, id); err != nil {
+				return fmt.Errorf("failed to restore the sequence of bucket %s: %w", bucketName, err)
+			}
 		}
-	}
 
-	return nil
-}
\ No newline at end of file
+		return nil
+	})
+}
@@ -0,0 +1,486 @@
+package postgres
+
+import (
+	"bufio"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	portainer "github.com/portainer/portainer/api"
+
+	"github.com/lib/pq"
+	"github.com/rs/zerolog/log"
+	"github.com/segmentio/encoding/json"
+)
+
+// A dump is the logical backup of a PostgreSQL store. It is a stream of JSON
+// documents, one per line, each holding exactly one of the following records:
+//
+//	{"header":{"format":"portainer-postgres-dump","version":1,"schemaVersion":2,"encrypted":true,"keyFingerprint":"...","createdAt":"..."}}
+//	{"bucket":{"name":"endpoints","keyKind":"integer","sequence":3}}
+//	{"row":{"id":"1","data":{...}}}
+//	{"row":{"id":"2","payload":"<base64>"}}
+//	{"trailer":{"buckets":1,"rows":2}}
+//
+// The header comes first. Every bucket record is followed by the rows of that
+// bucket in key order. The data of a row is its plaintext JSON value, and the
+// payload holds its encrypted or raw value exactly as stored. Encrypted
+// payloads can only be restored with the key that was used to create them,
+// the header of an encrypted dump holds the fingerprint of that key.
+// The trailer counts the buckets and rows so that a truncated dump is
+// rejected instead of being partially restored.
+const (
+	DumpFormat        = "portainer-postgres-dump"
+	DumpFormatVersion = 1
+
+	dumpKeyKindInteger = "integer"
+	dumpKeyKindString  = "string"
+)
+
+var (
+	ErrInvalidDump     = errors.New("invalid PostgreSQL dump")
+	ErrTruncatedDump   = errors.New("the PostgreSQL dump is truncated")
+	ErrDumpKeyMismatch = errors.New("the PostgreSQL dump is encrypted with another secret")
+)
+
+// dumpKeyFingerprintLabel is authenticated by the key to compute its
+// fingerprint, so that the dumps do not hold a plain hash of the key
+const dumpKeyFingerprintLabel = "portainer-postgres-dump-key"
+
+type dumpHeader struct {
+	Format        string `json:"format"`
+	Version       int    `json:"version"`
+	SchemaVersion int    `json:"schemaVersion"`
+	Encrypted     bool   `json:"encrypted"`
+	// KeyFingerprint identifies the key of an encrypted dump
+	KeyFingerprint string    `json:"keyFingerprint,omitempty"`
+	CreatedAt      time.Time `json:"createdAt"`
+}
+
+type dumpBucket struct {
+	Name     string `json:"name"`
+	KeyKind  string `json:"keyKind"`
+	Sequence int64  `json:"sequence"`
+}
+
+type dumpRow struct {
+	ID      string          `json:"id"`
+	Data    json.RawMessage `json:"data,omitempty"`
+	Payload []byte          `json:"payload,omitempty"`
+}
+
+type dumpTrailer struct {
+	Buckets int `json:"buckets"`
+	Rows    int `json:"rows"`
+}
+
+type dumpRecord struct {
+	Header  *dumpHeader  `json:"header,omitempty"`
+	Bucket  *dumpBucket  `json:"bucket,omitempty"`
+	Row     *dumpRow     `json:"row,omitempty"`
+	Trailer *dumpTrailer `json:"trailer,omitempty"`
+}
+
+// keyFingerprint returns the fingerprint of an encryption key
+func keyFingerprint(key []byte) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(dumpKeyFingerprintLabel))
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func dumpKeyKind(keyKind portainer.KeyKind) string {
+	if keyKind == portainer.KeyKindString {
+		return dumpKeyKindString
+	}
+
+	return dumpKeyKindInteger
+}
+
+func parseDumpKeyKind(kind string) (portainer.KeyKind, error) {
+	switch kind {
+	case dumpKeyKindInteger:
+		return portainer.KeyKindInteger, nil
+	case dumpKeyKindString:
+		return portainer.KeyKindString, nil
+	}
+
+	return portainer.KeyKindInteger, fmt.Errorf("%w: unknown key kind %q", ErrInvalidDump, kind)
+}
+
+// dumpWriter writes the records of a dump and keeps count for the trailer
+type dumpWriter struct {
+	encoder *json.Encoder
+	buckets int
+	rows    int
+}
+
+func newDumpWriter(w io.Writer) *dumpWriter {
+	return &dumpWriter{encoder: json.NewEncoder(w)}
+}
+
+func (w *dumpWriter) header(header dumpHeader) error {
+	return w.encoder.Encode(dumpRecord{Header: &header})
+}
+
+func (w *dumpWriter) bucket(bucket dumpBucket) error {
+	w.buckets++
+
+	return w.encoder.Encode(dumpRecord{Bucket: &bucket})
+}
+
+func (w *dumpWriter) row(row dumpRow) error {
+	w.rows++
+
+	return w.encoder.Encode(dumpRecord{Row: &row})
+}
+
+func (w *dumpWriter) close() error {
+	return w.encoder.Encode(dumpRecord{Trailer: &dumpTrailer{Buckets: w.buckets, Rows: w.rows}})
+}
+
+// dumpVisitor receives the records of a dump as they are read
+type dumpVisitor interface {
+	header(header dumpHeader) error
+	bucket(bucket dumpBucket) error
+	row(row dumpRow) error
+}
+
+// readDump reads a dump and hands its records to visitor, validating the
+// structure of the dump along the way
+func readDump(r io.Reader, visitor dumpVisitor) error {
+	decoder := json.NewDecoder(bufio.NewReader(r))
+
+	var haveHeader, inBucket bool
+	var buckets, rows int
+
+	for {
+		var record dumpRecord
+		if err := decoder.Decode(&record); errors.Is(err, io.EOF) {
+			return ErrTruncatedDump
+		} else if err != nil {
+			return fmt.Errorf("%w: %w", ErrInvalidDump, err)
+		}
+
+		var err error
+		switch {
+		case record.Header != nil:
+			if haveHeader {
+				return fmt.Errorf("%w: duplicate header", ErrInvalidDump)
+			}
+
+			if record.Header.Format != DumpFormat || record.Header.Version != DumpFormatVersion {
+				return fmt.Errorf("%w: unsupported format %s version %d", ErrInvalidDump, record.Header.Format, record.Header.Version)
+			}
+
+			haveHeader = true
+			err = visitor.header(*record.Header)
+
+		case !haveHeader:
+			return fmt.Errorf("%w: missing header", ErrInvalidDump)
+
+		case record.Bucket != nil:
+			buckets++
+			inBucket = true
+			err = visitor.bucket(*record.Bucket)
+
+		case record.Row != nil:
+			if !inBucket {
+				return fmt.Errorf("%w: row outside of a bucket", ErrInvalidDump)
+			}
+
+			rows++
+			err = visitor.row(*record.Row)
+
+		case record.Trailer != nil:
+			if record.Trailer.Buckets != buckets || record.Trailer.Rows != rows {
+				return ErrTruncatedDump
+			}
+
+			if err := decoder.Decode(&dumpRecord{}); !errors.Is(err, io.EOF) {
+				return fmt.Errorf("%w: data after the trailer", ErrInvalidDump)
+			}
+
+			return nil
+
+		default:
+			return fmt.Errorf("%w: empty record", ErrInvalidDump)
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// BackupTo streams a dump of every bucket, with its sequence, to w. The dump
+// is read from a single REPEATABLE READ snapshot so that it is consistent
+// without closing the store. Sequences are not transactional, their values
+// can be ahead of the snapshot, which never leads to reusing an identifier.
+func (connection *DbConnection) BackupTo(w io.Writer) error {
+	if connection.DB == nil {
+		return ErrNoConnection
+	}
+
+	ctx := connection.ctx
+
+	tx, err := connection.DB.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// The schema version is read from the snapshot that is dumped
+	version, err := schemaVersion(ctx, tx)
+	if err != nil {
+		return err
+	}
+
+	buffered := bufio.NewWriter(w)
+	dump := newDumpWriter(buffered)
+
+	header := dumpHeader{
+		Format:        DumpFormat,
+		Version:       DumpFormatVersion,
+		SchemaVersion: version,
+		Encrypted:     connection.IsEncryptedStore(),
+		CreatedAt:     time.Now().UTC(),
+	}
+	if header.Encrypted {
+		header.KeyFingerprint = keyFingerprint(connection.getEncryptionKey())
+	}
+
+	if err := dump.header(header); err != nil {
+		return err
+	}
+
+	buckets, err := bucketTables(ctx, tx)
+	if err != nil {
+		return err
+	}
+
+	for _, bucketName := range buckets {
+		if err := dumpBucketRows(ctx, tx, dump, bucketName); err != nil {
+			return fmt.Errorf("failed to dump bucket %s: %w", bucketName, err)
+		}
+	}
+
+	if err := dump.close(); err != nil {
+		return err
+	}
+
+	if err := buffered.Flush(); err != nil {
+		return err
+	}
+
+	log.Debug().Int("buckets", dump.buckets).Int("rows", dump.rows).Msg("database dumped")
+
+	return nil
+}
+
+func dumpBucketRows(ctx context.Context, tx *sql.Tx, dump *dumpWriter, bucketName string) error {
+	keyKind, _, err := columnKeyKind(ctx, tx, bucketName)
+	if err != nil {
+		return err
+	}
+
+	sequence, err := currentSequence(ctx, tx, bucketName)
+	if err != nil {
+		return err
+	}
+
+	if err := dump.bucket(dumpBucket{Name: bucketName, KeyKind: dumpKeyKind(keyKind), Sequence: sequence}); err != nil {
+		return err
+	}
+
+	rows, err := tx.QueryContext(ctx, fmt.Sprintf("SELECT id::TEXT, data, payload FROM %s ORDER BY %s", pq.QuoteIdentifier(bucketName), orderByKey(keyKind)))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var row dumpRow
+		if err := rows.Scan(&row.ID, &row.Data, &row.Payload); err != nil {
+			return err
+		}
+
+		if err := dump.row(row); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+// RestoreFrom replaces the content of the store with the dump read from r. The
+// restore runs in a single transaction, every bucket is emptied and refilled,
+// so the store is either fully restored or left untouched. A plaintext dump
+// restored with a key loaded is encrypted in the same transaction.
+func (connection *DbConnection) RestoreFrom(r io.Reader) error {
+	if err := connection.MigrateSchema(); err != nil {
+		return err
+	}
+
+	tx, err := connection.DB.BeginTx(connection.ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	restorer := &dumpRestorer{conn: connection, ctx: connection.ctx, tx: tx, keyKinds: map[string]portainer.KeyKind{}}
+	defer restorer.closeStatement()
+
+	if err := readDump(r, restorer); err != nil {
+		return err
+	}
+
+	restorer.closeStatement()
+
+	// Keep the data encrypted at rest when the dump comes from an unencrypted store
+	encrypted := restorer.encrypted
+	if !encrypted && connection.EncryptionKey != nil {
+		if err := encryptTables(connection.ctx, tx, connection.EncryptionKey); err != nil {
+			return err
+		}
+
+		encrypted = true
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit the restore: %w", err)
+	}
+
+	// The key kinds of the restored buckets are only known once they are committed
+	for bucketName, keyKind := range restorer.keyKinds {
+		connection.keyKinds.Store(bucketName, keyKind)
+	}
+
+	connection.SetEncrypted(encrypted)
+
+	log.Info().Int("buckets", restorer.buckets).Int("rows", restorer.rows).Bool("encrypted", encrypted).Msg("database restored")
+
+	return nil
+}
+
+// dumpRestorer writes the records of a dump into a transaction
+type dumpRestorer struct {
+	conn      *DbConnection
+	ctx       context.Context
+	tx        *sql.Tx
+	insert    *sql.Stmt
+	encrypted bool
+	keyKinds  map[string]portainer.KeyKind
+	buckets   int
+	rows      int
+}
+
+func (r *dumpRestorer) header(header dumpHeader) error {
+	if header.SchemaVersion > LatestSchemaVersion() {
+		return fmt.Errorf("%w (dump version %d, supported version %d)", ErrSchemaTooNew, header.SchemaVersion, LatestSchemaVersion())
+	}
+
+	if header.Encrypted {
+		if r.conn.EncryptionKey == nil {
+			return fmt.Errorf("%w: no secret was loaded", ErrDumpKeyMismatch)
+		}
+
+		if !hmac.Equal([]byte(header.KeyFingerprint), []byte(keyFingerprint(r.conn.EncryptionKey))) {
+			return ErrDumpKeyMismatch
+		}
+	}
+
+	r.encrypted = header.Encrypted
+
+	buckets, err := bucketTables(r.ctx, r.tx)
+	if err != nil {
+		return err
+	}
+
+	if len(buckets) > 0 {
+		tables := make([]string, len(buckets))
+		for i, bucketName := range buckets {
+			tables[i] = pq.QuoteIdentifier(bucketName)
+		}
+
+		if _, err := r.tx.ExecContext(r.ctx, "TRUNCATE "+strings.Join(tables, ", ")); err != nil {
+			return fmt.Errorf("failed to empty the buckets: %w", err)
+		}
+
+		// The other instances sharing the database drop what they derived from the buckets
+		for _, bucketName := range buckets {
+			if err := notifyBucketChanges(r.ctx, r.tx, r.conn.origin, bucketName, nil); err != nil {
+				return err
+			}
+		}
+	}
+
+	marker, stale := UnencryptedMetadataTable, EncryptedMetadataTable
+	if header.Encrypted {
+		marker, stale = stale, marker
+	}
+
+	if _, err := r.tx.ExecContext(r.ctx, fmt.Sprintf("DROP TABLE IF EXISTS %s", pq.QuoteIdentifier(stale))); err != nil {
+		return err
+	}
+
+	return createMarker(r.ctx, r.tx, marker)
+}
+
+func (r *dumpRestorer) bucket(bucket dumpBucket) error {
+	keyKind, err := parseDumpKeyKind(bucket.KeyKind)
+	if err != nil {
+		return err
+	}
+
+	if err := createBucket(r.ctx, r.tx, bucket.Name, keyKind); err != nil {
+		return fmt.Errorf("failed to create bucket %s: %w", bucket.Name, err)
+	}
+
+	r.keyKinds[bucket.Name] = keyKind
+
+	if err := setSequence(r.ctx, r.tx, bucket.Name, bucket.Sequence); err != nil {
+		return fmt.Errorf("failed to restore the sequence of bucket %s: %w", bucket.Name, err)
+	}
+
+	r.closeStatement()
+
+	r.insert, err = r.tx.PrepareContext(r.ctx, fmt.Sprintf("INSERT INTO %s (id, data, payload) VALUES ($1, $2, $3)", pq.QuoteIdentifier(bucket.Name)))
+	if err != nil {
+		return err
+	}
+
+	r.buckets++
+
+	return nil
+}
+
+func (r *dumpRestorer) row(row dumpRow) error {
+	// A nil []byte is sent as NULL, a string is required for the JSONB column
+	var data any
+	if len(row.Data) > 0 && string(row.Data) != "null" {
+		data = string(row.Data)
+	}
+
+	if _, err := r.insert.ExecContext(r.ctx, row.ID, data, row.Payload); err != nil {
+		return fmt.Errorf("failed to restore row %s: %w", row.ID, err)
+	}
+
+	r.rows++
+
+	return nil
+}
+
+func (r *dumpRestorer) closeStatement() {
+	if r.insert != nil {
+		r.insert.Close()
+		r.insert = nil
+	}
+}
@@ -0,0 +1,108 @@
+package postgres
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type recordingVisitor struct {
+	headers []dumpHeader
+	buckets []dumpBucket
+	rows    []dumpRow
+}
+
+func (v *recordingVisitor) header(header dumpHeader) error {
+	v.headers = append(v.headers, header)
+	return nil
+}
+
+func (v *recordingVisitor) bucket(bucket dumpBucket) error {
+	v.buckets = append(v.buckets, bucket)
+	return nil
+}
+
+func (v *recordingVisitor) row(row dumpRow) error {
+	v.rows = append(v.rows, row)
+	return nil
+}
+
+func writeTestDump(t *testing.T, withTrailer bool) []byte {
+	var buf bytes.Buffer
+
+	dump := newDumpWriter(&buf)
+	require.NoError(t, dump.header(dumpHeader{Format: DumpFormat, Version: DumpFormatVersion, SchemaVersion: 2}))
+	require.NoError(t, dump.bucket(dumpBucket{Name: "endpoints", KeyKind: dumpKeyKindInteger, Sequence: 2}))
+	require.NoError(t, dump.row(dumpRow{ID: "1", Data: []byte(`{"Id":1}`)}))
+	require.NoError(t, dump.row(dumpRow{ID: "2", Payload: []byte{0, 1, 2}}))
+	require.NoError(t, dump.bucket(dumpBucket{Name: "version", KeyKind: dumpKeyKindString}))
+	require.NoError(t, dump.row(dumpRow{ID: "VERSION", Data: []byte(`{"SchemaVersion":"2.21.0"}`)}))
+
+	if withTrailer {
+		require.NoError(t, dump.close())
+	}
+
+	return buf.Bytes()
+}
+
+func Test_DumpRoundTrip(t *testing.T) {
+	is := assert.New(t)
+
+	visitor := &recordingVisitor{}
+	require.NoError(t, readDump(bytes.NewReader(writeTestDump(t, true)), visitor))
+
+	is.Len(visitor.headers, 1)
+	is.Equal(2, visitor.headers[0].SchemaVersion)
+	is.Equal([]dumpBucket{
+		{Name: "endpoints", KeyKind: dumpKeyKindInteger, Sequence: 2},
+		{Name: "version", KeyKind: dumpKeyKindString},
+	}, visitor.buckets)
+	is.Len(visitor.rows, 3)
+	is.JSONEq(`{"Id":1}`, string(visitor.rows[0].Data))
+	is.Equal([]byte{0, 1, 2}, visitor.rows[1].Payload)
+	is.Equal("VERSION", visitor.rows[2].ID)
+}
+
+func Test_ReadDumpRejectsInvalidDumps(t *testing.T) {
+	complete := writeTestDump(t, true)
+
+	tests := []struct {
+		name string
+		dump []byte
+		err  error
+	}{
+		{name: "empty", dump: nil, err: ErrTruncatedDump},
+		{name: "missing trailer", dump: writeTestDump(t, false), err: ErrTruncatedDump},
+		{name: "wrong trailer counts", dump: append(writeTestDump(t, false), []byte(`{"trailer":{"buckets":2,"rows":1}}`)...), err: ErrTruncatedDump},
+		{name: "data after the trailer", dump: append(bytes.Clone(complete), []byte(`{"row":{"id":"3"}}`)...), err: ErrInvalidDump},
+		{name: "missing header", dump: []byte(`{"bucket":{"name":"endpoints","keyKind":"integer"}}`), err: ErrInvalidDump},
+		{name: "unknown format", dump: []byte(`{"header":{"format":"other","version":1}}`), err: ErrInvalidDump},
+		{name: "row outside of a bucket", dump: []byte(`{"header":{"format":"portainer-postgres-dump","version":1}}` + "\n" + `{"row":{"id":"1"}}`), err: ErrInvalidDump},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := readDump(bytes.NewReader(test.dump), &recordingVisitor{})
+			require.ErrorIs(t, err, test.err)
+		})
+	}
+}
+
+func Test_RestoreRejectsDumpsEncryptedWithAnotherKey(t *testing.T) {
+	key := bytes.Repeat([]byte{1}, 32)
+	otherKey := bytes.Repeat([]byte{2}, 32)
+
+	header := dumpHeader{Format: DumpFormat, Version: DumpFormatVersion, Encrypted: true, KeyFingerprint: keyFingerprint(otherKey)}
+
+	restorer := &dumpRestorer{conn: &DbConnection{EncryptionKey: key}}
+	require.ErrorIs(t, restorer.header(header), ErrDumpKeyMismatch)
+
+	restorer = &dumpRestorer{conn: &DbConnection{}}
+	require.ErrorIs(t, restorer.header(header), ErrDumpKeyMismatch)
+
+	header.KeyFingerprint = ""
+	restorer = &dumpRestorer{conn: &DbConnection{EncryptionKey: key}}
+	require.ErrorIs(t, restorer.header(header), ErrDumpKeyMismatch)
+}
@@ -0,0 +1,128 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/lib/pq"
+	"github.com/rs/zerolog/log"
+)
+
+var errNoEncryptionKey = errors.New("unable to encrypt the store, no secret was loaded")
+
+// EncryptStore encrypts every plaintext row of the store with the loaded key
+// and flags the store as encrypted. It is the PostgreSQL counterpart of the
+// BoltDB export/import encryption and runs in a single transaction, so a
+// failure leaves the store untouched.
+func (connection *DbConnection) EncryptStore() error {
+	key := connection.EncryptionKey
+	if key == nil {
+		return errNoEncryptionKey
+	}
+
+	// Legacy tables need the payload column before they can be encrypted
+	if err := connection.MigrateSchema(); err != nil {
+		return err
+	}
+
+	log.Info().Msg("encrypting database")
+
+	tx, err := connection.DB.BeginTx(connection.ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := encryptTables(connection.ctx, tx, key); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit the encrypted store: %w", err)
+	}
+
+	connection.SetEncrypted(true)
+
+	log.Info().Msg("database successfully encrypted")
+
+	return nil
+}
+
+// encryptTables encrypts every plaintext row of the buckets with key and
+// flags the store as encrypted within tx
+func encryptTables(ctx context.Context, tx *sql.Tx, key []byte) error {
+	buckets, err := bucketTables(ctx, tx)
+	if err != nil {
+		return err
+	}
+
+	for _, bucketName := range buckets {
+		count, err := encryptBucket(ctx, tx, bucketName, key)
+		if err != nil {
+			return fmt.Errorf("failed to encrypt bucket %s: %w", bucketName, err)
+		}
+
+		log.Debug().Str("bucket", bucketName).Int("rows", count).Msg("bucket encrypted")
+	}
+
+	if _, err := tx.ExecContext(ctx, fmt.Sprintf("DROP TABLE IF EXISTS %s", pq.QuoteIdentifier(UnencryptedMetadataTable))); err != nil {
+		return err
+	}
+
+	return createMarker(ctx, tx, EncryptedMetadataTable)
+}
+
+func encryptBucket(ctx context.Context, tx *sql.Tx, bucketName string, key []byte) (int, error) {
+	keyKind, _, err := columnKeyKind(ctx, tx, bucketName)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := createBucket(ctx, tx, bucketName, keyKind); err != nil {
+		return 0, err
+	}
+
+	table := pq.QuoteIdentifier(bucketName)
+
+	rows, err := tx.QueryContext(ctx, fmt.Sprintf("SELECT id, data, payload FROM %s", table))
+	if err != nil {
+		return 0, err
+	}
+
+	encrypted := make(map[any][]byte)
+	for rows.Next() {
+		var id any
+		var data, payload []byte
+		if err := rows.Scan(&id, &data, &payload); err != nil {
+			rows.Close()
+			return 0, err
+		}
+
+		// In an unencrypted store the payload column only holds raw strings
+		plaintext := data
+		if payload != nil {
+			plaintext = payload
+		}
+
+		if encrypted[id], err = encrypt(plaintext, key); err != nil {
+			rows.Close()
+			return 0, err
+		}
+	}
+
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	query := fmt.Sprintf("UPDATE %s SET data = NULL, payload = $1 WHERE id = $2", table)
+	for id, payload := range encrypted {
+		if _, err := tx.ExecContext(ctx, query, payload, id); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(encrypted), nil
+}
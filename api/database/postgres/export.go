@@ -1,161 +1,209 @@
 package postgres
 
 import (
-	"encoding/json"
+	"database/sql"
 	"fmt"
+	"strconv"
 
+	portainer "github.com/portainer/portainer/api"
+	"github.com/portainer/portainer/api/database/models"
+
+	"github.com/lib/pq"
 	"github.com/rs/zerolog/log"
+	"github.com/segmentio/encoding/json"
 )
 
-// backupMetadata retrieves metadata about tables in the PostgreSQL database
-func (c *DbConnection) backupMetadata() (map[string]any, error) {
-	query := `
-		SELECT 
-			table_name, 
-			(
-				SELECT COUNT(*) 
-				FROM information_schema.columns 
-				WHERE table_schema = 'public' AND table_name = t.table_name
-			) as column_count
-		FROM information_schema.tables 
-		WHERE table_schema = 'public'
-	`
-
-	rows, err := c.DB.Query(query)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
+const versionBucketName = "version"
 
-	buckets := make(map[string]any)
-	for rows.Next() {
-		var tableName string
-		var columnCount int
-		if err := rows.Scan(&tableName, &columnCount); err != nil {
-			return nil, err
-		}
-		buckets[tableName] = columnCount
-	}
-
-	return buckets, nil
-}
+// ExportJSON creates a JSON representation of every bucket of the store, in
+// the format of the BoltDB ExportJSON. You can include the database's metadata
+// or ignore it. databasePath is not used, the data is read from the server, it
+// is kept so that both connections share the same signature.
+func (c *DbConnection) ExportJSON(databasePath string, metadata bool) ([]byte, error) {
+	log.Debug().Msg("exportJson")
 
-// ExportJSON creates a JSON representation from the PostgreSQL database
-func (c *DbConnection) ExportJSON(metadata bool) ([]byte, error) {
-	log.Debug().Msg("Exporting database to JSON")
+	if c.DB == nil {
+		return []byte("{}"), ErrNoConnection
+	}
 
 	backup := make(map[string]any)
-
-	// Export metadata if requested
 	if metadata {
-		meta, err := c.backupMetadata()
+		meta, err := c.BackupMetadata()
 		if err != nil {
 			log.Error().Err(err).Msg("failed exporting metadata")
 		}
+
 		backup["__metadata"] = meta
 	}
 
-	// List of tables to export
-	tables := []string{
-		"version",
-		"ssl",
-		"settings", 
-		"tunnel_server",
-		// Add other tables you want to export
+	tx, err := c.DB.BeginTx(c.ctx, nil)
+	if err != nil {
+		return []byte("{}"), err
+	}
+	defer tx.Rollback()
+
+	buckets, err := bucketTables(c.ctx, tx)
+	if err != nil {
+		return []byte("{}"), err
 	}
 
-	for _, table := range tables {
-		data, err := c.exportTable(table)
+	for _, bucketName := range buckets {
+		keyKind, _, err := columnKeyKind(c.ctx, tx, bucketName)
 		if err != nil {
-			log.Error().
-				Str("table", table).
-				Err(err).
-				Msg("failed to export table")
-			continue
+			return []byte("{}"), err
 		}
 
-		// Special handling for specific tables
-		switch table {
-		case "version", "ssl", "settings", "tunnel_server":
-			if len(data) > 0 {
-				backup[table] = data[0]
-			} else {
-				backup[table] = nil
+		rows, err := tx.QueryContext(c.ctx, fmt.Sprintf("SELECT id::TEXT, data, payload FROM %s ORDER BY %s", pq.QuoteIdentifier(bucketName), orderByKey(keyKind)))
+		if err != nil {
+			return []byte("{}"), err
+		}
+
+		var list []any
+		version := make(map[string]string)
+		for rows.Next() {
+			var key string
+			var data, payload []byte
+			if err := rows.Scan(&key, &data, &payload); err != nil {
+				rows.Close()
+				return []byte("{}"), err
+			}
+
+			if bucketName == versionBucketName {
+				version[key] = c.exportVersionValue(key, data, payload)
+				continue
 			}
-		default:
-			if len(data) > 0 {
-				backup[table] = data
+
+			var obj any
+			if err := c.decodeRow(data, payload, &obj); err != nil {
+				log.Error().
+					Str("bucket", bucketName).
+					Str("key", key).
+					Err(err).
+					Msg("failed to unmarshal")
+
+				obj = payload
 			}
+
+			list = append(list, obj)
+		}
+
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return []byte("{}"), err
+		}
+
+		if bucketName == versionBucketName {
+			backup[bucketName] = version
+			continue
+		}
+
+		if len(list) == 0 {
+			continue
 		}
+
+		if bucketName == "ssl" ||
+			bucketName == "settings" ||
+			bucketName == "tunnel_server" {
+			backup[bucketName] = list[0]
+			continue
+		}
+
+		backup[bucketName] = list
 	}
 
 	return json.MarshalIndent(backup, "", "  ")
 }
 
-// exportTable retrieves all rows from a given table
-func (c *DbConnection) exportTable(tableName string) ([]any, error) {
-	query := fmt.Sprintf("SELECT * FROM %s", tableName)
-	
-	rows, err := c.DB.Query(query)
+// exportVersionValue returns a value of the version bucket as the raw string
+// BoltDB exports. JSONB does not keep the key order of the stored document, so
+// the version object is encoded again from its model.
+func (c *DbConnection) exportVersionValue(key string, data, payload []byte) string {
+	if key == "VERSION" {
+		var v models.Version
+		if err := c.decodeRow(data, payload, &v); err == nil {
+			if raw, err := marshal(v); err == nil {
+				return string(raw)
+			}
+		}
+	}
+
+	if payload == nil {
+		return string(data)
+	}
+
+	var raw string
+	if err := c.UnmarshalObject(payload, &raw); err != nil {
+		return string(payload)
+	}
+
+	return raw
+}
+
+// BucketNames returns the names of the buckets of the store
+func (c *DbConnection) BucketNames() ([]string, error) {
+	if c.DB == nil {
+		return nil, ErrNoConnection
+	}
+
+	return bucketTables(c.ctx, c.DB)
+}
+
+// ForEachRaw calls fn for every object of a bucket, in key order, with its key
+// encoded as on BoltDB and its decrypted value
+func (c *DbConnection) ForEachRaw(bucketName string, fn func(key, value []byte) error) error {
+	if c.DB == nil {
+		return ErrNoConnection
+	}
+
+	tx, err := c.DB.BeginTx(c.ctx, &sql.TxOptions{ReadOnly: true})
 	if err != nil {
-		return nil, err
+		return err
 	}
-	defer rows.Close()
+	defer tx.Rollback()
 
-	// Get column names
-	columns, err := rows.Columns()
+	keyKind, _, err := columnKeyKind(c.ctx, tx, bucketName)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	var results []any
+	rows, err := tx.QueryContext(c.ctx, fmt.Sprintf("SELECT id::TEXT, data, payload FROM %s ORDER BY %s", pq.QuoteIdentifier(bucketName), orderByKey(keyKind)))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
 
-	// Prepare to scan rows
 	for rows.Next() {
-		// Create a slice of empty interfaces to hold the row data
-		rowData := make([]interface{}, len(columns))
-		rowPtrs := make([]interface{}, len(columns))
-		for i := range columns {
-			rowPtrs[i] = &rowData[i]
+		var id string
+		var data, payload []byte
+		if err := rows.Scan(&id, &data, &payload); err != nil {
+			return err
 		}
 
-		// Scan the row
-		if err := rows.Scan(rowPtrs...); err != nil {
-			return nil, err
+		key := []byte(id)
+		if keyKind == portainer.KeyKindInteger {
+			n, err := strconv.ParseInt(id, 10, 64)
+			if err != nil {
+				return fmt.Errorf("%w (bucket=%s, key=%s)", ErrInvalidKey, bucketName, id)
+			}
+
+			key = c.ConvertToKey(int(n))
 		}
 
-		// Convert row to a map
-		rowMap := make(map[string]interface{})
-		for i, colName := range columns {
-			val := rowData[i]
-			
-			// Handle potential nil values
-			if val == nil {
-				rowMap[colName] = nil
-				continue
-			}
+		value := data
+		if payload != nil {
+			value = payload
 
-			// Special handling for byte slices (potentially encrypted)
-			if byteVal, ok := val.([]byte); ok {
-				var obj any
-				err := c.UnmarshalObject(byteVal, &obj)
-				if err == nil {
-					rowMap[colName] = obj
-				} else {
-					// If unmarshaling fails, keep original byte value
-					rowMap[colName] = string(byteVal)
+			if encryptionKey := c.getEncryptionKey(); encryptionKey != nil {
+				if value, err = decrypt(payload, encryptionKey); err != nil {
+					return fmt.Errorf("failed decrypting object %s in bucket %s: %w", id, bucketName, err)
 				}
-			} else {
-				rowMap[colName] = val
 			}
 		}
 
-		results = append(results, rowMap)
-	}
-
-	if err = rows.Err(); err != nil {
-		return nil, err
+		if err := fn(key, value); err != nil {
+			return err
+		}
 	}
 
-	return results, nil
-}
\ No newline at end of file
+	return rows.Err()
+}
@@ -0,0 +1,172 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// DefaultHealthCheckInterval is how often the primary server is probed
+	DefaultHealthCheckInterval = 10 * time.Second
+
+	healthCheckTimeout = 5 * time.Second
+	// reconnectAttempts bounds the fast attempts made once the server stops
+	// answering, it is then probed at the regular interval
+	reconnectAttempts = 8
+	reconnectBackoff  = 500 * time.Millisecond
+	maxReconnectDelay = 30 * time.Second
+)
+
+// DatabaseHealth describes whether the primary server answers
+type DatabaseHealth struct {
+	Healthy bool `json:"healthy"`
+	// Since is when the server started or stopped answering
+	Since     time.Time `json:"since"`
+	LastCheck time.Time `json:"lastCheck"`
+	// Error is the last error returned by the server while it is unhealthy
+	Error string `json:"error,omitempty"`
+}
+
+type healthState struct {
+	mu        sync.Mutex
+	state     DatabaseHealth
+	listeners []func(healthy bool)
+}
+
+// Health returns the result of the last probe of the primary server
+func (connection *DbConnection) Health() DatabaseHealth {
+	connection.health.mu.Lock()
+	defer connection.health.mu.Unlock()
+
+	return connection.health.state
+}
+
+// OnHealthChange registers fn to be called when the primary server stops or
+// starts answering again. fn is called by the probe, it must not block.
+func (connection *DbConnection) OnHealthChange(fn func(healthy bool)) {
+	connection.health.mu.Lock()
+	defer connection.health.mu.Unlock()
+
+	connection.health.listeners = append(connection.health.listeners, fn)
+}
+
+// StartHealthProbe probes the primary server at the given interval until the
+// connection is closed. The pool is reset when the server stops answering so
+// that the connections to a failed server are not reused.
+func (connection *DbConnection) StartHealthProbe(interval time.Duration) error {
+	if err := connection.connect(); err != nil {
+		return err
+	}
+
+	if interval <= 0 {
+		interval = DefaultHealthCheckInterval
+	}
+
+	go connection.monitorHealth(connection.ctx, connection.DB, interval)
+
+	return nil
+}
+
+func (connection *DbConnection) monitorHealth(ctx context.Context, db *sql.DB, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if connection.probe(ctx, db) != nil {
+				connection.reconnect(ctx, db)
+			}
+		}
+	}
+}
+
+// reconnect probes the server with an increasing delay until it answers
+// again or the attempts are exhausted
+func (connection *DbConnection) reconnect(ctx context.Context, db *sql.DB) {
+	for attempt := 1; attempt <= reconnectAttempts; attempt++ {
+		if sleepCtx(ctx, reconnectDelay(attempt)) != nil {
+			return
+		}
+
+		connection.resetPool(db)
+
+		if connection.probe(ctx, db) == nil {
+			log.Info().Int("attempt", attempt).Msg("reconnected to the PostgreSQL server")
+
+			return
+		}
+	}
+
+	log.Error().Int("attempts", reconnectAttempts).Msg("unable to reconnect to the PostgreSQL server, it will be probed again at the regular interval")
+}
+
+func reconnectDelay(attempt int) time.Duration {
+	return min(reconnectBackoff<<(attempt-1), maxReconnectDelay)
+}
+
+// probe pings the primary server and records the result
+func (connection *DbConnection) probe(ctx context.Context, db *sql.DB) error {
+	pingCtx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+	defer cancel()
+
+	err := db.PingContext(pingCtx)
+	if ctx.Err() != nil {
+		// The connection is being closed
+		return nil
+	}
+
+	connection.setHealth(err)
+
+	return err
+}
+
+// resetPool closes the idle connections, they may target the failed server
+func (connection *DbConnection) resetPool(db *sql.DB) {
+	db.SetMaxIdleConns(0)
+	connection.applyPoolConfig(db)
+}
+
+func (connection *DbConnection) setHealth(err error) {
+	h := &connection.health
+
+	h.mu.Lock()
+
+	now := time.Now()
+	changed := h.state.Healthy != (err == nil)
+
+	h.state.LastCheck = now
+	h.state.Healthy = err == nil
+	h.state.Error = ""
+	if err != nil {
+		h.state.Error = err.Error()
+	}
+
+	if changed {
+		h.state.Since = now
+	}
+
+	listeners := h.listeners
+
+	h.mu.Unlock()
+
+	if !changed {
+		return
+	}
+
+	if err != nil {
+		log.Error().Err(err).Msg("the PostgreSQL server is unreachable")
+	} else {
+		log.Info().Msg("the PostgreSQL server is reachable again")
+	}
+
+	for _, fn := range listeners {
+		fn(err == nil)
+	}
+}
@@ -0,0 +1,77 @@
+package postgres
+
+import (
+	"fmt"
+
+	portainer "github.com/portainer/portainer/api"
+
+	"github.com/lib/pq"
+)
+
+// HeartbeatsTable holds the last check-in of the environments. The heartbeats
+// are written far more often than the environments, they are kept out of the
+// endpoints bucket.
+const HeartbeatsTable = "endpoint_heartbeats"
+
+// SaveHeartbeats records the last check-in of several environments in a
+// single statement, a check-in never replaces a more recent one
+func (connection *DbConnection) SaveHeartbeats(heartbeats map[portainer.EndpointID]int64) error {
+	if connection.DB == nil {
+		return ErrNoConnection
+	}
+
+	ids := make([]int64, 0, len(heartbeats))
+	checkIns := make([]int64, 0, len(heartbeats))
+	for endpointID, checkIn := range heartbeats {
+		ids = append(ids, int64(endpointID))
+		checkIns = append(checkIns, checkIn)
+	}
+
+	query := fmt.Sprintf(`INSERT INTO %[1]s (endpoint_id, last_check_in)
+		SELECT * FROM unnest($1::BIGINT[], $2::BIGINT[])
+		ON CONFLICT (endpoint_id) DO UPDATE SET last_check_in = GREATEST(%[1]s.last_check_in, EXCLUDED.last_check_in)`, HeartbeatsTable)
+
+	if _, err := connection.DB.ExecContext(connection.ctx, query, pq.Array(ids), pq.Array(checkIns)); err != nil {
+		return fmt.Errorf("failed to save the heartbeats: %w", err)
+	}
+
+	return nil
+}
+
+// LoadHeartbeats returns the last check-in of every environment
+func (connection *DbConnection) LoadHeartbeats() (map[portainer.EndpointID]int64, error) {
+	if connection.DB == nil {
+		return nil, ErrNoConnection
+	}
+
+	rows, err := connection.DB.QueryContext(connection.ctx, fmt.Sprintf("SELECT endpoint_id, last_check_in FROM %s", HeartbeatsTable))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load the heartbeats: %w", err)
+	}
+	defer rows.Close()
+
+	heartbeats := make(map[portainer.EndpointID]int64)
+	for rows.Next() {
+		var endpointID, checkIn int64
+		if err := rows.Scan(&endpointID, &checkIn); err != nil {
+			return nil, err
+		}
+
+		heartbeats[portainer.EndpointID(endpointID)] = checkIn
+	}
+
+	return heartbeats, rows.Err()
+}
+
+// DeleteHeartbeat removes the heartbeat of a deleted environment
+func (connection *DbConnection) DeleteHeartbeat(endpointID portainer.EndpointID) error {
+	if connection.DB == nil {
+		return ErrNoConnection
+	}
+
+	if _, err := connection.DB.ExecContext(connection.ctx, fmt.Sprintf("DELETE FROM %s WHERE endpoint_id = $1", HeartbeatsTable), int64(endpointID)); err != nil {
+		return fmt.Errorf("failed to delete the heartbeat: %w", err)
+	}
+
+	return nil
+}
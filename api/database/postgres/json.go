@@ -15,33 +15,23 @@ var errEncryptedStringTooShort = errors.New("encrypted string too short")
 
 // MarshalObject encodes an object to binary format for PostgreSQL storage
 func (connection *DbConnection) MarshalObject(object any) ([]byte, error) {
-	buf := &bytes.Buffer{}
-
-	// Special case for VERSION bucket
-	if v, ok := object.(string); ok {
-		buf.WriteString(v)
-	} else {
-		enc := json.NewEncoder(buf)
-		enc.SetSortMapKeys(false)
-		enc.SetAppendNewline(false)
-
-		if err := enc.Encode(object); err != nil {
-			return nil, err
-		}
+	data, err := marshal(object)
+	if err != nil {
+		return nil, err
 	}
 
 	// Check if encryption is enabled
 	if connection.getEncryptionKey() == nil {
-		return buf.Bytes(), nil
+		return data, nil
 	}
 
-	return encrypt(buf.Bytes(), connection.getEncryptionKey())
+	return encrypt(data, connection.getEncryptionKey())
 }
 
 // UnmarshalObject decodes an object from binary data for PostgreSQL
 func (connection *DbConnection) UnmarshalObject(data []byte, object any) error {
 	var err error
-	
+
 	// Decrypt if encryption key is present
 	if connection.getEncryptionKey() != nil {
 		data, err = decrypt(data, connection.getEncryptionKey())
@@ -50,18 +40,68 @@ func (connection *DbConnection) UnmarshalObject(data []byte, object any) error {
 		}
 	}
 
-	// Handle JSON unmarshaling
+	return unmarshal(data, object)
+}
+
+// encodeRow marshals object into the columns of a bucket row. Plaintext JSON
+// goes to the queryable data column while encrypted values and raw strings
+// that are not valid JSON go to the payload column.
+func (connection *DbConnection) encodeRow(object any) (data, payload []byte, err error) {
+	raw, err := connection.MarshalObject(object)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if connection.getEncryptionKey() != nil || !json.Valid(raw) {
+		return nil, raw, nil
+	}
+
+	return raw, nil, nil
+}
+
+// decodeRow unmarshals a bucket row into object. Only the payload column can
+// hold encrypted content, the data column is always plaintext JSON.
+func (connection *DbConnection) decodeRow(data, payload []byte, object any) error {
+	if payload != nil {
+		return connection.UnmarshalObject(payload, object)
+	}
+
+	return unmarshal(data, object)
+}
+
+func marshal(object any) ([]byte, error) {
+	buf := &bytes.Buffer{}
+
+	// Special case for VERSION bucket
+	if v, ok := object.(string); ok {
+		buf.WriteString(v)
+
+		return buf.Bytes(), nil
+	}
+
+	enc := json.NewEncoder(buf)
+	enc.SetSortMapKeys(false)
+	enc.SetAppendNewline(false)
+
+	if err := enc.Encode(object); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func unmarshal(data []byte, object any) error {
 	if e := json.Unmarshal(data, object); e != nil {
 		// Special case for VERSION bucket
 		s, ok := object.(*string)
 		if !ok {
-			return errors.Wrap(err, e.Error())
+			return e
 		}
 
 		*s = string(data)
 	}
 
-	return err
+	return nil
 }
 
 // encrypt performs AES-GCM encryption
@@ -114,4 +154,4 @@ func decrypt(encrypted []byte, passphrase []byte) (plaintextByte []byte, err err
 	}
 
 	return plaintextByte, err
-}
\ No newline at end of file
+}
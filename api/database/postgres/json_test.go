@@ -0,0 +1,65 @@
+package postgres
+
+import (
+	"crypto/sha256"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testObject struct {
+	ID       int
+	Password string
+}
+
+func secretToEncryptionKey(passphrase string) []byte {
+	hash := sha256.Sum256([]byte(passphrase))
+	return hash[:]
+}
+
+func Test_EncodeRowUnencrypted(t *testing.T) {
+	is := assert.New(t)
+
+	conn := DbConnection{}
+
+	data, payload, err := conn.encodeRow(testObject{ID: 1, Password: "secret"})
+	require.NoError(t, err)
+	is.Nil(payload)
+	is.JSONEq(`{"ID":1,"Password":"secret"}`, string(data))
+
+	var object testObject
+	require.NoError(t, conn.decodeRow(data, payload, &object))
+	is.Equal(testObject{ID: 1, Password: "secret"}, object)
+
+	// raw strings such as the legacy VERSION values are not valid JSON
+	data, payload, err = conn.encodeRow("2.21.0")
+	require.NoError(t, err)
+	is.Nil(data)
+	is.Equal("2.21.0", string(payload))
+
+	var version string
+	require.NoError(t, conn.decodeRow(data, payload, &version))
+	is.Equal("2.21.0", version)
+}
+
+func Test_EncodeRowEncrypted(t *testing.T) {
+	is := assert.New(t)
+
+	conn := DbConnection{EncryptionKey: secretToEncryptionKey("my secret key")}
+	conn.SetEncrypted(true)
+
+	data, payload, err := conn.encodeRow(testObject{ID: 1, Password: "secret"})
+	require.NoError(t, err)
+	is.Nil(data)
+	is.NotContains(string(payload), "secret")
+
+	var object testObject
+	require.NoError(t, conn.decodeRow(data, payload, &object))
+	is.Equal(testObject{ID: 1, Password: "secret"}, object)
+
+	// rows that have not been migrated yet are still readable
+	object = testObject{}
+	require.NoError(t, conn.decodeRow([]byte(`{"ID":2,"Password":"plain"}`), nil, &object))
+	is.Equal(testObject{ID: 2, Password: "plain"}, object)
+}
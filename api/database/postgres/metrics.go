@@ -0,0 +1,37 @@
+package postgres
+
+import "sync/atomic"
+
+// TransactionMetrics counts the transactions that were executed again, since
+// the connection was created
+type TransactionMetrics struct {
+	// SerializationFailures counts the write transactions retried after a
+	// serialization failure (SQLSTATE 40001)
+	SerializationFailures uint64 `json:"serializationFailures"`
+	// Deadlocks counts the write transactions retried after a deadlock (SQLSTATE 40P01)
+	Deadlocks uint64 `json:"deadlocks"`
+	// Conflicts counts the UpdateObjectFunc calls retried after another
+	// transaction updated the object
+	Conflicts uint64 `json:"conflicts"`
+	// Exhausted counts the transactions that failed once every retry was used
+	Exhausted uint64 `json:"exhausted"`
+}
+
+type txMetrics struct {
+	serializationFailures atomic.Uint64
+	deadlocks             atomic.Uint64
+	conflicts             atomic.Uint64
+	exhausted             atomic.Uint64
+}
+
+// TransactionMetrics returns the retry counters of the connection
+func (connection *DbConnection) TransactionMetrics() TransactionMetrics {
+	m := &connection.txMetrics
+
+	return TransactionMetrics{
+		SerializationFailures: m.serializationFailures.Load(),
+		Deadlocks:             m.deadlocks.Load(),
+		Conflicts:             m.conflicts.Load(),
+		Exhausted:             m.exhausted.Load(),
+	}
+}
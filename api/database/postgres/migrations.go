@@ -0,0 +1,291 @@
+package postgres
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	SchemaMigrationsTable = "schema_migrations"
+
+	// schemaMigrationLockID identifies the advisory lock held while the schema
+	// is migrated, so that concurrent Portainer starts apply migrations once
+	schemaMigrationLockID int64 = 0x504f52545f534348
+)
+
+var (
+	ErrSchemaChecksumMismatch = errors.New("an applied schema migration does not match the one shipped with this version of Portainer")
+	ErrSchemaTooNew           = errors.New("the database schema is newer than the one supported by this version of Portainer")
+)
+
+// schemaMigration is a versioned change of the PostgreSQL schema. Migrations
+// are applied in order, each in its own transaction, and their checksum is
+// recorded so that a migration edited after release is detected instead of
+// being silently skipped. Never modify a released migration, add a new one.
+type schemaMigration struct {
+	Version    int
+	Name       string
+	Statements string
+}
+
+// Checksum returns the hex encoded SHA-256 of the migration statements
+func (m schemaMigration) Checksum() string {
+	sum := sha256.Sum256([]byte(m.Statements))
+
+	return hex.EncodeToString(sum[:])
+}
+
+var schemaMigrations = []schemaMigration{
+	{
+		Version: 1,
+		Name:    "bucket_payload_column",
+		Statements: `DO $$
+DECLARE t record;
+BEGIN
+	FOR t IN SELECT table_name FROM information_schema.columns
+		WHERE table_schema = current_schema() AND column_name = 'data'
+	LOOP
+		EXECUTE format('ALTER TABLE %I ADD COLUMN IF NOT EXISTS payload BYTEA', t.table_name);
+		EXECUTE format('ALTER TABLE %I ALTER COLUMN data DROP NOT NULL', t.table_name);
+	END LOOP;
+END $$;`,
+	},
+	{
+		Version: 2,
+		Name:    "bucket_sequences",
+		Statements: `DO $$
+DECLARE t record;
+BEGIN
+	FOR t IN SELECT table_name FROM information_schema.columns
+		WHERE table_schema = current_schema() AND column_name = 'data'
+	LOOP
+		IF to_regclass(quote_ident(t.table_name || '_id_seq')) IS NULL THEN
+			EXECUTE format('CREATE SEQUENCE %I', t.table_name || '_id_seq');
+			EXECUTE format(
+				'SELECT setval(%L::regclass, COALESCE(MAX(CASE WHEN id::TEXT ~ ''^[0-9]+$'' THEN id::TEXT::BIGINT END), 0) + 1, false) FROM %I',
+				quote_ident(t.table_name || '_id_seq'), t.table_name
+			);
+		END IF;
+	END LOOP;
+END $$;`,
+	},
+	{
+		Version: 3,
+		Name:    "endpoint_heartbeats",
+		Statements: `CREATE TABLE IF NOT EXISTS endpoint_heartbeats (
+	endpoint_id BIGINT PRIMARY KEY,
+	last_check_in BIGINT NOT NULL
+);`,
+	},
+	{
+		Version: 4,
+		Name:    "tunnel_routes",
+		Statements: `CREATE TABLE IF NOT EXISTS tunnel_routes (
+	endpoint_id BIGINT PRIMARY KEY,
+	owner TEXT NOT NULL DEFAULT '',
+	port INTEGER NOT NULL,
+	credentials TEXT NOT NULL,
+	updated_at BIGINT NOT NULL
+);`,
+	},
+	{
+		Version: 5,
+		Name:    "bucket_revision_column",
+		Statements: `DO $$
+DECLARE t record;
+BEGIN
+	FOR t IN SELECT table_name FROM information_schema.columns
+		WHERE table_schema = current_schema() AND column_name = 'data'
+	LOOP
+		EXECUTE format('ALTER TABLE %I ADD COLUMN IF NOT EXISTS revision BIGINT NOT NULL DEFAULT 1', t.table_name);
+	END LOOP;
+END $$;`,
+	},
+	{
+		// The buckets were all keyed by TEXT, the ones of the services with
+		// integer keys move to BIGINT. A bucket holding other keys is left as is.
+		Version: 6,
+		Name:    "bucket_integer_keys",
+		Statements: `DO $$
+DECLARE t record;
+DECLARE numeric_keys boolean;
+BEGIN
+	FOR t IN SELECT c.table_name FROM information_schema.columns c
+		WHERE c.table_schema = current_schema() AND c.column_name = 'id' AND c.data_type = 'text'
+			AND c.table_name NOT IN ('settings', 'ssl', 'version', 'dockerhub', 'tunnel_server', 'leader_leases')
+			AND EXISTS (SELECT FROM information_schema.columns d
+				WHERE d.table_schema = c.table_schema AND d.table_name = c.table_name AND d.column_name = 'data')
+	LOOP
+		EXECUTE format('SELECT COALESCE(bool_and(id ~ ''^[0-9]+$''), true) FROM %I', t.table_name) INTO numeric_keys;
+
+		IF numeric_keys THEN
+			EXECUTE format('ALTER TABLE %I ALTER COLUMN id TYPE BIGINT USING id::BIGINT', t.table_name);
+		END IF;
+	END LOOP;
+END $$;`,
+	},
+	{
+		// The key ordering strings in the natural order of query.Sort under the
+		// "C" collation, see naturalSortKeySQL
+		Version: 7,
+		Name:    "natural_sort_key",
+		Statements: `CREATE OR REPLACE FUNCTION portainer_natural_sort_key(value TEXT) RETURNS TEXT
+LANGUAGE SQL IMMUTABLE STRICT PARALLEL SAFE AS $$
+SELECT COALESCE(string_agg(
+	CASE WHEN m[1] ~ '^[0-9]' THEN
+		chr(1) || lpad(length(ltrim(m[1], '0'))::TEXT, 4, '0') || ltrim(m[1], '0')
+			|| lpad((length(m[1]) - length(ltrim(m[1], '0')))::TEXT, 4, '0')
+	ELSE m[1] END, '' ORDER BY n), '')
+FROM regexp_matches(value, '[0-9]+|[^0-9]+', 'g') WITH ORDINALITY AS t(m, n)
+$$;`,
+	},
+}
+
+// LatestSchemaVersion returns the schema version this version of Portainer migrates to
+func LatestSchemaVersion() int {
+	return schemaMigrations[len(schemaMigrations)-1].Version
+}
+
+// MigrateSchema applies the pending schema migrations. An advisory lock is held
+// for the whole run so that only one Portainer instance migrates at a time,
+// the others wait and then find nothing left to apply.
+func (connection *DbConnection) MigrateSchema() error {
+	if err := connection.connect(); err != nil {
+		return err
+	}
+
+	ctx := connection.ctx
+
+	// Advisory locks belong to a session, keep using the same connection
+	conn, err := connection.DB.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", schemaMigrationLockID); err != nil {
+		return fmt.Errorf("failed to acquire the schema migration lock: %w", err)
+	}
+	defer func() {
+		if _, err := conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", schemaMigrationLockID); err != nil {
+			log.Warn().Err(err).Msg("failed to release the schema migration lock")
+		}
+	}()
+
+	if _, err := conn.ExecContext(ctx, fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+		version INTEGER PRIMARY KEY,
+		name TEXT NOT NULL,
+		checksum TEXT NOT NULL,
+		applied_at TIMESTAMPTZ NOT NULL DEFAULT now()
+	)`, SchemaMigrationsTable)); err != nil {
+		return fmt.Errorf("failed to create the %s table: %w", SchemaMigrationsTable, err)
+	}
+
+	applied, err := appliedSchemaMigrations(ctx, conn)
+	if err != nil {
+		return err
+	}
+
+	if err := verifySchemaMigrations(applied); err != nil {
+		return err
+	}
+
+	for _, m := range schemaMigrations {
+		if _, ok := applied[m.Version]; ok {
+			continue
+		}
+
+		log.Info().Int("version", m.Version).Str("name", m.Name).Msg("applying database schema migration")
+
+		if err := applySchemaMigration(ctx, conn, m); err != nil {
+			return fmt.Errorf("failed to apply schema migration %d (%s): %w", m.Version, m.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// SchemaVersion returns the version of the last applied schema migration
+func (connection *DbConnection) SchemaVersion() (int, error) {
+	if connection.DB == nil {
+		return 0, ErrNoConnection
+	}
+
+	return schemaVersion(connection.ctx, connection.DB)
+}
+
+func schemaVersion(ctx context.Context, db querier) (int, error) {
+	var version int
+	err := db.QueryRowContext(ctx, fmt.Sprintf("SELECT COALESCE(MAX(version), 0) FROM %s", SchemaMigrationsTable)).Scan(&version)
+
+	return version, err
+}
+
+func appliedSchemaMigrations(ctx context.Context, db querier) (map[int]string, error) {
+	rows, err := db.QueryContext(ctx, fmt.Sprintf("SELECT version, checksum FROM %s", SchemaMigrationsTable))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := make(map[int]string)
+	for rows.Next() {
+		var version int
+		var checksum string
+		if err := rows.Scan(&version, &checksum); err != nil {
+			return nil, err
+		}
+
+		applied[version] = checksum
+	}
+
+	return applied, rows.Err()
+}
+
+// verifySchemaMigrations checks the applied migrations against the known ones
+func verifySchemaMigrations(applied map[int]string) error {
+	known := make(map[int]schemaMigration, len(schemaMigrations))
+	for _, m := range schemaMigrations {
+		known[m.Version] = m
+	}
+
+	for version, checksum := range applied {
+		m, ok := known[version]
+		if !ok {
+			return fmt.Errorf("%w (applied version %d, supported version %d)", ErrSchemaTooNew, version, LatestSchemaVersion())
+		}
+
+		if m.Checksum() != checksum {
+			return fmt.Errorf("%w (version %d, %s)", ErrSchemaChecksumMismatch, m.Version, m.Name)
+		}
+	}
+
+	return nil
+}
+
+func applySchemaMigration(ctx context.Context, conn *sql.Conn, m schemaMigration) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, m.Statements); err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx,
+		fmt.Sprintf("INSERT INTO %s (version, name, checksum) VALUES ($1, $2, $3)", SchemaMigrationsTable),
+		m.Version, m.Name, m.Checksum(),
+	); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,31 @@
+package postgres
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_SchemaMigrationsAreOrdered(t *testing.T) {
+	for i, m := range schemaMigrations {
+		assert.Equal(t, i+1, m.Version, "schema migration %s", m.Name)
+		assert.NotEmpty(t, m.Name)
+		assert.NotEmpty(t, m.Statements)
+	}
+
+	assert.Equal(t, len(schemaMigrations), LatestSchemaVersion())
+}
+
+func Test_VerifySchemaMigrations(t *testing.T) {
+	first := schemaMigrations[0]
+
+	require.NoError(t, verifySchemaMigrations(map[int]string{}))
+	require.NoError(t, verifySchemaMigrations(map[int]string{first.Version: first.Checksum()}))
+
+	err := verifySchemaMigrations(map[int]string{first.Version: "edited"})
+	require.ErrorIs(t, err, ErrSchemaChecksumMismatch)
+
+	err = verifySchemaMigrations(map[int]string{LatestSchemaVersion() + 1: "unknown"})
+	require.ErrorIs(t, err, ErrSchemaTooNew)
+}
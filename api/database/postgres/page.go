@@ -0,0 +1,200 @@
+package postgres
+
+import (
+	"fmt"
+	"strings"
+
+	portainer "github.com/portainer/portainer/api"
+	"github.com/portainer/portainer/api/database/query"
+
+	"github.com/lib/pq"
+)
+
+// QueryPage hands the objects of a page to appendFn. The page is selected by
+// the server with a keyset condition when it has a cursor. The encrypted
+// stores are scanned and their pages are selected in memory.
+func (tx *DbTransaction) QueryPage(bucketName string, page query.Page, obj any, appendFn func(o any) (any, error)) (query.PageInfo, error) {
+	if tx.conn.getEncryptionKey() != nil {
+		var objects []any
+		if err := tx.Query(bucketName, page.Filter, obj, query.CollectFn(&objects)); err != nil {
+			return query.PageInfo{}, err
+		}
+
+		return query.ApplyPage(objects, page, appendFn)
+	}
+
+	where, args, err := filterSQL(page.Filter, nil)
+	if err != nil {
+		return query.PageInfo{}, err
+	}
+
+	table := pq.QuoteIdentifier(bucketName)
+
+	var info query.PageInfo
+	if err := tx.tx.QueryRowContext(tx.ctx, fmt.Sprintf("SELECT count(*) FROM %s WHERE %s", table, where), args...).Scan(&info.Total); err != nil {
+		return query.PageInfo{}, fmt.Errorf("failed to count the objects of bucket %s: %w", bucketName, err)
+	}
+
+	keyKind := tx.keyKind(bucketName)
+	key := orderByKey(keyKind)
+	value := sortSQL(page.Sort)
+	orderKeys := append(sortKeysSQL(page.Sort, value), key)
+
+	direction, after := "ASC", ">"
+	if page.Sort.Desc {
+		direction, after = "DESC", "<"
+	}
+
+	stmt := fmt.Sprintf("SELECT id, %s, data, payload FROM %s WHERE %s", value, table, where)
+
+	if page.Cursor != "" {
+		cursorValue, cursorKey, err := query.DecodeCursor(page.Sort, page.Cursor)
+		if err != nil {
+			return query.PageInfo{}, err
+		}
+
+		// The sort value of the cursor goes through the expressions ordering
+		// the objects, a string is typed for its collation
+		var cursorSort []string
+		if page.Sort.Field != "" {
+			args = append(args, cursorValue)
+
+			cursorValueSQL := fmt.Sprintf("$%d", len(args))
+			if page.Sort.Text {
+				cursorValueSQL += "::TEXT"
+			}
+
+			cursorSort = sortKeysSQL(page.Sort, cursorValueSQL)
+		}
+
+		args = append(args, cursorKey)
+
+		stmt += fmt.Sprintf(" AND (%s) %s (%s)", strings.Join(orderKeys, ", "), after, strings.Join(append(cursorSort, fmt.Sprintf("$%d", len(args))), ", "))
+	}
+
+	orderBy := make([]string, len(orderKeys))
+	for i, orderKey := range orderKeys {
+		orderBy[i] = orderKey + " " + direction
+	}
+
+	stmt += " ORDER BY " + strings.Join(orderBy, ", ")
+
+	// The row following the page tells whether there is a next one
+	if page.Limit > 0 {
+		stmt += fmt.Sprintf(" LIMIT %d", page.Limit+1)
+	}
+
+	if page.Cursor == "" && page.Offset > 0 {
+		stmt += fmt.Sprintf(" OFFSET %d", page.Offset)
+	}
+
+	rows, err := tx.tx.QueryContext(tx.ctx, stmt, args...)
+	if err != nil {
+		return query.PageInfo{}, fmt.Errorf("failed to query bucket %s: %w", bucketName, err)
+	}
+	defer rows.Close()
+
+	var lastValue, lastKey any
+	for n := 0; rows.Next(); n++ {
+		if page.Limit > 0 && n == page.Limit {
+			info.Next = query.EncodeCursor(page.Sort, lastValue, lastKey)
+
+			break
+		}
+
+		var data, payload []byte
+		if err := rows.Scan(&lastKey, &lastValue, &data, &payload); err != nil {
+			return query.PageInfo{}, err
+		}
+
+		if err := tx.conn.decodeRow(data, payload, obj); err != nil {
+			return query.PageInfo{}, err
+		}
+
+		if obj, err = appendFn(obj); err != nil {
+			return query.PageInfo{}, err
+		}
+	}
+
+	return info, rows.Err()
+}
+
+// Count returns the number of objects of a bucket matched by filter
+func (tx *DbTransaction) Count(bucketName string, filter query.Filter, obj any) (int, error) {
+	var count int
+
+	if tx.conn.getEncryptionKey() != nil {
+		return count, tx.GetAll(bucketName, obj, query.CountFn(filter, &count))
+	}
+
+	where, args, err := filterSQL(filter, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := tx.tx.QueryRowContext(tx.ctx, fmt.Sprintf("SELECT count(*) FROM %s WHERE %s", pq.QuoteIdentifier(bucketName), where), args...).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count the objects of bucket %s: %w", bucketName, err)
+	}
+
+	return count, nil
+}
+
+// QueryPage hands the objects of a page to appendFn
+func (connection *DbConnection) QueryPage(bucketName string, page query.Page, obj any, appendFn func(o any) (any, error)) (query.PageInfo, error) {
+	var info query.PageInfo
+
+	return info, connection.ViewTx(func(tx portainer.Transaction) error {
+		var err error
+		info, err = tx.(*DbTransaction).QueryPage(bucketName, page, obj, appendFn)
+
+		return err
+	})
+}
+
+// Count returns the number of objects of a bucket matched by filter
+func (connection *DbConnection) Count(bucketName string, filter query.Filter, obj any) (int, error) {
+	var count int
+
+	return count, connection.ViewTx(func(tx portainer.Transaction) error {
+		var err error
+		count, err = tx.(*DbTransaction).Count(bucketName, filter, obj)
+
+		return err
+	})
+}
+
+// sortSQL returns the sort value of the objects of a page, it yields the
+// values compared by query.Sort: strings, empty when the field is not one, or
+// numbers, zero when the field is missing
+func sortSQL(sort query.Sort) string {
+	switch {
+	case sort.Field == "":
+		return "NULL"
+	case sort.Text:
+		return fmt.Sprintf("CASE WHEN jsonb_typeof(%s) = 'string' THEN %s ELSE '' END", fieldSQL(sort.Field, false), fieldSQL(sort.Field, true))
+	}
+
+	return fmt.Sprintf("COALESCE((%s)::float8, 0)", fieldSQL(sort.Field, true))
+}
+
+// sortKeysSQL returns the expressions ordering a page by the sort value
+// value, before the key of the objects. The strings are ordered by their
+// natural sort key, then byte-wise like query.Sort.
+func sortKeysSQL(sort query.Sort, value string) []string {
+	switch {
+	case sort.Field == "":
+		return nil
+	case sort.Text:
+		return []string{naturalSortKeySQL(value), value + ` COLLATE "C"`}
+	}
+
+	return []string{value}
+}
+
+// naturalSortKeySQL returns the key ordering the string value in natural
+// order. The portainer_natural_sort_key function replaces every number by
+// the length of its digits, its digits and the number of its leading zeros,
+// after a byte lower than the other characters, like sortorder.NaturalLess.
+func naturalSortKeySQL(value string) string {
+	return fmt.Sprintf(`portainer_natural_sort_key(%s) COLLATE "C"`, value)
+}
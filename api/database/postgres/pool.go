@@ -0,0 +1,115 @@
+package postgres
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+var ErrInvalidPoolConfig = errors.New("invalid PostgreSQL pool configuration, the maximum of idle connections cannot exceed the maximum of open connections")
+
+// PoolConfig sizes the connection pool of the primary server, the zero
+// values select the defaults
+type PoolConfig struct {
+	// MaxOpen bounds the number of connections opened to the server
+	MaxOpen int
+	// MaxIdle bounds the number of connections kept open while unused
+	MaxIdle int
+	// MaxLifetime is the age at which a connection is replaced, it spreads the
+	// connections over the servers behind a proxy or a failover address
+	MaxLifetime time.Duration
+	// MaxIdleTime is how long an unused connection is kept open
+	MaxIdleTime time.Duration
+}
+
+// Validate returns an error when the maximums contradict each other
+func (c PoolConfig) Validate() error {
+	if c.MaxOpen < 0 || c.MaxIdle < 0 || c.MaxLifetime < 0 || c.MaxIdleTime < 0 {
+		return ErrInvalidPoolConfig
+	}
+
+	if c.MaxIdle > c.withDefaults().MaxOpen {
+		return ErrInvalidPoolConfig
+	}
+
+	return nil
+}
+
+func (c PoolConfig) withDefaults() PoolConfig {
+	if c.MaxOpen == 0 {
+		c.MaxOpen = DatabaseMaxOpen
+	}
+
+	if c.MaxIdle == 0 {
+		c.MaxIdle = min(DatabaseMaxIdle, c.MaxOpen)
+	}
+
+	if c.MaxLifetime == 0 {
+		c.MaxLifetime = DatabaseTimeout
+	}
+
+	return c
+}
+
+// PoolStats describes the connection pool of the primary server
+type PoolStats struct {
+	MaxOpen int `json:"maxOpen"`
+	MaxIdle int `json:"maxIdle"`
+	// Open is the number of connections, in use or idle
+	Open  int `json:"open"`
+	InUse int `json:"inUse"`
+	Idle  int `json:"idle"`
+	// WaitCount is the number of times a transaction waited for a connection
+	WaitCount    int64         `json:"waitCount"`
+	WaitDuration time.Duration `json:"waitDuration" swaggertype:"integer"`
+	// The number of connections closed because of the pool limits
+	MaxIdleClosed     int64 `json:"maxIdleClosed"`
+	MaxIdleTimeClosed int64 `json:"maxIdleTimeClosed"`
+	MaxLifetimeClosed int64 `json:"maxLifetimeClosed"`
+}
+
+// ConfigurePool applies config to the connection pool of the primary server
+func (connection *DbConnection) ConfigurePool(config PoolConfig) error {
+	if err := config.Validate(); err != nil {
+		return err
+	}
+
+	connection.pool = config.withDefaults()
+
+	if connection.DB != nil {
+		connection.applyPoolConfig(connection.DB)
+	}
+
+	return nil
+}
+
+func (connection *DbConnection) applyPoolConfig(db *sql.DB) {
+	pool := connection.pool.withDefaults()
+
+	db.SetMaxOpenConns(pool.MaxOpen)
+	db.SetMaxIdleConns(pool.MaxIdle)
+	db.SetConnMaxLifetime(pool.MaxLifetime)
+	db.SetConnMaxIdleTime(pool.MaxIdleTime)
+}
+
+// PoolStats returns the state of the connection pool of the primary server
+func (connection *DbConnection) PoolStats() PoolStats {
+	pool := connection.pool.withDefaults()
+
+	stats := PoolStats{MaxOpen: pool.MaxOpen, MaxIdle: pool.MaxIdle}
+	if connection.DB == nil {
+		return stats
+	}
+
+	s := connection.DB.Stats()
+	stats.Open = s.OpenConnections
+	stats.InUse = s.InUse
+	stats.Idle = s.Idle
+	stats.WaitCount = s.WaitCount
+	stats.WaitDuration = s.WaitDuration
+	stats.MaxIdleClosed = s.MaxIdleClosed
+	stats.MaxIdleTimeClosed = s.MaxIdleTimeClosed
+	stats.MaxLifetimeClosed = s.MaxLifetimeClosed
+
+	return stats
+}
@@ -0,0 +1,55 @@
+package postgres
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_PoolConfig(t *testing.T) {
+	is := assert.New(t)
+
+	is.NoError(PoolConfig{}.Validate())
+	is.Equal(PoolConfig{MaxOpen: DatabaseMaxOpen, MaxIdle: DatabaseMaxIdle, MaxLifetime: DatabaseTimeout}, PoolConfig{}.withDefaults())
+
+	// The default maximum of idle connections follows a smaller maximum of open connections
+	is.NoError(PoolConfig{MaxOpen: 10}.Validate())
+	is.Equal(10, PoolConfig{MaxOpen: 10}.withDefaults().MaxIdle)
+
+	is.ErrorIs(PoolConfig{MaxOpen: 10, MaxIdle: 20}.Validate(), ErrInvalidPoolConfig)
+	is.ErrorIs(PoolConfig{MaxIdle: DatabaseMaxOpen + 1}.Validate(), ErrInvalidPoolConfig)
+	is.ErrorIs(PoolConfig{MaxLifetime: -time.Second}.Validate(), ErrInvalidPoolConfig)
+}
+
+func Test_ReconnectDelay(t *testing.T) {
+	is := assert.New(t)
+
+	is.Equal(reconnectBackoff, reconnectDelay(1))
+	is.Equal(2*reconnectBackoff, reconnectDelay(2))
+	is.Equal(maxReconnectDelay, reconnectDelay(reconnectAttempts))
+}
+
+func Test_SetHealthNotifiesChanges(t *testing.T) {
+	is := assert.New(t)
+
+	connection := &DbConnection{}
+	connection.health.state.Healthy = true
+
+	var changes []bool
+	connection.OnHealthChange(func(healthy bool) {
+		changes = append(changes, healthy)
+	})
+
+	connection.setHealth(nil)
+	connection.setHealth(ErrNoConnection)
+	connection.setHealth(ErrNoConnection)
+
+	health := connection.Health()
+	is.False(health.Healthy)
+	is.Equal(ErrNoConnection.Error(), health.Error)
+
+	connection.setHealth(nil)
+	is.Equal([]bool{false, true}, changes)
+	is.Empty(connection.Health().Error)
+}
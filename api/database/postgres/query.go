@@ -0,0 +1,210 @@
+package postgres
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	portainer "github.com/portainer/portainer/api"
+	"github.com/portainer/portainer/api/database/query"
+
+	"github.com/lib/pq"
+	"github.com/segmentio/encoding/json"
+)
+
+// Query returns the objects of a bucket matched by filter, in key order. The
+// filter is evaluated by the server on the data column, encrypted stores are
+// scanned instead since their objects are not queryable.
+func (tx *DbTransaction) Query(bucketName string, filter query.Filter, obj any, appendFn func(o any) (any, error)) error {
+	table := pq.QuoteIdentifier(bucketName)
+	orderBy := orderByKey(tx.keyKind(bucketName))
+
+	if tx.conn.getEncryptionKey() != nil {
+		stmt := fmt.Sprintf("SELECT data, payload FROM %s ORDER BY %s", table, orderBy)
+
+		return tx.scan(stmt, obj, query.MatchFn(filter, appendFn))
+	}
+
+	where, args, err := filterSQL(filter, nil)
+	if err != nil {
+		return err
+	}
+
+	stmt := fmt.Sprintf("SELECT data, payload FROM %s WHERE %s ORDER BY %s", table, where, orderBy)
+
+	return tx.scan(stmt, obj, appendFn, args...)
+}
+
+// Query returns the objects of a bucket matched by filter, in key order
+func (connection *DbConnection) Query(bucketName string, filter query.Filter, obj any, appendFn func(o any) (any, error)) error {
+	return connection.ViewTx(func(tx portainer.Transaction) error {
+		return tx.(*DbTransaction).Query(bucketName, filter, obj, appendFn)
+	})
+}
+
+// CreateIndexes creates the expression indexes speeding up the queries on the
+// fields of a bucket. The migration lock serializes the instances creating
+// them at the same time.
+func (connection *DbConnection) CreateIndexes(bucketName string, indexes ...query.Index) error {
+	if len(indexes) == 0 {
+		return nil
+	}
+
+	return connection.UpdateTx(func(tx portainer.Transaction) error {
+		pgTx := tx.(*DbTransaction)
+
+		if _, err := pgTx.tx.ExecContext(pgTx.ctx, "SELECT pg_advisory_xact_lock($1)", schemaMigrationLockID); err != nil {
+			return fmt.Errorf("failed to acquire the schema lock: %w", err)
+		}
+
+		for _, index := range indexes {
+			if _, err := pgTx.tx.ExecContext(pgTx.ctx, indexSQL(bucketName, index)); err != nil {
+				return fmt.Errorf("failed to index the field %s of bucket %s: %w", index.Field, bucketName, err)
+			}
+		}
+
+		return nil
+	})
+}
+
+// indexSQL returns the statement creating an index, its expression is the one
+// used by filterSQL for the filters it speeds up
+func indexSQL(bucketName string, index query.Index) string {
+	name := fmt.Sprintf("%s_%s", bucketName, strings.ToLower(strings.ReplaceAll(index.Field, ".", "_")))
+
+	var method, expression string
+	switch index.Kind {
+	case query.IndexEqualFold:
+		name += "_fold_idx"
+		expression = fmt.Sprintf("lower(%s)", fieldSQL(index.Field, true))
+	case query.IndexElements:
+		name += "_elements_idx"
+		method = " USING gin"
+		expression = fieldSQL(index.Field, false)
+	default:
+		name += "_idx"
+		expression = fieldSQL(index.Field, false)
+	}
+
+	return fmt.Sprintf("CREATE INDEX IF NOT EXISTS %s ON %s%s ((%s))", pq.QuoteIdentifier(name), pq.QuoteIdentifier(bucketName), method, expression)
+}
+
+// fieldSQL returns the expression extracting a field from the data column, as
+// text when asText is set
+func fieldSQL(field string, asText bool) string {
+	if !strings.Contains(field, ".") {
+		if asText {
+			return fmt.Sprintf("data ->> %s", pq.QuoteLiteral(field))
+		}
+
+		return fmt.Sprintf("data -> %s", pq.QuoteLiteral(field))
+	}
+
+	path := pq.QuoteLiteral("{" + strings.ReplaceAll(field, ".", ",") + "}")
+	if asText {
+		return fmt.Sprintf("data #>> %s", path)
+	}
+
+	return fmt.Sprintf("data #> %s", path)
+}
+
+// filterSQL translates filter into a condition on the data column, its
+// parameters are appended to args
+func filterSQL(filter query.Filter, args []any) (string, []any, error) {
+	switch filter.Op {
+	case query.OpAll, query.OpAny:
+		if len(filter.Filters) == 0 {
+			return fmt.Sprint(filter.Op == query.OpAll), args, nil
+		}
+
+		separator := " AND "
+		if filter.Op == query.OpAny {
+			separator = " OR "
+		}
+
+		conditions := make([]string, 0, len(filter.Filters))
+		for _, sub := range filter.Filters {
+			var condition string
+			var err error
+
+			condition, args, err = filterSQL(sub, args)
+			if err != nil {
+				return "", nil, err
+			}
+
+			conditions = append(conditions, condition)
+		}
+
+		return "(" + strings.Join(conditions, separator) + ")", args, nil
+	case query.OpNot:
+		if len(filter.Filters) != 1 {
+			return "", nil, errors.New("a negation requires a single filter")
+		}
+
+		condition, args, err := filterSQL(filter.Filters[0], args)
+		if err != nil {
+			return "", nil, err
+		}
+
+		// A missing field yields NULL, which is not matched either
+		return fmt.Sprintf("((%s) IS NOT TRUE)", condition), args, nil
+	case query.OpIn:
+		if len(filter.Filters) == 0 {
+			return "false", args, nil
+		}
+
+		placeholders := make([]string, 0, len(filter.Filters))
+		for _, sub := range filter.Filters {
+			value, err := json.Marshal(sub.Value)
+			if err != nil {
+				return "", nil, fmt.Errorf("invalid value for the field %s: %w", filter.Field, err)
+			}
+
+			args = append(args, string(value))
+			placeholders = append(placeholders, fmt.Sprintf("$%d::jsonb", len(args)))
+		}
+
+		return fmt.Sprintf("(%s) IN (%s)", fieldSQL(filter.Field, false), strings.Join(placeholders, ", ")), args, nil
+	}
+
+	placeholder := fmt.Sprintf("$%d", len(args)+1)
+
+	switch filter.Op {
+	case query.OpEquals:
+		value, err := json.Marshal(filter.Value)
+		if err != nil {
+			return "", nil, fmt.Errorf("invalid value for the field %s: %w", filter.Field, err)
+		}
+
+		return fmt.Sprintf("(%s) = %s::jsonb", fieldSQL(filter.Field, false), placeholder), append(args, string(value)), nil
+	case query.OpEqualFold:
+		return fmt.Sprintf("lower(%s) = lower(%s)", fieldSQL(filter.Field, true), placeholder), append(args, filter.Value), nil
+	case query.OpSearch:
+		return fmt.Sprintf("strpos(lower(%s), lower(%s)) > 0", fieldSQL(filter.Field, true), placeholder), append(args, filter.Value), nil
+	case query.OpContains:
+		value, err := json.Marshal([]any{filter.Value})
+		if err != nil {
+			return "", nil, fmt.Errorf("invalid value for the field %s: %w", filter.Field, err)
+		}
+
+		return fmt.Sprintf("(%s) @> %s::jsonb", fieldSQL(filter.Field, false), placeholder), append(args, string(value)), nil
+	case query.OpAtLeast, query.OpAtMost:
+		value, err := json.Marshal(filter.Value)
+		if err != nil {
+			return "", nil, fmt.Errorf("invalid value for the field %s: %w", filter.Field, err)
+		}
+
+		operator := ">="
+		if filter.Op == query.OpAtMost {
+			operator = "<="
+		}
+
+		// The JSON numbers are compared by value, the fields of another JSON
+		// type are not matched
+		return fmt.Sprintf("(jsonb_typeof(%[1]s) = 'number' AND (%[1]s) %[2]s %[3]s::jsonb)", fieldSQL(filter.Field, false), operator, placeholder), append(args, string(value)), nil
+	case query.OpHasKey:
+		return fmt.Sprintf("(%s) ? %s", fieldSQL(filter.Field, false), placeholder), append(args, filter.Key()), nil
+	}
+
+	return "", nil, fmt.Errorf("unsupported filter operator %d", filter.Op)
+}
@@ -0,0 +1,79 @@
+package postgres
+
+import (
+	"testing"
+
+	"github.com/portainer/portainer/api/database/query"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_FilterSQL(t *testing.T) {
+	is := assert.New(t)
+
+	filter := query.Any(
+		query.All(query.Equals("ResourceId", "abc"), query.Equals("Type", 1)),
+		query.Contains("SubResourceIds", "abc"),
+		query.EqualFold("Username", "Admin"),
+		query.HasKey("TeamAccessPolicies", 2),
+	)
+
+	where, args, err := filterSQL(filter, nil)
+	is.NoError(err)
+	is.Equal(`(((data -> 'ResourceId') = $1::jsonb AND (data -> 'Type') = $2::jsonb) OR (data -> 'SubResourceIds') @> $3::jsonb OR lower(data ->> 'Username') = lower($4) OR (data -> 'TeamAccessPolicies') ? $5)`, where)
+	is.Equal([]any{`"abc"`, `1`, `["abc"]`, "Admin", "2"}, args)
+
+	where, args, err = filterSQL(query.Equals("it's", true), []any{"x"})
+	is.NoError(err)
+	is.Equal(`(data -> 'it''s') = $2::jsonb`, where)
+	is.Equal([]any{"x", `true`}, args)
+
+	where, _, err = filterSQL(query.Any(), nil)
+	is.NoError(err)
+	is.Equal("false", where)
+
+	where, args, err = filterSQL(query.All(
+		query.Not(query.In("Id", []int{1, 2})),
+		query.Search("Name", "prod"),
+		query.Equals("Agent.Version", "2.0"),
+		query.In("Type", []int{}),
+	), nil)
+	is.NoError(err)
+	is.Equal(`((((data -> 'Id') IN ($1::jsonb, $2::jsonb)) IS NOT TRUE) AND strpos(lower(data ->> 'Name'), lower($3)) > 0 AND (data #> '{Agent,Version}') = $4::jsonb AND false)`, where)
+	is.Equal([]any{`1`, `2`, "prod", `"2.0"`}, args)
+
+	where, args, err = filterSQL(query.All(query.AtLeast("Timestamp", 10), query.AtMost("Timestamp", 20)), nil)
+	is.NoError(err)
+	is.Equal(`((jsonb_typeof(data -> 'Timestamp') = 'number' AND (data -> 'Timestamp') >= $1::jsonb) AND (jsonb_typeof(data -> 'Timestamp') = 'number' AND (data -> 'Timestamp') <= $2::jsonb))`, where)
+	is.Equal([]any{`10`, `20`}, args)
+
+	_, _, err = filterSQL(query.Filter{Op: -1}, nil)
+	is.Error(err)
+}
+
+func Test_IndexSQL(t *testing.T) {
+	is := assert.New(t)
+
+	is.Equal(`CREATE INDEX IF NOT EXISTS "users_username_fold_idx" ON "users" ((lower(data ->> 'Username')))`,
+		indexSQL("users", query.Index{Field: "Username", Kind: query.IndexEqualFold}))
+	is.Equal(`CREATE INDEX IF NOT EXISTS "stacks_name_idx" ON "stacks" ((data -> 'Name'))`,
+		indexSQL("stacks", query.Index{Field: "Name", Kind: query.IndexEquals}))
+	is.Equal(`CREATE INDEX IF NOT EXISTS "endpoints_teamaccesspolicies_elements_idx" ON "endpoints" USING gin ((data -> 'TeamAccessPolicies'))`,
+		indexSQL("endpoints", query.Index{Field: "TeamAccessPolicies", Kind: query.IndexElements}))
+}
+
+func Test_SortKeysSQL(t *testing.T) {
+	is := assert.New(t)
+
+	is.Empty(sortKeysSQL(query.Sort{}, sortSQL(query.Sort{})))
+
+	numeric := query.Sort{Field: "Priority"}
+	is.Equal([]string{`COALESCE((data ->> 'Priority')::float8, 0)`}, sortKeysSQL(numeric, sortSQL(numeric)))
+
+	text := query.Sort{Field: "Name", Text: true}
+	is.Equal([]string{
+		`portainer_natural_sort_key(CASE WHEN jsonb_typeof(data -> 'Name') = 'string' THEN data ->> 'Name' ELSE '' END) COLLATE "C"`,
+		`CASE WHEN jsonb_typeof(data -> 'Name') = 'string' THEN data ->> 'Name' ELSE '' END COLLATE "C"`,
+	}, sortKeysSQL(text, sortSQL(text)))
+	is.Equal([]string{`portainer_natural_sort_key($1::TEXT) COLLATE "C"`, `$1::TEXT COLLATE "C"`}, sortKeysSQL(text, "$1::TEXT"))
+}
@@ -0,0 +1,144 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// DefaultMaxReplicaLag is the replication delay above which a replica stops serving reads
+	DefaultMaxReplicaLag = 10 * time.Second
+
+	replicaCheckInterval = 5 * time.Second
+	replicaMaxOpen       = 10
+)
+
+// replica is a read-only server streaming from the primary. It serves the
+// read-only transactions whose context allows it while it is reachable and
+// its replication delay stays under the configured maximum.
+type replica struct {
+	name    string
+	db      *sql.DB
+	healthy atomic.Bool
+}
+
+// replicaLagQuery returns the replication delay in seconds. A replica that
+// replayed everything it received is up to date, even when the primary has
+// been idle for a while and the last replayed transaction is old.
+const replicaLagQuery = `SELECT CASE
+	WHEN NOT pg_is_in_recovery() THEN 0
+	WHEN pg_last_wal_receive_lsn() = pg_last_wal_replay_lsn() THEN 0
+	ELSE COALESCE(EXTRACT(EPOCH FROM now() - pg_last_xact_replay_timestamp()), 0)
+END`
+
+// ConnectReplicas opens a connection pool for every read replica and starts
+// monitoring their replication delay. Replicas that cannot be reached are
+// kept, they start serving reads once they catch up.
+func (connection *DbConnection) ConnectReplicas(configs []Config) error {
+	if err := connection.connect(); err != nil {
+		return err
+	}
+
+	if connection.MaxReplicaLag <= 0 {
+		connection.MaxReplicaLag = DefaultMaxReplicaLag
+	}
+
+	for _, config := range configs {
+		connectionString, err := config.ConnectionString()
+		if err != nil {
+			return fmt.Errorf("invalid read replica %s: %w", config.Redacted(), err)
+		}
+
+		db, err := sql.Open(DatabaseDriverName, connectionString)
+		if err != nil {
+			return fmt.Errorf("failed to connect to read replica %s: %w", config.Redacted(), err)
+		}
+
+		db.SetMaxOpenConns(replicaMaxOpen)
+		db.SetMaxIdleConns(replicaMaxOpen)
+		db.SetConnMaxLifetime(DatabaseTimeout)
+
+		connection.replicas = append(connection.replicas, &replica{name: config.Redacted(), db: db})
+	}
+
+	if len(connection.replicas) == 0 {
+		return nil
+	}
+
+	connection.checkReplicas()
+
+	go connection.monitorReplicas(connection.ctx)
+
+	return nil
+}
+
+func (connection *DbConnection) monitorReplicas(ctx context.Context) {
+	ticker := time.NewTicker(replicaCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			connection.checkReplicas()
+		}
+	}
+}
+
+func (connection *DbConnection) checkReplicas() {
+	for _, r := range connection.replicas {
+		healthy := connection.checkReplica(r)
+
+		if r.healthy.Swap(healthy) != healthy {
+			log.Info().Str("replica", r.name).Bool("healthy", healthy).Msg("read replica state changed")
+		}
+	}
+}
+
+func (connection *DbConnection) checkReplica(r *replica) bool {
+	ctx, cancel := context.WithTimeout(connection.ctx, replicaCheckInterval)
+	defer cancel()
+
+	var lag float64
+	if err := r.db.QueryRowContext(ctx, replicaLagQuery).Scan(&lag); err != nil {
+		log.Debug().Err(err).Str("replica", r.name).Msg("unable to read the replication delay")
+
+		return false
+	}
+
+	return time.Duration(lag*float64(time.Second)) <= connection.MaxReplicaLag
+}
+
+// pickReplica returns the next healthy replica, or nil when reads must go to the primary
+func (connection *DbConnection) pickReplica() *replica {
+	n := len(connection.replicas)
+	if n == 0 {
+		return nil
+	}
+
+	start := connection.nextReplica.Add(1)
+	for i := range n {
+		r := connection.replicas[(start+uint64(i))%uint64(n)]
+		if r.healthy.Load() {
+			return r
+		}
+	}
+
+	return nil
+}
+
+func (connection *DbConnection) closeReplicas() {
+	for _, r := range connection.replicas {
+		if err := r.db.Close(); err != nil {
+			log.Error().Err(err).Str("replica", r.name).Msg("failed to close the read replica connection")
+		}
+	}
+
+	connection.replicas = nil
+}
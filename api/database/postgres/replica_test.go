@@ -0,0 +1,30 @@
+package postgres
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_PickReplicaSkipsUnhealthyReplicas(t *testing.T) {
+	is := assert.New(t)
+
+	connection := &DbConnection{}
+	is.Nil(connection.pickReplica(), "no replica configured")
+
+	first, second, third := &replica{name: "first"}, &replica{name: "second"}, &replica{name: "third"}
+	connection.replicas = []*replica{first, second, third}
+	is.Nil(connection.pickReplica(), "no healthy replica")
+
+	first.healthy.Store(true)
+	third.healthy.Store(true)
+
+	picked := map[string]int{}
+	for range 6 {
+		picked[connection.pickReplica().name]++
+	}
+
+	is.Len(picked, 2)
+	is.Positive(picked["first"])
+	is.Positive(picked["third"])
+}
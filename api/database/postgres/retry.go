@@ -0,0 +1,78 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"reflect"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const (
+	// txRetries bounds the attempts of a write transaction aborted by a
+	// serialization failure or a deadlock
+	txRetries = 10
+	// conflictRetries bounds the attempts of an update losing the race against
+	// other transactions
+	conflictRetries = 5
+	// retryBackoff is the base delay between two attempts, it doubles with
+	// every attempt up to maxRetryBackoff and is jittered so that the
+	// contenders spread out
+	retryBackoff    = 10 * time.Millisecond
+	maxRetryBackoff = time.Second
+)
+
+const (
+	serializationFailure pq.ErrorCode = "40001"
+	deadlockDetected     pq.ErrorCode = "40P01"
+)
+
+// retryDelay returns the delay before the given retry, starting at 1
+func retryDelay(attempt int) time.Duration {
+	backoff := maxRetryBackoff
+	if attempt < 8 {
+		backoff = min(retryBackoff<<(attempt-1), maxRetryBackoff)
+	}
+
+	return backoff/2 + rand.N(backoff/2+1)
+}
+
+// sleepCtx waits for d, it returns early with the error of ctx when it is done
+func sleepCtx(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// retryableCode returns the SQLSTATE of the errors aborting a transaction
+// that succeeds when it is executed again
+func retryableCode(err error) (pq.ErrorCode, bool) {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
+		return "", false
+	}
+
+	switch pqErr.Code {
+	case serializationFailure, deadlockDetected:
+		return pqErr.Code, true
+	}
+
+	return "", false
+}
+
+// resetObject sets the value pointed to by object to its zero value, so that
+// decoding it again does not merge with the result of a previous attempt
+func resetObject(object any) {
+	v := reflect.ValueOf(object)
+	if v.Kind() == reflect.Pointer && !v.IsNil() {
+		v.Elem().SetZero()
+	}
+}
@@ -0,0 +1,51 @@
+package postgres
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_RetryDelay(t *testing.T) {
+	for attempt := 1; attempt < txRetries; attempt++ {
+		backoff := min(retryBackoff<<(attempt-1), maxRetryBackoff)
+
+		for range 10 {
+			delay := retryDelay(attempt)
+			assert.GreaterOrEqual(t, delay, backoff/2, "attempt %d", attempt)
+			assert.LessOrEqual(t, delay, backoff, "attempt %d", attempt)
+		}
+	}
+}
+
+func Test_RetryableCode(t *testing.T) {
+	code, ok := retryableCode(fmt.Errorf("transaction function failed: %w", &pq.Error{Code: "40001"}))
+	assert.True(t, ok)
+	assert.Equal(t, serializationFailure, code)
+
+	code, ok = retryableCode(fmt.Errorf("failed to commit transaction: %w", &pq.Error{Code: "40P01"}))
+	assert.True(t, ok)
+	assert.Equal(t, deadlockDetected, code)
+
+	_, ok = retryableCode(&pq.Error{Code: "23505"})
+	assert.False(t, ok)
+
+	_, ok = retryableCode(nil)
+	assert.False(t, ok)
+}
+
+func Test_ResetObject(t *testing.T) {
+	type object struct {
+		Name   string
+		Labels map[string]string
+	}
+
+	o := &object{Name: "name", Labels: map[string]string{"a": "b"}}
+	resetObject(o)
+	assert.Equal(t, object{}, *o)
+
+	// Non pointer values are left as is
+	resetObject(object{Name: "name"})
+}
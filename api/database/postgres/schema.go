@@ -0,0 +1,93 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	portainer "github.com/portainer/portainer/api"
+
+	"github.com/lib/pq"
+)
+
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+type querier interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+type queryExecer interface {
+	execer
+	querier
+}
+
+// createBucket creates the key/value table and the sequence backing a bucket.
+// Integer keyed buckets use a BIGINT primary key and string keyed buckets a
+// TEXT one. The data column holds plaintext JSON so that it stays queryable,
+// the payload column holds encrypted values and raw strings, the revision
+// column is incremented by every write of a row. Tables created by
+// earlier versions are brought to this layout by the schema migrations.
+func createBucket(ctx context.Context, db queryExecer, bucketName string, keyKind portainer.KeyKind) error {
+	idType := "BIGINT"
+	if keyKind == portainer.KeyKindString {
+		idType = "TEXT"
+	}
+
+	query := fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (id %s PRIMARY KEY, data JSONB, payload BYTEA, revision BIGINT NOT NULL DEFAULT 1)`, pq.QuoteIdentifier(bucketName), idType)
+	if _, err := db.ExecContext(ctx, query); err != nil {
+		return err
+	}
+
+	return createSequence(ctx, db, bucketName)
+}
+
+// columnKeyKind deduces the key kind of a bucket from the type of its id column
+func columnKeyKind(ctx context.Context, db querier, bucketName string) (kind portainer.KeyKind, exists bool, err error) {
+	var dataType string
+	err = db.QueryRowContext(ctx, `SELECT data_type FROM information_schema.columns
+		WHERE table_schema = current_schema() AND table_name = $1 AND column_name = 'id'`, bucketName).Scan(&dataType)
+	if errors.Is(err, sql.ErrNoRows) {
+		return portainer.KeyKindInteger, false, nil
+	} else if err != nil {
+		return portainer.KeyKindInteger, false, err
+	}
+
+	if dataType == "bigint" || dataType == "integer" {
+		return portainer.KeyKindInteger, true, nil
+	}
+
+	return portainer.KeyKindString, true, nil
+}
+
+func createMarker(ctx context.Context, db execer, marker string) error {
+	_, err := db.ExecContext(ctx, fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (created_at TIMESTAMPTZ NOT NULL DEFAULT now())`, pq.QuoteIdentifier(marker)))
+
+	return err
+}
+
+// bucketTables lists the tables holding bucket data
+func bucketTables(ctx context.Context, tx querier) ([]string, error) {
+	rows, err := tx.QueryContext(ctx, `SELECT table_name FROM information_schema.columns
+		WHERE table_schema = current_schema() AND column_name = 'data'
+		ORDER BY table_name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tables []string
+	for rows.Next() {
+		var table string
+		if err := rows.Scan(&table); err != nil {
+			return nil, err
+		}
+
+		tables = append(tables, table)
+	}
+
+	return tables, rows.Err()
+}
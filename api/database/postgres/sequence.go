@@ -0,0 +1,81 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/lib/pq"
+)
+
+const sequenceSuffix = "_id_seq"
+
+// sequenceName returns the quoted name of the sequence backing bucketName,
+// the PostgreSQL counterpart of the BoltDB bucket sequence
+func sequenceName(bucketName string) string {
+	return pq.QuoteIdentifier(bucketName + sequenceSuffix)
+}
+
+// createSequence creates the sequence of a bucket. When the bucket already
+// holds rows, which happens for stores created before buckets had their own
+// sequence, the sequence is seeded after the highest numeric identifier.
+func createSequence(ctx context.Context, db queryExecer, bucketName string) error {
+	var exists bool
+	if err := db.QueryRowContext(ctx, "SELECT to_regclass($1) IS NOT NULL", sequenceName(bucketName)).Scan(&exists); err != nil {
+		return err
+	}
+
+	if exists {
+		return nil
+	}
+
+	if _, err := db.ExecContext(ctx, fmt.Sprintf("CREATE SEQUENCE IF NOT EXISTS %s", sequenceName(bucketName))); err != nil {
+		return err
+	}
+
+	_, err := db.ExecContext(ctx, fmt.Sprintf(
+		`SELECT setval($1::regclass, COALESCE(MAX(CASE WHEN id::TEXT ~ '^[0-9]+$' THEN id::TEXT::BIGINT END), 0) + 1, false) FROM %s`,
+		pq.QuoteIdentifier(bucketName),
+	), sequenceName(bucketName))
+
+	return err
+}
+
+// nextSequence returns the next value of the bucket sequence, like BoltDB's NextSequence
+func nextSequence(ctx context.Context, db querier, bucketName string) (int, error) {
+	var id int
+	err := db.QueryRowContext(ctx, "SELECT nextval($1::regclass)", sequenceName(bucketName)).Scan(&id)
+
+	return id, err
+}
+
+// currentSequence returns the last value handed out by the bucket sequence, or
+// 0 when none was, which matches BoltDB's Bucket.Sequence
+func currentSequence(ctx context.Context, db querier, bucketName string) (int64, error) {
+	var value int64
+	err := db.QueryRowContext(ctx, fmt.Sprintf(
+		"SELECT CASE WHEN is_called THEN last_value ELSE last_value - 1 END FROM %s",
+		sequenceName(bucketName),
+	)).Scan(&value)
+
+	return value, err
+}
+
+// setSequence makes the next value of the bucket sequence value+1, like BoltDB's SetSequence
+func setSequence(ctx context.Context, db querier, bucketName string, value int64) error {
+	return db.QueryRowContext(ctx, "SELECT setval($1::regclass, $2, false)", sequenceName(bucketName), value+1).Err()
+}
+
+// sequenceValue converts a metadata value to a sequence value. Metadata read
+// back from JSON holds float64 values.
+func sequenceValue(v any) (int64, bool) {
+	switch value := v.(type) {
+	case float64:
+		return int64(value), true
+	case int:
+		return int64(value), true
+	case int64:
+		return value, true
+	}
+
+	return 0, false
+}
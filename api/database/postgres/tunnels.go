@@ -0,0 +1,89 @@
+package postgres
+
+import (
+	"fmt"
+
+	portainer "github.com/portainer/portainer/api"
+)
+
+// TunnelRoutesTable registers the reverse tunnels of the Edge environments,
+// the instances sharing the database use it to find the one holding the chisel
+// session of an agent. The changes are notified as the ones of a bucket.
+const TunnelRoutesTable = "tunnel_routes"
+
+// TunnelRoutesBucket returns the bucket of the notified changes of the tunnel routes
+func (connection *DbConnection) TunnelRoutesBucket() string {
+	return TunnelRoutesTable
+}
+
+// SaveTunnelRoute creates or replaces the registry entry of a tunnel
+func (connection *DbConnection) SaveTunnelRoute(route portainer.TunnelRoute) error {
+	query := fmt.Sprintf(`INSERT INTO %s (endpoint_id, owner, port, credentials, updated_at) VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (endpoint_id) DO UPDATE SET owner = EXCLUDED.owner, port = EXCLUDED.port, credentials = EXCLUDED.credentials, updated_at = EXCLUDED.updated_at`, TunnelRoutesTable)
+
+	return connection.writeTunnelRoute(route.EndpointID, query, int64(route.EndpointID), route.Owner, route.Port, route.Credentials, route.UpdatedAt)
+}
+
+// DeleteTunnelRoute removes the registry entry of a closed tunnel
+func (connection *DbConnection) DeleteTunnelRoute(endpointID portainer.EndpointID) error {
+	return connection.writeTunnelRoute(endpointID, fmt.Sprintf("DELETE FROM %s WHERE endpoint_id = $1", TunnelRoutesTable), int64(endpointID))
+}
+
+func (connection *DbConnection) writeTunnelRoute(endpointID portainer.EndpointID, query string, args ...any) error {
+	if connection.DB == nil {
+		return ErrNoConnection
+	}
+
+	return connection.UpdateTx(func(tx portainer.Transaction) error {
+		pgTx := tx.(*DbTransaction)
+
+		if _, err := pgTx.tx.ExecContext(pgTx.ctx, query, args...); err != nil {
+			return fmt.Errorf("failed to write the tunnel route: %w", err)
+		}
+
+		pgTx.recordChange(TunnelRoutesTable, int(endpointID))
+
+		return nil
+	})
+}
+
+// LoadTunnelRoute returns the registry entry of a tunnel, nil when the tunnel is closed
+func (connection *DbConnection) LoadTunnelRoute(endpointID portainer.EndpointID) (*portainer.TunnelRoute, error) {
+	routes, err := connection.loadTunnelRoutes(fmt.Sprintf("SELECT endpoint_id, owner, port, credentials, updated_at FROM %s WHERE endpoint_id = $1", TunnelRoutesTable), int64(endpointID))
+	if err != nil || len(routes) == 0 {
+		return nil, err
+	}
+
+	return &routes[0], nil
+}
+
+// LoadTunnelRoutes returns the registry entries of every open tunnel
+func (connection *DbConnection) LoadTunnelRoutes() ([]portainer.TunnelRoute, error) {
+	return connection.loadTunnelRoutes(fmt.Sprintf("SELECT endpoint_id, owner, port, credentials, updated_at FROM %s", TunnelRoutesTable))
+}
+
+func (connection *DbConnection) loadTunnelRoutes(query string, args ...any) ([]portainer.TunnelRoute, error) {
+	if connection.DB == nil {
+		return nil, ErrNoConnection
+	}
+
+	rows, err := connection.DB.QueryContext(connection.ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load the tunnel routes: %w", err)
+	}
+	defer rows.Close()
+
+	var routes []portainer.TunnelRoute
+	for rows.Next() {
+		var route portainer.TunnelRoute
+		var endpointID int64
+		if err := rows.Scan(&endpointID, &route.Owner, &route.Port, &route.Credentials, &route.UpdatedAt); err != nil {
+			return nil, err
+		}
+
+		route.EndpointID = portainer.EndpointID(endpointID)
+		routes = append(routes, route)
+	}
+
+	return routes, rows.Err()
+}
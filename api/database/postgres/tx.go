@@ -1,16 +1,19 @@
 package postgres
 
 import (
+	"context"
 	"database/sql"
-	"encoding/json"
+	"encoding/binary"
+	"errors"
 	"fmt"
+	"math"
 	"reflect"
-	"context"
-	"encoding/binary"
+	"strconv"
 
-	// "github.com/jmoiron/sqlx"
+	portainer "github.com/portainer/portainer/api"
 	dserrors "github.com/portainer/portainer/api/dataservices/errors"
 
+	"github.com/lib/pq"
 	"github.com/rs/zerolog/log"
 )
 
@@ -18,286 +21,346 @@ type DbTransaction struct {
 	conn *DbConnection
 	tx   *sql.Tx
 	ctx  context.Context
+
+	// changes holds the keys written or deleted in every bucket, they are
+	// notified to the other instances on commit
+	changes map[string][]string
+
+	// revisions holds the revision of the rows read by the transaction, a
+	// row is only written back when nobody else updated it in the meantime
+	revisions map[rowKey]int64
 }
 
-func (tx *DbTransaction) SetServiceName(bucketName string) error {
-    // Create a table that closely mimics BoltDB's key-value storage pattern
-    createTableQuery := fmt.Sprintf(`
-        CREATE TABLE IF NOT EXISTS %s (
-            id TEXT PRIMARY KEY,
-            data JSONB NOT NULL
-        )`, bucketName)
-    
-    _, err := tx.tx.Exec(createTableQuery)
-    return err
+type rowKey struct {
+	bucket string
+	id     any
 }
 
-func (tx *DbTransaction) GetObject(bucketName string, key []byte, object any) error {
-    // Determine the key format
-    var keyValue any
-    keyInt := binary.BigEndian.Uint64(key)
-    keyStr := fmt.Sprintf("%d", keyInt)
-    
-    if bucketName == "settings" || bucketName == "ssl" || bucketName == "version" {
-        keyValue = string(key) // Use the key as a string for these buckets
-    } else {
-        keyValue = keyStr // Default to the integer representation
-    }
-
-    // Construct the query
-    query := fmt.Sprintf("SELECT data FROM %s WHERE id = $1", bucketName)
-    fmt.Println("query:", query)
-    fmt.Println("bucketName in getobj:", bucketName)
-    fmt.Println("keyValue in getobj:", keyValue)
-
-    // Execute the query
-    var rawData []byte
-    row := tx.tx.QueryRow(query, keyValue)
-    err := row.Scan(&rawData)
-
-    if err != nil {
-        fmt.Println("row.Scan error:", err)
-        if err == sql.ErrNoRows {
-            return fmt.Errorf("%w (bucket=%s, key=%v)", dserrors.ErrObjectNotFound, bucketName, keyValue)
-        }
-        return err
-    }
-
-    // Unmarshal the JSON data into the object
-    err = json.Unmarshal(rawData, object)
-    if err != nil {
-        fmt.Println("json.Unmarshal error:", err)
-        return err
-    }
-
-    return nil
+func (tx *DbTransaction) SetServiceName(bucketName string, keyKind portainer.KeyKind) error {
+	if err := createBucket(tx.ctx, tx.tx, bucketName, keyKind); err != nil {
+		return err
+	}
+
+	tx.conn.keyKinds.Store(bucketName, keyKind)
+
+	return nil
 }
 
+func (tx *DbTransaction) GetObject(bucketName string, key []byte, object any) error {
+	keyValue, err := tx.key(bucketName, key)
+	if err != nil {
+		return err
+	}
+
+	var data, payload []byte
+	var revision int64
+	query := fmt.Sprintf("SELECT data, payload, revision FROM %s WHERE id = $1", pq.QuoteIdentifier(bucketName))
+	err = tx.tx.QueryRowContext(tx.ctx, query, keyValue).Scan(&data, &payload, &revision)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("%w (bucket=%s, key=%v)", dserrors.ErrObjectNotFound, bucketName, keyValue)
+	} else if err != nil {
+		return err
+	}
 
-func (tx *DbTransaction) UpdateObject(bucketName string, key []byte, object any) error {
-    data, err := json.Marshal(object)
-    if err != nil {
-        return fmt.Errorf("failed to marshal object: %w", err)
-    }
-
-    stringObj := string(data)
-    fmt.Println("bucketName in updateobj:", bucketName)
-    fmt.Println("key in updateobj:", string(key))
-    fmt.Println("stringobj in updateobj:", stringObj)
-
-    var keyValue any
-    if bucketName == "settings" || bucketName == "ssl" || bucketName == "version" {
-        keyValue = string(key)
-    } else {
-        keyValue = fmt.Sprintf("%d", binary.BigEndian.Uint64(key))
-    }
-
-    // Check if the key exists
-    selectQuery := fmt.Sprintf("SELECT id FROM %s WHERE id = $1", bucketName)
-    fmt.Println("query in updateobj: ", selectQuery)
-
-    var existingKey string
-    err = tx.tx.QueryRow(selectQuery, keyValue).Scan(&existingKey)
-    if err == sql.ErrNoRows {
-        // Key doesn't exist, insert it
-        insertQuery := fmt.Sprintf("INSERT INTO %s (id, data) VALUES ($1, $2)", bucketName)
-        _, err = tx.tx.Exec(insertQuery, keyValue, stringObj)
-        if err != nil {
-            return fmt.Errorf("failed to insert data: %w", err)
-        }
-    } else if err != nil {
-        return fmt.Errorf("failed to query database: %w", err)
-    } else {
-        // Update the existing key
-        updateQuery := fmt.Sprintf("UPDATE %s SET data = $1 WHERE id = $2", bucketName)
-        _, err = tx.tx.Exec(updateQuery, stringObj, keyValue)
-        if err != nil {
-            return fmt.Errorf("failed to execute update query: %w", err)
-        }
-    }
-
-    return nil
+	if err := tx.conn.decodeRow(data, payload, object); err != nil {
+		return err
+	}
+
+	tx.setRevision(bucketName, keyValue, revision)
+
+	return nil
 }
 
+func (tx *DbTransaction) UpdateObject(bucketName string, key []byte, object any) error {
+	keyValue, err := tx.key(bucketName, key)
+	if err != nil {
+		return err
+	}
+
+	return tx.put(bucketName, keyValue, object)
+}
 
 func (tx *DbTransaction) DeleteObject(bucketName string, key []byte) error {
-	query := fmt.Sprintf("DELETE FROM %s WHERE id = $1", bucketName)
-	keyInt := binary.BigEndian.Uint64(key)
-    keyStr := fmt.Sprintf("%d", keyInt)
-	_, err := tx.tx.Exec(query, keyStr)
-	return err
+	keyValue, err := tx.key(bucketName, key)
+	if err != nil {
+		return err
+	}
+
+	query := fmt.Sprintf("DELETE FROM %s WHERE id = $1", pq.QuoteIdentifier(bucketName))
+	if _, err := tx.tx.ExecContext(tx.ctx, query, keyValue); err != nil {
+		return err
+	}
+
+	delete(tx.revisions, rowKey{bucketName, keyValue})
+	tx.recordChange(bucketName, keyValue)
+
+	return nil
 }
 
 func (tx *DbTransaction) DeleteAllObjects(bucketName string, obj any, matchingFn func(o any) (id int, ok bool)) error {
-	// Retrieve all objects
-	query := fmt.Sprintf("SELECT id, data FROM %s", bucketName)
-	rows, err := tx.tx.Query(query)
+	query := fmt.Sprintf("SELECT data, payload FROM %s", pq.QuoteIdentifier(bucketName))
+	rows, err := tx.tx.QueryContext(tx.ctx, query)
 	if err != nil {
 		return err
 	}
 	defer rows.Close()
 
-	var idsToDelete []int
-
-	// Use reflection to create a new slice of the same type as obj
+	// The matching functions expect values, not pointers
 	objType := reflect.TypeOf(obj)
+	if objType.Kind() == reflect.Pointer {
+		objType = objType.Elem()
+	}
 
+	var ids []int
 	for rows.Next() {
-		var id string
-		var rawData []byte
-
-		if err := rows.Scan(&id, &rawData); err != nil {
+		var data, payload []byte
+		if err := rows.Scan(&data, &payload); err != nil {
 			return err
 		}
 
-		// Unmarshal the object
-		tempObj := reflect.New(objType).Elem()
-		if err := json.Unmarshal(rawData, tempObj.Addr().Interface()); err != nil {
+		element := reflect.New(objType)
+		if err := tx.conn.decodeRow(data, payload, element.Interface()); err != nil {
 			return err
 		}
 
-		// Check if the object matches the deletion criteria
-		if deleteID, ok := matchingFn(tempObj.Interface()); ok {
-			idsToDelete = append(idsToDelete, deleteID)
+		if id, ok := matchingFn(element.Elem().Interface()); ok {
+			ids = append(ids, id)
 		}
 	}
 
-	// Delete matching objects
-	for _, id := range idsToDelete {
-		deleteQuery := fmt.Sprintf("DELETE FROM %s WHERE id = $1", bucketName)
-		_, err := tx.tx.Exec(deleteQuery, id)
-		if err != nil {
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	deleteQuery := fmt.Sprintf("DELETE FROM %s WHERE id = $1", pq.QuoteIdentifier(bucketName))
+	for _, id := range ids {
+		keyValue := tx.intKey(bucketName, id)
+		if _, err := tx.tx.ExecContext(tx.ctx, deleteQuery, keyValue); err != nil {
 			return err
 		}
+
+		delete(tx.revisions, rowKey{bucketName, keyValue})
+		tx.recordChange(bucketName, keyValue)
 	}
 
 	return nil
 }
 
 func (tx *DbTransaction) GetNextIdentifier(bucketName string) int {
-	var nextID int
-	// Convert the max(id) to an integer
-	query := fmt.Sprintf("SELECT COALESCE(CAST(MAX(CAST(id AS INTEGER)) AS INTEGER), 0) + 1 FROM %s", bucketName)
-	
-	err := tx.tx.QueryRow(query).Scan(&nextID)
+	id, err := nextSequence(tx.ctx, tx.tx, bucketName)
 	if err != nil {
-		log.Error().
-			Err(err).
-			Str("bucket", bucketName).
-			Msg("failed to get the next identifier")
+		log.Error().Err(err).Str("bucket", bucketName).Msg("failed to get the next identifier")
+
 		return 0
 	}
 
-	return nextID
+	return id
 }
 
 func (tx *DbTransaction) CreateObject(bucketName string, fn func(uint64) (int, any)) error {
-	// Get the next sequence number
-	var seqID uint64
-	// Convert the max(id) to an integer
-	query := fmt.Sprintf("SELECT COALESCE(CAST(MAX(CAST(id AS INTEGER)) AS INTEGER), 0) + 1 FROM %s", bucketName)
-	err := tx.tx.QueryRow(query).Scan(&seqID)
+	seqID, err := nextSequence(tx.ctx, tx.tx, bucketName)
 	if err != nil {
 		return fmt.Errorf("failed to fetch next sequence ID: %w", err)
 	}
 
-	// Generate the object
-	id, obj := fn(seqID)
+	id, obj := fn(uint64(seqID))
+
+	return tx.put(bucketName, tx.intKey(bucketName, id), obj)
+}
+
+func (tx *DbTransaction) CreateObjectWithId(bucketName string, id int, obj any) error {
+	return tx.put(bucketName, tx.intKey(bucketName, id), obj)
+}
 
-	// Marshal the object
-	data, err := json.Marshal(obj)
+func (tx *DbTransaction) CreateObjectWithStringId(bucketName string, id []byte, obj any) error {
+	keyValue, err := tx.key(bucketName, id)
 	if err != nil {
-		return fmt.Errorf("failed to marshal object: %w", err)
+		return err
 	}
 
-	// Insert the object
-	insertQuery := fmt.Sprintf("INSERT INTO %s (id, data) VALUES ($1, $2)", bucketName)
-	_, err = tx.tx.Exec(insertQuery, id, data)
-	if err != nil {
-		return fmt.Errorf("failed to insert object into bucket %s: %w", bucketName, err)
+	return tx.put(bucketName, keyValue, obj)
+}
+
+// GetAll returns the objects of a bucket in key order, like a BoltDB cursor
+func (tx *DbTransaction) GetAll(bucketName string, obj any, appendFn func(o any) (any, error)) error {
+	query := fmt.Sprintf("SELECT data, payload FROM %s ORDER BY %s", pq.QuoteIdentifier(bucketName), orderByKey(tx.keyKind(bucketName)))
+
+	return tx.scan(query, obj, appendFn)
+}
+
+// GetAllWithKeyPrefix returns the objects whose raw key starts with keyPrefix,
+// in key order. For integer keyed buckets the prefix applies to the 8-byte big
+// endian representation of the key, exactly as it does on BoltDB.
+func (tx *DbTransaction) GetAllWithKeyPrefix(bucketName string, keyPrefix []byte, obj any, appendFn func(o any) (any, error)) error {
+	table := pq.QuoteIdentifier(bucketName)
+
+	keyKind := tx.keyKind(bucketName)
+	if keyKind == portainer.KeyKindString {
+		query := fmt.Sprintf("SELECT data, payload FROM %s WHERE starts_with(id, $1) ORDER BY %s", table, orderByKey(keyKind))
+
+		return tx.scan(query, obj, appendFn, string(keyPrefix))
 	}
 
-	return nil
+	low, high, ok := keyPrefixRange(keyPrefix)
+	if !ok {
+		return nil
+	}
+
+	query := fmt.Sprintf("SELECT data, payload FROM %s WHERE id BETWEEN $1 AND $2 ORDER BY %s", table, orderByKey(keyKind))
+
+	return tx.scan(query, obj, appendFn, low, high)
 }
 
-func (tx *DbTransaction) CreateObjectWithId(bucketName string, id int, obj any) error {
-	data, err := json.Marshal(obj)
+// put encodes object through the connection codec and writes it. A row read
+// by the transaction is only replaced when its revision did not change since,
+// ErrConflict is returned otherwise. The other rows are upserted, matching the
+// overwrite semantics of a BoltDB Put.
+func (tx *DbTransaction) put(bucketName string, keyValue any, object any) error {
+	data, payload, err := tx.conn.encodeRow(object)
 	if err != nil {
-		return err
+		return fmt.Errorf("failed to marshal object: %w", err)
+	}
+
+	// A nil []byte is sent as NULL, a string is required for the JSONB column
+	var jsonData any
+	if data != nil {
+		jsonData = string(data)
+	}
+
+	table := pq.QuoteIdentifier(bucketName)
+
+	query := fmt.Sprintf(`INSERT INTO %s AS t (id, data, payload) VALUES ($1, $2, $3)
+		ON CONFLICT (id) DO UPDATE SET data = EXCLUDED.data, payload = EXCLUDED.payload, revision = t.revision + 1
+		RETURNING revision`, table)
+	args := []any{keyValue, jsonData, payload}
+
+	expected, compare := tx.revisions[rowKey{bucketName, keyValue}]
+	if compare {
+		query = fmt.Sprintf(`UPDATE %s SET data = $2, payload = $3, revision = revision + 1
+			WHERE id = $1 AND revision = $4 RETURNING revision`, table)
+		args = append(args, expected)
 	}
 
-	query := fmt.Sprintf("INSERT INTO %s (id, data) VALUES ($1, $2)", bucketName)
-	_, err = tx.tx.Exec(query, id, data)
-	return err
+	var revision int64
+	err = tx.tx.QueryRowContext(tx.ctx, query, args...).Scan(&revision)
+	if compare && errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("%w (bucket=%s, key=%v)", dserrors.ErrConflict, bucketName, keyValue)
+	} else if err != nil {
+		return fmt.Errorf("failed to write object into bucket %s: %w", bucketName, err)
+	}
+
+	tx.setRevision(bucketName, keyValue, revision)
+	tx.recordChange(bucketName, keyValue)
+
+	return nil
 }
 
-func (tx *DbTransaction) CreateObjectWithStringId(bucketName string, id []byte, obj any) error {
-	data, err := json.Marshal(obj)
-	if err != nil {
-		return err
+func (tx *DbTransaction) setRevision(bucketName string, keyValue any, revision int64) {
+	if tx.revisions == nil {
+		tx.revisions = make(map[rowKey]int64)
 	}
 
-	query := fmt.Sprintf("INSERT INTO %s (id, data) VALUES ($1, $2)", bucketName)
-	_, err = tx.tx.Exec(query, string(id), data)
-	return err
+	tx.revisions[rowKey{bucketName, keyValue}] = revision
 }
 
-func (tx *DbTransaction) GetAll(bucketName string, obj any, appendFn func(o any) (any, error)) error {
-	query := fmt.Sprintf("SELECT data FROM %s", bucketName)
-	rows, err := tx.tx.Query(query)
+// scan decodes every row returned by query and hands it to appendFn
+func (tx *DbTransaction) scan(query string, obj any, appendFn func(o any) (any, error), args ...any) error {
+	rows, err := tx.tx.QueryContext(tx.ctx, query, args...)
 	if err != nil {
 		return err
 	}
 	defer rows.Close()
 
 	for rows.Next() {
-		var rawData []byte
-		if err := rows.Scan(&rawData); err != nil {
+		var data, payload []byte
+		if err := rows.Scan(&data, &payload); err != nil {
 			return err
 		}
 
-		// Unmarshal the object
-		err := json.Unmarshal(rawData, obj)
-		if err != nil {
+		if err := tx.conn.decodeRow(data, payload, obj); err != nil {
 			return err
 		}
 
-		// Call the append function
 		obj, err = appendFn(obj)
 		if err != nil {
 			return err
 		}
 	}
 
-	return nil
+	return rows.Err()
 }
 
-func (tx *DbTransaction) GetAllWithKeyPrefix(bucketName string, keyPrefix []byte, obj any, appendFn func(o any) (any, error)) error {
-	query := fmt.Sprintf("SELECT data FROM %s WHERE id LIKE $1", bucketName)
-	rows, err := tx.tx.Query(query, string(keyPrefix)+"%")
+// keyKind returns the key kind registered for a bucket by SetServiceName. Buckets
+// that were not registered by this process get it from their id column.
+func (tx *DbTransaction) keyKind(bucketName string) portainer.KeyKind {
+	if keyKind, ok := tx.conn.keyKinds.Load(bucketName); ok {
+		return keyKind.(portainer.KeyKind)
+	}
+
+	keyKind, exists, err := columnKeyKind(tx.ctx, tx.tx, bucketName)
 	if err != nil {
-		return err
+		log.Error().Err(err).Str("bucket", bucketName).Msg("failed to determine the key kind of the bucket")
+
+		return portainer.KeyKindInteger
 	}
-	defer rows.Close()
 
-	for rows.Next() {
-		var rawData []byte
-		if err := rows.Scan(&rawData); err != nil {
-			return err
-		}
+	if exists {
+		tx.conn.keyKinds.Store(bucketName, keyKind)
+	}
 
-		// Unmarshal the object
-		err := json.Unmarshal(rawData, obj)
-		if err != nil {
-			return err
-		}
+	return keyKind
+}
 
-		// Call the append function
-		obj, err = appendFn(obj)
-		if err != nil {
-			return err
-		}
+// key converts a BoltDB style key into the value of the id column. Integer keys
+// are 8-byte big endian values built by ConvertToKey.
+func (tx *DbTransaction) key(bucketName string, key []byte) (any, error) {
+	if tx.keyKind(bucketName) == portainer.KeyKindString {
+		return string(key), nil
 	}
 
-	return nil
-}
\ No newline at end of file
+	if len(key) != 8 {
+		return nil, fmt.Errorf("%w (bucket=%s, key=%q)", ErrInvalidKey, bucketName, key)
+	}
+
+	return int64(binary.BigEndian.Uint64(key)), nil
+}
+
+// intKey converts an integer identifier into the value of the id column
+func (tx *DbTransaction) intKey(bucketName string, id int) any {
+	if tx.keyKind(bucketName) == portainer.KeyKindString {
+		return strconv.Itoa(id)
+	}
+
+	return int64(id)
+}
+
+// orderByKey returns the ORDER BY expression matching the BoltDB byte-wise key ordering
+func orderByKey(keyKind portainer.KeyKind) string {
+	if keyKind == portainer.KeyKindString {
+		return `id COLLATE "C"`
+	}
+
+	return "id"
+}
+
+// keyPrefixRange returns the range of integer keys whose 8-byte big endian
+// representation starts with prefix
+func keyPrefixRange(prefix []byte) (low, high int64, ok bool) {
+	if len(prefix) > 8 {
+		return 0, 0, false
+	}
+
+	var lowKey, highKey [8]byte
+	copy(lowKey[:], prefix)
+	copy(highKey[:], prefix)
+	for i := len(prefix); i < 8; i++ {
+		highKey[i] = 0xff
+	}
+
+	l, h := binary.BigEndian.Uint64(lowKey[:]), binary.BigEndian.Uint64(highKey[:])
+	if l > math.MaxInt64 {
+		return 0, 0, false
+	}
+
+	return int64(l), int64(min(h, math.MaxInt64)), true
+}
@@ -0,0 +1,36 @@
+package postgres
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_KeyPrefixRange(t *testing.T) {
+	is := assert.New(t)
+
+	tests := []struct {
+		prefix []byte
+		low    int64
+		high   int64
+		ok     bool
+	}{
+		{prefix: nil, low: 0, high: math.MaxInt64, ok: true},
+		{prefix: []byte{0, 0, 0, 0, 0, 0, 0, 1}, low: 1, high: 1, ok: true},
+		{prefix: []byte{0, 0, 0, 0, 0, 0, 1}, low: 256, high: 511, ok: true},
+		{prefix: []byte{0x7f}, low: 0x7f << 56, high: math.MaxInt64, ok: true},
+		{prefix: []byte{0x80}, ok: false},
+		{prefix: []byte{0, 0, 0, 0, 0, 0, 0, 1, 0}, ok: false},
+	}
+
+	for _, test := range tests {
+		low, high, ok := keyPrefixRange(test.prefix)
+		is.Equal(test.ok, ok, "prefix %v", test.prefix)
+
+		if test.ok {
+			is.Equal(test.low, low, "prefix %v", test.prefix)
+			is.Equal(test.high, high, "prefix %v", test.prefix)
+		}
+	}
+}
@@ -0,0 +1,33 @@
+package query
+
+// IndexKind describes the filters an index speeds up
+type IndexKind int
+
+const (
+	// IndexEquals speeds up the Equals filters, and the AtLeast and AtMost
+	// filters on numeric fields
+	IndexEquals IndexKind = iota
+	// IndexEqualFold speeds up the EqualFold filters
+	IndexEqualFold
+	// IndexElements speeds up the Contains and HasKey filters
+	IndexElements
+)
+
+// Index describes an index on a field of the objects of a bucket
+type Index struct {
+	Field string
+	Kind  IndexKind
+}
+
+// Querier is implemented by the transactions and connections able to select
+// the objects of a bucket by their fields without decoding the whole bucket.
+// The objects are passed to appendFn in key order, like GetAll does.
+type Querier interface {
+	Query(bucketName string, filter Filter, obj any, appendFn func(o any) (any, error)) error
+}
+
+// Indexer is implemented by the connections maintaining indexes on the fields
+// of the objects, the indexes are created when they do not exist
+type Indexer interface {
+	CreateIndexes(bucketName string, indexes ...Index) error
+}
@@ -0,0 +1,286 @@
+package query
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/fvbommel/sortorder"
+	"github.com/segmentio/encoding/json"
+)
+
+// ErrInvalidCursor is returned when the cursor of a page was not built for its sort
+var ErrInvalidCursor = errors.New("invalid page cursor")
+
+// KeyField is the field holding the key of the objects, the backends that
+// cannot read the key of a row read it from the object
+const KeyField = "Id"
+
+// Sort orders the objects of a page by a field, the objects sharing a value are
+// ordered by key. The objects are in key order when Field is empty.
+type Sort struct {
+	Field string
+	// Text compares the values as strings in natural order, "env2" before
+	// "env10", they are compared as numbers otherwise
+	Text bool
+	Desc bool
+}
+
+// Page selects a window of the objects matched by Filter. The window starts
+// after the object Cursor was built from, or after Offset objects when there
+// is no cursor, and holds at most Limit objects when Limit is positive.
+type Page struct {
+	Filter Filter
+	Sort   Sort
+	Offset int
+	Limit  int
+	Cursor string
+}
+
+// PageInfo describes the window selected by a Page
+type PageInfo struct {
+	// Total is the number of objects matched by the filter
+	Total int
+	// Next is the cursor of the next window, empty on the last one
+	Next string
+}
+
+// Pager is implemented by the transactions and connections able to select a
+// page of objects without decoding the whole bucket
+type Pager interface {
+	QueryPage(bucketName string, page Page, obj any, appendFn func(o any) (any, error)) (PageInfo, error)
+	Count(bucketName string, filter Filter, obj any) (int, error)
+}
+
+// cursor is the position of the last object of a window, the sort it was built
+// for is recorded to reject the cursors reused with another sort
+type cursor struct {
+	Field string `json:"f,omitempty"`
+	Desc  bool   `json:"d,omitempty"`
+	Value any    `json:"v"`
+	Key   any    `json:"k"`
+}
+
+// EncodeCursor returns the cursor of the window following the object whose
+// sort value and key are given
+func EncodeCursor(sort Sort, value, key any) string {
+	data, err := json.Marshal(cursor{Field: sort.Field, Desc: sort.Desc, Value: value, Key: key})
+	if err != nil {
+		return ""
+	}
+
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// DecodeCursor returns the sort value and the key recorded by EncodeCursor,
+// the integer keys are returned as int64
+func DecodeCursor(sort Sort, encoded string) (value, key any, err error) {
+	data, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, nil, ErrInvalidCursor
+	}
+
+	var c cursor
+	if err := json.Unmarshal(data, &c); err != nil || c.Key == nil {
+		return nil, nil, ErrInvalidCursor
+	}
+
+	if c.Field != sort.Field || c.Desc != sort.Desc {
+		return nil, nil, fmt.Errorf("%w: it was built for another sort", ErrInvalidCursor)
+	}
+
+	if sort.Field != "" {
+		if _, ok := c.Value.(string); sort.Text != ok {
+			return nil, nil, ErrInvalidCursor
+		}
+	}
+
+	if n, ok := c.Key.(float64); ok {
+		c.Key = int64(n)
+	}
+
+	return c.Value, c.Key, nil
+}
+
+// CollectFn returns an appendFn collecting the decoded objects
+func CollectFn(objects *[]any) func(o any) (any, error) {
+	return func(o any) (any, error) {
+		*objects = append(*objects, o)
+
+		return reflect.New(reflect.TypeOf(o).Elem()).Interface(), nil
+	}
+}
+
+// ApplyPage selects the page from objects decoded in key order and hands its
+// objects to appendFn, it is used by the backends that cannot select the page
+// in the database
+func ApplyPage(objects []any, page Page, appendFn func(o any) (any, error)) (PageInfo, error) {
+	matched := slices.DeleteFunc(objects, func(o any) bool { return !page.Filter.Match(o) })
+	info := PageInfo{Total: len(matched)}
+
+	slices.SortStableFunc(matched, page.Sort.compare)
+
+	start := min(max(page.Offset, 0), len(matched))
+	if page.Cursor != "" {
+		value, key, err := DecodeCursor(page.Sort, page.Cursor)
+		if err != nil {
+			return PageInfo{}, err
+		}
+
+		start, _ = slices.BinarySearchFunc(matched, struct{}{}, func(o any, _ struct{}) int {
+			if c := page.Sort.compareValues(page.Sort.value(o), value, keyOf(o), key); c != 0 {
+				return c
+			}
+
+			// The object the cursor was built from belongs to the previous window
+			return -1
+		})
+	}
+
+	end := len(matched)
+	if page.Limit > 0 && start+page.Limit < end {
+		end = start + page.Limit
+
+		last := matched[end-1]
+		info.Next = EncodeCursor(page.Sort, page.Sort.value(last), keyOf(last))
+	}
+
+	for _, o := range matched[start:end] {
+		if _, err := appendFn(o); err != nil {
+			return PageInfo{}, err
+		}
+	}
+
+	return info, nil
+}
+
+func (s Sort) compare(a, b any) int {
+	return s.compareValues(s.value(a), s.value(b), keyOf(a), keyOf(b))
+}
+
+// compareValues orders two objects by their sort value, then by key
+func (s Sort) compareValues(va, vb, ka, kb any) int {
+	var c int
+	if a, ok := va.(string); ok && s.Text {
+		b, _ := vb.(string)
+		c = naturalCompare(a, b)
+	} else {
+		c = compareScalars(va, vb)
+	}
+
+	if c == 0 {
+		c = compareScalars(ka, kb)
+	}
+
+	if s.Desc {
+		return -c
+	}
+
+	return c
+}
+
+// value returns the sort value of an object, a string or a float64
+func (s Sort) value(o any) any {
+	if s.Field == "" {
+		return nil
+	}
+
+	field, ok := fieldByName(reflect.ValueOf(o), s.Field)
+
+	if s.Text {
+		if ok && field.Kind() == reflect.String {
+			return field.String()
+		}
+
+		return ""
+	}
+
+	if !ok {
+		return float64(0)
+	}
+
+	return number(field)
+}
+
+func keyOf(o any) any {
+	field, ok := fieldByName(reflect.ValueOf(o), KeyField)
+	if !ok {
+		return nil
+	}
+
+	if field.Kind() == reflect.String {
+		return field.String()
+	}
+
+	return number(field)
+}
+
+func number(v reflect.Value) float64 {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint())
+	case reflect.Float32, reflect.Float64:
+		return v.Float()
+	}
+
+	return 0
+}
+
+// compareScalars compares strings and numbers, the numbers of the cursors are
+// decoded as float64 or int64
+func compareScalars(a, b any) int {
+	switch a := a.(type) {
+	case string:
+		if b, ok := b.(string); ok {
+			return strings.Compare(a, b)
+		}
+	case float64, int64:
+		x, y := toFloat(a), toFloat(b)
+		switch {
+		case x < y:
+			return -1
+		case x > y:
+			return 1
+		}
+	}
+
+	return 0
+}
+
+// naturalCompare compares the strings in natural order, the strings that
+// NaturalLess considers equal are compared byte-wise
+func naturalCompare(a, b string) int {
+	switch {
+	case sortorder.NaturalLess(a, b):
+		return -1
+	case sortorder.NaturalLess(b, a):
+		return 1
+	}
+
+	return strings.Compare(a, b)
+}
+
+func toFloat(v any) float64 {
+	switch v := v.(type) {
+	case float64:
+		return v
+	case int64:
+		return float64(v)
+	}
+
+	return 0
+}
+
+// CountFn returns an appendFn counting the objects matched by filter
+func CountFn(filter Filter, count *int) func(o any) (any, error) {
+	return MatchFn(filter, func(o any) (any, error) {
+		*count++
+
+		return reflect.New(reflect.TypeOf(o).Elem()).Interface(), nil
+	})
+}
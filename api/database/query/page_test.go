@@ -0,0 +1,99 @@
+package query
+
+import (
+	"testing"
+
+	portainer "github.com/portainer/portainer/api"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func stacksPage(is *assert.Assertions, stacks []portainer.Stack, page Page) ([]portainer.StackID, PageInfo) {
+	objects := make([]any, 0, len(stacks))
+	for _, stack := range stacks {
+		objects = append(objects, &stack)
+	}
+
+	var ids []portainer.StackID
+	info, err := ApplyPage(objects, page, func(o any) (any, error) {
+		ids = append(ids, o.(*portainer.Stack).ID)
+
+		return &portainer.Stack{}, nil
+	})
+	is.NoError(err)
+
+	return ids, info
+}
+
+func TestApplyPage(t *testing.T) {
+	is := assert.New(t)
+
+	stacks := []portainer.Stack{
+		{ID: 1, Name: "web", CreationDate: 30},
+		{ID: 2, Name: "DB", CreationDate: 10},
+		{ID: 3, Name: "cache", CreationDate: 20},
+		{ID: 4, Name: "db", CreationDate: 20},
+		{ID: 5, Name: "worker", Type: portainer.DockerSwarmStack},
+	}
+
+	ids, info := stacksPage(is, stacks, Page{})
+	is.Equal([]portainer.StackID{1, 2, 3, 4, 5}, ids)
+	is.Equal(PageInfo{Total: 5}, info)
+
+	// The names are compared in natural order, the upper case letters first
+	ids, info = stacksPage(is, stacks, Page{Sort: Sort{Field: "Name", Text: true}, Offset: 1, Limit: 2})
+	is.Equal([]portainer.StackID{3, 4}, ids)
+	is.NotEmpty(info.Next)
+
+	ids, _ = stacksPage(is, stacks, Page{Sort: Sort{Field: "Name", Text: true}, Limit: 2, Cursor: info.Next})
+	is.Equal([]portainer.StackID{1, 5}, ids)
+
+	ids, info = stacksPage(is, stacks, Page{Sort: Sort{Field: "CreationDate", Desc: true}, Filter: Not(Equals("Type", portainer.DockerSwarmStack)), Limit: 3})
+	is.Equal([]portainer.StackID{1, 4, 3}, ids)
+	is.Equal(4, info.Total)
+
+	ids, info = stacksPage(is, stacks, Page{Sort: Sort{Field: "CreationDate", Desc: true}, Filter: Not(Equals("Type", portainer.DockerSwarmStack)), Limit: 3, Cursor: info.Next})
+	is.Equal([]portainer.StackID{2}, ids)
+	is.Empty(info.Next)
+}
+
+func TestApplyPageNaturalOrder(t *testing.T) {
+	is := assert.New(t)
+
+	stacks := []portainer.Stack{
+		{ID: 1, Name: "env10"},
+		{ID: 2, Name: "env2"},
+		{ID: 3, Name: "env1"},
+		{ID: 4, Name: "Env3"},
+		{ID: 5, Name: "env2"},
+	}
+
+	ids, info := stacksPage(is, stacks, Page{Sort: Sort{Field: "Name", Text: true}, Limit: 3})
+	is.Equal([]portainer.StackID{4, 3, 2}, ids)
+
+	ids, _ = stacksPage(is, stacks, Page{Sort: Sort{Field: "Name", Text: true}, Limit: 3, Cursor: info.Next})
+	is.Equal([]portainer.StackID{5, 1}, ids, "env2 is before env10")
+
+	ids, _ = stacksPage(is, stacks, Page{Sort: Sort{Field: "Name", Text: true, Desc: true}})
+	is.Equal([]portainer.StackID{1, 5, 2, 3, 4}, ids)
+}
+
+func TestDecodeCursor(t *testing.T) {
+	is := assert.New(t)
+
+	sort := Sort{Field: "Name", Text: true}
+
+	value, key, err := DecodeCursor(sort, EncodeCursor(sort, "", 3))
+	is.NoError(err)
+	is.Equal("", value)
+	is.Equal(int64(3), key)
+
+	_, _, err = DecodeCursor(Sort{Field: "Name", Text: true, Desc: true}, EncodeCursor(sort, "db", 3))
+	is.ErrorIs(err, ErrInvalidCursor)
+
+	_, _, err = DecodeCursor(Sort{Field: "Name"}, EncodeCursor(sort, "db", 3))
+	is.ErrorIs(err, ErrInvalidCursor)
+
+	_, _, err = DecodeCursor(sort, "invalid")
+	is.ErrorIs(err, ErrInvalidCursor)
+}
@@ -0,0 +1,317 @@
+package query
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// Op is the operator of a Filter
+type Op int
+
+const (
+	// OpAll matches the objects matched by every sub-filter, the zero Filter
+	// matches every object
+	OpAll Op = iota
+	// OpAny matches the objects matched by at least one sub-filter
+	OpAny
+	// OpNot matches the objects not matched by its sub-filter
+	OpNot
+	// OpEquals matches the objects whose field equals the value
+	OpEquals
+	// OpEqualFold matches the objects whose string field equals the value under
+	// Unicode case folding
+	OpEqualFold
+	// OpContains matches the objects whose array field has an element equal to the value
+	OpContains
+	// OpHasKey matches the objects whose map field has the value as a key
+	OpHasKey
+	// OpIn matches the objects whose field equals one of the values
+	OpIn
+	// OpSearch matches the objects whose string field includes the value, ignoring case
+	OpSearch
+	// OpAtLeast matches the objects whose numeric field is greater than or equal to the value
+	OpAtLeast
+	// OpAtMost matches the objects whose numeric field is less than or equal to the value
+	OpAtMost
+)
+
+// Filter selects the objects of a bucket by the value of their fields. Fields
+// are named as they are in the JSON representation of the objects, the fields
+// of nested objects are separated by dots. Zero values are omitted from the
+// JSON of many objects, filtering on them is not supported: Not(Equals(f, true))
+// selects the objects whose boolean field is false.
+type Filter struct {
+	Op      Op
+	Field   string
+	Value   any
+	Filters []Filter
+}
+
+// Equals matches the objects whose field equals value
+func Equals(field string, value any) Filter {
+	return Filter{Op: OpEquals, Field: field, Value: value}
+}
+
+// EqualFold matches the objects whose string field equals value, ignoring case
+func EqualFold(field string, value string) Filter {
+	return Filter{Op: OpEqualFold, Field: field, Value: value}
+}
+
+// Contains matches the objects whose array field holds value
+func Contains(field string, value any) Filter {
+	return Filter{Op: OpContains, Field: field, Value: value}
+}
+
+// HasKey matches the objects whose map field has the key
+func HasKey(field string, key any) Filter {
+	return Filter{Op: OpHasKey, Field: field, Value: key}
+}
+
+// In matches the objects whose field equals one of values, none is matched
+// when values is empty
+func In[T any](field string, values []T) Filter {
+	filter := Filter{Op: OpIn, Field: field, Filters: make([]Filter, 0, len(values))}
+	for _, value := range values {
+		filter.Filters = append(filter.Filters, Equals(field, value))
+	}
+
+	return filter
+}
+
+// Search matches the objects whose string field includes term, ignoring case
+func Search(field string, term string) Filter {
+	return Filter{Op: OpSearch, Field: field, Value: term}
+}
+
+// AtLeast matches the objects whose numeric field is greater than or equal to value
+func AtLeast(field string, value any) Filter {
+	return Filter{Op: OpAtLeast, Field: field, Value: value}
+}
+
+// AtMost matches the objects whose numeric field is less than or equal to value
+func AtMost(field string, value any) Filter {
+	return Filter{Op: OpAtMost, Field: field, Value: value}
+}
+
+// Not matches the objects not matched by filter
+func Not(filter Filter) Filter {
+	return Filter{Op: OpNot, Filters: []Filter{filter}}
+}
+
+// All matches the objects matched by every filter
+func All(filters ...Filter) Filter {
+	return Filter{Op: OpAll, Filters: filters}
+}
+
+// Any matches the objects matched by one of the filters
+func Any(filters ...Filter) Filter {
+	return Filter{Op: OpAny, Filters: filters}
+}
+
+// Key returns the map key matched by an OpHasKey filter as it appears in JSON
+func (f Filter) Key() string {
+	return fmt.Sprint(f.Value)
+}
+
+// Match evaluates the filter against a decoded object, it is used by the
+// backends that cannot select the objects by their fields
+func (f Filter) Match(obj any) bool {
+	switch f.Op {
+	case OpAll:
+		for _, sub := range f.Filters {
+			if !sub.Match(obj) {
+				return false
+			}
+		}
+
+		return true
+	case OpAny, OpIn:
+		for _, sub := range f.Filters {
+			if sub.Match(obj) {
+				return true
+			}
+		}
+
+		return false
+	case OpNot:
+		return len(f.Filters) == 1 && !f.Filters[0].Match(obj)
+	}
+
+	field, ok := fieldByName(reflect.ValueOf(obj), f.Field)
+	if !ok {
+		return false
+	}
+
+	switch f.Op {
+	case OpEquals:
+		return equal(field, f.Value)
+	case OpEqualFold:
+		value, ok := f.Value.(string)
+
+		return ok && field.Kind() == reflect.String && strings.EqualFold(field.String(), value)
+	case OpContains:
+		if field.Kind() != reflect.Slice && field.Kind() != reflect.Array {
+			return false
+		}
+
+		for i := range field.Len() {
+			if equal(field.Index(i), f.Value) {
+				return true
+			}
+		}
+	case OpSearch:
+		value, ok := f.Value.(string)
+
+		return ok && field.Kind() == reflect.String && strings.Contains(strings.ToLower(field.String()), strings.ToLower(value))
+	case OpAtLeast:
+		c, ok := compareNumber(field, f.Value)
+
+		return ok && c >= 0
+	case OpAtMost:
+		c, ok := compareNumber(field, f.Value)
+
+		return ok && c <= 0
+	case OpHasKey:
+		if field.Kind() != reflect.Map {
+			return false
+		}
+
+		if key, ok := convert(f.Value, field.Type().Key()); ok {
+			return field.MapIndex(key).IsValid()
+		}
+	}
+
+	return false
+}
+
+func equal(field reflect.Value, value any) bool {
+	target, ok := convert(value, field.Type())
+
+	return ok && reflect.DeepEqual(field.Interface(), target.Interface())
+}
+
+// compareNumber compares a numeric field to a numeric value
+func compareNumber(field reflect.Value, value any) (int, bool) {
+	v := reflect.ValueOf(value)
+	if !v.IsValid() || !isNumber(field.Kind()) || !isNumber(v.Kind()) {
+		return 0, false
+	}
+
+	return compareScalars(number(field), number(v)), true
+}
+
+func isNumber(kind reflect.Kind) bool {
+	return kind >= reflect.Int && kind <= reflect.Float64 && kind != reflect.Uintptr
+}
+
+// convert converts value to t, the typed identifiers are compared with the
+// plain values they are built from
+func convert(value any, t reflect.Type) (reflect.Value, bool) {
+	v := reflect.ValueOf(value)
+	if !v.IsValid() || !v.Type().ConvertibleTo(t) {
+		return reflect.Value{}, false
+	}
+
+	// An integer converted to a string is a rune
+	if (t.Kind() == reflect.String) != (v.Kind() == reflect.String) {
+		return reflect.Value{}, false
+	}
+
+	return v.Convert(t), true
+}
+
+// jsonFields caches the index of the fields of a struct type by JSON name
+var jsonFields sync.Map
+
+// fieldByName returns the field of v at the dotted path name
+func fieldByName(v reflect.Value, name string) (reflect.Value, bool) {
+	for _, part := range strings.Split(name, ".") {
+		var ok bool
+		if v, ok = structField(v, part); !ok {
+			return reflect.Value{}, false
+		}
+	}
+
+	return v, true
+}
+
+func structField(v reflect.Value, name string) (reflect.Value, bool) {
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return reflect.Value{}, false
+		}
+
+		v = v.Elem()
+	}
+
+	if v.Kind() != reflect.Struct {
+		return reflect.Value{}, false
+	}
+
+	fields, ok := jsonFields.Load(v.Type())
+	if !ok {
+		fields, _ = jsonFields.LoadOrStore(v.Type(), structFields(v.Type()))
+	}
+
+	index, ok := fields.(map[string][]int)[name]
+	if !ok {
+		return reflect.Value{}, false
+	}
+
+	field, err := v.FieldByIndexErr(index)
+
+	return field, err == nil
+}
+
+// structFields maps the JSON names of the fields of t to their index,
+// following the embedded structs like encoding/json does
+func structFields(t reflect.Type) map[string][]int {
+	fields := make(map[string][]int)
+
+	for i := range t.NumField() {
+		f := t.Field(i)
+
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" || (!f.IsExported() && !f.Anonymous) {
+			continue
+		}
+
+		ft := f.Type
+		if ft.Kind() == reflect.Pointer {
+			ft = ft.Elem()
+		}
+
+		if f.Anonymous && name == "" && ft.Kind() == reflect.Struct {
+			for embedded, index := range structFields(ft) {
+				if _, ok := fields[embedded]; !ok {
+					fields[embedded] = append([]int{i}, index...)
+				}
+			}
+
+			continue
+		}
+
+		if name == "" {
+			name = f.Name
+		}
+
+		fields[name] = []int{i}
+	}
+
+	return fields
+}
+
+// MatchFn wraps the appendFn of a GetAll so that it only receives the objects
+// matched by the filter
+func MatchFn(filter Filter, appendFn func(o any) (any, error)) func(o any) (any, error) {
+	return func(o any) (any, error) {
+		if filter.Match(o) {
+			return appendFn(o)
+		}
+
+		// Decoding the next object into this one would keep the fields it omits
+		return reflect.New(reflect.TypeOf(o).Elem()).Interface(), nil
+	}
+}
@@ -0,0 +1,84 @@
+package query
+
+import (
+	"testing"
+
+	portainer "github.com/portainer/portainer/api"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFilterMatch(t *testing.T) {
+	is := assert.New(t)
+
+	user := &portainer.User{ID: 1, Username: "Admin", Role: portainer.AdministratorRole}
+	is.True(EqualFold("Username", "admin").Match(user))
+	is.False(Equals("Username", "admin").Match(user))
+	is.True(Equals("Id", 1).Match(user), "fields are named as in JSON")
+	is.True(Equals("Role", portainer.AdministratorRole).Match(user))
+	is.False(Equals("ID", 1).Match(user))
+	is.False(Equals("Username", 1).Match(user))
+
+	rc := portainer.ResourceControl{
+		ResourceID:     "stack",
+		SubResourceIDs: []string{"service"},
+		Type:           portainer.StackResourceControl,
+	}
+	byResource := func(id string, resourceType portainer.ResourceControlType) Filter {
+		return Any(All(Equals("ResourceId", id), Equals("Type", resourceType)), Contains("SubResourceIds", id))
+	}
+	is.True(byResource("stack", portainer.StackResourceControl).Match(rc))
+	is.False(byResource("stack", portainer.ContainerResourceControl).Match(rc))
+	is.True(byResource("service", portainer.ContainerResourceControl).Match(rc))
+	is.False(byResource("other", portainer.StackResourceControl).Match(rc))
+
+	endpoint := &portainer.Endpoint{TeamAccessPolicies: portainer.TeamAccessPolicies{2: {}}}
+	is.True(HasKey("TeamAccessPolicies", portainer.TeamID(2)).Match(endpoint))
+	is.True(HasKey("TeamAccessPolicies", 2).Match(endpoint))
+	is.False(HasKey("TeamAccessPolicies", 3).Match(endpoint))
+	is.Equal("2", HasKey("TeamAccessPolicies", portainer.TeamID(2)).Key())
+
+	endpoint.Name = "Production"
+	endpoint.Agent.Version = "2.0"
+	is.True(Search("Name", "DUCT").Match(endpoint))
+	is.True(In("Agent.Version", []string{"1.0", "2.0"}).Match(endpoint), "nested fields are separated by dots")
+	is.False(In("Agent.Version", []string{}).Match(endpoint))
+	is.True(Not(Equals("UserTrusted", true)).Match(endpoint))
+	is.True(Filter{}.Match(endpoint))
+
+	endpoint.LastCheckInDate = 100
+	is.True(AtLeast("LastCheckInDate", 100).Match(endpoint))
+	is.False(AtLeast("LastCheckInDate", 101).Match(endpoint))
+	is.True(AtMost("LastCheckInDate", int64(100)).Match(endpoint))
+	is.False(AtMost("LastCheckInDate", 99.5).Match(endpoint))
+	is.False(AtLeast("Name", 0).Match(endpoint), "only numeric fields are compared")
+
+	// Fields of the embedded structs are promoted
+	type named struct{ Name string }
+	type stack struct {
+		named
+		ID int
+	}
+	is.True(Equals("Name", "stack").Match(&stack{named: named{Name: "stack"}}))
+}
+
+func TestMatchFn(t *testing.T) {
+	is := assert.New(t)
+
+	var names []string
+	appendFn := MatchFn(Equals("Name", "a"), func(o any) (any, error) {
+		names = append(names, o.(*portainer.Stack).Name)
+
+		return &portainer.Stack{}, nil
+	})
+
+	skipped := &portainer.Stack{Name: "b"}
+	next, err := appendFn(skipped)
+	is.NoError(err)
+	is.NotSame(skipped, next, "the skipped object is not reused")
+	is.Equal(&portainer.Stack{}, next)
+
+	_, err = appendFn(&portainer.Stack{Name: "a"})
+	is.NoError(err)
+	is.Equal([]string{"a"}, names)
+}
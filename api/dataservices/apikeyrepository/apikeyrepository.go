@@ -1,14 +1,9 @@
 package apikeyrepository
 
 import (
-	"errors"
-	"fmt"
-
 	portainer "github.com/portainer/portainer/api"
+	"github.com/portainer/portainer/api/database/query"
 	"github.com/portainer/portainer/api/dataservices"
-	dserrors "github.com/portainer/portainer/api/dataservices/errors"
-
-	"github.com/rs/zerolog/log"
 )
 
 // BucketName represents the name of the bucket where this service stores data.
@@ -21,7 +16,15 @@ type Service struct {
 
 // NewService creates a new instance of a service.
 func NewService(connection portainer.Connection) (*Service, error) {
-	if err := connection.SetServiceName(BucketName); err != nil {
+	if err := connection.SetServiceName(BucketName, portainer.KeyKindInteger); err != nil {
+		return nil, err
+	}
+
+	if err := dataservices.CreateIndexes(connection, BucketName,
+		query.Index{Field: "digest", Kind: query.IndexEquals},
+		query.Index{Field: "userId", Kind: query.IndexEquals},
+		query.Index{Field: "expiresAt", Kind: query.IndexEquals},
+	); err != nil {
 		return nil, err
 	}
 
@@ -35,59 +38,21 @@ func NewService(connection portainer.Connection) (*Service, error) {
 
 // GetAPIKeysByUserID returns a slice containing all the APIKeys a user has access to.
 func (service *Service) GetAPIKeysByUserID(userID portainer.UserID) ([]portainer.APIKey, error) {
-	result := make([]portainer.APIKey, 0)
-
-	err := service.Connection.GetAll(
-		BucketName,
-		&portainer.APIKey{},
-		func(obj any) (any, error) {
-			record, ok := obj.(*portainer.APIKey)
-			if !ok {
-				log.Debug().Str("obj", fmt.Sprintf("%#v", obj)).Msg("failed to convert to APIKey object")
-				return nil, fmt.Errorf("failed to convert to APIKey object: %s", obj)
-			}
-
-			if record.UserID == userID {
-				result = append(result, *record)
-			}
-
-			return &portainer.APIKey{}, nil
-		})
-
-	return result, err
+	return dataservices.Find[portainer.APIKey](service.Connection, BucketName, query.Equals("userId", userID))
 }
 
 // GetAPIKeyByDigest returns the API key for the associated digest.
 // Note: there is a 1-to-1 mapping of api-key and digest
 func (service *Service) GetAPIKeyByDigest(digest string) (*portainer.APIKey, error) {
-	var k *portainer.APIKey
-	stop := errors.New("ok")
-	err := service.Connection.GetAll(
-		BucketName,
-		&portainer.APIKey{},
-		func(obj any) (any, error) {
-			key, ok := obj.(*portainer.APIKey)
-			if !ok {
-				log.Debug().Str("obj", fmt.Sprintf("%#v", obj)).Msg("failed to convert to APIKey object")
-				return nil, fmt.Errorf("failed to convert to APIKey object: %s", obj)
-			}
-			if key.Digest == digest {
-				k = key
-				return nil, stop
-			}
-
-			return &portainer.APIKey{}, nil
-		})
-
-	if errors.Is(err, stop) {
-		return k, nil
-	}
-
-	if err == nil {
-		return nil, dserrors.ErrObjectNotFound
-	}
+	return dataservices.GetByField[portainer.APIKey](service.Connection, BucketName, "digest", digest)
+}
 
-	return nil, err
+// GetExpiredAPIKeys returns the API keys that expired at the specified Unix timestamp.
+func (service *Service) GetExpiredAPIKeys(now int64) ([]portainer.APIKey, error) {
+	return dataservices.Find[portainer.APIKey](service.Connection, BucketName, query.All(
+		query.AtLeast("expiresAt", 1),
+		query.AtMost("expiresAt", now),
+	))
 }
 
 // Create creates a new APIKey object.
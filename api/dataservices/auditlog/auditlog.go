@@ -0,0 +1,82 @@
+package auditlog
+
+import (
+	portainer "github.com/portainer/portainer/api"
+	"github.com/portainer/portainer/api/database/query"
+	"github.com/portainer/portainer/api/dataservices"
+)
+
+// BucketName represents the name of the bucket where this service stores data.
+const BucketName = "audit_logs"
+
+// Service represents a service for managing the audit log. The records are
+// appended and removed once they are past the retention, they are never
+// updated.
+type Service struct {
+	connection portainer.Connection
+}
+
+func (service *Service) BucketName() string {
+	return BucketName
+}
+
+// NewService creates a new instance of a service.
+func NewService(connection portainer.Connection) (*Service, error) {
+	if err := connection.SetServiceName(BucketName, portainer.KeyKindInteger); err != nil {
+		return nil, err
+	}
+
+	if err := dataservices.CreateIndexes(connection, BucketName,
+		query.Index{Field: "Timestamp", Kind: query.IndexEquals},
+		query.Index{Field: "UserId", Kind: query.IndexEquals},
+		query.Index{Field: "EndpointId", Kind: query.IndexEquals},
+		query.Index{Field: "ResourceType", Kind: query.IndexEquals},
+	); err != nil {
+		return nil, err
+	}
+
+	return &Service{connection: connection}, nil
+}
+
+func (service *Service) Tx(tx portainer.Transaction) ServiceTx {
+	return ServiceTx{
+		service: service,
+		tx:      tx,
+	}
+}
+
+// Create appends a record to the audit log
+func (service *Service) Create(record *portainer.AuditLog) error {
+	return service.connection.UpdateTx(func(tx portainer.Transaction) error {
+		return service.Tx(tx).Create(record)
+	})
+}
+
+// Read returns a record of the audit log
+func (service *Service) Read(ID portainer.AuditLogID) (*portainer.AuditLog, error) {
+	var record *portainer.AuditLog
+
+	return record, service.connection.ViewTx(func(tx portainer.Transaction) error {
+		var err error
+		record, err = service.Tx(tx).Read(ID)
+
+		return err
+	})
+}
+
+// AuditLogsPage returns the records of a page
+func (service *Service) AuditLogsPage(page query.Page) ([]portainer.AuditLog, query.PageInfo, error) {
+	return dataservices.FindPage[portainer.AuditLog](service.connection, BucketName, page)
+}
+
+// DeleteBefore removes the records older than the timestamp
+func (service *Service) DeleteBefore(timestamp int64) (int, error) {
+	var count int
+
+	return count, service.connection.UpdateTx(func(tx portainer.Transaction) error {
+		var err error
+		count, err = service.Tx(tx).DeleteBefore(timestamp)
+
+		return err
+	})
+}
@@ -0,0 +1,59 @@
+package auditlog
+
+import (
+	portainer "github.com/portainer/portainer/api"
+	"github.com/portainer/portainer/api/database/query"
+	"github.com/portainer/portainer/api/dataservices"
+)
+
+type ServiceTx struct {
+	service *Service
+	tx      portainer.Transaction
+}
+
+func (service ServiceTx) BucketName() string {
+	return BucketName
+}
+
+// Create appends a record to the audit log
+func (service ServiceTx) Create(record *portainer.AuditLog) error {
+	return service.tx.CreateObject(BucketName, func(id uint64) (int, any) {
+		record.ID = portainer.AuditLogID(id)
+
+		return int(record.ID), record
+	})
+}
+
+// Read returns a record of the audit log
+func (service ServiceTx) Read(ID portainer.AuditLogID) (*portainer.AuditLog, error) {
+	var record portainer.AuditLog
+
+	identifier := service.service.connection.ConvertToKey(int(ID))
+
+	if err := service.tx.GetObject(BucketName, identifier, &record); err != nil {
+		return nil, err
+	}
+
+	return &record, nil
+}
+
+// AuditLogsPage returns the records of a page
+func (service ServiceTx) AuditLogsPage(page query.Page) ([]portainer.AuditLog, query.PageInfo, error) {
+	return dataservices.FindPage[portainer.AuditLog](service.tx, BucketName, page)
+}
+
+// DeleteBefore removes the records older than the timestamp
+func (service ServiceTx) DeleteBefore(timestamp int64) (int, error) {
+	records, err := dataservices.Find[portainer.AuditLog](service.tx, BucketName, query.AtMost("Timestamp", timestamp-1))
+	if err != nil {
+		return 0, err
+	}
+
+	for _, record := range records {
+		if err := service.tx.DeleteObject(BucketName, service.service.connection.ConvertToKey(int(record.ID))); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(records), nil
+}
@@ -4,6 +4,7 @@ import (
 	"sync"
 
 	portainer "github.com/portainer/portainer/api"
+	"github.com/portainer/portainer/api/database/query"
 	"github.com/portainer/portainer/api/dataservices"
 )
 
@@ -24,7 +25,7 @@ func (service *Service) BucketName() string {
 
 // NewService creates a new instance of a service.
 func NewService(connection portainer.Connection, cacheInvalidationFn func(portainer.EdgeStackID)) (*Service, error) {
-	err := connection.SetServiceName(BucketName)
+	err := connection.SetServiceName(BucketName, portainer.KeyKindInteger)
 	if err != nil {
 		return nil, err
 	}
@@ -69,6 +70,11 @@ func (service *Service) EdgeStacks() ([]portainer.EdgeStack, error) {
 	)
 }
 
+// EdgeStacksPage returns a page of edge stacks
+func (service *Service) EdgeStacksPage(page query.Page) ([]portainer.EdgeStack, query.PageInfo, error) {
+	return dataservices.FindPage[portainer.EdgeStack](service.connection, BucketName, page)
+}
+
 // EdgeStack returns an Edge stack by ID.
 func (service *Service) EdgeStack(ID portainer.EdgeStackID) (*portainer.EdgeStack, error) {
 	var stack portainer.EdgeStack
@@ -4,6 +4,8 @@ import (
 	"fmt"
 
 	portainer "github.com/portainer/portainer/api"
+	"github.com/portainer/portainer/api/database/query"
+	"github.com/portainer/portainer/api/dataservices"
 
 	"github.com/rs/zerolog/log"
 )
@@ -39,6 +41,11 @@ func (service ServiceTx) EdgeStacks() ([]portainer.EdgeStack, error) {
 	return stacks, err
 }
 
+// EdgeStacksPage returns a page of edge stacks
+func (service ServiceTx) EdgeStacksPage(page query.Page) ([]portainer.EdgeStack, query.PageInfo, error) {
+	return dataservices.FindPage[portainer.EdgeStack](service.tx, BucketName, page)
+}
+
 // EdgeStack returns an Edge stack by ID.
 func (service ServiceTx) EdgeStack(ID portainer.EdgeStackID) (*portainer.EdgeStack, error) {
 	var stack portainer.EdgeStack
@@ -5,7 +5,10 @@ import (
 	"time"
 
 	portainer "github.com/portainer/portainer/api"
+	"github.com/portainer/portainer/api/database/changefeed"
+	"github.com/portainer/portainer/api/database/query"
 	"github.com/portainer/portainer/api/dataservices"
+	"github.com/portainer/portainer/api/internal/edge/cache"
 
 	"github.com/rs/zerolog/log"
 )
@@ -19,6 +22,11 @@ type Service struct {
 	mu         sync.RWMutex
 	idxEdgeID  map[string]portainer.EndpointID
 	heartbeats sync.Map
+
+	// pending holds the check-ins that are not saved in heartbeatStore yet
+	heartbeatStore HeartbeatStore
+	pendingMu      sync.Mutex
+	pending        map[portainer.EndpointID]int64
 }
 
 func (service *Service) BucketName() string {
@@ -27,14 +35,25 @@ func (service *Service) BucketName() string {
 
 // NewService creates a new instance of a service.
 func NewService(connection portainer.Connection) (*Service, error) {
-	err := connection.SetServiceName(BucketName)
+	err := connection.SetServiceName(BucketName, portainer.KeyKindInteger)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := dataservices.CreateIndexes(connection, BucketName, query.Index{Field: "TeamAccessPolicies", Kind: query.IndexElements}); err != nil {
+		return nil, err
+	}
+
+	heartbeatStore, err := newHeartbeatStore(connection)
 	if err != nil {
 		return nil, err
 	}
 
 	s := &Service{
-		connection: connection,
-		idxEdgeID:  make(map[string]portainer.EndpointID),
+		connection:     connection,
+		idxEdgeID:      make(map[string]portainer.EndpointID),
+		heartbeatStore: heartbeatStore,
+		pending:        make(map[portainer.EndpointID]int64),
 	}
 
 	es, err := s.endpoints()
@@ -50,9 +69,105 @@ func NewService(connection portainer.Connection) (*Service, error) {
 		s.heartbeats.Store(e.ID, e.LastCheckInDate)
 	}
 
+	s.loadHeartbeats()
+
+	if source, ok := connection.(changefeed.Source); ok {
+		source.ChangeFeed().Subscribe(BucketName, s.endpointChanged)
+	}
+
 	return s, nil
 }
 
+// endpointChanged updates the edge ID index and the heartbeats after an
+// environment was written or deleted by another instance
+func (service *Service) endpointChanged(change changefeed.Change) {
+	id, ok := change.ID()
+	if !ok {
+		service.reloadEndpoints()
+
+		return
+	}
+
+	endpointID := portainer.EndpointID(id)
+	defer cache.Del(endpointID)
+
+	var endpoint portainer.Endpoint
+	err := service.connection.ViewTx(func(tx portainer.Transaction) error {
+		return tx.GetObject(BucketName, service.connection.ConvertToKey(id), &endpoint)
+	})
+	if dataservices.IsErrObjectNotFound(err) {
+		service.removeEndpoint(endpointID)
+
+		return
+	} else if err != nil {
+		log.Warn().Err(err).Int("endpoint_id", id).Msg("unable to reload the changed environment")
+
+		return
+	}
+
+	service.indexEndpoint(endpoint)
+}
+
+// reloadEndpoints rebuilds the edge ID index and the heartbeats from the store
+func (service *Service) reloadEndpoints() {
+	endpoints, err := service.endpoints()
+	if err != nil {
+		log.Warn().Err(err).Msg("unable to reload the environments")
+
+		return
+	}
+
+	existing := make(map[portainer.EndpointID]struct{}, len(endpoints))
+	for _, endpoint := range endpoints {
+		existing[endpoint.ID] = struct{}{}
+		service.indexEndpoint(endpoint)
+	}
+
+	service.heartbeats.Range(func(key, _ any) bool {
+		if _, ok := existing[key.(portainer.EndpointID)]; !ok {
+			service.removeEndpoint(key.(portainer.EndpointID))
+		}
+
+		return true
+	})
+
+	cache.Clear()
+}
+
+// indexEndpoint adds an environment to the edge ID index, its heartbeat is
+// only moved forward since the check-ins are not always persisted
+func (service *Service) indexEndpoint(endpoint portainer.Endpoint) {
+	service.mu.Lock()
+	defer service.mu.Unlock()
+
+	for edgeID, endpointID := range service.idxEdgeID {
+		if endpointID == endpoint.ID && edgeID != endpoint.EdgeID {
+			delete(service.idxEdgeID, edgeID)
+		}
+	}
+
+	if len(endpoint.EdgeID) > 0 {
+		service.idxEdgeID[endpoint.EdgeID] = endpoint.ID
+	}
+
+	if t, ok := service.Heartbeat(endpoint.ID); !ok || t < endpoint.LastCheckInDate {
+		service.heartbeats.Store(endpoint.ID, endpoint.LastCheckInDate)
+	}
+}
+
+func (service *Service) removeEndpoint(endpointID portainer.EndpointID) {
+	service.mu.Lock()
+	defer service.mu.Unlock()
+
+	for edgeID, id := range service.idxEdgeID {
+		if id == endpointID {
+			delete(service.idxEdgeID, edgeID)
+		}
+	}
+
+	service.heartbeats.Delete(endpointID)
+}
+
 func (service *Service) Tx(tx portainer.Transaction) ServiceTx {
 	return ServiceTx{
 		service: service,
@@ -119,6 +234,33 @@ func (service *Service) Endpoints() ([]portainer.Endpoint, error) {
 	return endpoints, nil
 }
 
+// EndpointsPage returns a page of environments(endpoints)
+func (service *Service) EndpointsPage(page query.Page) ([]portainer.Endpoint, query.PageInfo, error) {
+	var endpoints []portainer.Endpoint
+	var info query.PageInfo
+
+	err := service.connection.ViewTx(func(tx portainer.Transaction) error {
+		var err error
+		endpoints, info, err = service.Tx(tx).EndpointsPage(page)
+
+		return err
+	})
+	if err != nil {
+		return nil, query.PageInfo{}, err
+	}
+
+	for i, e := range endpoints {
+		endpoints[i].LastCheckInDate, _ = service.Heartbeat(e.ID)
+	}
+
+	return endpoints, info, nil
+}
+
+// CountEndpoints returns the number of environments(endpoints) matched by filter
+func (service *Service) CountEndpoints(filter query.Filter) (int, error) {
+	return dataservices.Count[portainer.Endpoint](service.connection, BucketName, filter)
+}
+
 // EndpointIDByEdgeID returns the EndpointID from the given EdgeID using an in-memory index
 func (service *Service) EndpointIDByEdgeID(edgeID string) (portainer.EndpointID, bool) {
 	service.mu.RLock()
@@ -136,8 +278,15 @@ func (service *Service) Heartbeat(endpointID portainer.EndpointID) (int64, bool)
 	return 0, false
 }
 
+// UpdateHeartbeat records a check-in, it is saved to the store at the next sync
 func (service *Service) UpdateHeartbeat(endpointID portainer.EndpointID) {
-	service.heartbeats.Store(endpointID, time.Now().Unix())
+	checkIn := time.Now().Unix()
+
+	service.heartbeats.Store(endpointID, checkIn)
+
+	service.pendingMu.Lock()
+	service.pending[endpointID] = checkIn
+	service.pendingMu.Unlock()
 }
 
 // CreateEndpoint assign an ID to a new environment(endpoint) and saves it.
@@ -148,21 +297,7 @@ func (service *Service) Create(endpoint *portainer.Endpoint) error {
 }
 
 func (service *Service) EndpointsByTeamID(teamID portainer.TeamID) ([]portainer.Endpoint, error) {
-	var endpoints = make([]portainer.Endpoint, 0)
-
-	return endpoints, service.connection.GetAll(
-		BucketName,
-		&portainer.Endpoint{},
-		dataservices.FilterFn(&endpoints, func(e portainer.Endpoint) bool {
-			for t := range e.TeamAccessPolicies {
-				if t == teamID {
-					return true
-				}
-			}
-
-			return false
-		}),
-	)
+	return dataservices.Find[portainer.Endpoint](service.connection, BucketName, query.HasKey("TeamAccessPolicies", teamID))
 }
 
 // GetNextIdentifier returns the next identifier for an environment(endpoint).
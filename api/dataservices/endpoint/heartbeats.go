@@ -0,0 +1,183 @@
+package endpoint
+
+import (
+	"context"
+	"time"
+
+	portainer "github.com/portainer/portainer/api"
+	"github.com/portainer/portainer/api/dataservices"
+
+	"github.com/rs/zerolog/log"
+)
+
+// HeartbeatBucketName represents the name of the bucket holding the heartbeats
+// when the connection does not store them itself.
+const HeartbeatBucketName = "endpoint_heartbeats"
+
+// heartbeatSyncInterval is how often the check-ins are written to the store and
+// the ones recorded by the other instances are read back
+const heartbeatSyncInterval = 5 * time.Second
+
+// HeartbeatStore persists the last check-in of the environments
+type HeartbeatStore interface {
+	SaveHeartbeats(heartbeats map[portainer.EndpointID]int64) error
+	LoadHeartbeats() (map[portainer.EndpointID]int64, error)
+	DeleteHeartbeat(endpointID portainer.EndpointID) error
+}
+
+type heartbeat struct {
+	EndpointID  portainer.EndpointID
+	LastCheckIn int64
+}
+
+// bucketHeartbeatStore keeps the heartbeats in a bucket of their own
+type bucketHeartbeatStore struct {
+	connection portainer.Connection
+}
+
+// newHeartbeatStore returns the heartbeat store of the connection, or a bucket
+// when it does not provide one
+func newHeartbeatStore(connection portainer.Connection) (HeartbeatStore, error) {
+	if store, ok := connection.(HeartbeatStore); ok {
+		return store, nil
+	}
+
+	if err := connection.SetServiceName(HeartbeatBucketName, portainer.KeyKindInteger); err != nil {
+		return nil, err
+	}
+
+	return &bucketHeartbeatStore{connection: connection}, nil
+}
+
+func (store *bucketHeartbeatStore) SaveHeartbeats(heartbeats map[portainer.EndpointID]int64) error {
+	return store.connection.UpdateTx(func(tx portainer.Transaction) error {
+		for endpointID, checkIn := range heartbeats {
+			key := store.connection.ConvertToKey(int(endpointID))
+
+			var current heartbeat
+			err := tx.GetObject(HeartbeatBucketName, key, &current)
+			if err != nil && !dataservices.IsErrObjectNotFound(err) {
+				return err
+			}
+
+			if current.LastCheckIn >= checkIn {
+				continue
+			}
+
+			if err := tx.UpdateObject(HeartbeatBucketName, key, &heartbeat{EndpointID: endpointID, LastCheckIn: checkIn}); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+func (store *bucketHeartbeatStore) LoadHeartbeats() (map[portainer.EndpointID]int64, error) {
+	heartbeats := make(map[portainer.EndpointID]int64)
+
+	return heartbeats, store.connection.GetAll(HeartbeatBucketName, &heartbeat{}, func(o any) (any, error) {
+		if h, ok := o.(*heartbeat); ok {
+			heartbeats[h.EndpointID] = h.LastCheckIn
+		}
+
+		return &heartbeat{}, nil
+	})
+}
+
+func (store *bucketHeartbeatStore) DeleteHeartbeat(endpointID portainer.EndpointID) error {
+	return store.connection.DeleteObject(HeartbeatBucketName, store.connection.ConvertToKey(int(endpointID)))
+}
+
+// StartHeartbeatSync writes the check-ins to the store in batches and reads
+// the ones recorded by the other instances, until ctx is done
+func (service *Service) StartHeartbeatSync(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(heartbeatSyncInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				service.saveHeartbeats()
+
+				return
+			case <-ticker.C:
+				service.saveHeartbeats()
+				service.loadHeartbeats()
+			}
+		}
+	}()
+}
+
+// saveHeartbeats writes the check-ins received since the last sync
+func (service *Service) saveHeartbeats() {
+	service.pendingMu.Lock()
+	pending := service.pending
+	service.pending = make(map[portainer.EndpointID]int64)
+	service.pendingMu.Unlock()
+
+	if len(pending) == 0 {
+		return
+	}
+
+	if err := service.heartbeatStore.SaveHeartbeats(pending); err != nil {
+		log.Warn().Err(err).Int("count", len(pending)).Msg("unable to save the heartbeats, retrying at the next sync")
+
+		service.pendingMu.Lock()
+		for endpointID, checkIn := range pending {
+			service.pending[endpointID] = max(service.pending[endpointID], checkIn)
+		}
+		service.pendingMu.Unlock()
+	}
+}
+
+// loadHeartbeats moves the heartbeats forward with the check-ins recorded by
+// the other instances, and deletes the heartbeats of deleted environments
+func (service *Service) loadHeartbeats() {
+	heartbeats, err := service.heartbeatStore.LoadHeartbeats()
+	if err != nil {
+		log.Warn().Err(err).Msg("unable to load the heartbeats")
+
+		return
+	}
+
+	for endpointID, checkIn := range heartbeats {
+		current, ok := service.Heartbeat(endpointID)
+		if !ok {
+			// The environment may have been created by another instance since
+			// this one loaded the environments, it is only deleted when the
+			// store confirms it
+			service.loadUnknownHeartbeat(endpointID, checkIn)
+
+			continue
+		}
+
+		if checkIn > current {
+			service.heartbeats.Store(endpointID, checkIn)
+		}
+	}
+}
+
+// loadUnknownHeartbeat indexes the environment of a heartbeat it does not know
+// yet, or deletes the heartbeat when the environment does not exist anymore
+func (service *Service) loadUnknownHeartbeat(endpointID portainer.EndpointID, checkIn int64) {
+	var endpoint portainer.Endpoint
+	err := service.connection.ViewTx(func(tx portainer.Transaction) error {
+		return tx.GetObject(BucketName, service.connection.ConvertToKey(int(endpointID)), &endpoint)
+	})
+	if dataservices.IsErrObjectNotFound(err) {
+		if err := service.heartbeatStore.DeleteHeartbeat(endpointID); err != nil {
+			log.Warn().Err(err).Int("endpoint_id", int(endpointID)).Msg("unable to delete the heartbeat")
+		}
+
+		return
+	} else if err != nil {
+		log.Warn().Err(err).Int("endpoint_id", int(endpointID)).Msg("unable to read the environment of the heartbeat")
+
+		return
+	}
+
+	endpoint.LastCheckInDate = max(endpoint.LastCheckInDate, checkIn)
+	service.indexEndpoint(endpoint)
+}
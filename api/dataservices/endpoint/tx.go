@@ -2,6 +2,7 @@ package endpoint
 
 import (
 	portainer "github.com/portainer/portainer/api"
+	"github.com/portainer/portainer/api/database/query"
 	"github.com/portainer/portainer/api/dataservices"
 	"github.com/portainer/portainer/api/internal/edge/cache"
 
@@ -89,6 +90,16 @@ func (service ServiceTx) Endpoints() ([]portainer.Endpoint, error) {
 	)
 }
 
+// EndpointsPage returns a page of environments(endpoints)
+func (service ServiceTx) EndpointsPage(page query.Page) ([]portainer.Endpoint, query.PageInfo, error) {
+	return dataservices.FindPage[portainer.Endpoint](service.tx, BucketName, page)
+}
+
+// CountEndpoints returns the number of environments(endpoints) matched by filter
+func (service ServiceTx) CountEndpoints(filter query.Filter) (int, error) {
+	return dataservices.Count[portainer.Endpoint](service.tx, BucketName, filter)
+}
+
 func (service ServiceTx) EndpointIDByEdgeID(edgeID string) (portainer.EndpointID, bool) {
 	log.Error().Str("func", "EndpointIDByEdgeID").Msg("cannot be called inside a transaction")
 
@@ -123,21 +134,7 @@ func (service ServiceTx) Create(endpoint *portainer.Endpoint) error {
 }
 
 func (service ServiceTx) EndpointsByTeamID(teamID portainer.TeamID) ([]portainer.Endpoint, error) {
-	var endpoints = make([]portainer.Endpoint, 0)
-
-	return endpoints, service.tx.GetAll(
-		BucketName,
-		&portainer.Endpoint{},
-		dataservices.FilterFn(&endpoints, func(e portainer.Endpoint) bool {
-			for t := range e.TeamAccessPolicies {
-				if t == teamID {
-					return true
-				}
-			}
-
-			return false
-		}),
-	)
+	return dataservices.Find[portainer.Endpoint](service.tx, BucketName, query.HasKey("TeamAccessPolicies", teamID))
 }
 
 // GetNextIdentifier returns the next identifier for an environment(endpoint).
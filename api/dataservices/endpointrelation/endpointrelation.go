@@ -2,6 +2,7 @@ package endpointrelation
 
 import (
 	portainer "github.com/portainer/portainer/api"
+	"github.com/portainer/portainer/api/database/changefeed"
 	"github.com/portainer/portainer/api/dataservices"
 	"github.com/portainer/portainer/api/internal/edge/cache"
 
@@ -32,15 +33,31 @@ func (service *Service) RegisterUpdateStackFunction(
 
 // NewService creates a new instance of a service.
 func NewService(connection portainer.Connection) (*Service, error) {
-	if err := connection.SetServiceName(BucketName); err != nil {
+	if err := connection.SetServiceName(BucketName, portainer.KeyKindInteger); err != nil {
 		return nil, err
 	}
 
+	if source, ok := connection.(changefeed.Source); ok {
+		source.ChangeFeed().Subscribe(BucketName, relationChanged)
+	}
+
 	return &Service{
 		connection: connection,
 	}, nil
 }
 
+// relationChanged drops the cached edge status of the environments whose
+// relation was changed by another instance
+func relationChanged(change changefeed.Change) {
+	if endpointID, ok := change.ID(); ok {
+		cache.Del(portainer.EndpointID(endpointID))
+
+		return
+	}
+
+	cache.Clear()
+}
+
 func (service *Service) Tx(tx portainer.Transaction) ServiceTx {
 	return ServiceTx{
 		service: service,
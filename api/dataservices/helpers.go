@@ -16,6 +16,11 @@ func IsErrObjectNotFound(e error) bool {
 	return errors.Is(e, perrors.ErrObjectNotFound)
 }
 
+// IsErrConflict returns true when an update lost the race against another transaction
+func IsErrConflict(e error) bool {
+	return errors.Is(e, perrors.ErrConflict)
+}
+
 // AppendFn appends elements to the given collection slice
 func AppendFn[T any](collection *[]T) func(obj any) (any, error) {
 	return func(obj any) (any, error) {
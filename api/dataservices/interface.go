@@ -1,13 +1,17 @@
 package dataservices
 
 import (
+	"context"
+
 	portainer "github.com/portainer/portainer/api"
 	"github.com/portainer/portainer/api/database/models"
+	"github.com/portainer/portainer/api/database/query"
 )
 
 type (
 	DataStoreTx interface {
 		IsErrObjectNotFound(err error) bool
+		AuditLog() AuditLogService
 		CustomTemplate() CustomTemplateService
 		EdgeGroup() EdgeGroupService
 		EdgeJob() EdgeJobService
@@ -16,6 +20,7 @@ type (
 		EndpointGroup() EndpointGroupService
 		EndpointRelation() EndpointRelationService
 		HelmUserRepository() HelmUserRepositoryService
+		JWTSigningKey() JWTSigningKeyService
 		Registry() RegistryService
 		ResourceControl() ResourceControlService
 		Role() RoleService
@@ -29,6 +34,7 @@ type (
 		Team() TeamService
 		TunnelServer() TunnelServerService
 		User() UserService
+		UserSession() UserSessionService
 		Version() VersionService
 		Webhook() WebhookService
 		PendingActions() PendingActionsService
@@ -41,15 +47,28 @@ type (
 		Close() error
 		UpdateTx(func(DataStoreTx) error) error
 		ViewTx(func(DataStoreTx) error) error
+		UpdateTxCtx(ctx context.Context, fn func(DataStoreTx) error) error
+		ViewTxCtx(ctx context.Context, fn func(DataStoreTx) error) error
+		UpdateTxRetryable(ctx context.Context, fn func(DataStoreTx) error) error
 		MigrateData() error
 		Rollback(force bool) error
 		CheckCurrentEdition() error
 		Backup(path string) (string, error)
+		RestoreFromFile(path string) error
 		Export(filename string) (err error)
 
 		DataStoreTx
 	}
 
+	// AuditLogService represents a service for managing the audit log, it is append-only
+	AuditLogService interface {
+		Create(record *portainer.AuditLog) error
+		Read(ID portainer.AuditLogID) (*portainer.AuditLog, error)
+		AuditLogsPage(page query.Page) ([]portainer.AuditLog, query.PageInfo, error)
+		DeleteBefore(timestamp int64) (int, error)
+		BucketName() string
+	}
+
 	// CustomTemplateService represents a service to manage custom templates
 	CustomTemplateService interface {
 		BaseCRUD[portainer.CustomTemplate, portainer.CustomTemplateID]
@@ -79,6 +98,7 @@ type (
 	// EdgeStackService represents a service to manage Edge stacks
 	EdgeStackService interface {
 		EdgeStacks() ([]portainer.EdgeStack, error)
+		EdgeStacksPage(page query.Page) ([]portainer.EdgeStack, query.PageInfo, error)
 		EdgeStack(ID portainer.EdgeStackID) (*portainer.EdgeStack, error)
 		EdgeStackVersion(ID portainer.EdgeStackID) (int, bool)
 		Create(id portainer.EdgeStackID, edgeStack *portainer.EdgeStack) error
@@ -97,6 +117,8 @@ type (
 		Heartbeat(endpointID portainer.EndpointID) (int64, bool)
 		UpdateHeartbeat(endpointID portainer.EndpointID)
 		Endpoints() ([]portainer.Endpoint, error)
+		EndpointsPage(page query.Page) ([]portainer.Endpoint, query.PageInfo, error)
+		CountEndpoints(filter query.Filter) (int, error)
 		Create(endpoint *portainer.Endpoint) error
 		UpdateEndpoint(ID portainer.EndpointID, endpoint *portainer.Endpoint) error
 		DeleteEndpoint(ID portainer.EndpointID) error
@@ -136,6 +158,11 @@ type (
 		ResourceControlByResourceIDAndType(resourceID string, resourceType portainer.ResourceControlType) (*portainer.ResourceControl, error)
 	}
 
+	// JWTSigningKeyService represents a service for managing the JWT signing keys
+	JWTSigningKeyService interface {
+		BaseCRUD[portainer.JWTSigningKey, portainer.JWTSigningKeyID]
+	}
+
 	// RoleService represents a service for managing user roles
 	RoleService interface {
 		BaseCRUD[portainer.Role, portainer.RoleID]
@@ -146,6 +173,7 @@ type (
 		BaseCRUD[portainer.APIKey, portainer.APIKeyID]
 		GetAPIKeysByUserID(userID portainer.UserID) ([]portainer.APIKey, error)
 		GetAPIKeyByDigest(digest string) (*portainer.APIKey, error)
+		GetExpiredAPIKeys(now int64) ([]portainer.APIKey, error)
 	}
 
 	// SettingsService represents a service for managing application settings
@@ -171,6 +199,7 @@ type (
 		BaseCRUD[portainer.Stack, portainer.StackID]
 		StackByName(name string) (*portainer.Stack, error)
 		StacksByName(name string) ([]portainer.Stack, error)
+		StacksPage(page query.Page) ([]portainer.Stack, query.PageInfo, error)
 		GetNextIdentifier() int
 		StackByWebhookID(ID string) (*portainer.Stack, error)
 		RefreshableStacks() ([]portainer.Stack, error)
@@ -212,6 +241,15 @@ type (
 		UsersByRole(role portainer.UserRole) ([]portainer.User, error)
 	}
 
+	// UserSessionService represents a service for managing the sessions opened by the users
+	UserSessionService interface {
+		BaseCRUD[portainer.UserSession, portainer.UserSessionID]
+		SessionByJTI(jti string) (*portainer.UserSession, error)
+		SessionsByUserID(userID portainer.UserID) ([]portainer.UserSession, error)
+		RevokeUserSessions(userID portainer.UserID, revokedAt int64) error
+		DeleteExpiredSessions(now int64) error
+	}
+
 	// VersionService represents a service for managing version data
 	VersionService interface {
 		InstanceID() (string, error)
@@ -22,7 +22,7 @@ type ServiceTx struct {
 }
 
 func NewService(connection portainer.Connection) (*Service, error) {
-	err := connection.SetServiceName(BucketName)
+	err := connection.SetServiceName(BucketName, portainer.KeyKindInteger)
 	if err != nil {
 		return nil, err
 	}
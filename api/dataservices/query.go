@@ -0,0 +1,95 @@
+package dataservices
+
+import (
+	"errors"
+
+	portainer "github.com/portainer/portainer/api"
+	"github.com/portainer/portainer/api/database/query"
+	dserrors "github.com/portainer/portainer/api/dataservices/errors"
+)
+
+// Query passes the objects of a bucket matched by filter to appendFn, in key
+// order. The transactions implementing query.Querier select them in the
+// database, the bucket is scanned otherwise.
+func Query(tx portainer.ReadTransaction, bucketName string, filter query.Filter, obj any, appendFn func(o any) (any, error)) error {
+	if querier, ok := tx.(query.Querier); ok {
+		return querier.Query(bucketName, filter, obj, appendFn)
+	}
+
+	return tx.GetAll(bucketName, obj, query.MatchFn(filter, appendFn))
+}
+
+// Find returns the objects of a bucket matched by filter
+func Find[T any](tx portainer.ReadTransaction, bucketName string, filter query.Filter) ([]T, error) {
+	var collection = make([]T, 0)
+
+	return collection, Query(tx, bucketName, filter, new(T), AppendFn(&collection))
+}
+
+// First returns the first object of a bucket matched by filter, or
+// ErrObjectNotFound
+func First[T any](tx portainer.ReadTransaction, bucketName string, filter query.Filter) (*T, error) {
+	var element T
+
+	err := Query(tx, bucketName, filter, new(T), FirstFn(&element, func(T) bool { return true }))
+	if errors.Is(err, ErrStop) {
+		return &element, nil
+	}
+
+	if err == nil {
+		return nil, dserrors.ErrObjectNotFound
+	}
+
+	return nil, err
+}
+
+// GetByField returns the first object of a bucket whose field equals value
+func GetByField[T any](tx portainer.ReadTransaction, bucketName string, field string, value any) (*T, error) {
+	return First[T](tx, bucketName, query.Equals(field, value))
+}
+
+// QueryPage hands the objects of a page to appendFn. The transactions
+// implementing query.Pager select the page in the database, the bucket is
+// scanned otherwise.
+func QueryPage(tx portainer.ReadTransaction, bucketName string, page query.Page, obj any, appendFn func(o any) (any, error)) (query.PageInfo, error) {
+	if pager, ok := tx.(query.Pager); ok {
+		return pager.QueryPage(bucketName, page, obj, appendFn)
+	}
+
+	var objects []any
+	if err := tx.GetAll(bucketName, obj, query.CollectFn(&objects)); err != nil {
+		return query.PageInfo{}, err
+	}
+
+	return query.ApplyPage(objects, page, appendFn)
+}
+
+// FindPage returns the objects of a page
+func FindPage[T any](tx portainer.ReadTransaction, bucketName string, page query.Page) ([]T, query.PageInfo, error) {
+	var collection = make([]T, 0)
+
+	info, err := QueryPage(tx, bucketName, page, new(T), AppendFn(&collection))
+
+	return collection, info, err
+}
+
+// Count returns the number of objects of a bucket matched by filter
+func Count[T any](tx portainer.ReadTransaction, bucketName string, filter query.Filter) (int, error) {
+	if pager, ok := tx.(query.Pager); ok {
+		return pager.Count(bucketName, filter, new(T))
+	}
+
+	var count int
+
+	return count, tx.GetAll(bucketName, new(T), query.CountFn(filter, &count))
+}
+
+// CreateIndexes declares the fields of a bucket used by the queries, they are
+// indexed when the connection implements query.Indexer
+func CreateIndexes(connection portainer.Connection, bucketName string, indexes ...query.Index) error {
+	if indexer, ok := connection.(query.Indexer); ok {
+		return indexer.CreateIndexes(bucketName, indexes...)
+	}
+
+	return nil
+}
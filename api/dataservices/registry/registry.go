@@ -15,7 +15,7 @@ type Service struct {
 
 // NewService creates a new instance of a service.
 func NewService(connection portainer.Connection) (*Service, error) {
-	err := connection.SetServiceName(BucketName)
+	err := connection.SetServiceName(BucketName, portainer.KeyKindInteger)
 	if err != nil {
 		return nil, err
 	}
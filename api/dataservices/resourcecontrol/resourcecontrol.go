@@ -1,13 +1,9 @@
 package resourcecontrol
 
 import (
-	"errors"
-	"fmt"
-
 	portainer "github.com/portainer/portainer/api"
+	"github.com/portainer/portainer/api/database/query"
 	"github.com/portainer/portainer/api/dataservices"
-
-	"github.com/rs/zerolog/log"
 )
 
 // BucketName represents the name of the bucket where this service stores data.
@@ -20,11 +16,18 @@ type Service struct {
 
 // NewService creates a new instance of a service.
 func NewService(connection portainer.Connection) (*Service, error) {
-	err := connection.SetServiceName(BucketName)
+	err := connection.SetServiceName(BucketName, portainer.KeyKindInteger)
 	if err != nil {
 		return nil, err
 	}
 
+	if err := dataservices.CreateIndexes(connection, BucketName,
+		query.Index{Field: "ResourceId", Kind: query.IndexEquals},
+		query.Index{Field: "SubResourceIds", Kind: query.IndexElements},
+	); err != nil {
+		return nil, err
+	}
+
 	return &Service{
 		BaseDataService: dataservices.BaseDataService[portainer.ResourceControl, portainer.ResourceControlID]{
 			Bucket:     BucketName,
@@ -47,37 +50,7 @@ func (service *Service) Tx(tx portainer.Transaction) ServiceTx {
 // to the main ResourceID or in SubResourceIDs. It also performs a check on the resource type. Return nil
 // if no ResourceControl was found.
 func (service *Service) ResourceControlByResourceIDAndType(resourceID string, resourceType portainer.ResourceControlType) (*portainer.ResourceControl, error) {
-	var resourceControl *portainer.ResourceControl
-	stop := errors.New("ok")
-	err := service.Connection.GetAll(
-		BucketName,
-		&portainer.ResourceControl{},
-		func(obj any) (any, error) {
-			rc, ok := obj.(*portainer.ResourceControl)
-			if !ok {
-				log.Debug().Str("obj", fmt.Sprintf("%#v", obj)).Msg("failed to convert to ResourceControl object")
-				return nil, fmt.Errorf("failed to convert to ResourceControl object: %s", obj)
-			}
-
-			if rc.ResourceID == resourceID && rc.Type == resourceType {
-				resourceControl = rc
-				return nil, stop
-			}
-
-			for _, subResourceID := range rc.SubResourceIDs {
-				if subResourceID == resourceID {
-					resourceControl = rc
-					return nil, stop
-				}
-			}
-
-			return &portainer.ResourceControl{}, nil
-		})
-	if errors.Is(err, stop) {
-		return resourceControl, nil
-	}
-
-	return nil, err
+	return resourceControlByResourceIDAndType(service.Connection, resourceID, resourceType)
 }
 
 // CreateResourceControl creates a new ResourceControl object
@@ -90,3 +63,15 @@ func (service *Service) Create(resourceControl *portainer.ResourceControl) error
 		},
 	)
 }
+
+func resourceControlByResourceIDAndType(tx portainer.ReadTransaction, resourceID string, resourceType portainer.ResourceControlType) (*portainer.ResourceControl, error) {
+	resourceControl, err := dataservices.First[portainer.ResourceControl](tx, BucketName, query.Any(
+		query.All(query.Equals("ResourceId", resourceID), query.Equals("Type", resourceType)),
+		query.Contains("SubResourceIds", resourceID),
+	))
+	if dataservices.IsErrObjectNotFound(err) {
+		return nil, nil
+	}
+
+	return resourceControl, err
+}
@@ -8,6 +8,12 @@ import (
 // BucketName represents the name of the bucket where this service stores data.
 const BucketName = "roles"
 
+// LastBuiltInRoleID is the identifier of the last built-in role. The
+// identifiers of the environment administrator, helpdesk, standard user and
+// read-only user roles are relied upon by the UI and the migrations, they are
+// never given to the new roles.
+const LastBuiltInRoleID portainer.RoleID = 4
+
 // Service represents a service for managing environment(endpoint) data.
 type Service struct {
 	dataservices.BaseDataService[portainer.Role, portainer.RoleID]
@@ -15,7 +21,7 @@ type Service struct {
 
 // NewService creates a new instance of a service.
 func NewService(connection portainer.Connection) (*Service, error) {
-	err := connection.SetServiceName(BucketName)
+	err := connection.SetServiceName(BucketName, portainer.KeyKindInteger)
 	if err != nil {
 		return nil, err
 	}
@@ -40,11 +46,7 @@ func (service *Service) Tx(tx portainer.Transaction) ServiceTx {
 
 // CreateRole creates a new Role.
 func (service *Service) Create(role *portainer.Role) error {
-	return service.Connection.CreateObject(
-		BucketName,
-		func(id uint64) (int, any) {
-			role.ID = portainer.RoleID(id)
-			return int(role.ID), role
-		},
-	)
+	return service.Connection.UpdateTx(func(tx portainer.Transaction) error {
+		return service.Tx(tx).Create(role)
+	})
 }
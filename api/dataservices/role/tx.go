@@ -1,6 +1,8 @@
 package role
 
 import (
+	"errors"
+
 	portainer "github.com/portainer/portainer/api"
 	"github.com/portainer/portainer/api/dataservices"
 )
@@ -9,13 +11,19 @@ type ServiceTx struct {
 	dataservices.BaseDataServiceTx[portainer.Role, portainer.RoleID]
 }
 
-// CreateRole creates a new Role.
+// CreateRole creates a new Role, its identifier follows the ones of the
+// built-in roles even when they do not exist.
 func (service ServiceTx) Create(role *portainer.Role) error {
-	return service.Tx.CreateObject(
-		BucketName,
-		func(id uint64) (int, any) {
-			role.ID = portainer.RoleID(id)
-			return int(role.ID), role
-		},
-	)
+	id := portainer.RoleID(service.Tx.GetNextIdentifier(BucketName))
+	for id > 0 && id <= LastBuiltInRoleID {
+		id = portainer.RoleID(service.Tx.GetNextIdentifier(BucketName))
+	}
+
+	if id == 0 {
+		return errors.New("unable to generate the role identifier")
+	}
+
+	role.ID = id
+
+	return service.Tx.CreateObjectWithId(BucketName, int(role.ID), role)
 }
@@ -5,6 +5,7 @@ import (
 	"strings"
 
 	portainer "github.com/portainer/portainer/api"
+	"github.com/portainer/portainer/api/database/query"
 	"github.com/portainer/portainer/api/dataservices"
 	dserrors "github.com/portainer/portainer/api/dataservices/errors"
 )
@@ -19,11 +20,15 @@ type Service struct {
 
 // NewService creates a new instance of a service.
 func NewService(connection portainer.Connection) (*Service, error) {
-	err := connection.SetServiceName(BucketName)
+	err := connection.SetServiceName(BucketName, portainer.KeyKindInteger)
 	if err != nil {
 		return nil, err
 	}
 
+	if err := dataservices.CreateIndexes(connection, BucketName, query.Index{Field: "Name", Kind: query.IndexEquals}); err != nil {
+		return nil, err
+	}
+
 	return &Service{
 		BaseDataService: dataservices.BaseDataService[portainer.Stack, portainer.StackID]{
 			Bucket:     BucketName,
@@ -40,38 +45,17 @@ func (service *Service) Tx(tx portainer.Transaction) ServiceTx {
 
 // StackByName returns a stack object by name.
 func (service *Service) StackByName(name string) (*portainer.Stack, error) {
-	var s portainer.Stack
-
-	err := service.Connection.GetAll(
-		BucketName,
-		&portainer.Stack{},
-		dataservices.FirstFn(&s, func(e portainer.Stack) bool {
-			return e.Name == name
-		}),
-	)
-
-	if errors.Is(err, dataservices.ErrStop) {
-		return &s, nil
-	}
-
-	if err == nil {
-		return nil, dserrors.ErrObjectNotFound
-	}
-
-	return nil, err
+	return dataservices.GetByField[portainer.Stack](service.Connection, BucketName, "Name", name)
 }
 
 // Stacks returns an array containing all the stacks with same name
 func (service *Service) StacksByName(name string) ([]portainer.Stack, error) {
-	var stacks = make([]portainer.Stack, 0)
+	return dataservices.Find[portainer.Stack](service.Connection, BucketName, query.Equals("Name", name))
+}
 
-	return stacks, service.Connection.GetAll(
-		BucketName,
-		&portainer.Stack{},
-		dataservices.FilterFn(&stacks, func(e portainer.Stack) bool {
-			return e.Name == name
-		}),
-	)
+// StacksPage returns a page of stacks
+func (service *Service) StacksPage(page query.Page) ([]portainer.Stack, query.PageInfo, error) {
+	return dataservices.FindPage[portainer.Stack](service.Connection, BucketName, page)
 }
 
 // GetNextIdentifier returns the next identifier for a stack.
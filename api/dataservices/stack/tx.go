@@ -5,6 +5,7 @@ import (
 	"strings"
 
 	portainer "github.com/portainer/portainer/api"
+	"github.com/portainer/portainer/api/database/query"
 	"github.com/portainer/portainer/api/dataservices"
 	dserrors "github.com/portainer/portainer/api/dataservices/errors"
 )
@@ -15,38 +16,17 @@ type ServiceTx struct {
 
 // StackByName returns a stack object by name.
 func (service ServiceTx) StackByName(name string) (*portainer.Stack, error) {
-	var s portainer.Stack
-
-	err := service.Tx.GetAll(
-		BucketName,
-		&portainer.Stack{},
-		dataservices.FirstFn(&s, func(e portainer.Stack) bool {
-			return e.Name == name
-		}),
-	)
-
-	if errors.Is(err, dataservices.ErrStop) {
-		return &s, nil
-	}
-
-	if err == nil {
-		return nil, dserrors.ErrObjectNotFound
-	}
-
-	return nil, err
+	return dataservices.GetByField[portainer.Stack](service.Tx, BucketName, "Name", name)
 }
 
 // Stacks returns an array containing all the stacks with same name
 func (service ServiceTx) StacksByName(name string) ([]portainer.Stack, error) {
-	var stacks = make([]portainer.Stack, 0)
+	return dataservices.Find[portainer.Stack](service.Tx, BucketName, query.Equals("Name", name))
+}
 
-	return stacks, service.Tx.GetAll(
-		BucketName,
-		&portainer.Stack{},
-		dataservices.FilterFn(&stacks, func(e portainer.Stack) bool {
-			return e.Name == name
-		}),
-	)
+// StacksPage returns a page of stacks
+func (service ServiceTx) StacksPage(page query.Page) ([]portainer.Stack, query.PageInfo, error) {
+	return dataservices.FindPage[portainer.Stack](service.Tx, BucketName, page)
 }
 
 // GetNextIdentifier returns the next identifier for a stack.
@@ -19,7 +19,7 @@ type Service struct {
 
 // NewService creates a new instance of a service.
 func NewService(connection portainer.Connection) (*Service, error) {
-	if err := connection.SetServiceName(BucketName); err != nil {
+	if err := connection.SetServiceName(BucketName, portainer.KeyKindInteger); err != nil {
 		return nil, err
 	}
 
@@ -4,6 +4,7 @@ import (
 	"fmt"
 
 	portainer "github.com/portainer/portainer/api"
+	"github.com/portainer/portainer/api/database/query"
 	"github.com/portainer/portainer/api/dataservices"
 
 	"github.com/rs/zerolog/log"
@@ -19,11 +20,18 @@ type Service struct {
 
 // NewService creates a new instance of a service.
 func NewService(connection portainer.Connection) (*Service, error) {
-	err := connection.SetServiceName(BucketName)
+	err := connection.SetServiceName(BucketName, portainer.KeyKindInteger)
 	if err != nil {
 		return nil, err
 	}
 
+	if err := dataservices.CreateIndexes(connection, BucketName,
+		query.Index{Field: "UserID", Kind: query.IndexEquals},
+		query.Index{Field: "TeamID", Kind: query.IndexEquals},
+	); err != nil {
+		return nil, err
+	}
+
 	return &Service{
 		BaseDataService: dataservices.BaseDataService[portainer.TeamMembership, portainer.TeamMembershipID]{
 			Bucket:     BucketName,
@@ -44,28 +52,12 @@ func (service *Service) Tx(tx portainer.Transaction) ServiceTx {
 
 // TeamMembershipsByUserID return an array containing all the TeamMembership objects where the specified userID is present.
 func (service *Service) TeamMembershipsByUserID(userID portainer.UserID) ([]portainer.TeamMembership, error) {
-	var memberships = make([]portainer.TeamMembership, 0)
-
-	return memberships, service.Connection.GetAll(
-		BucketName,
-		&portainer.TeamMembership{},
-		dataservices.FilterFn(&memberships, func(e portainer.TeamMembership) bool {
-			return e.UserID == userID
-		}),
-	)
+	return dataservices.Find[portainer.TeamMembership](service.Connection, BucketName, query.Equals("UserID", userID))
 }
 
 // TeamMembershipsByTeamID return an array containing all the TeamMembership objects where the specified teamID is present.
 func (service *Service) TeamMembershipsByTeamID(teamID portainer.TeamID) ([]portainer.TeamMembership, error) {
-	var memberships = make([]portainer.TeamMembership, 0)
-
-	return memberships, service.Connection.GetAll(
-		BucketName,
-		&portainer.TeamMembership{},
-		dataservices.FilterFn(&memberships, func(e portainer.TeamMembership) bool {
-			return e.TeamID == teamID
-		}),
-	)
+	return dataservices.Find[portainer.TeamMembership](service.Connection, BucketName, query.Equals("TeamID", teamID))
 }
 
 // CreateTeamMembership creates a new TeamMembership object.
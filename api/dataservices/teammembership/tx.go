@@ -4,6 +4,7 @@ import (
 	"fmt"
 
 	portainer "github.com/portainer/portainer/api"
+	"github.com/portainer/portainer/api/database/query"
 	"github.com/portainer/portainer/api/dataservices"
 
 	"github.com/rs/zerolog/log"
@@ -15,28 +16,12 @@ type ServiceTx struct {
 
 // TeamMembershipsByUserID return an array containing all the TeamMembership objects where the specified userID is present.
 func (service ServiceTx) TeamMembershipsByUserID(userID portainer.UserID) ([]portainer.TeamMembership, error) {
-	var memberships = make([]portainer.TeamMembership, 0)
-
-	return memberships, service.Tx.GetAll(
-		BucketName,
-		&portainer.TeamMembership{},
-		dataservices.FilterFn(&memberships, func(e portainer.TeamMembership) bool {
-			return e.UserID == userID
-		}),
-	)
+	return dataservices.Find[portainer.TeamMembership](service.Tx, BucketName, query.Equals("UserID", userID))
 }
 
 // TeamMembershipsByTeamID return an array containing all the TeamMembership objects where the specified teamID is present.
 func (service ServiceTx) TeamMembershipsByTeamID(teamID portainer.TeamID) ([]portainer.TeamMembership, error) {
-	var memberships = make([]portainer.TeamMembership, 0)
-
-	return memberships, service.Tx.GetAll(
-		BucketName,
-		&portainer.TeamMembership{},
-		dataservices.FilterFn(&memberships, func(e portainer.TeamMembership) bool {
-			return e.TeamID == teamID
-		}),
-	)
+	return dataservices.Find[portainer.TeamMembership](service.Tx, BucketName, query.Equals("TeamID", teamID))
 }
 
 // CreateTeamMembership creates a new TeamMembership object.
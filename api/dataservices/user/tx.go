@@ -1,12 +1,11 @@
 package user
 
 import (
-	"errors"
 	"strings"
 
 	portainer "github.com/portainer/portainer/api"
+	"github.com/portainer/portainer/api/database/query"
 	"github.com/portainer/portainer/api/dataservices"
-	dserrors "github.com/portainer/portainer/api/dataservices/errors"
 )
 
 type ServiceTx struct {
@@ -15,25 +14,7 @@ type ServiceTx struct {
 
 // UserByUsername returns a user by username.
 func (service ServiceTx) UserByUsername(username string) (*portainer.User, error) {
-	var u portainer.User
-
-	err := service.Tx.GetAll(
-		BucketName,
-		&portainer.User{},
-		dataservices.FirstFn(&u, func(e portainer.User) bool {
-			return strings.EqualFold(e.Username, username)
-		}),
-	)
-
-	if errors.Is(err, dataservices.ErrStop) {
-		return &u, nil
-	}
-
-	if err == nil {
-		return nil, dserrors.ErrObjectNotFound
-	}
-
-	return nil, err
+	return dataservices.First[portainer.User](service.Tx, BucketName, query.EqualFold("Username", username))
 }
 
 // UsersByRole return an array containing all the users with the specified role.
@@ -1,12 +1,11 @@
 package user
 
 import (
-	"errors"
 	"strings"
 
 	portainer "github.com/portainer/portainer/api"
+	"github.com/portainer/portainer/api/database/query"
 	"github.com/portainer/portainer/api/dataservices"
-	dserrors "github.com/portainer/portainer/api/dataservices/errors"
 )
 
 // BucketName represents the name of the bucket where this service stores data.
@@ -19,11 +18,15 @@ type Service struct {
 
 // NewService creates a new instance of a service.
 func NewService(connection portainer.Connection) (*Service, error) {
-	err := connection.SetServiceName(BucketName)
+	err := connection.SetServiceName(BucketName, portainer.KeyKindInteger)
 	if err != nil {
 		return nil, err
 	}
 
+	if err := dataservices.CreateIndexes(connection, BucketName, query.Index{Field: "Username", Kind: query.IndexEqualFold}); err != nil {
+		return nil, err
+	}
+
 	return &Service{
 		BaseDataService: dataservices.BaseDataService[portainer.User, portainer.UserID]{
 			Bucket:     BucketName,
@@ -44,25 +47,7 @@ func (service *Service) Tx(tx portainer.Transaction) ServiceTx {
 
 // UserByUsername returns a user by username.
 func (service *Service) UserByUsername(username string) (*portainer.User, error) {
-	var u portainer.User
-
-	err := service.Connection.GetAll(
-		BucketName,
-		&portainer.User{},
-		dataservices.FirstFn(&u, func(e portainer.User) bool {
-			return strings.EqualFold(e.Username, username)
-		}),
-	)
-
-	if errors.Is(err, dataservices.ErrStop) {
-		return &u, nil
-	}
-
-	if err == nil {
-		return nil, dserrors.ErrObjectNotFound
-	}
-
-	return nil, err
+	return dataservices.First[portainer.User](service.Connection, BucketName, query.EqualFold("Username", username))
 }
 
 // UsersByRole return an array containing all the users with the specified role.
@@ -0,0 +1,63 @@
+package usersession
+
+import (
+	portainer "github.com/portainer/portainer/api"
+	"github.com/portainer/portainer/api/database/query"
+	"github.com/portainer/portainer/api/dataservices"
+)
+
+type ServiceTx struct {
+	dataservices.BaseDataServiceTx[portainer.UserSession, portainer.UserSessionID]
+}
+
+// Create assigns an ID to a new session and saves it.
+func (service ServiceTx) Create(session *portainer.UserSession) error {
+	return service.Tx.CreateObject(BucketName, func(id uint64) (int, any) {
+		session.ID = portainer.UserSessionID(id)
+
+		return int(session.ID), session
+	})
+}
+
+// SessionByJTI returns the session of the token identified by jti.
+func (service ServiceTx) SessionByJTI(jti string) (*portainer.UserSession, error) {
+	return dataservices.GetByField[portainer.UserSession](service.Tx, BucketName, "jti", jti)
+}
+
+// SessionsByUserID returns the sessions of a user, including the revoked ones.
+func (service ServiceTx) SessionsByUserID(userID portainer.UserID) ([]portainer.UserSession, error) {
+	return dataservices.Find[portainer.UserSession](service.Tx, BucketName, query.Equals("userId", userID))
+}
+
+// RevokeUserSessions revokes every session of a user.
+func (service ServiceTx) RevokeUserSessions(userID portainer.UserID, revokedAt int64) error {
+	sessions, err := service.SessionsByUserID(userID)
+	if err != nil {
+		return err
+	}
+
+	for _, session := range sessions {
+		if session.RevokedAt != 0 {
+			continue
+		}
+
+		session.RevokedAt = revokedAt
+		if err := service.Update(session.ID, &session); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// DeleteExpiredSessions removes the sessions whose token expired before now.
+func (service ServiceTx) DeleteExpiredSessions(now int64) error {
+	return service.Tx.DeleteAllObjects(BucketName, &portainer.UserSession{}, func(o any) (int, bool) {
+		session, ok := o.(*portainer.UserSession)
+		if !ok {
+			return 0, false
+		}
+
+		return int(session.ID), session.ExpiresAt != 0 && session.ExpiresAt < now
+	})
+}
@@ -0,0 +1,77 @@
+package usersession
+
+import (
+	portainer "github.com/portainer/portainer/api"
+	"github.com/portainer/portainer/api/database/query"
+	"github.com/portainer/portainer/api/dataservices"
+)
+
+// BucketName represents the name of the bucket where this service stores data.
+const BucketName = "user_sessions"
+
+// Service represents a service for managing the sessions of the users.
+type Service struct {
+	dataservices.BaseDataService[portainer.UserSession, portainer.UserSessionID]
+}
+
+// NewService creates a new instance of a service.
+func NewService(connection portainer.Connection) (*Service, error) {
+	if err := connection.SetServiceName(BucketName, portainer.KeyKindInteger); err != nil {
+		return nil, err
+	}
+
+	if err := dataservices.CreateIndexes(connection, BucketName,
+		query.Index{Field: "jti", Kind: query.IndexEquals},
+		query.Index{Field: "userId", Kind: query.IndexEquals},
+	); err != nil {
+		return nil, err
+	}
+
+	return &Service{
+		BaseDataService: dataservices.BaseDataService[portainer.UserSession, portainer.UserSessionID]{
+			Bucket:     BucketName,
+			Connection: connection,
+		},
+	}, nil
+}
+
+func (service *Service) Tx(tx portainer.Transaction) ServiceTx {
+	return ServiceTx{
+		BaseDataServiceTx: dataservices.BaseDataServiceTx[portainer.UserSession, portainer.UserSessionID]{
+			Bucket:     BucketName,
+			Connection: service.Connection,
+			Tx:         tx,
+		},
+	}
+}
+
+// Create assigns an ID to a new session and saves it.
+func (service *Service) Create(session *portainer.UserSession) error {
+	return service.Connection.UpdateTx(func(tx portainer.Transaction) error {
+		return service.Tx(tx).Create(session)
+	})
+}
+
+// SessionByJTI returns the session of the token identified by jti.
+func (service *Service) SessionByJTI(jti string) (*portainer.UserSession, error) {
+	return dataservices.GetByField[portainer.UserSession](service.Connection, BucketName, "jti", jti)
+}
+
+// SessionsByUserID returns the sessions of a user, including the revoked ones.
+func (service *Service) SessionsByUserID(userID portainer.UserID) ([]portainer.UserSession, error) {
+	return dataservices.Find[portainer.UserSession](service.Connection, BucketName, query.Equals("userId", userID))
+}
+
+// RevokeUserSessions revokes every session of a user.
+func (service *Service) RevokeUserSessions(userID portainer.UserID, revokedAt int64) error {
+	return service.Connection.UpdateTx(func(tx portainer.Transaction) error {
+		return service.Tx(tx).RevokeUserSessions(userID, revokedAt)
+	})
+}
+
+// DeleteExpiredSessions removes the sessions whose token expired before now.
+func (service *Service) DeleteExpiredSessions(now int64) error {
+	return service.Connection.UpdateTx(func(tx portainer.Transaction) error {
+		return service.Tx(tx).DeleteExpiredSessions(now)
+	})
+}
@@ -2,6 +2,7 @@ package datastore
 
 import (
 	"fmt"
+	"io"
 	"os"
 	"path"
 
@@ -9,6 +10,13 @@ import (
 	"github.com/rs/zerolog/log"
 )
 
+// logicalBackuper is implemented by the connections that are not backed by a
+// database file. They are backed up to a dump and restored from it while open.
+type logicalBackuper interface {
+	BackupTo(w io.Writer) error
+	RestoreFrom(r io.Reader) error
+}
+
 // Backup takes an optional output path and creates a backup of the database.
 // The database connection is stopped before running the backup to avoid any
 // corruption and if a path is not given a default is used.
@@ -22,6 +30,11 @@ func (store *Store) Backup(path string) (string, error) {
 	if path != "" {
 		backupFilename = path
 	}
+
+	if connection, ok := store.connection.(logicalBackuper); ok {
+		return backupFilename, store.dump(connection, backupFilename)
+	}
+
 	log.Info().Str("from", store.connection.GetDatabaseFilePath()).Str("to", backupFilename).Msgf("Backing up database")
 
 	// Close the store before backing up
@@ -50,16 +63,22 @@ func (store *Store) Restore() error {
 }
 
 func (store *Store) RestoreFromFile(backupFilename string) error {
-	store.Close()
-	if err := store.fileService.Copy(backupFilename, store.connection.GetDatabaseFilePath(), true); err != nil {
-		return fmt.Errorf("unable to restore backup file %q. err: %w", backupFilename, err)
-	}
+	if connection, ok := store.connection.(logicalBackuper); ok {
+		if err := store.restoreDump(connection, backupFilename); err != nil {
+			return err
+		}
+	} else {
+		store.Close()
+		if err := store.fileService.Copy(backupFilename, store.connection.GetDatabaseFilePath(), true); err != nil {
+			return fmt.Errorf("unable to restore backup file %q. err: %w", backupFilename, err)
+		}
 
-	log.Info().Str("from", backupFilename).Str("to", store.connection.GetDatabaseFilePath()).Msgf("database restored")
+		log.Info().Str("from", backupFilename).Str("to", store.connection.GetDatabaseFilePath()).Msgf("database restored")
 
-	_, err := store.Open()
-	if err != nil {
-		return fmt.Errorf("unable to determine version of restored portainer backup file: %w", err)
+		_, err := store.Open()
+		if err != nil {
+			return fmt.Errorf("unable to determine version of restored portainer backup file: %w", err)
+		}
 	}
 
 	// determine the db version
@@ -73,6 +92,40 @@ func (store *Store) RestoreFromFile(backupFilename string) error {
 	return nil
 }
 
+// dump writes a dump of the open store to backupFilename
+func (store *Store) dump(connection logicalBackuper, backupFilename string) error {
+	log.Info().Str("to", backupFilename).Msg("Backing up database")
+
+	f, err := os.OpenFile(backupFilename, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to create backup file: %w", err)
+	}
+
+	if err := connection.BackupTo(f); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to back up database: %w", err)
+	}
+
+	return f.Close()
+}
+
+// restoreDump replaces the content of the open store with the dump in backupFilename
+func (store *Store) restoreDump(connection logicalBackuper, backupFilename string) error {
+	f, err := os.Open(backupFilename)
+	if err != nil {
+		return fmt.Errorf("unable to open backup file %q. err: %w", backupFilename, err)
+	}
+	defer f.Close()
+
+	if err := connection.RestoreFrom(f); err != nil {
+		return fmt.Errorf("unable to restore backup file %q. err: %w", backupFilename, err)
+	}
+
+	log.Info().Str("from", backupFilename).Msg("database restored")
+
+	return nil
+}
+
 func (store *Store) createBackupPath() error {
 	backupDir := path.Join(store.connection.GetStorePath(), "backups")
 	if exists, _ := store.fileService.FileExists(backupDir); !exists {
@@ -0,0 +1,265 @@
+package datastore
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"slices"
+
+	portainer "github.com/portainer/portainer/api"
+
+	"github.com/rs/zerolog/log"
+	"github.com/segmentio/encoding/json"
+)
+
+var (
+	ErrCopyUnsupported = errors.New("the database connection cannot be used to copy a store")
+	ErrSourceEmpty     = errors.New("the source store is empty")
+	ErrTargetNotEmpty  = errors.New("the target store is not empty")
+	ErrCopyMismatch    = errors.New("the copied store does not match its source")
+)
+
+// rawIterator is implemented by the connections that can list their buckets
+// and read their objects without knowing their type
+type rawIterator interface {
+	BucketNames() ([]string, error)
+	ForEachRaw(bucketName string, fn func(key, value []byte) error) error
+}
+
+// bucketDigest summarizes the content of a bucket. The checksum covers the keys
+// and the canonical JSON form of the values, so that it does not depend on how
+// a backend formats the documents it stores.
+type bucketDigest struct {
+	Count    int
+	Checksum string
+}
+
+type digester struct {
+	hash  hash.Hash
+	count int
+}
+
+func newDigester() *digester {
+	return &digester{hash: sha256.New()}
+}
+
+func (d *digester) add(key, value []byte) {
+	var size [8]byte
+
+	for _, b := range [][]byte{key, canonicalValue(value)} {
+		binary.BigEndian.PutUint64(size[:], uint64(len(b)))
+		d.hash.Write(size[:])
+		d.hash.Write(b)
+	}
+
+	d.count++
+}
+
+func (d *digester) digest() bucketDigest {
+	return bucketDigest{Count: d.count, Checksum: hex.EncodeToString(d.hash.Sum(nil))}
+}
+
+// canonicalValue returns the JSON value with sorted keys and no insignificant
+// whitespace, values that are not JSON are returned as is
+func canonicalValue(value []byte) []byte {
+	decoder := json.NewDecoder(bytes.NewReader(value))
+	decoder.UseNumber()
+
+	var v any
+	if err := decoder.Decode(&v); err != nil {
+		return value
+	}
+
+	canonical, err := json.Marshal(v)
+	if err != nil {
+		return value
+	}
+
+	return canonical
+}
+
+// rawObject wraps a raw value so that the connection codec stores it unchanged
+func rawObject(value []byte) any {
+	if json.Valid(value) {
+		return json.RawMessage(bytes.Clone(value))
+	}
+
+	// Written as is by MarshalObject, like the raw strings of the version bucket
+	return string(value)
+}
+
+// CopyFrom copies every bucket of source, with its sequence, into the store,
+// which must be open and empty. The copy is verified by comparing the number
+// of objects and a checksum of every bucket, then the version record is
+// stamped with the schema version of the store. The source is only read.
+func (store *Store) CopyFrom(source portainer.Connection) error {
+	sourceIterator, ok := source.(rawIterator)
+	if !ok {
+		return fmt.Errorf("%w: source", ErrCopyUnsupported)
+	}
+
+	targetIterator, ok := store.connection.(rawIterator)
+	if !ok {
+		return fmt.Errorf("%w: target", ErrCopyUnsupported)
+	}
+
+	targetBuckets, err := targetIterator.BucketNames()
+	if err != nil {
+		return err
+	}
+
+	for _, bucketName := range targetBuckets {
+		digest, err := digestBucket(targetIterator, bucketName)
+		if err != nil {
+			return err
+		}
+
+		if digest.Count > 0 {
+			return fmt.Errorf("%w: bucket %s holds %d objects", ErrTargetNotEmpty, bucketName, digest.Count)
+		}
+	}
+
+	buckets, err := sourceIterator.BucketNames()
+	if err != nil {
+		return err
+	}
+
+	if len(buckets) == 0 {
+		return ErrSourceEmpty
+	}
+
+	digests := make(map[string]bucketDigest, len(buckets))
+	for _, bucketName := range buckets {
+		if !slices.Contains(targetBuckets, bucketName) {
+			if err := store.createBucketLike(sourceIterator, bucketName); err != nil {
+				return err
+			}
+		}
+
+		digest, err := store.copyBucket(sourceIterator, bucketName)
+		if err != nil {
+			return fmt.Errorf("failed to copy bucket %s: %w", bucketName, err)
+		}
+
+		digests[bucketName] = digest
+
+		log.Info().Str("bucket", bucketName).Int("objects", digest.Count).Msg("bucket copied")
+	}
+
+	if err := store.copySequences(source); err != nil {
+		return err
+	}
+
+	for bucketName, want := range digests {
+		got, err := digestBucket(targetIterator, bucketName)
+		if err != nil {
+			return err
+		}
+
+		if got != want {
+			return fmt.Errorf("%w: bucket %s has %d objects (checksum %s), expected %d (checksum %s)", ErrCopyMismatch, bucketName, got.Count, got.Checksum, want.Count, want.Checksum)
+		}
+	}
+
+	return store.stampVersion()
+}
+
+func digestBucket(iterator rawIterator, bucketName string) (bucketDigest, error) {
+	d := newDigester()
+
+	err := iterator.ForEachRaw(bucketName, func(key, value []byte) error {
+		d.add(key, value)
+
+		return nil
+	})
+
+	return d.digest(), err
+}
+
+// createBucketLike creates a bucket that no service of the store registers,
+// such as the buckets left over by older versions. The key kind is deduced from
+// the keys, only integer keys are 8 bytes long.
+func (store *Store) createBucketLike(source rawIterator, bucketName string) error {
+	keyKind := portainer.KeyKindInteger
+
+	err := source.ForEachRaw(bucketName, func(key, _ []byte) error {
+		if len(key) != 8 {
+			keyKind = portainer.KeyKindString
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return store.connection.SetServiceName(bucketName, keyKind)
+}
+
+func (store *Store) copyBucket(source rawIterator, bucketName string) (bucketDigest, error) {
+	d := newDigester()
+
+	err := store.connection.UpdateTx(func(tx portainer.Transaction) error {
+		return source.ForEachRaw(bucketName, func(key, value []byte) error {
+			d.add(key, value)
+
+			return tx.UpdateObject(bucketName, bytes.Clone(key), rawObject(value))
+		})
+	})
+
+	return d.digest(), err
+}
+
+// copySequences copies the bucket sequences and checks them back
+func (store *Store) copySequences(source portainer.Connection) error {
+	sequences, err := source.BackupMetadata()
+	if err != nil {
+		return fmt.Errorf("failed to read the source sequences: %w", err)
+	}
+
+	// RestoreMetadata expects the float64 values of a JSON document
+	metadata := make(map[string]any, len(sequences))
+	for bucketName, v := range sequences {
+		if i, ok := v.(int); ok {
+			metadata[bucketName] = float64(i)
+		}
+	}
+
+	if err := store.connection.RestoreMetadata(metadata); err != nil {
+		return fmt.Errorf("failed to restore the sequences: %w", err)
+	}
+
+	copied, err := store.connection.BackupMetadata()
+	if err != nil {
+		return err
+	}
+
+	for bucketName, v := range sequences {
+		if copied[bucketName] != v {
+			return fmt.Errorf("%w: sequence of bucket %s is %v, expected %v", ErrCopyMismatch, bucketName, copied[bucketName], v)
+		}
+	}
+
+	return nil
+}
+
+// stampVersion records the schema version of the store in the copied version record
+func (store *Store) stampVersion() error {
+	version, err := store.VersionService.Version()
+	if err != nil {
+		return fmt.Errorf("failed to read the copied version: %w", err)
+	}
+
+	schemaVersion, err := store.SchemaVersion()
+	if err != nil {
+		return err
+	}
+
+	version.StoreSchemaVersion = schemaVersion
+
+	return store.VersionService.UpdateVersion(version)
+}
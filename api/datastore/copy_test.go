@@ -0,0 +1,68 @@
+package datastore
+
+import (
+	"testing"
+
+	portainer "github.com/portainer/portainer/api"
+	"github.com/portainer/portainer/api/database"
+	"github.com/portainer/portainer/api/filesystem"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newEmptyTestStore(t *testing.T, secretKey []byte) *Store {
+	storePath := t.TempDir()
+
+	fileService, err := filesystem.NewService(storePath, "")
+	require.NoError(t, err)
+
+	connection, err := database.NewDatabase("boltdb", storePath, secretKey)
+	require.NoError(t, err)
+
+	store := NewStore(storePath, fileService, connection)
+	_, err = store.Open()
+	require.NoError(t, err)
+
+	t.Cleanup(func() { store.Close() })
+
+	return store
+}
+
+func TestCopyFrom(t *testing.T) {
+	_, source := MustNewTestStore(t, true, false)
+	require.NoError(t, source.User().Create(&portainer.User{Username: "admin", Role: portainer.AdministratorRole}))
+	require.NoError(t, source.Endpoint().Create(&portainer.Endpoint{ID: 5, Name: "local"}))
+
+	target := newEmptyTestStore(t, []byte("apassphrasewhichneedstobe32bytes"))
+	require.NoError(t, target.CopyFrom(source.connection))
+	require.True(t, target.connection.IsEncryptedStore())
+
+	sourceBuckets, err := source.connection.(rawIterator).BucketNames()
+	require.NoError(t, err)
+
+	for _, bucketName := range sourceBuckets {
+		want, err := digestBucket(source.connection.(rawIterator), bucketName)
+		require.NoError(t, err)
+
+		got, err := digestBucket(target.connection.(rawIterator), bucketName)
+		require.NoError(t, err)
+
+		require.Equal(t, want, got, bucketName)
+	}
+
+	user, err := target.User().Read(1)
+	require.NoError(t, err)
+	require.Equal(t, "admin", user.Username)
+
+	// sequences are copied, new objects do not reuse identifiers
+	other := &portainer.User{Username: "user"}
+	require.NoError(t, target.User().Create(other))
+	require.Equal(t, portainer.UserID(2), other.ID)
+}
+
+func TestCopyFromRefusesNonEmptyTarget(t *testing.T) {
+	_, source := MustNewTestStore(t, true, false)
+	_, target := MustNewTestStore(t, true, false)
+
+	require.ErrorIs(t, target.CopyFrom(source.connection), ErrTargetNotEmpty)
+}
@@ -1,6 +1,7 @@
 package datastore
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io"
@@ -15,6 +16,12 @@ import (
 	"github.com/rs/zerolog/log"
 )
 
+// inPlaceEncrypter is implemented by connections that can encrypt an existing
+// store transactionally instead of going through a JSON export and import
+type inPlaceEncrypter interface {
+	EncryptStore() error
+}
+
 // NewStore initializes a new Store and the associated services
 func NewStore(storePath string, fileService portainer.FileService, connection portainer.Connection) *Store {
 	return &Store{
@@ -30,7 +37,11 @@ func (store *Store) Open() (newStore bool, err error) {
 		return false, err
 	}
 
-	if encryptionReq {
+	if encrypter, ok := store.connection.(inPlaceEncrypter); ok && encryptionReq {
+		if err := encrypter.EncryptStore(); err != nil {
+			return false, fmt.Errorf("failed to encrypt the database: %w", err)
+		}
+	} else if encryptionReq {
 		backupFilename, err := store.Backup("")
 		if err != nil {
 			return false, fmt.Errorf("failed to backup database prior to encrypting: %w", err)
@@ -87,6 +98,37 @@ func (store *Store) ViewTx(fn func(dataservices.DataStoreTx) error) error {
 	})
 }
 
+// UpdateTxCtx is UpdateTx, aborted when ctx is done, see portainer.Connection
+func (store *Store) UpdateTxCtx(ctx context.Context, fn func(dataservices.DataStoreTx) error) error {
+	return store.connection.UpdateTxCtx(ctx, func(tx portainer.Transaction) error {
+		return fn(&StoreTx{
+			store: store,
+			tx:    tx,
+		})
+	})
+}
+
+// UpdateTxRetryable is UpdateTxCtx, executed again after a serialization
+// failure or a deadlock, see portainer.Connection
+func (store *Store) UpdateTxRetryable(ctx context.Context, fn func(dataservices.DataStoreTx) error) error {
+	return store.connection.UpdateTxRetryable(ctx, func(tx portainer.Transaction) error {
+		return fn(&StoreTx{
+			store: store,
+			tx:    tx,
+		})
+	})
+}
+
+// ViewTxCtx is ViewTx, aborted when ctx is done, see portainer.Connection
+func (store *Store) ViewTxCtx(ctx context.Context, fn func(dataservices.DataStoreTx) error) error {
+	return store.connection.ViewTxCtx(ctx, func(tx portainer.Transaction) error {
+		return fn(&StoreTx{
+			store: store,
+			tx:    tx,
+		})
+	})
+}
+
 // BackupTo backs up db to a provided writer.
 // It does hot backup and doesn't block other database reads and writes
 func (store *Store) BackupTo(w io.Writer) error {
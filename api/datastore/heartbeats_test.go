@@ -0,0 +1,73 @@
+package datastore
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	portainer "github.com/portainer/portainer/api"
+	"github.com/portainer/portainer/api/dataservices/endpoint"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHeartbeatsSharedAcrossInstances(t *testing.T) {
+	_, store := MustNewTestStore(t, true, false)
+
+	require.NoError(t, store.Endpoint().Create(&portainer.Endpoint{ID: 1, Name: "edge"}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	store.EndpointService.StartHeartbeatSync(ctx)
+
+	store.Endpoint().UpdateHeartbeat(1)
+	checkIn, ok := store.Endpoint().Heartbeat(1)
+	require.True(t, ok)
+
+	// The pending check-ins are saved when the sync stops
+	cancel()
+
+	require.Eventually(t, func() bool {
+		other, err := endpoint.NewService(store.GetConnection())
+		if err != nil {
+			return false
+		}
+
+		heartbeat, _ := other.Heartbeat(1)
+
+		return heartbeat == checkIn
+	}, 5*time.Second, 50*time.Millisecond, "another instance reads the saved heartbeat")
+}
+
+func TestHeartbeatsOfEnvironmentsCreatedByAnotherInstance(t *testing.T) {
+	_, store := MustNewTestStore(t, true, false)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	store.EndpointService.StartHeartbeatSync(ctx)
+
+	// Another instance creates an environment this instance has not loaded,
+	// and records a check-in of an environment deleted since
+	other, err := endpoint.NewService(store.GetConnection())
+	require.NoError(t, err)
+	require.NoError(t, other.Create(&portainer.Endpoint{ID: 2, Name: "edge"}))
+
+	otherCtx, otherCancel := context.WithCancel(context.Background())
+	other.StartHeartbeatSync(otherCtx)
+	other.UpdateHeartbeat(2)
+	other.UpdateHeartbeat(3)
+	checkIn, _ := other.Heartbeat(2)
+	otherCancel()
+
+	deleted := func(endpointID portainer.EndpointID) bool {
+		connection := store.GetConnection()
+		err := connection.GetObject(endpoint.HeartbeatBucketName, connection.ConvertToKey(int(endpointID)), &struct{}{})
+
+		return store.IsErrObjectNotFound(err)
+	}
+
+	require.Eventually(t, func() bool {
+		heartbeat, ok := store.Endpoint().Heartbeat(2)
+
+		return ok && heartbeat == checkIn && !deleted(2) && deleted(3)
+	}, 10*time.Second, 50*time.Millisecond, "only the heartbeat of the deleted environment is deleted")
+}
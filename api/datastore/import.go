@@ -0,0 +1,222 @@
+package datastore
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+
+	portainer "github.com/portainer/portainer/api"
+	"github.com/portainer/portainer/api/dataservices/apikeyrepository"
+	"github.com/portainer/portainer/api/dataservices/auditlog"
+	"github.com/portainer/portainer/api/dataservices/customtemplate"
+	"github.com/portainer/portainer/api/dataservices/dockerhub"
+	"github.com/portainer/portainer/api/dataservices/edgegroup"
+	"github.com/portainer/portainer/api/dataservices/edgejob"
+	"github.com/portainer/portainer/api/dataservices/edgestack"
+	"github.com/portainer/portainer/api/dataservices/endpoint"
+	"github.com/portainer/portainer/api/dataservices/endpointgroup"
+	"github.com/portainer/portainer/api/dataservices/endpointrelation"
+	"github.com/portainer/portainer/api/dataservices/extension"
+	"github.com/portainer/portainer/api/dataservices/helmuserrepository"
+	"github.com/portainer/portainer/api/dataservices/jwtsigningkey"
+	"github.com/portainer/portainer/api/dataservices/pendingactions"
+	"github.com/portainer/portainer/api/dataservices/registry"
+	"github.com/portainer/portainer/api/dataservices/resourcecontrol"
+	"github.com/portainer/portainer/api/dataservices/role"
+	"github.com/portainer/portainer/api/dataservices/schedule"
+	"github.com/portainer/portainer/api/dataservices/settings"
+	"github.com/portainer/portainer/api/dataservices/snapshot"
+	"github.com/portainer/portainer/api/dataservices/ssl"
+	"github.com/portainer/portainer/api/dataservices/stack"
+	"github.com/portainer/portainer/api/dataservices/tag"
+	"github.com/portainer/portainer/api/dataservices/team"
+	"github.com/portainer/portainer/api/dataservices/teammembership"
+	"github.com/portainer/portainer/api/dataservices/tunnelserver"
+	"github.com/portainer/portainer/api/dataservices/user"
+	"github.com/portainer/portainer/api/dataservices/usersession"
+	"github.com/portainer/portainer/api/dataservices/version"
+	"github.com/portainer/portainer/api/dataservices/webhook"
+
+	"github.com/segmentio/encoding/json"
+)
+
+const (
+	exportMetadataKey = "__metadata"
+
+	// templatesBucketName is the bucket of the app templates of the stores
+	// older than version 2.0, it is found in the exports of these stores
+	templatesBucketName = "templates"
+)
+
+var errUnknownBucket = errors.New("unknown bucket")
+
+// singletonKeys are the keys of the buckets holding a single object. The JSON
+// export does not contain the keys, they are needed to import the objects back.
+var singletonKeys = map[string]string{
+	dockerhub.BucketName:    "DOCKERHUB",
+	settings.BucketName:     "SETTINGS",
+	ssl.BucketName:          "SSL",
+	tunnelserver.BucketName: "INFO",
+}
+
+// keyFields are the fields holding the key of the objects of the buckets of
+// objects, as they are named in the JSON of their model
+var keyFields = map[string]string{
+	apikeyrepository.BucketName:   "id",
+	auditlog.BucketName:           "Id",
+	customtemplate.BucketName:     "Id",
+	edgegroup.BucketName:          "Id",
+	edgejob.BucketName:            "Id",
+	edgestack.BucketName:          "Id",
+	endpoint.BucketName:           "Id",
+	endpoint.HeartbeatBucketName:  "EndpointID",
+	endpointgroup.BucketName:      "Id",
+	endpointrelation.BucketName:   "EndpointID",
+	extension.BucketName:          "Id",
+	helmuserrepository.BucketName: "Id",
+	jwtsigningkey.BucketName:      "id",
+	pendingactions.BucketName:     "ID",
+	registry.BucketName:           "Id",
+	resourcecontrol.BucketName:    "Id",
+	role.BucketName:               "Id",
+	schedule.BucketName:           "Id",
+	snapshot.BucketName:           "EndpointId",
+	stack.BucketName:              "Id",
+	tag.BucketName:                "ID",
+	team.BucketName:               "Id",
+	teammembership.BucketName:     "Id",
+	templatesBucketName:           "Id",
+	user.BucketName:               "Id",
+	usersession.BucketName:        "id",
+	webhook.BucketName:            "Id",
+}
+
+// ImportJSON loads a document written by the ExportJSON function of either
+// database connection into the store. The objects are written in a single
+// transaction, and the bucket sequences are restored when the document holds
+// the export metadata.
+func (store *Store) ImportJSON(r io.Reader) error {
+	var export map[string]json.RawMessage
+	if err := json.NewDecoder(r).Decode(&export); err != nil {
+		return fmt.Errorf("failed to decode the export: %w", err)
+	}
+
+	err := store.connection.UpdateTx(func(tx portainer.Transaction) error {
+		for bucketName, raw := range export {
+			if bucketName == exportMetadataKey {
+				continue
+			}
+
+			if err := importBucket(tx, bucketName, raw); err != nil {
+				return fmt.Errorf("failed to import bucket %s: %w", bucketName, err)
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	raw, ok := export[exportMetadataKey]
+	if !ok {
+		return nil
+	}
+
+	var metadata map[string]any
+	if err := json.Unmarshal(raw, &metadata); err != nil {
+		return fmt.Errorf("failed to decode the export metadata: %w", err)
+	}
+
+	return store.connection.RestoreMetadata(metadata)
+}
+
+func importBucket(tx portainer.Transaction, bucketName string, raw json.RawMessage) error {
+	if bucketName == version.BucketName {
+		return importVersion(tx, raw)
+	}
+
+	if key, ok := singletonKeys[bucketName]; ok {
+		return importSingleton(tx, bucketName, key, raw)
+	}
+
+	keyField, ok := keyFields[bucketName]
+	if !ok {
+		return errUnknownBucket
+	}
+
+	var objects []json.RawMessage
+	if err := json.Unmarshal(raw, &objects); err != nil {
+		return err
+	}
+
+	if err := tx.SetServiceName(bucketName, portainer.KeyKindInteger); err != nil {
+		return err
+	}
+
+	for _, object := range objects {
+		var fields map[string]json.RawMessage
+		if err := json.Unmarshal(object, &fields); err != nil {
+			return err
+		}
+
+		id, err := strconv.Atoi(string(fields[keyField]))
+		if err != nil {
+			return fmt.Errorf("invalid %s field: %w", keyField, err)
+		}
+
+		if err := tx.CreateObjectWithId(bucketName, id, object); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// importVersion writes the values of the version bucket, they are exported as
+// the raw strings stored in the bucket
+func importVersion(tx portainer.Transaction, raw json.RawMessage) error {
+	var values map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &values); err != nil {
+		return err
+	}
+
+	if err := tx.SetServiceName(version.BucketName, portainer.KeyKindString); err != nil {
+		return err
+	}
+
+	for key, value := range values {
+		var object any = value
+
+		var s string
+		if err := json.Unmarshal(value, &s); err == nil {
+			object = s
+		}
+
+		if err := tx.CreateObjectWithStringId(version.BucketName, []byte(key), object); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// importSingleton writes the object of a bucket holding a single object, which
+// is exported either on its own or as a list of one
+func importSingleton(tx portainer.Transaction, bucketName, key string, raw json.RawMessage) error {
+	var objects []json.RawMessage
+	if err := json.Unmarshal(raw, &objects); err != nil {
+		objects = []json.RawMessage{raw}
+	}
+
+	if len(objects) == 0 || string(objects[0]) == "null" {
+		return nil
+	}
+
+	if err := tx.SetServiceName(bucketName, portainer.KeyKindString); err != nil {
+		return err
+	}
+
+	return tx.CreateObjectWithStringId(bucketName, []byte(key), objects[0])
+}
@@ -0,0 +1,144 @@
+package datastore
+
+import (
+	"bytes"
+	"testing"
+
+	portainer "github.com/portainer/portainer/api"
+	"github.com/portainer/portainer/api/database/boltdb"
+	"github.com/portainer/portainer/api/dataservices/dockerhub"
+	"github.com/portainer/portainer/api/dataservices/endpoint"
+	"github.com/portainer/portainer/api/dataservices/extension"
+	"github.com/portainer/portainer/api/dataservices/schedule"
+	"github.com/portainer/portainer/api/dataservices/version"
+
+	"github.com/segmentio/encoding/json"
+	"github.com/stretchr/testify/require"
+)
+
+func exportTestStore(t *testing.T, store *Store) []byte {
+	con, ok := store.connection.(jsonExporter)
+	require.True(t, ok)
+
+	// The BoltDB connection exports its database file, it is closed meanwhile
+	_, isBolt := store.connection.(*boltdb.DbConnection)
+	if isBolt {
+		require.NoError(t, store.connection.Close())
+	}
+
+	b, err := con.ExportJSON(store.connection.GetDatabaseFilePath(), true)
+	require.NoError(t, err)
+
+	if isBolt {
+		_, err = store.Open()
+		require.NoError(t, err)
+	}
+
+	return b
+}
+
+func TestImportJSON(t *testing.T) {
+	_, store := MustNewTestStore(t, true, false)
+
+	require.NoError(t, store.User().Create(&portainer.User{Username: "admin", Role: portainer.AdministratorRole}))
+	require.NoError(t, store.Endpoint().Create(&portainer.Endpoint{ID: 3, Name: "local"}))
+	require.NoError(t, store.EndpointRelation().Create(&portainer.EndpointRelation{EndpointID: 3}))
+	require.NoError(t, store.Snapshot().Create(&portainer.Snapshot{EndpointID: 3}))
+
+	exported := exportTestStore(t, store)
+
+	_, imported := MustNewTestStore(t, true, false)
+	require.NoError(t, imported.ImportJSON(bytes.NewReader(exported)))
+
+	require.JSONEq(t, string(exported), string(exportTestStore(t, imported)))
+
+	// sequences are restored, new objects do not reuse identifiers
+	user := &portainer.User{Username: "user"}
+	require.NoError(t, imported.User().Create(user))
+	require.Equal(t, portainer.UserID(2), user.ID)
+}
+
+// fillEveryBucket writes an object in every bucket of the store
+func fillEveryBucket(t *testing.T, store *Store) {
+	is := require.New(t)
+
+	is.NoError(store.User().Create(&portainer.User{Username: "admin", Role: portainer.AdministratorRole}))
+	is.NoError(store.Team().Create(&portainer.Team{Name: "team"}))
+	is.NoError(store.TeamMembership().Create(&portainer.TeamMembership{UserID: 1, TeamID: 1}))
+	is.NoError(store.Endpoint().Create(&portainer.Endpoint{ID: 3, Name: "local"}))
+	is.NoError(store.EndpointGroup().Create(&portainer.EndpointGroup{Name: "group"}))
+	is.NoError(store.EndpointRelation().Create(&portainer.EndpointRelation{EndpointID: 3}))
+	is.NoError(store.Snapshot().Create(&portainer.Snapshot{EndpointID: 3}))
+	is.NoError(store.PendingActions().Create(&portainer.PendingAction{EndpointID: 3, Action: "action"}))
+	is.NoError(store.Stack().Create(&portainer.Stack{ID: 4, Name: "stack"}))
+	is.NoError(store.EdgeStack().Create(5, &portainer.EdgeStack{Name: "edge"}))
+	is.NoError(store.EdgeGroup().Create(&portainer.EdgeGroup{Name: "edge"}))
+	is.NoError(store.EdgeJob().Create(&portainer.EdgeJob{Name: "job"}))
+	is.NoError(store.CustomTemplate().Create(&portainer.CustomTemplate{ID: 6, Title: "template"}))
+	is.NoError(store.Registry().Create(&portainer.Registry{Name: "registry"}))
+	is.NoError(store.ResourceControl().Create(&portainer.ResourceControl{ResourceID: "resource"}))
+	is.NoError(store.Role().Create(&portainer.Role{Name: "role"}))
+	is.NoError(store.Tag().Create(&portainer.Tag{Name: "tag"}))
+	is.NoError(store.Webhook().Create(&portainer.Webhook{Token: "token"}))
+	is.NoError(store.HelmUserRepository().Create(&portainer.HelmUserRepository{URL: "https://charts"}))
+	is.NoError(store.APIKeyRepository().Create(&portainer.APIKey{UserID: 1, Description: "key"}))
+	is.NoError(store.JWTSigningKey().Create(&portainer.JWTSigningKey{Secret: []byte("secret")}))
+	is.NoError(store.UserSession().Create(&portainer.UserSession{JTI: "jti", UserID: 1}))
+	is.NoError(store.AuditLog().Create(&portainer.AuditLog{Method: "POST"}))
+	is.NoError(store.TunnelServer().UpdateInfo(&portainer.TunnelServerInfo{PrivateKeySeed: "seed"}))
+
+	// The buckets of the older stores and the heartbeats have no service in the store
+	con := store.connection
+	is.NoError(con.SetServiceName(schedule.BucketName, portainer.KeyKindInteger))
+	is.NoError(con.CreateObjectWithId(schedule.BucketName, 7, &portainer.Schedule{ID: 7, Name: "schedule"}))
+	is.NoError(con.SetServiceName(extension.BucketName, portainer.KeyKindInteger))
+	is.NoError(con.CreateObjectWithId(extension.BucketName, 8, &portainer.Extension{ID: 8, Name: "extension"}))
+	is.NoError(con.SetServiceName(templatesBucketName, portainer.KeyKindInteger))
+	is.NoError(con.CreateObjectWithId(templatesBucketName, 9, map[string]any{"Id": 9, "title": "template"}))
+	is.NoError(con.SetServiceName(endpoint.HeartbeatBucketName, portainer.KeyKindInteger))
+	is.NoError(con.CreateObjectWithId(endpoint.HeartbeatBucketName, 3, map[string]any{"EndpointID": 3, "LastCheckIn": 1700000000}))
+	is.NoError(con.SetServiceName(dockerhub.BucketName, portainer.KeyKindString))
+	is.NoError(con.CreateObjectWithStringId(dockerhub.BucketName, []byte("DOCKERHUB"), &portainer.DockerHub{Username: "user"}))
+}
+
+func TestImportJSONEveryBucket(t *testing.T) {
+	_, store := MustNewTestStore(t, true, false)
+	fillEveryBucket(t, store)
+
+	exported := exportTestStore(t, store)
+
+	var buckets map[string]json.RawMessage
+	require.NoError(t, json.Unmarshal(exported, &buckets))
+
+	for bucketName := range keyFields {
+		require.Contains(t, buckets, bucketName, "every bucket of objects is exported")
+	}
+
+	for bucketName := range buckets {
+		_, objects := keyFields[bucketName]
+		_, singleton := singletonKeys[bucketName]
+		require.True(t, objects || singleton || bucketName == version.BucketName || bucketName == exportMetadataKey, "bucket %s has no key field", bucketName)
+	}
+
+	for _, storeType := range StoreTypesUnderTest() {
+		t.Run(storeType, func(t *testing.T) {
+			_, imported := MustNewTestStoreOfType(t, storeType, false, false)
+			require.NoError(t, imported.ImportJSON(bytes.NewReader(exported)))
+
+			// The metadata of the backends differ, the sequences are checked by TestImportJSON
+			require.JSONEq(t, withoutMetadata(t, exported), withoutMetadata(t, exportTestStore(t, imported)))
+		})
+	}
+}
+
+func withoutMetadata(t *testing.T, exported []byte) string {
+	var buckets map[string]json.RawMessage
+	require.NoError(t, json.Unmarshal(exported, &buckets))
+
+	delete(buckets, exportMetadataKey)
+
+	b, err := json.Marshal(buckets)
+	require.NoError(t, err)
+
+	return string(b)
+}
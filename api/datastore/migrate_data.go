@@ -36,7 +36,7 @@ func (store *Store) MigrateData() error {
 	migrator := migrator.NewMigrator(migratorParams)
 
 	if !migrator.NeedsMigration() {
-		return nil
+		return store.updateStoreSchemaVersion(version)
 	}
 
 	// before we alter anything in the DB, create a backup
@@ -110,6 +110,13 @@ func (store *Store) FailSafeMigrate(migrator *migrator.Migrator, version *models
 		return errors.Wrap(err, "while updating version")
 	}
 
+	// the migrator persists the version once the data is migrated, so that the
+	// schema and data versions are recorded together
+	version.StoreSchemaVersion, err = store.SchemaVersion()
+	if err != nil {
+		return errors.Wrap(err, "while reading the schema version")
+	}
+
 	log.Info().Msg("migrating database from version " + version.SchemaVersion + " to " + portainer.APIVersion)
 
 	err = migrator.Migrate()
@@ -130,6 +137,38 @@ func (store *Store) FailSafeMigrate(migrator *migrator.Migrator, version *models
 	return nil
 }
 
+// schemaVersioner is implemented by the connections that keep a versioned SQL schema
+type schemaVersioner interface {
+	SchemaVersion() (int, error)
+}
+
+// SchemaVersion returns the version of the SQL schema of the store, 0 for the
+// backends that do not have one
+func (store *Store) SchemaVersion() (int, error) {
+	if connection, ok := store.connection.(schemaVersioner); ok {
+		return connection.SchemaVersion()
+	}
+
+	return 0, nil
+}
+
+// updateStoreSchemaVersion records the schema version when only the schema was
+// migrated, the connection applies schema migrations when it is opened
+func (store *Store) updateStoreSchemaVersion(version *models.Version) error {
+	schemaVersion, err := store.SchemaVersion()
+	if err != nil {
+		return errors.Wrap(err, "while reading the schema version")
+	}
+
+	if version.StoreSchemaVersion == schemaVersion {
+		return nil
+	}
+
+	version.StoreSchemaVersion = schemaVersion
+
+	return store.VersionService.UpdateVersion(version)
+}
+
 // Rollback to a pre-upgrade backup copy/snapshot of portainer.db
 func (store *Store) connectionRollback(force bool) error {
 	if !force {
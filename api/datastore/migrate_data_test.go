@@ -2,9 +2,6 @@ package datastore
 
 import (
 	"bytes"
-	"encoding/json"
-	"fmt"
-	"io"
 	"os"
 	"path/filepath"
 	"testing"
@@ -33,17 +30,19 @@ func TestMigrateData(t *testing.T) {
 			overrideInstanceId: true,
 		},
 	}
-	for _, test := range tests {
-		t.Run(test.testName, func(t *testing.T) {
-			err := migrateDBTestHelper(t, test.srcPath, test.wantPath, test.overrideInstanceId)
-			if err != nil {
-				t.Errorf(
-					"Failed migrating mock database %v: %v",
-					test.srcPath,
-					err,
-				)
-			}
-		})
+	for _, storeType := range StoreTypesUnderTest() {
+		for _, test := range tests {
+			t.Run(storeType+"/"+test.testName, func(t *testing.T) {
+				err := migrateDBTestHelper(t, storeType, test.srcPath, test.wantPath, test.overrideInstanceId)
+				if err != nil {
+					t.Errorf(
+						"Failed migrating mock database %v: %v",
+						test.srcPath,
+						err,
+					)
+				}
+			})
+		}
 	}
 
 	t.Run("MigrateData for New Store & Re-Open Check", func(t *testing.T) {
@@ -223,10 +222,10 @@ func TestRollback(t *testing.T) {
 	})
 }
 
-// migrateDBTestHelper loads a json representation of a bolt database from srcPath,
-// parses it into a database, runs a migration on that database, and then
+// migrateDBTestHelper loads a json representation of a database from srcPath
+// into a store of storeType, runs a migration on that database, and then
 // compares it with an expected output database.
-func migrateDBTestHelper(t *testing.T, srcPath, wantPath string, overrideInstanceId bool) error {
+func migrateDBTestHelper(t *testing.T, storeType, srcPath, wantPath string, overrideInstanceId bool) error {
 	srcJSON, err := os.ReadFile(srcPath)
 	if err != nil {
 		t.Fatalf("failed loading source JSON file %v: %v", srcPath, err)
@@ -234,13 +233,11 @@ func migrateDBTestHelper(t *testing.T, srcPath, wantPath string, overrideInstanc
 
 	// Parse source json to db.
 	// When we create a new test store, it sets its version field automatically to latest.
-	_, store := MustNewTestStore(t, true, false)
+	_, store := MustNewTestStoreOfType(t, storeType, true, false)
 
-	fmt.Println("store.path=", store.GetConnection().GetDatabaseFilePath())
 	store.connection.DeleteObject("version", []byte("VERSION"))
 
-	//	defer teardown()
-	err = importJSON(t, bytes.NewReader(srcJSON), store)
+	err = store.ImportJSON(bytes.NewReader(srcJSON))
 	if err != nil {
 		return err
 	}
@@ -266,25 +263,21 @@ func migrateDBTestHelper(t *testing.T, srcPath, wantPath string, overrideInstanc
 		}
 	}
 
-	// Assert that our database connection is using bolt so we can call
-	// exportJson rather than ExportRaw. The exportJson function allows us to
-	// strip out the metadata which we don't want for our tests.
-	// TODO: update connection interface in CE to allow us to use ExportRaw and pass meta false
-	err = store.connection.Close()
-	if err != nil {
-		t.Fatalf("err closing bolt connection: %v", err)
-	}
-	con, ok := store.connection.(*boltdb.DbConnection)
+	// Convert database back to json, without the metadata which we don't want
+	// for our tests. The BoltDB connection exports its database file, it is
+	// closed first.
+	con, ok := store.connection.(jsonExporter)
 	if !ok {
-		t.Fatalf("backing database is not using boltdb, but the migrations test requires it")
+		t.Fatalf("the %s connection cannot export JSON", storeType)
 	}
 
-	// Convert database back to json.
-	databasePath := con.GetDatabaseFilePath()
-	if _, err := os.Stat(databasePath); err != nil {
-		return fmt.Errorf("stat on %s failed: %w", databasePath, err)
+	if _, isBolt := store.connection.(*boltdb.DbConnection); isBolt {
+		if err := store.connection.Close(); err != nil {
+			t.Fatalf("err closing bolt connection: %v", err)
+		}
 	}
 
+	databasePath := store.connection.GetDatabaseFilePath()
 	gotJSON, err := con.ExportJSON(databasePath, false)
 	if err != nil {
 		t.Logf(
@@ -318,197 +311,7 @@ func migrateDBTestHelper(t *testing.T, srcPath, wantPath string, overrideInstanc
 	return nil
 }
 
-// importJSON reads input JSON and commits it to a portainer datastore.Store.
-// Errors are logged with the testing package.
-func importJSON(t *testing.T, r io.Reader, store *Store) error {
-	objects := make(map[string]any)
-
-	// Parse json into map of objects.
-	d := json.NewDecoder(r)
-	d.UseNumber()
-	err := d.Decode(&objects)
-	if err != nil {
-		return err
-	}
-
-	// Get database connection from store.
-	con := store.connection
-
-	for k, v := range objects {
-		switch k {
-		case "version":
-			versions, ok := v.(map[string]any)
-			if !ok {
-				t.Logf("failed casting %s to map[string]any", k)
-			}
-
-			// New format db
-			version, ok := versions["VERSION"]
-			if ok {
-				err := con.CreateObjectWithStringId(
-					k,
-					[]byte("VERSION"),
-					version,
-				)
-				if err != nil {
-					t.Logf("failed writing VERSION in %s: %v", k, err)
-				}
-			}
-
-			// old format db
-
-			dbVersion, ok := versions["DB_VERSION"]
-			if ok {
-				numDBVersion, ok := dbVersion.(json.Number)
-				if !ok {
-					t.Logf("failed parsing DB_VERSION as json number from %s", k)
-				}
-
-				intDBVersion, err := numDBVersion.Int64()
-				if err != nil {
-					t.Logf("failed casting %v to int: %v", numDBVersion, intDBVersion)
-				}
-
-				err = con.CreateObjectWithStringId(
-					k,
-					[]byte("DB_VERSION"),
-					int(intDBVersion),
-				)
-				if err != nil {
-					t.Logf("failed writing DB_VERSION in %s: %v", k, err)
-				}
-			}
-
-			instanceID, ok := versions["INSTANCE_ID"]
-			if ok {
-				err = con.CreateObjectWithStringId(
-					k,
-					[]byte("INSTANCE_ID"),
-					instanceID,
-				)
-				if err != nil {
-					t.Logf("failed writing INSTANCE_ID in %s: %v", k, err)
-				}
-			}
-
-			edition, ok := versions["EDITION"]
-			if ok {
-				err = con.CreateObjectWithStringId(
-					k,
-					[]byte("EDITION"),
-					edition,
-				)
-				if err != nil {
-					t.Logf("failed writing EDITION in %s: %v", k, err)
-				}
-			}
-
-		case "dockerhub":
-			obj, ok := v.([]any)
-			if !ok {
-				t.Logf("failed to cast %s to []any", k)
-			}
-			err := con.CreateObjectWithStringId(
-				k,
-				[]byte("DOCKERHUB"),
-				obj[0],
-			)
-			if err != nil {
-				t.Logf("failed writing DOCKERHUB in %s: %v", k, err)
-			}
-
-		case "ssl":
-			obj, ok := v.(map[string]any)
-			if !ok {
-				t.Logf("failed to case %s to map[string]any", k)
-			}
-			err := con.CreateObjectWithStringId(
-				k,
-				[]byte("SSL"),
-				obj,
-			)
-			if err != nil {
-				t.Logf("failed writing SSL in %s: %v", k, err)
-			}
-
-		case "settings":
-			obj, ok := v.(map[string]any)
-			if !ok {
-				t.Logf("failed to case %s to map[string]any", k)
-			}
-			err := con.CreateObjectWithStringId(
-				k,
-				[]byte("SETTINGS"),
-				obj,
-			)
-			if err != nil {
-				t.Logf("failed writing SETTINGS in %s: %v", k, err)
-			}
-
-		case "tunnel_server":
-			obj, ok := v.(map[string]any)
-			if !ok {
-				t.Logf("failed to case %s to map[string]any", k)
-			}
-			err := con.CreateObjectWithStringId(
-				k,
-				[]byte("INFO"),
-				obj,
-			)
-			if err != nil {
-				t.Logf("failed writing INFO in %s: %v", k, err)
-			}
-		case "templates":
-			continue
-
-		default:
-			objlist, ok := v.([]any)
-			if !ok {
-				t.Logf("failed to cast %s to []any", k)
-			}
-
-			for _, obj := range objlist {
-				value, ok := obj.(map[string]any)
-				if !ok {
-					t.Logf("failed to cast %v to map[string]any", obj)
-				} else {
-					var ok bool
-					var id any
-					switch k {
-					case "endpoint_relations":
-						// TODO: need to make into an int, then do that weird
-						// stringification
-						id, ok = value["EndpointID"]
-					default:
-						id, ok = value["Id"]
-					}
-					if !ok {
-						// endpoint_relations: EndpointID
-						t.Logf("missing Id field: %s", k)
-						id = "error"
-					}
-					n, ok := id.(json.Number)
-					if !ok {
-						t.Logf("failed to cast %v to json.Number in %s", id, k)
-					} else {
-						key, err := n.Int64()
-						if err != nil {
-							t.Logf("failed to cast %v to int in %s", n, k)
-						} else {
-							err := con.CreateObjectWithId(
-								k,
-								int(key),
-								value,
-							)
-							if err != nil {
-								t.Logf("failed writing %v in %s: %v", key, k, err)
-							}
-						}
-					}
-				}
-			}
-		}
-	}
-
-	return nil
+// jsonExporter is implemented by the connections of both backends
+type jsonExporter interface {
+	ExportJSON(databasePath string, metadata bool) ([]byte, error)
 }
@@ -8,6 +8,7 @@ import (
 	"github.com/portainer/portainer/api/database/models"
 	"github.com/portainer/portainer/api/dataservices"
 	"github.com/portainer/portainer/api/dataservices/apikeyrepository"
+	"github.com/portainer/portainer/api/dataservices/auditlog"
 	"github.com/portainer/portainer/api/dataservices/customtemplate"
 	"github.com/portainer/portainer/api/dataservices/dockerhub"
 	"github.com/portainer/portainer/api/dataservices/edgegroup"
@@ -18,6 +19,7 @@ import (
 	"github.com/portainer/portainer/api/dataservices/endpointrelation"
 	"github.com/portainer/portainer/api/dataservices/extension"
 	"github.com/portainer/portainer/api/dataservices/helmuserrepository"
+	"github.com/portainer/portainer/api/dataservices/jwtsigningkey"
 	"github.com/portainer/portainer/api/dataservices/pendingactions"
 	"github.com/portainer/portainer/api/dataservices/registry"
 	"github.com/portainer/portainer/api/dataservices/resourcecontrol"
@@ -32,6 +34,7 @@ import (
 	"github.com/portainer/portainer/api/dataservices/teammembership"
 	"github.com/portainer/portainer/api/dataservices/tunnelserver"
 	"github.com/portainer/portainer/api/dataservices/user"
+	"github.com/portainer/portainer/api/dataservices/usersession"
 	"github.com/portainer/portainer/api/dataservices/version"
 	"github.com/portainer/portainer/api/dataservices/webhook"
 
@@ -45,6 +48,7 @@ type Store struct {
 	connection portainer.Connection
 
 	fileService               portainer.FileService
+	AuditLogService           *auditlog.Service
 	CustomTemplateService     *customtemplate.Service
 	DockerHubService          *dockerhub.Service
 	EdgeGroupService          *edgegroup.Service
@@ -55,6 +59,7 @@ type Store struct {
 	EndpointRelationService   *endpointrelation.Service
 	ExtensionService          *extension.Service
 	HelmUserRepositoryService *helmuserrepository.Service
+	JWTSigningKeyService      *jwtsigningkey.Service
 	RegistryService           *registry.Service
 	ResourceControlService    *resourcecontrol.Service
 	RoleService               *role.Service
@@ -69,6 +74,7 @@ type Store struct {
 	TeamService               *team.Service
 	TunnelServerService       *tunnelserver.Service
 	UserService               *user.Service
+	UserSessionService        *usersession.Service
 	VersionService            *version.Service
 	WebhookService            *webhook.Service
 	PendingActionsService     *pendingactions.Service
@@ -81,6 +87,12 @@ func (store *Store) initServices() error {
 	}
 	store.RoleService = authorizationsetService
 
+	auditLogService, err := auditlog.NewService(store.connection)
+	if err != nil {
+		return err
+	}
+	store.AuditLogService = auditLogService
+
 	customTemplateService, err := customtemplate.NewService(store.connection)
 	if err != nil {
 		return err
@@ -208,6 +220,12 @@ func (store *Store) initServices() error {
 	}
 	store.UserService = userService
 
+	userSessionService, err := usersession.NewService(store.connection)
+	if err != nil {
+		return err
+	}
+	store.UserSessionService = userSessionService
+
 	apiKeyService, err := apikeyrepository.NewService(store.connection)
 	if err != nil {
 		return err
@@ -238,6 +256,12 @@ func (store *Store) initServices() error {
 	}
 	store.PendingActionsService = pendingActionsService
 
+	jwtSigningKeyService, err := jwtsigningkey.NewService(store.connection)
+	if err != nil {
+		return err
+	}
+	store.JWTSigningKeyService = jwtSigningKeyService
+
 	return nil
 }
 
@@ -246,6 +270,16 @@ func (store *Store) PendingActions() dataservices.PendingActionsService {
 	return store.PendingActionsService
 }
 
+// JWTSigningKey gives access to the JWTSigningKey data management layer
+func (store *Store) JWTSigningKey() dataservices.JWTSigningKeyService {
+	return store.JWTSigningKeyService
+}
+
+// AuditLog gives access to the AuditLog data management layer
+func (store *Store) AuditLog() dataservices.AuditLogService {
+	return store.AuditLogService
+}
+
 // CustomTemplate gives access to the CustomTemplate data management layer
 func (store *Store) CustomTemplate() dataservices.CustomTemplateService {
 	return store.CustomTemplateService
@@ -350,6 +384,11 @@ func (store *Store) User() dataservices.UserService {
 	return store.UserService
 }
 
+// UserSession gives access to the UserSession data management layer
+func (store *Store) UserSession() dataservices.UserSessionService {
+	return store.UserSessionService
+}
+
 // Version gives access to the Version data management layer
 func (store *Store) Version() dataservices.VersionService {
 	return store.VersionService
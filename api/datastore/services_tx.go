@@ -14,6 +14,10 @@ func (tx *StoreTx) IsErrObjectNotFound(err error) bool {
 	return tx.store.IsErrObjectNotFound(err)
 }
 
+func (tx *StoreTx) AuditLog() dataservices.AuditLogService {
+	return tx.store.AuditLogService.Tx(tx.tx)
+}
+
 func (tx *StoreTx) CustomTemplate() dataservices.CustomTemplateService { return nil }
 
 func (tx *StoreTx) PendingActions() dataservices.PendingActionsService {
@@ -58,6 +62,10 @@ func (tx *StoreTx) Role() dataservices.RoleService {
 	return tx.store.RoleService.Tx(tx.tx)
 }
 
+func (tx *StoreTx) JWTSigningKey() dataservices.JWTSigningKeyService {
+	return tx.store.JWTSigningKeyService.Tx(tx.tx)
+}
+
 func (tx *StoreTx) APIKeyRepository() dataservices.APIKeyRepository { return nil }
 
 func (tx *StoreTx) Settings() dataservices.SettingsService {
@@ -92,5 +100,9 @@ func (tx *StoreTx) User() dataservices.UserService {
 	return tx.store.UserService.Tx(tx.tx)
 }
 
+func (tx *StoreTx) UserSession() dataservices.UserSessionService {
+	return tx.store.UserSessionService.Tx(tx.tx)
+}
+
 func (tx *StoreTx) Version() dataservices.VersionService { return nil }
 func (tx *StoreTx) Webhook() dataservices.WebhookService { return nil }
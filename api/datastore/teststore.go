@@ -1,16 +1,39 @@
 package datastore
 
 import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
 	"testing"
 
 	portainer "github.com/portainer/portainer/api"
 	"github.com/portainer/portainer/api/database"
 	"github.com/portainer/portainer/api/database/models"
+	"github.com/portainer/portainer/api/database/postgres"
 	"github.com/portainer/portainer/api/filesystem"
 
+	"github.com/lib/pq"
 	"github.com/rs/zerolog/log"
 )
 
+// TestPostgresDSNEnv is the environment variable holding the connection string
+// of a PostgreSQL server. The tests that run against every backend also run
+// against PostgreSQL when it is set, each in a schema of its own.
+const TestPostgresDSNEnv = "PORTAINER_TEST_POSTGRES_DSN"
+
+// StoreTypesUnderTest returns the backends the store tests can run against
+func StoreTypesUnderTest() []string {
+	if os.Getenv(TestPostgresDSNEnv) == "" {
+		return []string{database.StoreTypeBoltDB}
+	}
+
+	return []string{database.StoreTypeBoltDB, database.StoreTypePostgres}
+}
+
 func (store *Store) GetConnection() portainer.Connection {
 	return store.connection
 }
@@ -27,6 +50,23 @@ func MustNewTestStore(t testing.TB, init, secure bool) (bool, *Store) {
 }
 
 func NewTestStore(t testing.TB, init, secure bool) (bool, *Store, func(), error) {
+	return NewTestStoreOfType(t, database.StoreTypeBoltDB, init, secure)
+}
+
+// MustNewTestStoreOfType is MustNewTestStore with the backend of storeType
+func MustNewTestStoreOfType(t testing.TB, storeType string, init, secure bool) (bool, *Store) {
+	newStore, store, teardown, err := NewTestStoreOfType(t, storeType, init, secure)
+	if err != nil {
+		t.Fatalf("failed to create the %s test store: %v", storeType, err)
+	}
+
+	t.Cleanup(teardown)
+
+	return newStore, store
+}
+
+// NewTestStoreOfType is NewTestStore with the backend of storeType
+func NewTestStoreOfType(t testing.TB, storeType string, init, secure bool) (bool, *Store, func(), error) {
 	// Creates unique temp directory in a concurrency friendly manner.
 	storePath := t.TempDir()
 
@@ -40,9 +80,19 @@ func NewTestStore(t testing.TB, init, secure bool) (bool, *Store, func(), error)
 		secretKey = nil
 	}
 
-	connection, err := database.NewDatabase("boltdb", storePath, secretKey)
+	config := database.Config{Type: storeType, StorePath: storePath, EncryptionKey: secretKey}
+	if storeType == database.StoreTypePostgres {
+		dsn, err := newTestSchema(t)
+		if err != nil {
+			return false, nil, nil, err
+		}
+
+		config.Postgres = postgres.Config{DSN: dsn}
+	}
+
+	connection, err := database.NewDatabaseFromConfig(config)
 	if err != nil {
-		panic(err)
+		return false, nil, nil, err
 	}
 
 	store := NewStore(storePath, fileService, connection)
@@ -76,3 +126,54 @@ func NewTestStore(t testing.TB, init, secure bool) (bool, *Store, func(), error)
 
 	return newStore, store, teardown, nil
 }
+
+// newTestSchema creates a schema of its own for a test on the PostgreSQL
+// server of TestPostgresDSNEnv and returns the connection string using it. The
+// schema is dropped at the end of the test.
+func newTestSchema(t testing.TB) (string, error) {
+	dsn := os.Getenv(TestPostgresDSNEnv)
+	if dsn == "" {
+		return "", fmt.Errorf("%s is not set", TestPostgresDSNEnv)
+	}
+
+	suffix := make([]byte, 8)
+	if _, err := rand.Read(suffix); err != nil {
+		return "", err
+	}
+
+	schema := "portainer_test_" + hex.EncodeToString(suffix)
+
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		return "", err
+	}
+
+	if _, err := db.Exec("CREATE SCHEMA " + pq.QuoteIdentifier(schema)); err != nil {
+		db.Close()
+		return "", err
+	}
+
+	t.Cleanup(func() {
+		defer db.Close()
+
+		if _, err := db.Exec("DROP SCHEMA " + pq.QuoteIdentifier(schema) + " CASCADE"); err != nil {
+			t.Errorf("failed to drop the test schema %s: %v", schema, err)
+		}
+	})
+
+	// lib/pq sends the unknown parameters of a connection string to the server
+	if strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://") {
+		u, err := url.Parse(dsn)
+		if err != nil {
+			return "", err
+		}
+
+		query := u.Query()
+		query.Set("search_path", schema)
+		u.RawQuery = query.Encode()
+
+		return u.String(), nil
+	}
+
+	return dsn + " search_path=" + schema, nil
+}
@@ -0,0 +1,33 @@
+package datastore
+
+import (
+	"testing"
+
+	portainer "github.com/portainer/portainer/api"
+	"github.com/portainer/portainer/api/dataservices"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUpdateTxPanic(t *testing.T) {
+	for _, storeType := range StoreTypesUnderTest() {
+		t.Run(storeType, func(t *testing.T) {
+			is := require.New(t)
+
+			_, store := MustNewTestStoreOfType(t, storeType, false, false)
+
+			is.PanicsWithValue("update failed", func() {
+				_ = store.UpdateTx(func(tx dataservices.DataStoreTx) error {
+					if err := tx.Tag().Create(&portainer.Tag{Name: "tag"}); err != nil {
+						return err
+					}
+
+					panic("update failed")
+				})
+			}, "the panic of a transaction is not swallowed")
+
+			tags, err := store.Tag().ReadAll()
+			is.NoError(err)
+			is.Empty(tags, "the transaction is rolled back")
+		})
+	}
+}
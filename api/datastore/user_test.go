@@ -0,0 +1,33 @@
+package datastore
+
+import (
+	"bytes"
+	"testing"
+
+	portainer "github.com/portainer/portainer/api"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTwoFactorSecretIsEncryptedWithTheStore(t *testing.T) {
+	const secret = "JBSWY3DPEHPK3PXPJBSWY3DPEHPK3PXP"
+
+	for _, storeType := range StoreTypesUnderTest() {
+		t.Run(storeType, func(t *testing.T) {
+			is := require.New(t)
+
+			_, store := MustNewTestStoreOfType(t, storeType, true, true)
+
+			user := &portainer.User{Username: "admin", Role: portainer.AdministratorRole, TwoFactor: portainer.UserTwoFactor{Enabled: true, Secret: secret}}
+			is.NoError(store.User().Create(user))
+
+			var backup bytes.Buffer
+			is.NoError(store.GetConnection().BackupTo(&backup))
+			is.NotContains(backup.String(), secret)
+
+			stored, err := store.User().Read(user.ID)
+			is.NoError(err)
+			is.Equal(secret, stored.TwoFactor.Secret)
+		})
+	}
+}
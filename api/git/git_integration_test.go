@@ -246,7 +246,7 @@ func TestService_purgeCache_Github(t *testing.T) {
 	assert.Equal(t, 1, service.repoRefCache.Len())
 	assert.Equal(t, 1, service.repoFileCache.Len())
 
-	service.purgeCache()
+	service.PurgeCache()
 	assert.Equal(t, 0, service.repoRefCache.Len())
 	assert.Equal(t, 0, service.repoFileCache.Len())
 }
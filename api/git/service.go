@@ -101,7 +101,7 @@ func (service *Service) startCacheCleanTimer(d time.Duration) {
 	for {
 		select {
 		case <-ticker.C:
-			service.purgeCache()
+			service.PurgeCache()
 
 		case <-service.shutdownCtx.Done():
 			ticker.Stop()
@@ -265,7 +265,8 @@ func (service *Service) listFiles(repositoryURL, referenceName, username, passwo
 	return files, nil
 }
 
-func (service *Service) purgeCache() {
+// PurgeCache removes the cached references and file lists of every repository
+func (service *Service) PurgeCache() {
 	if service.repoRefCache != nil {
 		service.repoRefCache.Purge()
 	}
@@ -0,0 +1,207 @@
+// Package audit records the API requests that change the state of Portainer or
+// of its environments, including the requests proxied to the Docker and
+// Kubernetes APIs of the environments.
+package audit
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	portainer "github.com/portainer/portainer/api"
+	"github.com/portainer/portainer/api/dataservices"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Logger appends the audited requests to the audit log of the store
+type Logger struct {
+	dataStore dataservices.DataStore
+}
+
+// NewLogger creates a Logger
+func NewLogger(dataStore dataservices.DataStore) *Logger {
+	return &Logger{dataStore: dataStore}
+}
+
+type actorKey struct{}
+
+// actor is the identity a request was authenticated with, it is filled by
+// the bouncer once the request reaches the handler
+type actor struct {
+	mu       sync.Mutex
+	userID   portainer.UserID
+	username string
+	apiKeyID portainer.APIKeyID
+}
+
+// SetUser records the user a request was authenticated as
+func SetUser(ctx context.Context, userID portainer.UserID, username string) {
+	if a, ok := ctx.Value(actorKey{}).(*actor); ok {
+		a.mu.Lock()
+		a.userID, a.username = userID, username
+		a.mu.Unlock()
+	}
+}
+
+// SetAPIKey records the API key a request was authenticated with
+func SetAPIKey(ctx context.Context, apiKeyID portainer.APIKeyID) {
+	if a, ok := ctx.Value(actorKey{}).(*actor); ok {
+		a.mu.Lock()
+		a.apiKeyID = apiKeyID
+		a.mu.Unlock()
+	}
+}
+
+// WithAudit records the requests that are not read-only once they are served
+func (logger *Logger) WithAudit(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !audited(r) {
+			next.ServeHTTP(w, r)
+
+			return
+		}
+
+		record := &portainer.AuditLog{
+			Timestamp: time.Now().Unix(),
+			Method:    r.Method,
+			Path:      r.URL.Path,
+			ClientIP:  clientIP(r.RemoteAddr),
+			Request:   summarize(r),
+		}
+		describe(record, r)
+
+		a := &actor{}
+		recorder := &statusRecorder{ResponseWriter: w}
+
+		next.ServeHTTP(recorder, r.WithContext(context.WithValue(r.Context(), actorKey{}, a)))
+
+		a.mu.Lock()
+		record.UserID, record.Username, record.APIKeyID = a.userID, a.username, a.apiKeyID
+		a.mu.Unlock()
+
+		record.StatusCode = recorder.code()
+		record.Outcome = portainer.AuditSuccess
+		if record.StatusCode >= http.StatusBadRequest {
+			record.Outcome = portainer.AuditFailure
+		}
+
+		if err := logger.dataStore.AuditLog().Create(record); err != nil {
+			log.Error().Err(err).Str("method", record.Method).Str("path", record.Path).Msg("unable to record the request in the audit log")
+		}
+	})
+}
+
+// Purge removes the records older than the retention, they are kept forever
+// when the retention is 0
+func (logger *Logger) Purge(retention time.Duration) error {
+	if retention <= 0 {
+		return nil
+	}
+
+	count, err := logger.dataStore.AuditLog().DeleteBefore(time.Now().Add(-retention).Unix())
+	if err != nil {
+		return err
+	}
+
+	if count > 0 {
+		log.Debug().Int("count", count).Msg("removed the audit log records past the retention")
+	}
+
+	return nil
+}
+
+// audited returns whether a request is recorded. The read-only requests are
+// not, neither are the requests of the Edge agents polling their environment.
+func audited(r *http.Request) bool {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return false
+	}
+
+	if !strings.HasPrefix(r.URL.Path, "/api/") {
+		return false
+	}
+
+	return !(strings.HasPrefix(r.URL.Path, "/api/endpoints/") && strings.Contains(r.URL.Path, "/edge/"))
+}
+
+func clientIP(remoteAddr string) string {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		return remoteAddr
+	}
+
+	return host
+}
+
+// statusRecorder records the status code of a response. The proxied Docker
+// requests hijack the connection to attach to the containers.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (rec *statusRecorder) WriteHeader(code int) {
+	if rec.status == 0 {
+		rec.status = code
+	}
+
+	rec.ResponseWriter.WriteHeader(code)
+}
+
+func (rec *statusRecorder) Write(b []byte) (int, error) {
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+
+	return rec.ResponseWriter.Write(b)
+}
+
+func (rec *statusRecorder) Flush() {
+	if flusher, ok := rec.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (rec *statusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := rec.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("the response writer does not support hijacking")
+	}
+
+	if rec.status == 0 {
+		rec.status = http.StatusSwitchingProtocols
+	}
+
+	return hijacker.Hijack()
+}
+
+func (rec *statusRecorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
+
+func (rec *statusRecorder) code() int {
+	if rec.status == 0 {
+		return http.StatusOK
+	}
+
+	return rec.status
+}
+
+// endpointIDParam returns the environment named by the endpointId query
+// parameter of the requests creating a resource in an environment
+func endpointIDParam(r *http.Request) portainer.EndpointID {
+	id, err := strconv.Atoi(r.URL.Query().Get("endpointId"))
+	if err != nil {
+		return 0
+	}
+
+	return portainer.EndpointID(id)
+}
@@ -0,0 +1,124 @@
+package audit
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	portainer "github.com/portainer/portainer/api"
+	"github.com/portainer/portainer/api/database/query"
+	"github.com/portainer/portainer/api/datastore"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithAudit(t *testing.T) {
+	is := require.New(t)
+
+	_, store := datastore.MustNewTestStore(t, true, false)
+
+	var body string
+	handler := NewLogger(store).WithAudit(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		SetUser(r.Context(), 2, "bob")
+		SetAPIKey(r.Context(), 3)
+
+		data, _ := io.ReadAll(r.Body)
+		body = string(data)
+
+		w.WriteHeader(http.StatusConflict)
+	}))
+
+	payload := `{"Name":"web","Password":"s3cret","Env":[{"name":"DB_PASSWORD","value":"s3cret"}]}`
+	r := httptest.NewRequest(http.MethodPut, "/api/stacks/4?endpointId=1", strings.NewReader(payload))
+	r.Header.Set("Content-Type", "application/json")
+	handler.ServeHTTP(httptest.NewRecorder(), r)
+
+	is.Equal(payload, body, "the handler reads the whole body")
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/stacks/4", nil))
+
+	records, _, err := store.AuditLog().AuditLogsPage(query.Page{})
+	is.NoError(err)
+	is.Len(records, 1, "the read-only requests are not recorded")
+
+	record := records[0]
+	is.Equal(portainer.UserID(2), record.UserID)
+	is.Equal("bob", record.Username)
+	is.Equal(portainer.APIKeyID(3), record.APIKeyID)
+	is.Equal(portainer.EndpointID(1), record.EndpointID)
+	is.Equal("stacks", record.ResourceType)
+	is.Equal("4", record.ResourceID)
+	is.Equal("update", record.Action)
+	is.Equal(http.StatusConflict, record.StatusCode)
+	is.Equal(portainer.AuditFailure, record.Outcome)
+	is.Equal(`{"Env":"[REDACTED]","Name":"web","Password":"[REDACTED]"}`, record.Request)
+
+	old := &portainer.AuditLog{Timestamp: time.Now().Add(-2 * time.Hour).Unix(), Method: http.MethodDelete}
+	is.NoError(store.AuditLog().Create(old))
+
+	logger := NewLogger(store)
+
+	is.NoError(logger.Purge(0))
+	records, _, err = store.AuditLog().AuditLogsPage(query.Page{})
+	is.NoError(err)
+	is.Len(records, 2, "the records are kept forever without retention")
+
+	is.NoError(logger.Purge(time.Hour))
+	records, _, err = store.AuditLog().AuditLogsPage(query.Page{})
+	is.NoError(err)
+	is.Len(records, 1, "the records past the retention are removed")
+	is.Equal(record.ID, records[0].ID)
+}
+
+func TestDescribe(t *testing.T) {
+	for _, tc := range []struct {
+		method, path string
+		expected     portainer.AuditLog
+	}{
+		{
+			method:   http.MethodPost,
+			path:     "/api/endpoints/1/docker/v1.41/containers/abc/start",
+			expected: portainer.AuditLog{EndpointID: 1, ResourceType: "containers", ResourceID: "abc", Action: "start"},
+		},
+		{
+			method:   http.MethodPost,
+			path:     "/api/endpoints/1/docker/containers/create",
+			expected: portainer.AuditLog{EndpointID: 1, ResourceType: "containers", Action: "create"},
+		},
+		{
+			method:   http.MethodDelete,
+			path:     "/api/endpoints/2/docker/images/library/nginx:latest",
+			expected: portainer.AuditLog{EndpointID: 2, ResourceType: "images", ResourceID: "library/nginx:latest", Action: "delete"},
+		},
+		{
+			method:   http.MethodPatch,
+			path:     "/api/endpoints/3/kubernetes/apis/apps/v1/namespaces/default/deployments/web/scale",
+			expected: portainer.AuditLog{EndpointID: 3, ResourceType: "deployments", ResourceID: "default/web", Action: "scale"},
+		},
+		{
+			method:   http.MethodPost,
+			path:     "/api/endpoints/3/kubernetes/api/v1/namespaces/default/pods",
+			expected: portainer.AuditLog{EndpointID: 3, ResourceType: "pods", ResourceID: "default", Action: "create"},
+		},
+		{
+			method:   http.MethodPut,
+			path:     "/api/users/2/passwd",
+			expected: portainer.AuditLog{ResourceType: "users", ResourceID: "2", Action: "passwd"},
+		},
+		{
+			method:   http.MethodDelete,
+			path:     "/api/endpoints/5",
+			expected: portainer.AuditLog{EndpointID: 5, ResourceType: "endpoints", ResourceID: "5", Action: "delete"},
+		},
+	} {
+		t.Run(tc.method+" "+tc.path, func(t *testing.T) {
+			var record portainer.AuditLog
+			describe(&record, httptest.NewRequest(tc.method, tc.path, nil))
+
+			require.Equal(t, tc.expected, record)
+		})
+	}
+}
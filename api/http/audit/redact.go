@@ -0,0 +1,114 @@
+package audit
+
+import (
+	"bytes"
+	"cmp"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/segmentio/encoding/json"
+)
+
+const (
+	// maxBodySize bounds the request bodies read to be summarized
+	maxBodySize = 64 * 1024
+	// maxSummarySize bounds the summary of a request
+	maxSummarySize = 2048
+	// maxValueSize bounds the strings of a summary, such as the content of the stack files
+	maxValueSize = 256
+
+	redacted = "[REDACTED]"
+)
+
+// sensitiveFields are the parts of the field names whose values are redacted
+var sensitiveFields = []string{"password", "secret", "token", "key", "credential", "passphrase", "cert", "env", "code"}
+
+// summarize returns a summary of the body of a request with its secrets
+// redacted. The JSON bodies are summarized, the others are only described.
+func summarize(r *http.Request) string {
+	if r.Body == nil || r.Body == http.NoBody || r.ContentLength == 0 {
+		return ""
+	}
+
+	contentType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if contentType != "application/json" {
+		if r.ContentLength > 0 {
+			return fmt.Sprintf("%s body of %d bytes", cmp.Or(contentType, "unknown"), r.ContentLength)
+		}
+
+		return fmt.Sprintf("%s body", cmp.Or(contentType, "unknown"))
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxBodySize+1))
+
+	// The handler reads the body after the summary
+	r.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(body), r.Body), r.Body}
+
+	if err != nil {
+		return "unreadable JSON body"
+	}
+
+	if len(body) > maxBodySize {
+		return "JSON body too large to be summarized"
+	}
+
+	var value any
+	if err := json.Unmarshal(body, &value); err != nil {
+		return "invalid JSON body"
+	}
+
+	summary, err := json.Marshal(redact(value))
+	if err != nil {
+		return "invalid JSON body"
+	}
+
+	if len(summary) > maxSummarySize {
+		return string(summary[:maxSummarySize]) + "..."
+	}
+
+	return string(summary)
+}
+
+// redact replaces the values of the sensitive fields and shortens the long strings
+func redact(value any) any {
+	switch v := value.(type) {
+	case map[string]any:
+		for field, fieldValue := range v {
+			if sensitive(field) {
+				v[field] = redacted
+
+				continue
+			}
+
+			v[field] = redact(fieldValue)
+		}
+	case []any:
+		for i := range v {
+			v[i] = redact(v[i])
+		}
+	case string:
+		if len(v) > maxValueSize {
+			return v[:maxValueSize] + "..."
+		}
+	}
+
+	return value
+}
+
+func sensitive(field string) bool {
+	field = strings.ToLower(field)
+
+	for _, part := range sensitiveFields {
+		if strings.Contains(field, part) {
+			return true
+		}
+	}
+
+	return false
+}
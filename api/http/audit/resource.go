@@ -0,0 +1,143 @@
+package audit
+
+import (
+	"net/http"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	portainer "github.com/portainer/portainer/api"
+)
+
+// dockerVersion matches the version prefix of the Docker API paths
+var dockerVersion = regexp.MustCompile(`^v\d+\.\d+$`)
+
+// collectionActions are the path segments following a resource type that
+// name an operation on the collection rather than a resource
+var collectionActions = []string{"create", "prune", "load", "import"}
+
+// describe fills the environment, the resource and the action of a record
+// from the path of its request
+func describe(record *portainer.AuditLog, r *http.Request) {
+	segments := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/"), "/"), "/")
+
+	record.EndpointID = endpointIDParam(r)
+
+	var namespace string
+
+	// /endpoints/{id}/docker/..., /endpoints/{id}/kubernetes/... and the
+	// /docker/{id}/... and /kubernetes/{id}/... APIs of Portainer
+	switch {
+	case len(segments) > 3 && segments[0] == "endpoints" && isID(segments[1]) && (segments[2] == "docker" || segments[2] == "kubernetes"):
+		record.EndpointID = endpointID(segments[1])
+
+		if segments[2] == "docker" {
+			segments = dockerPath(segments[3:])
+		} else {
+			segments, namespace = kubernetesPath(segments[3:])
+		}
+	case len(segments) > 2 && segments[0] == "docker" && isID(segments[1]):
+		record.EndpointID = endpointID(segments[1])
+		segments = segments[2:]
+	case len(segments) > 2 && segments[0] == "kubernetes" && isID(segments[1]):
+		record.EndpointID = endpointID(segments[1])
+		segments, namespace = kubernetesPath(segments[2:])
+	case len(segments) > 1 && segments[0] == "endpoints" && isID(segments[1]):
+		record.EndpointID = endpointID(segments[1])
+	}
+
+	describeResource(record, r.Method, segments)
+
+	// The namespaced resources are identified by their namespace and name
+	if namespace != "" {
+		record.ResourceID = strings.TrimSuffix(namespace+"/"+record.ResourceID, "/")
+	}
+}
+
+// describeResource reads the path of a resource: its type, its identifier and
+// the operation applied to it
+func describeResource(record *portainer.AuditLog, method string, segments []string) {
+	if len(segments) == 0 || segments[0] == "" {
+		record.Action = methodAction(method)
+
+		return
+	}
+
+	record.ResourceType = segments[0]
+	segments = segments[1:]
+
+	switch {
+	case len(segments) == 0:
+		record.Action = methodAction(method)
+	case slices.Contains(collectionActions, segments[0]):
+		record.Action = strings.Join(segments, "/")
+	case record.ResourceType == "images":
+		// The names of the images hold slashes, the operation is the last segment
+		record.ResourceID = strings.Join(segments, "/")
+		record.Action = methodAction(method)
+
+		if last := segments[len(segments)-1]; len(segments) > 1 && slices.Contains([]string{"tag", "push"}, last) {
+			record.ResourceID = strings.Join(segments[:len(segments)-1], "/")
+			record.Action = last
+		}
+	default:
+		record.ResourceID = segments[0]
+		record.Action = methodAction(method)
+
+		if len(segments) > 1 {
+			record.Action = strings.Join(segments[1:], "/")
+		}
+	}
+}
+
+// dockerPath removes the version prefix of a Docker API path
+func dockerPath(segments []string) []string {
+	if len(segments) > 0 && dockerVersion.MatchString(segments[0]) {
+		return segments[1:]
+	}
+
+	return segments
+}
+
+// kubernetesPath removes the group and version prefix of a Kubernetes API
+// path and returns the namespace of the namespaced resources apart
+func kubernetesPath(segments []string) ([]string, string) {
+	switch {
+	case len(segments) > 1 && segments[0] == "api":
+		segments = segments[2:]
+	case len(segments) > 2 && segments[0] == "apis":
+		segments = segments[3:]
+	}
+
+	if len(segments) > 2 && segments[0] == "namespaces" {
+		return segments[2:], segments[1]
+	}
+
+	return segments, ""
+}
+
+func methodAction(method string) string {
+	switch method {
+	case http.MethodPost:
+		return "create"
+	case http.MethodPut, http.MethodPatch:
+		return "update"
+	case http.MethodDelete:
+		return "delete"
+	}
+
+	return strings.ToLower(method)
+}
+
+func isID(segment string) bool {
+	_, err := strconv.Atoi(segment)
+
+	return err == nil
+}
+
+func endpointID(segment string) portainer.EndpointID {
+	id, _ := strconv.Atoi(segment)
+
+	return portainer.EndpointID(id)
+}
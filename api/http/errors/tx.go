@@ -3,11 +3,16 @@ package errors
 import (
 	"errors"
 
+	"github.com/portainer/portainer/api/dataservices"
 	httperror "github.com/portainer/portainer/pkg/libhttp/error"
 )
 
 func TxResponse(err error, validResponse func() *httperror.HandlerError) *httperror.HandlerError {
 	if err != nil {
+		if dataservices.IsErrConflict(err) {
+			return httperror.Conflict("The resource was modified by another request, try again", err)
+		}
+
 		var handlerError *httperror.HandlerError
 		if errors.As(err, &handlerError) {
 			return handlerError
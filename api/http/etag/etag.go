@@ -0,0 +1,60 @@
+package etag
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	httperror "github.com/portainer/portainer/pkg/libhttp/error"
+)
+
+var ErrPreconditionFailed = errors.New("the resource was modified since it was read")
+
+// Of returns the entity tag of an object as it is stored, an empty string
+// when it cannot be encoded
+func Of(object any) string {
+	data, err := json.Marshal(object)
+	if err != nil {
+		return ""
+	}
+
+	sum := sha256.Sum256(data)
+
+	return `"` + base64.RawURLEncoding.EncodeToString(sum[:]) + `"`
+}
+
+// Set sets the ETag header of the response to the entity tag of object. It
+// must be called with the object as it is stored, before it is altered for
+// the response.
+func Set(w http.ResponseWriter, object any) {
+	if tag := Of(object); tag != "" {
+		w.Header().Set("ETag", tag)
+	}
+}
+
+// CheckIfMatch returns a 412 error when the If-Match header of the request
+// does not match the entity tag of object. Requests without the header are
+// not checked.
+func CheckIfMatch(r *http.Request, object any) *httperror.HandlerError {
+	ifMatch := r.Header.Get("If-Match")
+	if ifMatch == "" || matches(ifMatch, Of(object)) {
+		return nil
+	}
+
+	return httperror.NewError(http.StatusPreconditionFailed, "The resource was modified by another request, reload it and try again", ErrPreconditionFailed)
+}
+
+// matches uses the strong comparison of RFC 9110, weak tags never match
+func matches(ifMatch, tag string) bool {
+	for _, candidate := range strings.Split(ifMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || (tag != "" && candidate == tag) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,47 @@
+package etag
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	portainer "github.com/portainer/portainer/api"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckIfMatch(t *testing.T) {
+	is := require.New(t)
+
+	group := &portainer.EdgeGroup{ID: 1, Name: "group"}
+	tag := Of(group)
+	is.NotEmpty(tag)
+
+	rec := httptest.NewRecorder()
+	Set(rec, group)
+	is.Equal(tag, rec.Header().Get("ETag"))
+
+	newRequest := func(ifMatch string) *http.Request {
+		r := httptest.NewRequest(http.MethodPut, "/edge_groups/1", nil)
+		if ifMatch != "" {
+			r.Header.Set("If-Match", ifMatch)
+		}
+
+		return r
+	}
+
+	is.Nil(CheckIfMatch(newRequest(""), group))
+	is.Nil(CheckIfMatch(newRequest(tag), group))
+	is.Nil(CheckIfMatch(newRequest(`"other", `+tag), group))
+	is.Nil(CheckIfMatch(newRequest("*"), group))
+
+	err := CheckIfMatch(newRequest("W/"+tag), group)
+	is.NotNil(err)
+	is.Equal(http.StatusPreconditionFailed, err.StatusCode)
+
+	// Another request renamed the group
+	group.Name = "renamed"
+	err = CheckIfMatch(newRequest(tag), group)
+	is.NotNil(err)
+	is.ErrorIs(err.Err, ErrPreconditionFailed)
+}
@@ -0,0 +1,92 @@
+package auditlogs
+
+import (
+	"net/http"
+
+	portainer "github.com/portainer/portainer/api"
+	"github.com/portainer/portainer/api/database/query"
+	"github.com/portainer/portainer/api/dataservices"
+	httperror "github.com/portainer/portainer/pkg/libhttp/error"
+
+	"github.com/rs/zerolog/log"
+	"github.com/segmentio/encoding/json"
+)
+
+// exportPageSize is the number of records read at once by an export
+const exportPageSize = 500
+
+// @id AuditLogExport
+// @summary Export the audit log
+// @description Export the records of the audit log as JSON lines, one record per line, the oldest first.
+// @description It accepts the filters of the audit log list.
+// @description **Access policy**: administrator
+// @tags audit
+// @security ApiKeyAuth
+// @security jwt
+// @produce application/x-ndjson
+// @param userId query int false "Only export the requests of this user"
+// @param username query string false "Only export the requests of the user with this name"
+// @param apiKeyId query int false "Only export the requests authenticated with this API key"
+// @param endpointId query int false "Only export the requests applying to this environment"
+// @param resourceType query string false "Only export the requests applying to this type of resource"
+// @param resourceId query string false "Only export the requests applying to this resource"
+// @param action query string false "Only export the requests with this action"
+// @param method query string false "Only export the requests with this HTTP method"
+// @param outcome query string false "Only export the requests with this outcome" Enum("success", "failure")
+// @param search query string false "Only export the requests whose path includes this value"
+// @param since query int false "Only export the requests made at or after this Unix timestamp"
+// @param until query int false "Only export the requests made at or before this Unix timestamp"
+// @success 200 {file} file "JSON lines"
+// @failure 400 "Invalid request"
+// @failure 500 "Server error"
+// @router /audit/export [get]
+func (handler *Handler) auditLogExport(w http.ResponseWriter, r *http.Request) *httperror.HandlerError {
+	filter, err := auditLogFilter(r)
+	if err != nil {
+		return httperror.BadRequest("Invalid query parameters", err)
+	}
+
+	page := query.Page{Filter: filter, Limit: exportPageSize}
+
+	var records []portainer.AuditLog
+	var pageInfo query.PageInfo
+	readPage := func() error {
+		return handler.DataStore.ViewTxCtx(r.Context(), func(tx dataservices.DataStoreTx) error {
+			records, pageInfo, err = tx.AuditLog().AuditLogsPage(page)
+
+			return err
+		})
+	}
+
+	if err := readPage(); err != nil {
+		return httperror.InternalServerError("Unable to retrieve the audit log from the database", err)
+	}
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.Header().Set("Content-Disposition", `attachment; filename="audit.jsonl"`)
+
+	encoder := json.NewEncoder(w)
+
+	for {
+		for _, record := range records {
+			if err := encoder.Encode(record); err != nil {
+				// The client is gone, the response cannot be changed anymore
+				log.Debug().Err(err).Msg("unable to write the audit log export")
+
+				return nil
+			}
+		}
+
+		if pageInfo.Next == "" {
+			return nil
+		}
+
+		page.Cursor = pageInfo.Next
+
+		if err := readPage(); err != nil {
+			log.Error().Err(err).Msg("unable to retrieve the audit log from the database, the export is truncated")
+
+			return nil
+		}
+	}
+}
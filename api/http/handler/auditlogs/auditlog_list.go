@@ -0,0 +1,84 @@
+package auditlogs
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	portainer "github.com/portainer/portainer/api"
+	"github.com/portainer/portainer/api/database/query"
+	"github.com/portainer/portainer/api/dataservices"
+	httperror "github.com/portainer/portainer/pkg/libhttp/error"
+	"github.com/portainer/portainer/pkg/libhttp/request"
+	"github.com/portainer/portainer/pkg/libhttp/response"
+)
+
+// @id AuditLogList
+// @summary List the audit log
+// @description List the records of the audit log, the most recent first. A record is appended for every request changing the state of Portainer or of an environment.
+// @description **Access policy**: administrator
+// @tags audit
+// @security ApiKeyAuth
+// @security jwt
+// @produce json
+// @param start query int false "Start searching from"
+// @param limit query int false "Limit results to this value"
+// @param cursor query string false "Start after the record the cursor was returned for, it replaces start. The cursor of the next page is returned in the X-Next-Cursor header"
+// @param order query string false "Order the records by date" Enum("asc", "desc")
+// @param userId query int false "Only return the requests of this user"
+// @param username query string false "Only return the requests of the user with this name"
+// @param apiKeyId query int false "Only return the requests authenticated with this API key"
+// @param endpointId query int false "Only return the requests applying to this environment"
+// @param resourceType query string false "Only return the requests applying to this type of resource, such as stacks or containers"
+// @param resourceId query string false "Only return the requests applying to this resource"
+// @param action query string false "Only return the requests with this action, such as create, update, delete or start"
+// @param method query string false "Only return the requests with this HTTP method"
+// @param outcome query string false "Only return the requests with this outcome" Enum("success", "failure")
+// @param search query string false "Only return the requests whose path includes this value"
+// @param since query int false "Only return the requests made at or after this Unix timestamp"
+// @param until query int false "Only return the requests made at or before this Unix timestamp"
+// @success 200 {array} portainer.AuditLog
+// @header 200 {string} X-Total-Count "The number of records matching the filters"
+// @header 200 {string} X-Next-Cursor "The cursor of the next page"
+// @failure 400 "Invalid request"
+// @failure 500 "Server error"
+// @router /audit [get]
+func (handler *Handler) auditLogList(w http.ResponseWriter, r *http.Request) *httperror.HandlerError {
+	start, _ := request.RetrieveNumericQueryParameter(r, "start", true)
+	if start != 0 {
+		start--
+	}
+
+	limit, _ := request.RetrieveNumericQueryParameter(r, "limit", true)
+	cursor, _ := request.RetrieveQueryParameter(r, "cursor", true)
+	order, _ := request.RetrieveQueryParameter(r, "order", true)
+
+	filter, err := auditLogFilter(r)
+	if err != nil {
+		return httperror.BadRequest("Invalid query parameters", err)
+	}
+
+	// The records are created in key order
+	page := query.Page{Filter: filter, Sort: query.Sort{Desc: order != "asc"}, Offset: start, Limit: limit, Cursor: cursor}
+
+	var records []portainer.AuditLog
+	var pageInfo query.PageInfo
+	err = handler.DataStore.ViewTxCtx(r.Context(), func(tx dataservices.DataStoreTx) error {
+		records, pageInfo, err = tx.AuditLog().AuditLogsPage(page)
+
+		return err
+	})
+	if errors.Is(err, query.ErrInvalidCursor) {
+		return httperror.BadRequest("Invalid query parameter: cursor", err)
+	} else if err != nil {
+		return httperror.InternalServerError("Unable to retrieve the audit log from the database", err)
+	}
+
+	if pageInfo.Next != "" {
+		w.Header().Set("X-Next-Cursor", pageInfo.Next)
+	}
+
+	w.Header().Set("X-Total-Count", strconv.Itoa(pageInfo.Total))
+
+	return response.JSON(w, records)
+}
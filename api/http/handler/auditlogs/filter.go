@@ -0,0 +1,90 @@
+package auditlogs
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	portainer "github.com/portainer/portainer/api"
+	"github.com/portainer/portainer/api/database/query"
+	"github.com/portainer/portainer/pkg/libhttp/request"
+)
+
+var errInvalidOutcome = errors.New("the outcome must be success or failure")
+
+// auditLogFilter translates the filters of the query into a filter evaluated
+// by the store
+func auditLogFilter(r *http.Request) (query.Filter, error) {
+	filters := make([]query.Filter, 0)
+
+	for _, param := range []struct{ name, field string }{
+		{"userId", "UserId"},
+		{"apiKeyId", "ApiKeyId"},
+		{"endpointId", "EndpointId"},
+	} {
+		id, err := numericParam(r, param.name)
+		if err != nil {
+			return query.Filter{}, err
+		}
+
+		if id != 0 {
+			filters = append(filters, query.Equals(param.field, id))
+		}
+	}
+
+	for _, param := range []struct{ name, field string }{
+		{"resourceType", "ResourceType"},
+		{"resourceId", "ResourceId"},
+		{"action", "Action"},
+		{"method", "Method"},
+	} {
+		if value, _ := request.RetrieveQueryParameter(r, param.name, true); value != "" {
+			filters = append(filters, query.Equals(param.field, value))
+		}
+	}
+
+	if username, _ := request.RetrieveQueryParameter(r, "username", true); username != "" {
+		filters = append(filters, query.EqualFold("Username", username))
+	}
+
+	if outcome, _ := request.RetrieveQueryParameter(r, "outcome", true); outcome != "" {
+		if outcome != string(portainer.AuditSuccess) && outcome != string(portainer.AuditFailure) {
+			return query.Filter{}, errInvalidOutcome
+		}
+
+		filters = append(filters, query.Equals("Outcome", outcome))
+	}
+
+	if search, _ := request.RetrieveQueryParameter(r, "search", true); search != "" {
+		filters = append(filters, query.Search("Path", search))
+	}
+
+	since, err := numericParam(r, "since")
+	if err != nil {
+		return query.Filter{}, err
+	}
+
+	if since != 0 {
+		filters = append(filters, query.AtLeast("Timestamp", since))
+	}
+
+	until, err := numericParam(r, "until")
+	if err != nil {
+		return query.Filter{}, err
+	}
+
+	if until != 0 {
+		filters = append(filters, query.AtMost("Timestamp", until))
+	}
+
+	return query.All(filters...), nil
+}
+
+func numericParam(r *http.Request, name string) (int, error) {
+	value, err := request.RetrieveNumericQueryParameter(r, name, true)
+	if err != nil {
+		return 0, fmt.Errorf("invalid query parameter %s: %w", name, err)
+	}
+
+	return value, nil
+}
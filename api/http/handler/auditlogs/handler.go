@@ -0,0 +1,32 @@
+package auditlogs
+
+import (
+	"net/http"
+
+	"github.com/portainer/portainer/api/dataservices"
+	"github.com/portainer/portainer/api/http/security"
+	httperror "github.com/portainer/portainer/pkg/libhttp/error"
+
+	"github.com/gorilla/mux"
+)
+
+// Handler is the HTTP handler used to query the audit log.
+type Handler struct {
+	*mux.Router
+	DataStore dataservices.DataStore
+}
+
+// NewHandler creates a handler to query the audit log.
+func NewHandler(bouncer security.BouncerService) *Handler {
+	h := &Handler{
+		Router: mux.NewRouter(),
+	}
+
+	adminRouter := h.NewRoute().Subrouter()
+	adminRouter.Use(bouncer.AdminAccess)
+
+	adminRouter.Handle("/audit", httperror.LoggerHandler(h.auditLogList)).Methods(http.MethodGet)
+	adminRouter.Handle("/audit/export", httperror.LoggerHandler(h.auditLogExport)).Methods(http.MethodGet)
+
+	return h
+}
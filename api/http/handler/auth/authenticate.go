@@ -4,8 +4,10 @@ import (
 	"net/http"
 	"strings"
 	"fmt"
+	"time"
 
 	portainer "github.com/portainer/portainer/api"
+	"github.com/portainer/portainer/api/http/audit"
 	httperrors "github.com/portainer/portainer/api/http/errors"
 	"github.com/portainer/portainer/api/http/security"
 	"github.com/portainer/portainer/api/internal/authorization"
@@ -45,6 +47,7 @@ func (payload *authenticatePayload) Validate(r *http.Request) error {
 // @summary Authenticate
 // @description **Access policy**: public
 // @description Use this environment(endpoint) to authenticate against Portainer using a username and password.
+// @description The users who enabled two-factor authentication receive a token to send with a TOTP or recovery code to /auth/2fa instead of a JWT.
 // @tags auth
 // @accept json
 // @produce json
@@ -84,7 +87,7 @@ func (handler *Handler) authenticate(rw http.ResponseWriter, r *http.Request) *h
 	}
 
 	if user != nil && isUserInitialAdmin(user) || settings.AuthenticationMethod == portainer.AuthenticationInternal {
-		return handler.authenticateInternal(rw, user, payload.Password)
+		return handler.authenticateInternal(rw, r, user, payload.Password, settings.RequireTwoFactor)
 	}
 
 	if settings.AuthenticationMethod == portainer.AuthenticationOAuth {
@@ -92,7 +95,7 @@ func (handler *Handler) authenticate(rw http.ResponseWriter, r *http.Request) *h
 	}
 
 	if settings.AuthenticationMethod == portainer.AuthenticationLDAP {
-		return handler.authenticateLDAP(rw, user, payload.Username, payload.Password, &settings.LDAPSettings)
+		return handler.authenticateLDAP(rw, r, user, payload.Username, payload.Password, &settings.LDAPSettings)
 	}
 
 	return httperror.NewError(http.StatusUnprocessableEntity, "Login method is not supported", httperrors.ErrUnauthorized)
@@ -102,17 +105,26 @@ func isUserInitialAdmin(user *portainer.User) bool {
 	return int(user.ID) == 1
 }
 
-func (handler *Handler) authenticateInternal(w http.ResponseWriter, user *portainer.User, password string) *httperror.HandlerError {
+func (handler *Handler) authenticateInternal(w http.ResponseWriter, r *http.Request, user *portainer.User, password string, requireTwoFactor bool) *httperror.HandlerError {
 	if err := handler.CryptoService.CompareHashAndData(user.Password, password); err != nil {
 		return httperror.NewError(http.StatusUnprocessableEntity, "Invalid credentials", httperrors.ErrUnauthorized)
 	}
 
 	forceChangePassword := !handler.passwordStrengthChecker.Check(password)
 
-	return handler.writeToken(w, user, forceChangePassword)
+	if user.TwoFactor.Enabled {
+		return handler.writeTwoFactorChallenge(w, user, forceChangePassword)
+	}
+
+	tokenData := composeTokenData(user, forceChangePassword)
+	// The users who must log in with a second factor and have not enrolled one
+	// yet are only allowed to enrol it
+	tokenData.TwoFactorEnrolmentRequired = requireTwoFactor
+
+	return handler.persistAndWriteToken(w, r, tokenData)
 }
 
-func (handler *Handler) authenticateLDAP(w http.ResponseWriter, user *portainer.User, username, password string, ldapSettings *portainer.LDAPSettings) *httperror.HandlerError {
+func (handler *Handler) authenticateLDAP(w http.ResponseWriter, r *http.Request, user *portainer.User, username, password string, ldapSettings *portainer.LDAPSettings) *httperror.HandlerError {
 	fmt.Println("user: ",user)
 	fmt.Println("username: ",username)
 	fmt.Println("password: ",password)
@@ -140,26 +152,50 @@ func (handler *Handler) authenticateLDAP(w http.ResponseWriter, user *portainer.
 		log.Warn().Err(err).Msg("unable to automatically sync user teams with ldap")
 	}
 
-	return handler.writeToken(w, user, false)
+	return handler.writeToken(w, r, user, false)
 }
 
-func (handler *Handler) writeToken(w http.ResponseWriter, user *portainer.User, forceChangePassword bool) *httperror.HandlerError {
+func (handler *Handler) writeToken(w http.ResponseWriter, r *http.Request, user *portainer.User, forceChangePassword bool) *httperror.HandlerError {
 	tokenData := composeTokenData(user, forceChangePassword)
 
-	return handler.persistAndWriteToken(w, tokenData)
+	return handler.persistAndWriteToken(w, r, tokenData)
 }
 
-func (handler *Handler) persistAndWriteToken(w http.ResponseWriter, tokenData *portainer.TokenData) *httperror.HandlerError {
+func (handler *Handler) persistAndWriteToken(w http.ResponseWriter, r *http.Request, tokenData *portainer.TokenData) *httperror.HandlerError {
 	token, expirationTime, err := handler.JWTService.GenerateToken(tokenData)
 	if err != nil {
 		return httperror.InternalServerError("Unable to generate JWT token", err)
 	}
 
+	if err := handler.createSession(r, token); err != nil {
+		return httperror.InternalServerError("Unable to persist the user session inside the database", err)
+	}
+
+	audit.SetUser(r.Context(), tokenData.ID, tokenData.Username)
+
 	security.AddAuthCookie(w, token, expirationTime)
 
 	return response.JSON(w, &authenticateResponse{JWT: token})
 }
 
+// createSession records the session opened by a token so that it can be
+// listed and revoked
+func (handler *Handler) createSession(r *http.Request, token string) error {
+	tokenData, jti, exp, err := handler.JWTService.ParseAndVerifyToken(token)
+	if err != nil {
+		return err
+	}
+
+	return handler.DataStore.UserSession().Create(&portainer.UserSession{
+		JTI:       jti,
+		UserID:    tokenData.ID,
+		IssuedAt:  time.Now().Unix(),
+		ExpiresAt: security.SessionExpiry(exp),
+		ClientIP:  security.StripAddrPort(r.RemoteAddr),
+		UserAgent: r.UserAgent(),
+	})
+}
+
 func (handler *Handler) syncUserTeamsWithLDAPGroups(user *portainer.User, settings *portainer.LDAPSettings) error {
 	// only sync if there is a group base DN
 	if len(settings.GroupSearchSettings) == 0 || len(settings.GroupSearchSettings[0].GroupBaseDN) == 0 {
@@ -0,0 +1,203 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	portainer "github.com/portainer/portainer/api"
+	"github.com/portainer/portainer/api/dataservices"
+	httperrors "github.com/portainer/portainer/api/http/errors"
+	"github.com/portainer/portainer/api/totp"
+	httperror "github.com/portainer/portainer/pkg/libhttp/error"
+	"github.com/portainer/portainer/pkg/libhttp/request"
+	"github.com/portainer/portainer/pkg/libhttp/response"
+)
+
+const (
+	// twoFactorChallengeTimeout is the time a user has to send a code once the
+	// password was verified
+	twoFactorChallengeTimeout = 5 * time.Minute
+	// maxTwoFactorAttempts is the number of codes tried before the login must
+	// be started again
+	maxTwoFactorAttempts = 5
+)
+
+var errInvalidTwoFactorLogin = errors.New("invalid or expired two-factor login")
+
+type twoFactorChallengeResponse struct {
+	// Whether a TOTP or recovery code must be sent to /auth/2fa to complete the login
+	TwoFactorRequired bool `json:"twoFactorRequired" example:"true"`
+	// Token identifying the login awaiting a code
+	TwoFactorToken string `json:"twoFactorToken" example:"1.c2VjcmV0"`
+}
+
+type authenticateTwoFactorPayload struct {
+	// Token returned by /auth
+	Token string `example:"1.c2VjcmV0" validate:"required"`
+	// TOTP code or recovery code
+	Code string `example:"123456" validate:"required"`
+}
+
+func (payload *authenticateTwoFactorPayload) Validate(r *http.Request) error {
+	if len(payload.Token) == 0 {
+		return errors.New("Invalid token")
+	}
+
+	if len(payload.Code) == 0 {
+		return errors.New("Invalid code")
+	}
+
+	return nil
+}
+
+// @id AuthenticateUserTwoFactor
+// @summary Complete a two-factor authentication
+// @description **Access policy**: public
+// @description Use this environment(endpoint) to complete the login of a user who enabled two-factor authentication,
+// @description with the token returned by /auth and a TOTP code or one of the recovery codes of the user.
+// @tags auth
+// @accept json
+// @produce json
+// @param body body authenticateTwoFactorPayload true "Token of the login and code"
+// @success 200 {object} authenticateResponse "Success"
+// @failure 400 "Invalid request"
+// @failure 422 "Invalid code or expired login"
+// @failure 500 "Server error"
+// @router /auth/2fa [post]
+func (handler *Handler) authenticateTwoFactor(w http.ResponseWriter, r *http.Request) *httperror.HandlerError {
+	var payload authenticateTwoFactorPayload
+	if err := request.DecodeAndValidateJSONPayload(r, &payload); err != nil {
+		return httperror.BadRequest("Invalid request payload", err)
+	}
+
+	userID, _, _ := strings.Cut(payload.Token, ".")
+	id, err := strconv.Atoi(userID)
+	if err != nil {
+		return httperror.NewError(http.StatusUnprocessableEntity, "Invalid or expired login", errInvalidTwoFactorLogin)
+	}
+
+	var user *portainer.User
+	var forceChangePassword bool
+	var codeErr *httperror.HandlerError
+
+	// The user is read, checked and updated in the same transaction so that
+	// concurrent requests cannot reuse a code or lose the invalid attempts
+	if err := handler.DataStore.UpdateTxRetryable(r.Context(), func(tx dataservices.DataStoreTx) error {
+		var err error
+		user, forceChangePassword, codeErr, err = handler.verifyTwoFactorCode(tx, portainer.UserID(id), payload)
+
+		return err
+	}); err != nil {
+		var httpErr *httperror.HandlerError
+		if errors.As(err, &httpErr) {
+			return httpErr
+		}
+
+		return httperror.InternalServerError("Unexpected error", err)
+	}
+
+	if codeErr != nil {
+		return codeErr
+	}
+
+	return handler.writeToken(w, r, user, forceChangePassword)
+}
+
+// verifyTwoFactorCode checks the code sent for the login of a user and records
+// its outcome. An invalid code is returned as codeErr so that the attempt is
+// still persisted when the transaction commits
+func (handler *Handler) verifyTwoFactorCode(tx dataservices.DataStoreTx, userID portainer.UserID, payload authenticateTwoFactorPayload) (user *portainer.User, forceChangePassword bool, codeErr *httperror.HandlerError, err error) {
+	user, err = tx.User().Read(userID)
+	if err != nil {
+		if tx.IsErrObjectNotFound(err) {
+			return nil, false, nil, httperror.NewError(http.StatusUnprocessableEntity, "Invalid or expired login", errInvalidTwoFactorLogin)
+		}
+
+		return nil, false, nil, httperror.InternalServerError("Unable to retrieve the user from the database", err)
+	}
+
+	twoFactor := &user.TwoFactor
+	if !twoFactor.Enabled || twoFactor.LoginChallenge == "" || time.Now().Unix() > twoFactor.LoginChallengeExpiresAt ||
+		subtle.ConstantTimeCompare([]byte(challengeDigest(payload.Token)), []byte(twoFactor.LoginChallenge)) != 1 {
+		return nil, false, nil, httperror.NewError(http.StatusUnprocessableEntity, "Invalid or expired login", errInvalidTwoFactorLogin)
+	}
+
+	if step, ok := totp.Validate(twoFactor.Secret, payload.Code, time.Now(), twoFactor.LastStep); ok {
+		twoFactor.LastStep = step
+	} else if i := handler.recoveryCodeIndex(twoFactor.RecoveryCodes, payload.Code); i >= 0 {
+		twoFactor.RecoveryCodes = slices.Delete(twoFactor.RecoveryCodes, i, i+1)
+	} else {
+		twoFactor.LoginAttempts++
+		if twoFactor.LoginAttempts >= maxTwoFactorAttempts {
+			clearTwoFactorChallenge(twoFactor)
+		}
+
+		if err := tx.User().Update(user.ID, user); err != nil {
+			return nil, false, nil, httperror.InternalServerError("Unable to persist user changes inside the database", err)
+		}
+
+		return nil, false, httperror.NewError(http.StatusUnprocessableEntity, "Invalid code", httperrors.ErrUnauthorized), nil
+	}
+
+	forceChangePassword = twoFactor.LoginForceChangePassword
+	clearTwoFactorChallenge(twoFactor)
+
+	if err := tx.User().Update(user.ID, user); err != nil {
+		return nil, false, nil, httperror.InternalServerError("Unable to persist user changes inside the database", err)
+	}
+
+	return user, forceChangePassword, nil, nil
+}
+
+// writeTwoFactorChallenge starts the login of a user who enabled two-factor
+// authentication, the user completes it with the token returned and a code
+func (handler *Handler) writeTwoFactorChallenge(w http.ResponseWriter, user *portainer.User, forceChangePassword bool) *httperror.HandlerError {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return httperror.InternalServerError("Unable to generate the two-factor login token", err)
+	}
+
+	token := strconv.Itoa(int(user.ID)) + "." + base64.RawURLEncoding.EncodeToString(secret)
+
+	user.TwoFactor.LoginChallenge = challengeDigest(token)
+	user.TwoFactor.LoginChallengeExpiresAt = time.Now().Add(twoFactorChallengeTimeout).Unix()
+	user.TwoFactor.LoginAttempts = 0
+	user.TwoFactor.LoginForceChangePassword = forceChangePassword
+
+	if err := handler.DataStore.User().Update(user.ID, user); err != nil {
+		return httperror.InternalServerError("Unable to persist user changes inside the database", err)
+	}
+
+	return response.JSON(w, &twoFactorChallengeResponse{TwoFactorRequired: true, TwoFactorToken: token})
+}
+
+// recoveryCodeIndex returns the index of the hash of a recovery code, or -1
+func (handler *Handler) recoveryCodeIndex(hashes []string, recoveryCode string) int {
+	recoveryCode = totp.NormalizeRecoveryCode(recoveryCode)
+
+	return slices.IndexFunc(hashes, func(hash string) bool {
+		return handler.CryptoService.CompareHashAndData(hash, recoveryCode) == nil
+	})
+}
+
+func clearTwoFactorChallenge(twoFactor *portainer.UserTwoFactor) {
+	twoFactor.LoginChallenge = ""
+	twoFactor.LoginChallengeExpiresAt = 0
+	twoFactor.LoginAttempts = 0
+	twoFactor.LoginForceChangePassword = false
+}
+
+func challengeDigest(token string) string {
+	digest := sha256.Sum256([]byte(token))
+
+	return hex.EncodeToString(digest[:])
+}
@@ -0,0 +1,196 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	portainer "github.com/portainer/portainer/api"
+	"github.com/portainer/portainer/api/apikey"
+	"github.com/portainer/portainer/api/crypto"
+	"github.com/portainer/portainer/api/datastore"
+	"github.com/portainer/portainer/api/http/security"
+	"github.com/portainer/portainer/api/jwt"
+	"github.com/portainer/portainer/api/totp"
+
+	"github.com/segmentio/encoding/json"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_authenticateTwoFactor(t *testing.T) {
+	is := require.New(t)
+
+	_, store := datastore.MustNewTestStore(t, true, true)
+
+	cryptoService := &crypto.Service{}
+	password, err := cryptoService.Hash("s3cr3tp@ssw0rd")
+	is.NoError(err)
+	recoveryCode, err := cryptoService.Hash("abcdefgh-ijklmnop")
+	is.NoError(err)
+	secret, err := totp.GenerateSecret()
+	is.NoError(err)
+
+	is.NoError(store.User().Create(&portainer.User{ID: 1, Username: "admin", Role: portainer.AdministratorRole, Password: password}))
+
+	user := &portainer.User{
+		ID:       2,
+		Username: "standard",
+		Role:     portainer.StandardUserRole,
+		Password: password,
+		TwoFactor: portainer.UserTwoFactor{
+			Enabled:       true,
+			Secret:        secret,
+			RecoveryCodes: []string{recoveryCode},
+		},
+	}
+	is.NoError(store.User().Create(user))
+
+	jwtService, err := jwt.NewService("1h", store)
+	is.NoError(err)
+	apiKeyService := apikey.NewAPIKeyService(store.APIKeyRepository(), store.User())
+	requestBouncer := security.NewRequestBouncer(store, jwtService, apiKeyService)
+	rateLimiter := security.NewRateLimiter(100, 1*time.Second, 1*time.Hour)
+	passwordChecker := security.NewPasswordStrengthChecker(store.SettingsService)
+
+	h := NewHandler(requestBouncer, rateLimiter, passwordChecker)
+	h.DataStore = store
+	h.CryptoService = cryptoService
+	h.JWTService = jwtService
+
+	serve := func(url, body string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, url, strings.NewReader(body)))
+
+		return rr
+	}
+
+	login := func(username string) twoFactorChallengeResponse {
+		rr := serve("/auth", `{"username":"`+username+`","password":"s3cr3tp@ssw0rd"}`)
+		is.Equal(http.StatusOK, rr.Code)
+
+		var challenge twoFactorChallengeResponse
+		is.NoError(json.NewDecoder(rr.Body).Decode(&challenge))
+
+		return challenge
+	}
+
+	t.Run("the password is not enough to log in", func(t *testing.T) {
+		challenge := login("standard")
+		is.True(challenge.TwoFactorRequired)
+		is.NotEmpty(challenge.TwoFactorToken)
+	})
+
+	t.Run("a TOTP code completes the login once", func(t *testing.T) {
+		challenge := login("standard")
+
+		code, err := totp.Code(secret, time.Now())
+		is.NoError(err)
+
+		rr := serve("/auth/2fa", `{"token":"`+challenge.TwoFactorToken+`","code":"`+code+`"}`)
+		is.Equal(http.StatusOK, rr.Code)
+
+		var response authenticateResponse
+		is.NoError(json.NewDecoder(rr.Body).Decode(&response))
+		is.NotEmpty(response.JWT)
+
+		rr = serve("/auth/2fa", `{"token":"`+challenge.TwoFactorToken+`","code":"`+code+`"}`)
+		is.Equal(http.StatusUnprocessableEntity, rr.Code, "the token of a login is used once")
+
+		challenge = login("standard")
+		rr = serve("/auth/2fa", `{"token":"`+challenge.TwoFactorToken+`","code":"`+code+`"}`)
+		is.Equal(http.StatusUnprocessableEntity, rr.Code, "a TOTP code is used once")
+	})
+
+	t.Run("a recovery code completes the login once", func(t *testing.T) {
+		challenge := login("standard")
+
+		rr := serve("/auth/2fa", `{"token":"`+challenge.TwoFactorToken+`","code":"ABCDEFGHIJKLMNOP"}`)
+		is.Equal(http.StatusOK, rr.Code)
+
+		u, err := store.User().Read(user.ID)
+		is.NoError(err)
+		is.Empty(u.TwoFactor.RecoveryCodes)
+		is.Empty(u.TwoFactor.LoginChallenge)
+	})
+
+	t.Run("the login is invalidated after too many invalid codes", func(t *testing.T) {
+		challenge := login("standard")
+
+		for range maxTwoFactorAttempts {
+			rr := serve("/auth/2fa", `{"token":"`+challenge.TwoFactorToken+`","code":"000000"}`)
+			is.Equal(http.StatusUnprocessableEntity, rr.Code)
+		}
+
+		code, err := totp.Code(secret, time.Now().Add(30*time.Second))
+		is.NoError(err)
+
+		rr := serve("/auth/2fa", `{"token":"`+challenge.TwoFactorToken+`","code":"`+code+`"}`)
+		is.Equal(http.StatusUnprocessableEntity, rr.Code)
+	})
+
+	// concurrently sends a code n times at once for the same login and returns
+	// the number of requests that completed it
+	concurrently := func(challenge twoFactorChallengeResponse, code string, n int) int {
+		var wg sync.WaitGroup
+		var logins atomic.Int32
+
+		for range n {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+
+				if serve("/auth/2fa", `{"token":"`+challenge.TwoFactorToken+`","code":"`+code+`"}`).Code == http.StatusOK {
+					logins.Add(1)
+				}
+			}()
+		}
+		wg.Wait()
+
+		return int(logins.Load())
+	}
+
+	t.Run("a code sent concurrently completes the login once", func(t *testing.T) {
+		u, err := store.User().Read(user.ID)
+		is.NoError(err)
+		u.TwoFactor.LastStep = 0
+		u.TwoFactor.RecoveryCodes = []string{recoveryCode}
+		is.NoError(store.User().Update(u.ID, u))
+
+		code, err := totp.Code(secret, time.Now())
+		is.NoError(err)
+
+		is.Equal(1, concurrently(login("standard"), code, 10), "a TOTP code is used once")
+		is.Equal(1, concurrently(login("standard"), "ABCDEFGHIJKLMNOP", 10), "a recovery code is used once")
+	})
+
+	t.Run("the invalid codes sent concurrently are all counted", func(t *testing.T) {
+		challenge := login("standard")
+
+		is.Zero(concurrently(challenge, "000000", maxTwoFactorAttempts))
+
+		u, err := store.User().Read(user.ID)
+		is.NoError(err)
+		is.Empty(u.TwoFactor.LoginChallenge, "the login is invalidated after too many invalid codes")
+	})
+
+	t.Run("the users without a second factor must enrol one when it is required", func(t *testing.T) {
+		settings, err := store.Settings().Settings()
+		is.NoError(err)
+		settings.RequireTwoFactor = true
+		is.NoError(store.Settings().UpdateSettings(settings))
+
+		rr := serve("/auth", `{"username":"admin","password":"s3cr3tp@ssw0rd"}`)
+		is.Equal(http.StatusOK, rr.Code)
+
+		var response authenticateResponse
+		is.NoError(json.NewDecoder(rr.Body).Decode(&response))
+
+		tokenData, _, _, err := jwtService.ParseAndVerifyToken(response.JWT)
+		is.NoError(err)
+		is.True(tokenData.TwoFactorEnrolmentRequired)
+	})
+}
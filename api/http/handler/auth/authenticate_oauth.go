@@ -112,5 +112,5 @@ func (handler *Handler) validateOAuth(w http.ResponseWriter, r *http.Request) *h
 
 	}
 
-	return handler.writeToken(w, user, false)
+	return handler.writeToken(w, r, user, false)
 }
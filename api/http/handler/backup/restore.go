@@ -47,7 +47,9 @@ func (h *Handler) restore(w http.ResponseWriter, r *http.Request) *httperror.Han
 
 	var archiveReader io.Reader = bytes.NewReader(payload.FileContent)
 	err = operations.RestoreArchive(archiveReader, payload.Password, h.filestorePath, h.gate, h.dataStore, h.shutdownTrigger)
-	if err != nil {
+	if errors.Is(err, operations.ErrBackendMismatch) || errors.Is(err, operations.ErrDatabaseMissing) {
+		return httperror.BadRequest("Invalid backup file", err)
+	} else if err != nil {
 		return httperror.InternalServerError("Failed to restore the backup", err)
 	}
 
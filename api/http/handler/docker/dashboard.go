@@ -47,7 +47,7 @@ type dashboardResponse struct {
 // @router /docker/{environmentId}/dashboard [post]
 func (h *Handler) dashboard(w http.ResponseWriter, r *http.Request) *httperror.HandlerError {
 	var resp dashboardResponse
-	err := h.dataStore.ViewTx(func(tx dataservices.DataStoreTx) error {
+	err := h.dataStore.ViewTxCtx(r.Context(), func(tx dataservices.DataStoreTx) error {
 		cli, httpErr := utils.GetClient(r, h.dockerClientFactory)
 		if httpErr != nil {
 			return httpErr
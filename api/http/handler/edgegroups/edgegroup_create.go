@@ -77,7 +77,7 @@ func (handler *Handler) edgeGroupCreate(w http.ResponseWriter, r *http.Request)
 
 	var edgeGroup *portainer.EdgeGroup
 
-	err := handler.DataStore.UpdateTx(func(tx dataservices.DataStoreTx) error {
+	err := handler.DataStore.UpdateTxCtx(r.Context(), func(tx dataservices.DataStoreTx) error {
 		edgeGroups, err := tx.EdgeGroup().ReadAll()
 		if err != nil {
 			return httperror.InternalServerError("Unable to retrieve Edge groups from the database", err)
@@ -5,6 +5,7 @@ import (
 
 	portainer "github.com/portainer/portainer/api"
 	"github.com/portainer/portainer/api/dataservices"
+	"github.com/portainer/portainer/api/http/etag"
 	httperror "github.com/portainer/portainer/pkg/libhttp/error"
 	"github.com/portainer/portainer/pkg/libhttp/request"
 )
@@ -18,6 +19,7 @@ import (
 // @produce json
 // @param id path int true "EdgeGroup Id"
 // @success 200 {object} portainer.EdgeGroup
+// @header 200 {string} ETag "Entity tag of the Edge group, to send in the If-Match header of an update"
 // @failure 503 "Edge compute features are disabled"
 // @failure 500
 // @router /edge_groups/{id} [get]
@@ -28,15 +30,15 @@ func (handler *Handler) edgeGroupInspect(w http.ResponseWriter, r *http.Request)
 	}
 
 	var edgeGroup *portainer.EdgeGroup
-	err = handler.DataStore.ViewTx(func(tx dataservices.DataStoreTx) error {
-		edgeGroup, err = getEdgeGroup(tx, portainer.EdgeGroupID(edgeGroupID))
+	err = handler.DataStore.ViewTxCtx(r.Context(), func(tx dataservices.DataStoreTx) error {
+		edgeGroup, err = getEdgeGroup(tx, portainer.EdgeGroupID(edgeGroupID), w)
 		return err
 	})
 
 	return txResponse(w, edgeGroup, err)
 }
 
-func getEdgeGroup(tx dataservices.DataStoreTx, ID portainer.EdgeGroupID) (*portainer.EdgeGroup, error) {
+func getEdgeGroup(tx dataservices.DataStoreTx, ID portainer.EdgeGroupID, w http.ResponseWriter) (*portainer.EdgeGroup, error) {
 	edgeGroup, err := tx.EdgeGroup().Read(ID)
 	if tx.IsErrObjectNotFound(err) {
 		return nil, httperror.NotFound("Unable to find an Edge group with the specified identifier inside the database", err)
@@ -44,6 +46,9 @@ func getEdgeGroup(tx dataservices.DataStoreTx, ID portainer.EdgeGroupID) (*porta
 		return nil, httperror.InternalServerError("Unable to find an Edge group with the specified identifier inside the database", err)
 	}
 
+	// The endpoints of a dynamic group are not part of the stored group
+	etag.Set(w, edgeGroup)
+
 	if edgeGroup.Dynamic {
 		endpoints, err := GetEndpointsByTags(tx, edgeGroup.TagIDs, edgeGroup.PartialMatch)
 		if err != nil {
@@ -33,7 +33,7 @@ func (handler *Handler) edgeGroupList(w http.ResponseWriter, r *http.Request) *h
 	var decoratedEdgeGroups []decoratedEdgeGroup
 	var err error
 
-	err = handler.DataStore.ViewTx(func(tx dataservices.DataStoreTx) error {
+	err = handler.DataStore.ViewTxCtx(r.Context(), func(tx dataservices.DataStoreTx) error {
 		decoratedEdgeGroups, err = getEdgeGroupList(tx)
 		return err
 	})
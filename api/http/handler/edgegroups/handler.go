@@ -41,6 +41,10 @@ func NewHandler(bouncer security.BouncerService) *Handler {
 
 func txResponse(w http.ResponseWriter, r any, err error) *httperror.HandlerError {
 	if err != nil {
+		if dataservices.IsErrConflict(err) {
+			return httperror.Conflict("The resource was modified by another request, try again", err)
+		}
+
 		var handlerError *httperror.HandlerError
 		if errors.As(err, &handlerError) {
 			return handlerError
@@ -92,7 +92,7 @@ func (handler *Handler) createEdgeJobFromFileContent(w http.ResponseWriter, r *h
 	}
 
 	var edgeJob *portainer.EdgeJob
-	err = handler.DataStore.UpdateTx(func(tx dataservices.DataStoreTx) error {
+	err = handler.DataStore.UpdateTxCtx(r.Context(), func(tx dataservices.DataStoreTx) error {
 		edgeJob, err = handler.createEdgeJob(tx, &payload.edgeJobBasePayload, []byte(payload.FileContent))
 
 		return err
@@ -198,7 +198,7 @@ func (handler *Handler) createEdgeJobFromFile(w http.ResponseWriter, r *http.Req
 	}
 
 	var edgeJob *portainer.EdgeJob
-	err = handler.DataStore.UpdateTx(func(tx dataservices.DataStoreTx) error {
+	err = handler.DataStore.UpdateTxCtx(r.Context(), func(tx dataservices.DataStoreTx) error {
 		edgeJob, err = handler.createEdgeJob(tx, &payload.edgeJobBasePayload, payload.File)
 
 		return err
@@ -4,6 +4,7 @@ import (
 	"net/http"
 
 	portainer "github.com/portainer/portainer/api"
+	"github.com/portainer/portainer/api/http/etag"
 	httperror "github.com/portainer/portainer/pkg/libhttp/error"
 	"github.com/portainer/portainer/pkg/libhttp/request"
 	"github.com/portainer/portainer/pkg/libhttp/response"
@@ -23,6 +24,7 @@ type edgeJobInspectResponse struct {
 // @produce json
 // @param id path int true "EdgeJob Id"
 // @success 200 {object} portainer.EdgeJob
+// @header 200 {string} ETag "Entity tag of the Edge job, to send in the If-Match header of an update"
 // @failure 500
 // @failure 400
 // @failure 503 "Edge compute features are disabled"
@@ -40,6 +42,8 @@ func (handler *Handler) edgeJobInspect(w http.ResponseWriter, r *http.Request) *
 		return httperror.InternalServerError("Unable to find an Edge job with the specified identifier inside the database", err)
 	}
 
+	etag.Set(w, edgeJob)
+
 	responseObj := edgeJobInspectResponse{
 		EdgeJob: edgeJob,
 	}
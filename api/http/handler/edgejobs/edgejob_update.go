@@ -9,6 +9,7 @@ import (
 
 	portainer "github.com/portainer/portainer/api"
 	"github.com/portainer/portainer/api/dataservices"
+	"github.com/portainer/portainer/api/http/etag"
 	"github.com/portainer/portainer/api/internal/edge"
 	"github.com/portainer/portainer/api/internal/edge/cache"
 	"github.com/portainer/portainer/api/internal/endpointutils"
@@ -44,10 +45,13 @@ func (payload *edgeJobUpdatePayload) Validate(r *http.Request) error {
 // @accept json
 // @produce json
 // @param id path int true "EdgeJob Id"
+// @param If-Match header string false "Entity tag returned when the Edge job was read, the update is rejected when the Edge job changed since"
 // @param body body edgeJobUpdatePayload true "EdgeGroup data"
 // @success 200 {object} portainer.EdgeJob
 // @failure 500
 // @failure 400
+// @failure 409 "The Edge job was modified by another request"
+// @failure 412 "The Edge job changed since it was read"
 // @failure 503 "Edge compute features are disabled"
 // @router /edge_jobs/{id} [put]
 func (handler *Handler) edgeJobUpdate(w http.ResponseWriter, r *http.Request) *httperror.HandlerError {
@@ -62,15 +66,18 @@ func (handler *Handler) edgeJobUpdate(w http.ResponseWriter, r *http.Request) *h
 	}
 
 	var edgeJob *portainer.EdgeJob
-	err = handler.DataStore.UpdateTx(func(tx dataservices.DataStoreTx) error {
-		edgeJob, err = handler.updateEdgeJob(tx, portainer.EdgeJobID(edgeJobID), payload)
+	err = handler.DataStore.UpdateTxCtx(r.Context(), func(tx dataservices.DataStoreTx) error {
+		edgeJob, err = handler.updateEdgeJob(tx, r, portainer.EdgeJobID(edgeJobID), payload)
 		return err
 	})
+	if err == nil {
+		etag.Set(w, edgeJob)
+	}
 
 	return txResponse(w, edgeJob, err)
 }
 
-func (handler *Handler) updateEdgeJob(tx dataservices.DataStoreTx, edgeJobID portainer.EdgeJobID, payload edgeJobUpdatePayload) (*portainer.EdgeJob, error) {
+func (handler *Handler) updateEdgeJob(tx dataservices.DataStoreTx, r *http.Request, edgeJobID portainer.EdgeJobID, payload edgeJobUpdatePayload) (*portainer.EdgeJob, error) {
 	edgeJob, err := tx.EdgeJob().Read(edgeJobID)
 	if tx.IsErrObjectNotFound(err) {
 		return nil, httperror.NotFound("Unable to find an Edge job with the specified identifier inside the database", err)
@@ -78,6 +85,10 @@ func (handler *Handler) updateEdgeJob(tx dataservices.DataStoreTx, edgeJobID por
 		return nil, httperror.InternalServerError("Unable to find an Edge job with the specified identifier inside the database", err)
 	}
 
+	if err := etag.CheckIfMatch(r, edgeJob); err != nil {
+		return nil, err
+	}
+
 	if err := handler.updateEdgeSchedule(tx, edgeJob, &payload); err != nil {
 		return nil, httperror.InternalServerError("Unable to update Edge job", err)
 	}
@@ -66,6 +66,10 @@ func convertEndpointsToMetaObject(endpoints []portainer.EndpointID) map[portaine
 
 func txResponse(w http.ResponseWriter, r any, err error) *httperror.HandlerError {
 	if err != nil {
+		if dataservices.IsErrConflict(err) {
+			return httperror.Conflict("The resource was modified by another request, try again", err)
+		}
+
 		var handlerError *httperror.HandlerError
 		if errors.As(err, &handlerError) {
 			return handlerError
@@ -4,6 +4,7 @@ import (
 	"net/http"
 
 	portainer "github.com/portainer/portainer/api"
+	"github.com/portainer/portainer/api/http/etag"
 	httperror "github.com/portainer/portainer/pkg/libhttp/error"
 	"github.com/portainer/portainer/pkg/libhttp/request"
 	"github.com/portainer/portainer/pkg/libhttp/response"
@@ -18,6 +19,7 @@ import (
 // @produce json
 // @param id path int true "EdgeStack Id"
 // @success 200 {object} portainer.EdgeStack
+// @header 200 {string} ETag "Entity tag of the Edge stack, to send in the If-Match header of an update"
 // @failure 500
 // @failure 400
 // @failure 503 "Edge compute features are disabled"
@@ -33,5 +35,7 @@ func (handler *Handler) edgeStackInspect(w http.ResponseWriter, r *http.Request)
 		return handler.handlerDBErr(err, "Unable to find an edge stack with the specified identifier inside the database")
 	}
 
+	etag.Set(w, edgeStack)
+
 	return response.JSON(w, edgeStack)
 }
@@ -1,9 +1,15 @@
 package edgestacks
 
 import (
+	"errors"
 	"net/http"
+	"strconv"
 
+	portainer "github.com/portainer/portainer/api"
+	"github.com/portainer/portainer/api/database/query"
+	"github.com/portainer/portainer/api/dataservices"
 	httperror "github.com/portainer/portainer/pkg/libhttp/error"
+	"github.com/portainer/portainer/pkg/libhttp/request"
 	"github.com/portainer/portainer/pkg/libhttp/response"
 )
 
@@ -14,16 +20,65 @@ import (
 // @security ApiKeyAuth
 // @security jwt
 // @produce json
+// @param start query int false "Start searching from"
+// @param limit query int false "Limit results to this value"
+// @param cursor query string false "Start after the edge stack the cursor was returned for, it replaces start. The cursor of the next page is returned in the X-Next-Cursor header"
+// @param sort query string false "Sort results by this value" Enum("Name", "CreationDate")
+// @param order query string false "Order sorted results by desc/asc" Enum("asc", "desc")
+// @param search query string false "Only return the edge stacks whose name includes this value"
 // @success 200 {array} portainer.EdgeStack
+// @header 200 {string} X-Total-Count "The number of edge stacks matching the search"
+// @header 200 {string} X-Next-Cursor "The cursor of the next page"
 // @failure 500
 // @failure 400
 // @failure 503 "Edge compute features are disabled"
 // @router /edge_stacks [get]
 func (handler *Handler) edgeStackList(w http.ResponseWriter, r *http.Request) *httperror.HandlerError {
-	edgeStacks, err := handler.DataStore.EdgeStack().EdgeStacks()
-	if err != nil {
+	start, _ := request.RetrieveNumericQueryParameter(r, "start", true)
+	if start != 0 {
+		start--
+	}
+
+	limit, _ := request.RetrieveNumericQueryParameter(r, "limit", true)
+	cursor, _ := request.RetrieveQueryParameter(r, "cursor", true)
+	sortField, _ := request.RetrieveQueryParameter(r, "sort", true)
+	sortOrder, _ := request.RetrieveQueryParameter(r, "order", true)
+	search, _ := request.RetrieveQueryParameter(r, "search", true)
+
+	page := query.Page{Offset: start, Limit: limit, Cursor: cursor}
+
+	switch sortField {
+	case "":
+	case "Name":
+		page.Sort = query.Sort{Field: "Name", Text: true, Desc: sortOrder == "desc"}
+	case "CreationDate":
+		page.Sort = query.Sort{Field: "CreationDate", Desc: sortOrder == "desc"}
+	default:
+		return httperror.BadRequest("Invalid query parameter: sort", errors.New("unsupported sort field"))
+	}
+
+	if search != "" {
+		page.Filter = query.Search("Name", search)
+	}
+
+	var edgeStacks []portainer.EdgeStack
+	var pageInfo query.PageInfo
+	err := handler.DataStore.ViewTxCtx(r.Context(), func(tx dataservices.DataStoreTx) (err error) {
+		edgeStacks, pageInfo, err = tx.EdgeStack().EdgeStacksPage(page)
+
+		return err
+	})
+	if errors.Is(err, query.ErrInvalidCursor) {
+		return httperror.BadRequest("Invalid query parameter: cursor", err)
+	} else if err != nil {
 		return httperror.InternalServerError("Unable to retrieve edge stacks from the database", err)
 	}
 
+	if pageInfo.Next != "" {
+		w.Header().Set("X-Next-Cursor", pageInfo.Next)
+	}
+
+	w.Header().Set("X-Total-Count", strconv.Itoa(pageInfo.Total))
+
 	return response.JSON(w, edgeStacks)
 }
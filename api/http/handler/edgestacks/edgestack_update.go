@@ -5,6 +5,7 @@ import (
 
 	portainer "github.com/portainer/portainer/api"
 	"github.com/portainer/portainer/api/dataservices"
+	"github.com/portainer/portainer/api/http/etag"
 	"github.com/portainer/portainer/api/internal/edge"
 	"github.com/portainer/portainer/api/set"
 	httperror "github.com/portainer/portainer/pkg/libhttp/error"
@@ -44,10 +45,13 @@ func (payload *updateEdgeStackPayload) Validate(r *http.Request) error {
 // @accept json
 // @produce json
 // @param id path int true "EdgeStack Id"
+// @param If-Match header string false "Entity tag returned when the Edge stack was read, the update is rejected when the Edge stack changed since"
 // @param body body updateEdgeStackPayload true "EdgeStack data"
 // @success 200 {object} portainer.EdgeStack
 // @failure 500
 // @failure 400
+// @failure 409 "The Edge stack was modified by another request"
+// @failure 412 "The Edge stack changed since it was read"
 // @failure 503 "Edge compute features are disabled"
 // @router /edge_stacks/{id} [put]
 func (handler *Handler) edgeStackUpdate(w http.ResponseWriter, r *http.Request) *httperror.HandlerError {
@@ -63,11 +67,15 @@ func (handler *Handler) edgeStackUpdate(w http.ResponseWriter, r *http.Request)
 	}
 
 	var stack *portainer.EdgeStack
-	err = handler.DataStore.UpdateTx(func(tx dataservices.DataStoreTx) error {
-		stack, err = handler.updateEdgeStack(tx, portainer.EdgeStackID(stackID), payload)
+	err = handler.DataStore.UpdateTxCtx(r.Context(), func(tx dataservices.DataStoreTx) error {
+		stack, err = handler.updateEdgeStack(tx, r, portainer.EdgeStackID(stackID), payload)
 		return err
 	})
 	if err != nil {
+		if dataservices.IsErrConflict(err) {
+			return httperror.Conflict("The Edge stack was modified by another request, try again", err)
+		}
+
 		var httpErr *httperror.HandlerError
 		if errors.As(err, &httpErr) {
 			return httpErr
@@ -76,15 +84,21 @@ func (handler *Handler) edgeStackUpdate(w http.ResponseWriter, r *http.Request)
 		return httperror.InternalServerError("Unexpected error", err)
 	}
 
+	etag.Set(w, stack)
+
 	return response.JSON(w, stack)
 }
 
-func (handler *Handler) updateEdgeStack(tx dataservices.DataStoreTx, stackID portainer.EdgeStackID, payload updateEdgeStackPayload) (*portainer.EdgeStack, error) {
+func (handler *Handler) updateEdgeStack(tx dataservices.DataStoreTx, r *http.Request, stackID portainer.EdgeStackID, payload updateEdgeStackPayload) (*portainer.EdgeStack, error) {
 	stack, err := tx.EdgeStack().EdgeStack(stackID)
 	if err != nil {
 		return nil, handler.handlerDBErr(err, "Unable to find a stack with the specified identifier inside the database")
 	}
 
+	if err := etag.CheckIfMatch(r, stack); err != nil {
+		return nil, err
+	}
+
 	relationConfig, err := edge.FetchEndpointRelationsConfig(tx)
 	if err != nil {
 		return nil, httperror.InternalServerError("Unable to retrieve environments relations config from database", err)
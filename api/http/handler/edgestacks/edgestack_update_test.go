@@ -6,11 +6,15 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"reflect"
+	"strconv"
 	"testing"
 
 	portainer "github.com/portainer/portainer/api"
+	httperror "github.com/portainer/portainer/pkg/libhttp/error"
 	"github.com/stretchr/testify/require"
 
+	"github.com/gorilla/mux"
+
 	"github.com/segmentio/encoding/json"
 )
 
@@ -246,3 +250,43 @@ func TestUpdateWithInvalidPayload(t *testing.T) {
 		})
 	}
 }
+
+func TestUpdateWithIfMatch(t *testing.T) {
+	handler, _ := setupHandler(t)
+
+	endpoint := createEndpoint(t, handler.DataStore)
+	edgeStack := createEdgeStack(t, handler.DataStore, endpoint.ID)
+	vars := map[string]string{"id": strconv.Itoa(int(edgeStack.ID))}
+
+	req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/edge_stacks/%d", edgeStack.ID), nil)
+	rec := httptest.NewRecorder()
+	require.Nil(t, handler.edgeStackInspect(rec, mux.SetURLVars(req, vars)))
+
+	etag := rec.Header().Get("ETag")
+	require.NotEmpty(t, etag)
+
+	update := func(ifMatch string) (*httptest.ResponseRecorder, *httperror.HandlerError) {
+		jsonPayload, err := json.Marshal(updateEdgeStackPayload{
+			StackFileContent: "update-test",
+			UpdateVersion:    true,
+			EdgeGroups:       edgeStack.EdgeGroups,
+			DeploymentType:   portainer.EdgeStackDeploymentCompose,
+		})
+		require.NoError(t, err)
+
+		req := httptest.NewRequest(http.MethodPut, fmt.Sprintf("/edge_stacks/%d", edgeStack.ID), bytes.NewBuffer(jsonPayload))
+		req.Header.Set("If-Match", ifMatch)
+		rec := httptest.NewRecorder()
+
+		return rec, handler.edgeStackUpdate(rec, mux.SetURLVars(req, vars))
+	}
+
+	rec, handlerErr := update(etag)
+	require.Nil(t, handlerErr)
+	require.NotEqual(t, etag, rec.Header().Get("ETag"))
+
+	// The first update changed the stack
+	_, handlerErr = update(etag)
+	require.NotNil(t, handlerErr)
+	require.Equal(t, http.StatusPreconditionFailed, handlerErr.StatusCode)
+}
@@ -106,11 +106,22 @@ func (handler *Handler) endpointEdgeStatusInspect(w http.ResponseWriter, r *http
 		return httperror.Forbidden("Permission denied to access environment. The device has not been trusted yet", fmt.Errorf("untrusted Edge environment access: %w. Environment name: %s", err, endpoint.Name))
 	}
 
-	var statusResponse *endpointEdgeStatusInspectResponse
-	if err := handler.DataStore.UpdateTx(func(tx dataservices.DataStoreTx) error {
-		statusResponse, err = handler.inspectStatus(tx, r, portainer.EndpointID(endpointID), firstConn)
+	var checkedIn *portainer.Endpoint
+	err = handler.DataStore.UpdateTxCtx(r.Context(), func(tx dataservices.DataStoreTx) error {
+		checkedIn, err = handler.checkIn(tx, r, portainer.EndpointID(endpointID), firstConn)
 		return err
-	}); err != nil {
+	})
+
+	var statusResponse *endpointEdgeStatusInspectResponse
+	if err == nil {
+		// The schedules and stacks can be read from a replica, the agent polls again shortly
+		err = handler.DataStore.ViewTxCtx(portainer.WithReadReplica(r.Context()), func(tx dataservices.DataStoreTx) error {
+			statusResponse, err = handler.inspectStatus(tx, checkedIn)
+			return err
+		})
+	}
+
+	if err != nil {
 		var httpErr *httperror.HandlerError
 		if errors.As(err, &httpErr) {
 			httpErr.Err = fmt.Errorf("edge polling error: %w. Environment name: %s", httpErr.Err, endpoint.Name)
@@ -138,7 +149,8 @@ func (handler *Handler) parseHeaders(r *http.Request, endpoint *portainer.Endpoi
 	return nil
 }
 
-func (handler *Handler) inspectStatus(tx dataservices.DataStoreTx, r *http.Request, endpointID portainer.EndpointID, firstConn bool) (*endpointEdgeStatusInspectResponse, error) {
+// checkIn records the check-in of the agent and the details it sent in the request headers
+func (handler *Handler) checkIn(tx dataservices.DataStoreTx, r *http.Request, endpointID portainer.EndpointID, firstConn bool) (*portainer.Endpoint, error) {
 	endpoint, err := tx.Endpoint().Endpoint(endpointID)
 	if err != nil {
 		return nil, err
@@ -161,6 +173,10 @@ func (handler *Handler) inspectStatus(tx dataservices.DataStoreTx, r *http.Reque
 		return nil, httperror.InternalServerError("Unable to persist environment changes inside the database", err)
 	}
 
+	return endpoint, nil
+}
+
+func (handler *Handler) inspectStatus(tx dataservices.DataStoreTx, endpoint *portainer.Endpoint) (*endpointEdgeStatusInspectResponse, error) {
 	tunnel := handler.ReverseTunnelService.Config(endpoint.ID)
 
 	statusResponse := endpointEdgeStatusInspectResponse{
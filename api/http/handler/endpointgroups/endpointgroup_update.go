@@ -7,6 +7,7 @@ import (
 
 	portainer "github.com/portainer/portainer/api"
 	"github.com/portainer/portainer/api/dataservices"
+	"github.com/portainer/portainer/api/internal/authorization"
 	"github.com/portainer/portainer/api/internal/endpointutils"
 	"github.com/portainer/portainer/api/pendingactions/handlers"
 	"github.com/portainer/portainer/api/tag"
@@ -61,7 +62,7 @@ func (handler *Handler) endpointGroupUpdate(w http.ResponseWriter, r *http.Reque
 
 	var endpointGroup *portainer.EndpointGroup
 
-	if err := handler.DataStore.UpdateTx(func(tx dataservices.DataStoreTx) error {
+	if err := handler.DataStore.UpdateTxCtx(r.Context(), func(tx dataservices.DataStoreTx) error {
 		endpointGroup, err = handler.updateEndpointGroup(tx, portainer.EndpointGroupID(endpointGroupID), payload)
 
 		return err
@@ -135,6 +136,12 @@ func (handler *Handler) updateEndpointGroup(tx dataservices.DataStoreTx, endpoin
 		}
 	}
 
+	if err := authorization.ValidateAccessPolicies(tx, payload.UserAccessPolicies, payload.TeamAccessPolicies); errors.Is(err, authorization.ErrUnknownRole) {
+		return nil, httperror.BadRequest("Invalid access policies", err)
+	} else if err != nil {
+		return nil, httperror.InternalServerError("Unable to retrieve the roles from the database", err)
+	}
+
 	updateAuthorizations := false
 	if payload.UserAccessPolicies != nil && !reflect.DeepEqual(payload.UserAccessPolicies, endpointGroup.UserAccessPolicies) {
 		endpointGroup.UserAccessPolicies = payload.UserAccessPolicies
@@ -170,6 +177,12 @@ func (handler *Handler) updateEndpointGroup(tx dataservices.DataStoreTx, endpoin
 		return nil, httperror.InternalServerError("Unable to persist environment group changes inside the database", err)
 	}
 
+	if updateAuthorizations {
+		if err := handler.AuthorizationService.UpdateUsersAuthorizationsTx(tx); err != nil {
+			return nil, httperror.InternalServerError("Unable to update user authorizations", err)
+		}
+	}
+
 	if tagsChanged {
 		endpoints, err := tx.Endpoint().Endpoints()
 		if err != nil {
@@ -7,6 +7,7 @@ import (
 	"strings"
 
 	portainer "github.com/portainer/portainer/api"
+	"github.com/portainer/portainer/api/chisel"
 	httperror "github.com/portainer/portainer/pkg/libhttp/error"
 	"github.com/portainer/portainer/pkg/libhttp/request"
 )
@@ -34,8 +35,9 @@ func (handler *Handler) proxyRequestsToDockerAPI(w http.ResponseWriter, r *http.
 			return httperror.InternalServerError("No Edge agent registered with the environment", errors.New("No agent available"))
 		}
 
+		// The proxy forwards the requests when another instance holds the tunnel
 		_, err := handler.ReverseTunnelService.TunnelAddr(endpoint)
-		if err != nil {
+		if err != nil && !errors.Is(err, chisel.ErrRemoteTunnel) {
 			return httperror.InternalServerError("Unable to get the active tunnel", err)
 		}
 	}
@@ -63,8 +63,11 @@ func (handler *Handler) endpointDelete(w http.ResponseWriter, r *http.Request) *
 		return httperror.BadRequest("Invalid boolean query parameter", err)
 	}
 
-	if err := handler.DataStore.UpdateTx(func(tx dataservices.DataStoreTx) error {
-		return handler.deleteEndpoint(tx, portainer.EndpointID(endpointID), deleteCluster)
+	var endpoint *portainer.Endpoint
+	if err := handler.DataStore.UpdateTxRetryable(r.Context(), func(tx dataservices.DataStoreTx) (err error) {
+		endpoint, err = handler.deleteEndpoint(tx, portainer.EndpointID(endpointID), deleteCluster)
+
+		return err
 	}); err != nil {
 		var handlerError *httperror.HandlerError
 		if errors.As(err, &handlerError) {
@@ -74,6 +77,8 @@ func (handler *Handler) endpointDelete(w http.ResponseWriter, r *http.Request) *
 		return httperror.InternalServerError("Unexpected error", err)
 	}
 
+	handler.cleanUpDeletedEndpoint(endpoint)
+
 	return response.Empty(w)
 }
 
@@ -103,21 +108,23 @@ func (handler *Handler) endpointDeleteBatch(w http.ResponseWriter, r *http.Reque
 		Errors:  []int{},
 	}
 
-	if err := handler.DataStore.UpdateTx(func(tx dataservices.DataStoreTx) error {
-		for _, e := range p.Endpoints {
-			if err := handler.deleteEndpoint(tx, portainer.EndpointID(e.ID), e.DeleteCluster); err != nil {
-				resp.Errors = append(resp.Errors, e.ID)
-				log.Warn().Err(err).Int("environment_id", e.ID).Msg("Unable to remove environment")
+	// Every environment is deleted in a transaction of its own, the response
+	// is only built from the committed deletions
+	for _, e := range p.Endpoints {
+		var endpoint *portainer.Endpoint
+		if err := handler.DataStore.UpdateTxRetryable(r.Context(), func(tx dataservices.DataStoreTx) (err error) {
+			endpoint, err = handler.deleteEndpoint(tx, portainer.EndpointID(e.ID), e.DeleteCluster)
 
-				continue
-			}
+			return err
+		}); err != nil {
+			resp.Errors = append(resp.Errors, e.ID)
+			log.Warn().Err(err).Int("environment_id", e.ID).Msg("Unable to remove environment")
 
-			resp.Deleted = append(resp.Deleted, e.ID)
+			continue
 		}
 
-		return nil
-	}); err != nil {
-		return httperror.InternalServerError("Unable to delete environments", err)
+		handler.cleanUpDeletedEndpoint(endpoint)
+		resp.Deleted = append(resp.Deleted, e.ID)
 	}
 
 	if len(resp.Errors) > 0 {
@@ -127,70 +134,71 @@ func (handler *Handler) endpointDeleteBatch(w http.ResponseWriter, r *http.Reque
 	return response.Empty(w)
 }
 
-func (handler *Handler) deleteEndpoint(tx dataservices.DataStoreTx, endpointID portainer.EndpointID, deleteCluster bool) error {
+// deleteEndpoint removes the environment and its references from the store.
+// It may be executed again when the transaction is retried, the files and the
+// proxy of the environment are removed by cleanUpDeletedEndpoint once it is
+// committed.
+func (handler *Handler) deleteEndpoint(tx dataservices.DataStoreTx, endpointID portainer.EndpointID, deleteCluster bool) (*portainer.Endpoint, error) {
 	endpoint, err := tx.Endpoint().Endpoint(endpointID)
 	if tx.IsErrObjectNotFound(err) {
-		return httperror.NotFound("Unable to find an environment with the specified identifier inside the database", err)
+		return nil, httperror.NotFound("Unable to find an environment with the specified identifier inside the database", err)
 	} else if err != nil {
-		return httperror.InternalServerError("Unable to read the environment record from the database", err)
-	}
-
-	if endpoint.TLSConfig.TLS {
-		folder := strconv.Itoa(int(endpointID))
-		if err := handler.FileService.DeleteTLSFiles(folder); err != nil {
-			log.Error().Err(err).Msgf("Unable to remove TLS files from disk when deleting endpoint %d", endpointID)
-		}
+		return nil, httperror.InternalServerError("Unable to read the environment record from the database", err)
 	}
 
-	if err := tx.Snapshot().Delete(endpointID); err != nil {
-		log.Warn().Err(err).Msg("Unable to remove the snapshot from the database")
+	if err := tx.Snapshot().Delete(endpointID); err != nil && !tx.IsErrObjectNotFound(err) {
+		return nil, httperror.InternalServerError("Unable to remove the snapshot from the database", err)
 	}
 
-	handler.ProxyManager.DeleteEndpointProxy(endpoint.ID)
-
 	if len(endpoint.UserAccessPolicies) > 0 || len(endpoint.TeamAccessPolicies) > 0 {
 		if err := handler.AuthorizationService.UpdateUsersAuthorizationsTx(tx); err != nil {
-			log.Warn().Err(err).Msg("Unable to update user authorizations")
+			return nil, httperror.InternalServerError("Unable to update user authorizations", err)
 		}
 	}
 
-	if err := tx.EndpointRelation().DeleteEndpointRelation(endpoint.ID); err != nil {
-		log.Warn().Err(err).Msg("Unable to remove environment relation from the database")
+	if err := tx.EndpointRelation().DeleteEndpointRelation(endpoint.ID); err != nil && !tx.IsErrObjectNotFound(err) {
+		return nil, httperror.InternalServerError("Unable to remove environment relation from the database", err)
 	}
 
 	for _, tagID := range endpoint.TagIDs {
-		var tag *portainer.Tag
-		tag, err = tx.Tag().Read(tagID)
-		if err == nil {
-			delete(tag.Endpoints, endpoint.ID)
-			err = tx.Tag().Update(tagID, tag)
-		}
-
-		if handler.DataStore.IsErrObjectNotFound(err) {
+		tag, err := tx.Tag().Read(tagID)
+		if tx.IsErrObjectNotFound(err) {
 			log.Warn().Err(err).Msg("Unable to find tag inside the database")
+
+			continue
 		} else if err != nil {
-			log.Warn().Err(err).Msg("Unable to delete tag relation from the database")
+			return nil, httperror.InternalServerError("Unable to retrieve tag from the database", err)
+		}
+
+		delete(tag.Endpoints, endpoint.ID)
+
+		if err := tx.Tag().Update(tagID, tag); err != nil {
+			return nil, httperror.InternalServerError("Unable to delete tag relation from the database", err)
 		}
 	}
 
 	edgeGroups, err := tx.EdgeGroup().ReadAll()
 	if err != nil {
-		log.Warn().Err(err).Msgf("Unable to retrieve edge groups from the database")
+		return nil, httperror.InternalServerError("Unable to retrieve edge groups from the database", err)
 	}
 
 	for _, edgeGroup := range edgeGroups {
+		if !slices.Contains(edgeGroup.Endpoints, endpoint.ID) {
+			continue
+		}
+
 		edgeGroup.Endpoints = slices.DeleteFunc(edgeGroup.Endpoints, func(e portainer.EndpointID) bool {
 			return e == endpoint.ID
 		})
 
 		if err := tx.EdgeGroup().Update(edgeGroup.ID, &edgeGroup); err != nil {
-			log.Warn().Err(err).Msg("Unable to update edge group")
+			return nil, httperror.InternalServerError("Unable to update edge group", err)
 		}
 	}
 
 	edgeStacks, err := tx.EdgeStack().EdgeStacks()
 	if err != nil {
-		log.Warn().Err(err).Msg("Unable to retrieve edge stacks from the database")
+		return nil, httperror.InternalServerError("Unable to retrieve edge stacks from the database", err)
 	}
 
 	for idx := range edgeStacks {
@@ -199,14 +207,14 @@ func (handler *Handler) deleteEndpoint(tx dataservices.DataStoreTx, endpointID p
 			delete(edgeStack.Status, endpoint.ID)
 
 			if err := tx.EdgeStack().UpdateEdgeStack(edgeStack.ID, edgeStack); err != nil {
-				log.Warn().Err(err).Msg("Unable to update edge stack")
+				return nil, httperror.InternalServerError("Unable to update edge stack", err)
 			}
 		}
 	}
 
 	registries, err := tx.Registry().ReadAll()
 	if err != nil {
-		log.Warn().Err(err).Msg("Unable to retrieve registries from the database")
+		return nil, httperror.InternalServerError("Unable to retrieve registries from the database", err)
 	}
 
 	for idx := range registries {
@@ -215,15 +223,15 @@ func (handler *Handler) deleteEndpoint(tx dataservices.DataStoreTx, endpointID p
 			delete(registry.RegistryAccesses, endpoint.ID)
 
 			if err := tx.Registry().Update(registry.ID, registry); err != nil {
-				log.Warn().Err(err).Msg("Unable to update registry accesses")
+				return nil, httperror.InternalServerError("Unable to update registry accesses", err)
 			}
 		}
 	}
 
 	if endpointutils.IsEdgeEndpoint(endpoint) {
-		edgeJobs, err := handler.DataStore.EdgeJob().ReadAll()
+		edgeJobs, err := tx.EdgeJob().ReadAll()
 		if err != nil {
-			log.Warn().Err(err).Msg("Unable to retrieve edge jobs from the database")
+			return nil, httperror.InternalServerError("Unable to retrieve edge jobs from the database", err)
 		}
 
 		for idx := range edgeJobs {
@@ -232,7 +240,7 @@ func (handler *Handler) deleteEndpoint(tx dataservices.DataStoreTx, endpointID p
 				delete(edgeJob.Endpoints, endpoint.ID)
 
 				if err := tx.EdgeJob().Update(edgeJob.ID, edgeJob); err != nil {
-					log.Warn().Err(err).Msg("Unable to update edge job")
+					return nil, httperror.InternalServerError("Unable to update edge job", err)
 				}
 			}
 		}
@@ -240,12 +248,24 @@ func (handler *Handler) deleteEndpoint(tx dataservices.DataStoreTx, endpointID p
 
 	// delete the pending actions
 	if err := tx.PendingActions().DeleteByEndpointID(endpoint.ID); err != nil {
-		log.Warn().Err(err).Int("endpointId", int(endpoint.ID)).Msg("Unable to delete pending actions")
+		return nil, httperror.InternalServerError("Unable to delete pending actions", err)
 	}
 
 	if err := tx.Endpoint().DeleteEndpoint(endpointID); err != nil {
-		return httperror.InternalServerError("Unable to delete the environment from the database", err)
+		return nil, httperror.InternalServerError("Unable to delete the environment from the database", err)
 	}
 
-	return nil
+	return endpoint, nil
+}
+
+// cleanUpDeletedEndpoint removes the TLS files and the proxy of a deleted environment
+func (handler *Handler) cleanUpDeletedEndpoint(endpoint *portainer.Endpoint) {
+	if endpoint.TLSConfig.TLS {
+		folder := strconv.Itoa(int(endpoint.ID))
+		if err := handler.FileService.DeleteTLSFiles(folder); err != nil {
+			log.Error().Err(err).Msgf("Unable to remove TLS files from disk when deleting endpoint %d", endpoint.ID)
+		}
+	}
+
+	handler.ProxyManager.DeleteEndpointProxy(endpoint.ID)
 }
@@ -1,16 +1,23 @@
 package endpoints
 
 import (
+	"context"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"strconv"
+	"strings"
 	"sync"
 	"testing"
 
 	portainer "github.com/portainer/portainer/api"
+	"github.com/portainer/portainer/api/dataservices"
 	"github.com/portainer/portainer/api/datastore"
 	"github.com/portainer/portainer/api/http/proxy"
 	"github.com/portainer/portainer/api/internal/testhelpers"
+
+	"github.com/segmentio/encoding/json"
+	"github.com/stretchr/testify/require"
 )
 
 func TestEndpointDeleteEdgeGroupsConcurrently(t *testing.T) {
@@ -21,7 +28,7 @@ func TestEndpointDeleteEdgeGroupsConcurrently(t *testing.T) {
 	handler := NewHandler(testhelpers.NewTestRequestBouncer())
 	handler.DataStore = store
 	handler.ProxyManager = proxy.NewManager(nil)
-	handler.ProxyManager.NewProxyFactory(nil, nil, nil, nil, nil, nil, nil, nil)
+	handler.ProxyManager.NewProxyFactory(nil, nil, nil, nil, nil, nil, nil, nil, nil)
 
 	// Create all the environments and add them to the same edge group
 
@@ -82,3 +89,78 @@ func TestEndpointDeleteEdgeGroupsConcurrently(t *testing.T) {
 		t.Fatal("the edge group is not consistent")
 	}
 }
+
+var errRetry = errors.New("retry")
+
+// retryingStore rolls back the first attempt of every transaction and
+// executes it again, as after a serialization failure
+type retryingStore struct {
+	dataservices.DataStore
+}
+
+func (s retryingStore) UpdateTxRetryable(ctx context.Context, fn func(dataservices.DataStoreTx) error) error {
+	if err := s.DataStore.UpdateTxRetryable(ctx, func(tx dataservices.DataStoreTx) error {
+		if err := fn(tx); err != nil {
+			return err
+		}
+
+		return errRetry
+	}); !errors.Is(err, errRetry) {
+		return err
+	}
+
+	return s.DataStore.UpdateTxRetryable(ctx, fn)
+}
+
+type tlsFileService struct {
+	portainer.FileService
+	deleted []string
+}
+
+func (s *tlsFileService) DeleteTLSFiles(folder string) error {
+	s.deleted = append(s.deleted, folder)
+
+	return nil
+}
+
+func TestEndpointDeleteRetried(t *testing.T) {
+	is := require.New(t)
+
+	_, store := datastore.MustNewTestStore(t, true, false)
+
+	fileService := &tlsFileService{}
+
+	handler := NewHandler(testhelpers.NewTestRequestBouncer())
+	handler.DataStore = retryingStore{DataStore: store}
+	handler.FileService = fileService
+	handler.ProxyManager = proxy.NewManager(nil)
+	handler.ProxyManager.NewProxyFactory(nil, nil, nil, nil, nil, nil, nil, nil, nil)
+
+	for i := 1; i <= 3; i++ {
+		is.NoError(store.Endpoint().Create(&portainer.Endpoint{
+			ID:        portainer.EndpointID(i),
+			Name:      "env-" + strconv.Itoa(i),
+			Type:      portainer.DockerEnvironment,
+			TLSConfig: portainer.TLSConfiguration{TLS: true},
+		}))
+	}
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, "/endpoints", strings.NewReader(`{"endpoints":[{"id":1},{"id":2},{"id":4}]}`)))
+	is.Equal(http.StatusPartialContent, rec.Code)
+
+	var resp endpointDeleteBatchPartialResponse
+	is.NoError(json.NewDecoder(rec.Body).Decode(&resp))
+	is.Equal([]int{1, 2}, resp.Deleted, "the retried deletions are reported once")
+	is.Equal([]int{4}, resp.Errors)
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, "/endpoints/3", nil))
+	is.Equal(http.StatusNoContent, rec.Code)
+
+	is.Equal([]string{"1", "2", "3"}, fileService.deleted, "the files are removed once the deletion is committed")
+
+	endpoints, err := store.Endpoint().Endpoints()
+	is.NoError(err)
+	is.Empty(endpoints)
+}
@@ -1,12 +1,16 @@
 package endpoints
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 
 	portainer "github.com/portainer/portainer/api"
+	"github.com/portainer/portainer/api/database/query"
+	"github.com/portainer/portainer/api/dataservices"
 	"github.com/portainer/portainer/api/http/security"
 	"github.com/portainer/portainer/api/internal/endpointutils"
+	"github.com/portainer/portainer/api/internal/snapshot"
 	httperror "github.com/portainer/portainer/pkg/libhttp/error"
 	"github.com/portainer/portainer/pkg/libhttp/request"
 	"github.com/portainer/portainer/pkg/libhttp/response"
@@ -29,6 +33,7 @@ const (
 // @produce json
 // @param start query int false "Start searching from"
 // @param limit query int false "Limit results to this value"
+// @param cursor query string false "Start after the environment the cursor was returned for, it replaces start. The cursor of the next page is returned in the X-Next-Cursor header. It cannot be used with the Group and LastCheckIn sorts, nor with the status and edgeCheckInPassedSeconds filters"
 // @param sort query sortKey false "Sort results by this value" Enum("Name", "Group", "Status", "LastCheckIn", "EdgeID")
 // @param order query int false "Order sorted results by desc/asc" Enum("asc", "desc")
 // @param search query string false "Search query"
@@ -48,6 +53,8 @@ const (
 // @param edgeStackId query portainer.EdgeStackID false "will return the environements of the specified edge stack"
 // @param edgeStackStatus query string false "only applied when edgeStackId exists. Filter the returned environments based on their deployment status in the stack (not the environment status!)" Enum("Pending", "Ok", "Error", "Acknowledged", "Remove", "RemoteUpdateSuccess", "ImagesPulled")
 // @success 200 {array} portainer.Endpoint "Endpoints"
+// @header 200 {string} X-Next-Cursor "The cursor of the next page"
+// @failure 400 "Invalid query parameters"
 // @failure 500 "Server error"
 // @router /endpoints [get]
 func (handler *Handler) endpointList(w http.ResponseWriter, r *http.Request) *httperror.HandlerError {
@@ -57,51 +64,109 @@ func (handler *Handler) endpointList(w http.ResponseWriter, r *http.Request) *ht
 	}
 
 	limit, _ := request.RetrieveNumericQueryParameter(r, "limit", true)
+	cursor, _ := request.RetrieveQueryParameter(r, "cursor", true)
 	sortField, _ := request.RetrieveQueryParameter(r, "sort", true)
 	sortOrder, _ := request.RetrieveQueryParameter(r, "order", true)
 
-	endpointGroups, err := handler.DataStore.EndpointGroup().ReadAll()
+	securityContext, err := security.RetrieveRestrictedRequestContext(r)
 	if err != nil {
-		return httperror.InternalServerError("Unable to retrieve environment groups from the database", err)
+		return httperror.InternalServerError("Unable to retrieve info from request context", err)
 	}
 
-	edgeGroups, err := handler.DataStore.EdgeGroup().ReadAll()
+	environmentsQuery, err := parseQuery(r)
 	if err != nil {
-		return httperror.InternalServerError("Unable to retrieve edge groups from the database", err)
+		return httperror.BadRequest("Invalid query parameters", err)
 	}
 
-	endpoints, err := handler.DataStore.Endpoint().Endpoints()
-	if err != nil {
-		return httperror.InternalServerError("Unable to retrieve environments from the database", err)
-	}
+	sort := getSortKey(sortField)
+	pageSort, paged := storeSort(sort, sortOrder == "desc")
 
-	settings, err := handler.DataStore.Settings().Settings()
-	if err != nil {
-		return httperror.InternalServerError("Unable to retrieve settings from the database", err)
+	// The heartbeats are not stored with the environments, the filters and
+	// sorts relying on them are applied once the environments are read
+	paged = paged && len(environmentsQuery.status) == 0 && environmentsQuery.edgeCheckInPassedSeconds == 0
+	if !paged && cursor != "" {
+		return httperror.BadRequest("Invalid query parameters", errors.New("the cursor cannot be used with this sort or filter"))
 	}
 
-	securityContext, err := security.RetrieveRestrictedRequestContext(r)
-	if err != nil {
-		return httperror.InternalServerError("Unable to retrieve info from request context", err)
-	}
+	// The listing is served by a read replica when one is configured
+	ctx := portainer.WithReadReplica(r.Context())
 
-	query, err := parseQuery(r)
-	if err != nil {
-		return httperror.BadRequest("Invalid query parameters", err)
-	}
+	var endpointGroups []portainer.EndpointGroup
+	var endpoints []portainer.Endpoint
+	var settings *portainer.Settings
+	var pageInfo query.PageInfo
+	var totalAvailableEndpoints int
 
-	filteredEndpoints := security.FilterEndpoints(endpoints, endpointGroups, securityContext)
+	if err := handler.DataStore.ViewTxCtx(ctx, func(tx dataservices.DataStoreTx) error {
+		var err error
 
-	filteredEndpoints, totalAvailableEndpoints, err := handler.filterEndpointsByQuery(filteredEndpoints, query, endpointGroups, edgeGroups, settings)
-	if err != nil {
-		return httperror.InternalServerError("Unable to filter endpoints", err)
+		endpointGroups, err = tx.EndpointGroup().ReadAll()
+		if err != nil {
+			return httperror.InternalServerError("Unable to retrieve environment groups from the database", err)
+		}
+
+		edgeGroups, err := tx.EdgeGroup().ReadAll()
+		if err != nil {
+			return httperror.InternalServerError("Unable to retrieve edge groups from the database", err)
+		}
+
+		settings, err = tx.Settings().Settings()
+		if err != nil {
+			return httperror.InternalServerError("Unable to retrieve settings from the database", err)
+		}
+
+		access := security.EndpointsAccessFilter(endpointGroups, securityContext)
+
+		totalAvailableEndpoints, err = tx.Endpoint().CountEndpoints(access)
+		if err != nil {
+			return httperror.InternalServerError("Unable to count environments in the database", err)
+		}
+
+		filter, err := handler.environmentsFilter(tx, environmentsQuery, endpointGroups, edgeGroups)
+		if err != nil {
+			return httperror.InternalServerError("Unable to filter endpoints", err)
+		}
+
+		page := query.Page{Filter: query.All(access, filter), Sort: pageSort}
+		if paged {
+			page.Offset = start
+			page.Limit = limit
+			page.Cursor = cursor
+		}
+
+		endpoints, pageInfo, err = tx.Endpoint().EndpointsPage(page)
+		if errors.Is(err, query.ErrInvalidCursor) {
+			return httperror.BadRequest("Invalid query parameters", err)
+		} else if err != nil {
+			return httperror.InternalServerError("Unable to retrieve environments from the database", err)
+		}
+
+		return nil
+	}); err != nil {
+		var httpErr *httperror.HandlerError
+		if errors.As(err, &httpErr) {
+			return httpErr
+		}
+
+		return httperror.InternalServerError("Unable to read environments from the database", err)
+	}
+
+	// The heartbeats are synchronized with the store in the background, outside of the transaction
+	for i := range endpoints {
+		endpoints[i].LastCheckInDate, _ = handler.DataStore.Endpoint().Heartbeat(endpoints[i].ID)
 	}
 
-	sortEnvironmentsByField(filteredEndpoints, endpointGroups, getSortKey(sortField), sortOrder == "desc")
+	paginatedEndpoints := security.FilterEndpoints(endpoints, endpointGroups, securityContext)
+	filteredEndpointCount := pageInfo.Total
 
-	filteredEndpointCount := len(filteredEndpoints)
+	if !paged {
+		paginatedEndpoints = filterEndpointsByHeartbeats(paginatedEndpoints, environmentsQuery, settings)
 
-	paginatedEndpoints := paginateEndpoints(filteredEndpoints, start, limit)
+		sortEnvironmentsByField(paginatedEndpoints, endpointGroups, sort, sortOrder == "desc")
+
+		filteredEndpointCount = len(paginatedEndpoints)
+		paginatedEndpoints = paginateEndpoints(paginatedEndpoints, start, limit)
+	}
 
 	for idx := range paginatedEndpoints {
 		hideFields(&paginatedEndpoints[idx])
@@ -110,19 +175,48 @@ func (handler *Handler) endpointList(w http.ResponseWriter, r *http.Request) *ht
 			paginatedEndpoints[idx].EdgeCheckinInterval = settings.EdgeAgentCheckinInterval
 		}
 		endpointutils.UpdateEdgeEndpointHeartbeat(&paginatedEndpoints[idx], settings)
-		if !query.excludeSnapshots {
-			err = handler.SnapshotService.FillSnapshotData(&paginatedEndpoints[idx])
-			if err != nil {
-				return httperror.InternalServerError("Unable to add snapshot data", err)
+	}
+
+	if !environmentsQuery.excludeSnapshots {
+		if err := handler.DataStore.ViewTxCtx(ctx, func(tx dataservices.DataStoreTx) error {
+			for idx := range paginatedEndpoints {
+				if err := snapshot.FillSnapshotData(tx, &paginatedEndpoints[idx]); err != nil {
+					return err
+				}
 			}
+
+			return nil
+		}); err != nil {
+			return httperror.InternalServerError("Unable to add snapshot data", err)
 		}
 	}
 
+	if pageInfo.Next != "" {
+		w.Header().Set("X-Next-Cursor", pageInfo.Next)
+	}
+
 	w.Header().Set("X-Total-Count", strconv.Itoa(filteredEndpointCount))
 	w.Header().Set("X-Total-Available", strconv.Itoa(totalAvailableEndpoints))
 	return response.JSON(w, paginatedEndpoints)
 }
 
+// storeSort returns the sort applied by the store, paged is false when the
+// environments are sorted once they are read
+func storeSort(sort sortKey, desc bool) (pageSort query.Sort, paged bool) {
+	switch sort {
+	case "":
+		return query.Sort{}, true
+	case sortKeyName:
+		return query.Sort{Field: "Name", Text: true, Desc: desc}, true
+	case sortKeyEdgeID:
+		return query.Sort{Field: "EdgeID", Text: true, Desc: desc}, true
+	case sortKeyStatus:
+		return query.Sort{Field: "Status", Desc: desc}, true
+	}
+
+	return query.Sort{}, false
+}
+
 func paginateEndpoints(endpoints []portainer.Endpoint, start, limit int) []portainer.Endpoint {
 	if limit == 0 {
 		return endpoints
@@ -179,6 +179,78 @@ func Test_endpointList_edgeFilter(t *testing.T) {
 	}
 }
 
+func Test_endpointList_cursor(t *testing.T) {
+	is := assert.New(t)
+
+	handler := setupEndpointListHandler(t, []portainer.Endpoint{
+		{ID: 1, Name: "delta", GroupID: 1, Type: portainer.DockerEnvironment},
+		{ID: 2, Name: "Alpha", GroupID: 1, Type: portainer.DockerEnvironment},
+		{ID: 3, Name: "charlie", GroupID: 1, Type: portainer.DockerEnvironment},
+		{ID: 4, Name: "bravo", GroupID: 1, Type: portainer.DockerEnvironment},
+		{ID: 5, Name: "echo", GroupID: 1, Type: portainer.DockerEnvironment},
+	})
+
+	respIds := []portainer.EndpointID{}
+	cursor := ""
+	for range 3 {
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, buildEndpointListRequest("sort=Name&limit=2&cursor="+cursor))
+		is.Equal(http.StatusOK, rr.Code)
+		is.Equal("5", rr.Header().Get("X-Total-Count"))
+
+		resp := []portainer.Endpoint{}
+		is.NoError(json.NewDecoder(rr.Body).Decode(&resp))
+
+		for _, endpoint := range resp {
+			respIds = append(respIds, endpoint.ID)
+		}
+
+		cursor = rr.Header().Get("X-Next-Cursor")
+	}
+
+	is.Equal([]portainer.EndpointID{2, 4, 3, 1, 5}, respIds)
+	is.Empty(cursor, "the last page has no next cursor")
+
+	// Invalid cursors are rejected, as are the cursors of the sorts applied in memory
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, buildEndpointListRequest("limit=2&cursor=invalid"))
+	is.Equal(http.StatusBadRequest, rr.Code)
+
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, buildEndpointListRequest("sort=LastCheckIn&limit=2&cursor=invalid"))
+	is.Equal(http.StatusBadRequest, rr.Code)
+}
+
+func Test_endpointList_naturalSort(t *testing.T) {
+	is := assert.New(t)
+
+	handler := setupEndpointListHandler(t, []portainer.Endpoint{
+		{ID: 1, Name: "env10", GroupID: 1, Type: portainer.DockerEnvironment},
+		{ID: 2, Name: "env2", GroupID: 1, Type: portainer.DockerEnvironment},
+		{ID: 3, Name: "env1", GroupID: 1, Type: portainer.DockerEnvironment},
+	})
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, buildEndpointListRequest("sort=Name&limit=2"))
+	is.Equal(http.StatusOK, rr.Code)
+
+	resp := []portainer.Endpoint{}
+	is.NoError(json.NewDecoder(rr.Body).Decode(&resp))
+	is.Len(resp, 2)
+	is.Equal([]string{"env1", "env2"}, []string{resp[0].Name, resp[1].Name})
+
+	cursor := rr.Header().Get("X-Next-Cursor")
+
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, buildEndpointListRequest("sort=Name&limit=2&cursor="+cursor))
+	is.Equal(http.StatusOK, rr.Code)
+
+	resp = []portainer.Endpoint{}
+	is.NoError(json.NewDecoder(rr.Body).Decode(&resp))
+	is.Len(resp, 1)
+	is.Equal("env10", resp[0].Name)
+}
+
 func setupEndpointListHandler(t *testing.T, endpoints []portainer.Endpoint) *Handler {
 	is := assert.New(t)
 	_, store := datastore.MustNewTestStore(t, true, true)
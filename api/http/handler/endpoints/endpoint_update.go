@@ -2,6 +2,7 @@ package endpoints
 
 import (
 	"cmp"
+	"errors"
 	"net/http"
 	"reflect"
 	"strconv"
@@ -10,6 +11,8 @@ import (
 	portainer "github.com/portainer/portainer/api"
 	"github.com/portainer/portainer/api/dataservices"
 	"github.com/portainer/portainer/api/http/client"
+	"github.com/portainer/portainer/api/http/etag"
+	"github.com/portainer/portainer/api/internal/authorization"
 	"github.com/portainer/portainer/api/internal/endpointutils"
 	"github.com/portainer/portainer/api/pendingactions/handlers"
 	httperror "github.com/portainer/portainer/pkg/libhttp/error"
@@ -69,11 +72,13 @@ func (payload *endpointUpdatePayload) Validate(r *http.Request) error {
 // @accept json
 // @produce json
 // @param id path int true "Environment(Endpoint) identifier"
+// @param If-Match header string false "Entity tag returned when the environment was read, the update is rejected when the environment changed since"
 // @param body body endpointUpdatePayload true "Environment(Endpoint) details"
 // @success 200 {object} portainer.Endpoint "Success"
 // @failure 400 "Invalid request"
 // @failure 404 "Environment(Endpoint) not found"
 // @failure 409 "Name is not unique"
+// @failure 412 "The environment changed since it was read"
 // @failure 500 "Server error"
 // @router /endpoints/{id} [put]
 func (handler *Handler) endpointUpdate(w http.ResponseWriter, r *http.Request) *httperror.HandlerError {
@@ -94,6 +99,10 @@ func (handler *Handler) endpointUpdate(w http.ResponseWriter, r *http.Request) *
 		return httperror.InternalServerError("Unable to find an environment with the specified identifier inside the database", err)
 	}
 
+	if err := etag.CheckIfMatch(r, endpoint); err != nil {
+		return err
+	}
+
 	updateEndpointProxy := shouldReloadTLSConfiguration(endpoint, &payload)
 
 	if payload.Name != nil {
@@ -129,7 +138,7 @@ func (handler *Handler) endpointUpdate(w http.ResponseWriter, r *http.Request) *
 	}
 
 	if payload.TagIDs != nil {
-		if err := handler.DataStore.UpdateTx(func(tx dataservices.DataStoreTx) error {
+		if err := handler.DataStore.UpdateTxCtx(r.Context(), func(tx dataservices.DataStoreTx) error {
 			tagsChanged, err := updateEnvironmentTags(tx, payload.TagIDs, endpoint.TagIDs, endpoint.ID)
 			if err != nil {
 				return err
@@ -155,6 +164,12 @@ func (handler *Handler) endpointUpdate(w http.ResponseWriter, r *http.Request) *
 		endpoint.Kubernetes = *payload.Kubernetes
 	}
 
+	if err := authorization.ValidateAccessPolicies(handler.DataStore, payload.UserAccessPolicies, payload.TeamAccessPolicies); errors.Is(err, authorization.ErrUnknownRole) {
+		return httperror.BadRequest("Invalid access policies", err)
+	} else if err != nil {
+		return httperror.InternalServerError("Unable to retrieve the roles from the database", err)
+	}
+
 	if payload.UserAccessPolicies != nil && !reflect.DeepEqual(payload.UserAccessPolicies, endpoint.UserAccessPolicies) {
 		updateAuthorizations = true
 		endpoint.UserAccessPolicies = payload.UserAccessPolicies
@@ -264,8 +279,16 @@ func (handler *Handler) endpointUpdate(w http.ResponseWriter, r *http.Request) *
 		return httperror.InternalServerError("Unable to persist environment changes inside the database", err)
 	}
 
+	if updateAuthorizations {
+		if err := handler.AuthorizationService.UpdateUsersAuthorizations(); err != nil {
+			return httperror.InternalServerError("Unable to update user authorizations", err)
+		}
+	}
+
+	etag.Set(w, endpoint)
+
 	if updateRelations {
-		if err := handler.DataStore.UpdateTx(func(tx dataservices.DataStoreTx) error {
+		if err := handler.DataStore.UpdateTxCtx(r.Context(), func(tx dataservices.DataStoreTx) error {
 			return handler.updateEdgeRelations(tx, endpoint)
 		}); err != nil {
 			return httperror.InternalServerError("Unable to update environment relations", err)
@@ -53,7 +53,7 @@ func (handler *Handler) updateRelations(w http.ResponseWriter, r *http.Request)
 		return httperror.BadRequest("Invalid request payload", err)
 	}
 
-	err = handler.DataStore.UpdateTx(func(tx dataservices.DataStoreTx) error {
+	err = handler.DataStore.UpdateTxCtx(r.Context(), func(tx dataservices.DataStoreTx) error {
 		for environmentID, relationPayload := range payload.Relations {
 			endpoint, err := tx.Endpoint().Endpoint(environmentID)
 			if err != nil {
@@ -168,19 +168,7 @@ func (handler *Handler) filterEndpointsByQuery(
 	})
 
 	if query.edgeCheckInPassedSeconds > 0 {
-		filteredEndpoints = filter(filteredEndpoints, func(endpoint portainer.Endpoint) bool {
-			// ignore non-edge endpoints
-			if !endpointutils.IsEdgeEndpoint(&endpoint) {
-				return true
-			}
-
-			// filter out endpoints that have never checked in
-			if endpoint.LastCheckInDate == 0 {
-				return false
-			}
-
-			return time.Now().Unix()-endpoint.LastCheckInDate < int64(query.edgeCheckInPassedSeconds)
-		})
+		filteredEndpoints = filterEndpointsByCheckIn(filteredEndpoints, query.edgeCheckInPassedSeconds)
 	}
 
 	if len(query.status) > 0 {
@@ -242,7 +230,18 @@ func endpointStatusInStackMatchesFilter(edgeStackStatus map[portainer.EndpointID
 	})
 }
 
-func filterEndpointsByEdgeStack(endpoints []portainer.Endpoint, edgeStackId portainer.EdgeStackID, statusFilter *portainer.EdgeStackStatusType, datastore dataservices.DataStore) ([]portainer.Endpoint, error) {
+func filterEndpointsByEdgeStack(endpoints []portainer.Endpoint, edgeStackId portainer.EdgeStackID, statusFilter *portainer.EdgeStackStatusType, datastore dataservices.DataStoreTx) ([]portainer.Endpoint, error) {
+	envIds, err := edgeStackEndpointIDs(datastore, edgeStackId, statusFilter)
+	if err != nil {
+		return nil, err
+	}
+
+	return filteredEndpointsByIds(endpoints, envIds), nil
+}
+
+// edgeStackEndpointIDs returns the environments targeted by an edge stack,
+// restricted to the ones whose deployment status matches statusFilter
+func edgeStackEndpointIDs(datastore dataservices.DataStoreTx, edgeStackId portainer.EdgeStackID, statusFilter *portainer.EdgeStackStatusType) ([]portainer.EndpointID, error) {
 	stack, err := datastore.EdgeStack().EdgeStack(edgeStackId)
 	if err != nil {
 		return nil, errors.WithMessage(err, "Unable to retrieve edge stack from the database")
@@ -277,10 +276,7 @@ func filterEndpointsByEdgeStack(endpoints []portainer.Endpoint, edgeStackId port
 		envIds = envIds[:n]
 	}
 
-	uniqueIds := slicesx.Unique(envIds)
-	filteredEndpoints := filteredEndpointsByIds(endpoints, uniqueIds)
-
-	return filteredEndpoints, nil
+	return slicesx.Unique(envIds), nil
 }
 
 func filterEndpointsByGroupIDs(endpoints []portainer.Endpoint, endpointGroupIDs []portainer.EndpointGroupID) []portainer.Endpoint {
@@ -0,0 +1,216 @@
+package endpoints
+
+import (
+	"slices"
+	"strings"
+	"time"
+
+	portainer "github.com/portainer/portainer/api"
+	"github.com/portainer/portainer/api/database/query"
+	"github.com/portainer/portainer/api/dataservices"
+	"github.com/portainer/portainer/api/internal/endpointutils"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	edgeEnvironmentTypes = []portainer.EndpointType{
+		portainer.EdgeAgentOnDockerEnvironment,
+		portainer.EdgeAgentOnKubernetesEnvironment,
+	}
+
+	agentEnvironmentTypes = []portainer.EndpointType{
+		portainer.AgentOnDockerEnvironment,
+		portainer.EdgeAgentOnDockerEnvironment,
+		portainer.AgentOnKubernetesEnvironment,
+		portainer.EdgeAgentOnKubernetesEnvironment,
+	}
+)
+
+// environmentsFilter translates the query into a filter evaluated by the store.
+// The status of the edge environments depends on their heartbeat, the filters
+// relying on it are applied by filterEndpointsByHeartbeats once the
+// environments are read.
+func (handler *Handler) environmentsFilter(
+	tx dataservices.DataStoreTx,
+	q EnvironmentsQuery,
+	groups []portainer.EndpointGroup,
+	edgeGroups []portainer.EdgeGroup,
+) (query.Filter, error) {
+	filters := make([]query.Filter, 0)
+
+	if len(q.endpointIds) > 0 {
+		filters = append(filters, query.In("Id", q.endpointIds))
+	}
+
+	if len(q.excludeIds) > 0 {
+		filters = append(filters, query.Not(query.In("Id", q.excludeIds)))
+	}
+
+	if len(q.groupIds) > 0 {
+		filters = append(filters, query.In("GroupId", q.groupIds))
+	}
+
+	if q.name != "" {
+		filters = append(filters, query.Equals("Name", q.name))
+	}
+
+	notEdge := query.Not(query.In("Type", edgeEnvironmentTypes))
+
+	if q.edgeAsync != nil {
+		filters = append(filters, query.Any(notEdge, boolFilter("Edge.AsyncMode", *q.edgeAsync)))
+	}
+
+	filters = append(filters, query.Any(notEdge, boolFilter("UserTrusted", !q.edgeDeviceUntrusted)))
+
+	if len(q.status) > 0 {
+		filters = append(filters, query.Any(query.In("Type", edgeEnvironmentTypes), query.In("Status", q.status)))
+	}
+
+	if q.search != "" {
+		tags, err := tx.Tag().ReadAll()
+		if err != nil {
+			return query.Filter{}, errors.WithMessage(err, "Unable to retrieve tags from the database")
+		}
+
+		filters = append(filters, searchFilter(q.search, groups, edgeGroups, tags))
+	}
+
+	if len(q.types) > 0 {
+		filters = append(filters, query.In("Type", q.types))
+	}
+
+	if len(q.tagIds) > 0 {
+		filters = append(filters, tagsFilter(q.tagIds, q.tagsPartialMatch, groups))
+	}
+
+	if len(q.agentVersions) > 0 {
+		filters = append(filters, query.Any(query.Not(query.In("Type", agentEnvironmentTypes)), query.In("Agent.Version", q.agentVersions)))
+	}
+
+	if q.edgeStackId != 0 {
+		endpointIDs, err := edgeStackEndpointIDs(tx, q.edgeStackId, q.edgeStackStatus)
+		if err != nil {
+			return query.Filter{}, err
+		}
+
+		filters = append(filters, query.In("Id", endpointIDs))
+	}
+
+	return query.All(filters...), nil
+}
+
+// boolFilter matches the environments whose boolean field has the value, the
+// false booleans are omitted from the stored environments
+func boolFilter(field string, value bool) query.Filter {
+	if value {
+		return query.Equals(field, true)
+	}
+
+	return query.Not(query.Equals(field, true))
+}
+
+// searchFilter is the store counterpart of filterEndpointsBySearchCriteria
+func searchFilter(search string, groups []portainer.EndpointGroup, edgeGroups []portainer.EdgeGroup, tags []portainer.Tag) query.Filter {
+	filters := []query.Filter{
+		query.Search("Name", search),
+		query.Search("URL", search),
+	}
+
+	switch search {
+	case "up":
+		filters = append(filters, query.Equals("Status", portainer.EndpointStatusUp))
+	case "down":
+		filters = append(filters, query.Equals("Status", portainer.EndpointStatusDown))
+	}
+
+	matchedTags := make([]portainer.TagID, 0)
+	for _, tag := range tags {
+		if strings.Contains(strings.ToLower(tag.Name), search) {
+			matchedTags = append(matchedTags, tag.ID)
+			filters = append(filters, query.Contains("TagIds", tag.ID))
+		}
+	}
+
+	matchedGroups := make([]portainer.EndpointGroupID, 0)
+	for _, group := range groups {
+		if strings.Contains(strings.ToLower(group.Name), search) || slices.ContainsFunc(group.TagIDs, func(tagID portainer.TagID) bool {
+			return slices.Contains(matchedTags, tagID)
+		}) {
+			matchedGroups = append(matchedGroups, group.ID)
+		}
+	}
+	filters = append(filters, query.In("GroupId", matchedGroups))
+
+	for _, edgeGroup := range edgeGroups {
+		if !strings.Contains(strings.ToLower(edgeGroup.Name), search) {
+			continue
+		}
+
+		if !edgeGroup.Dynamic {
+			filters = append(filters, query.In("Id", edgeGroup.Endpoints))
+
+			continue
+		}
+
+		filters = append(filters, query.All(
+			query.In("Type", edgeEnvironmentTypes),
+			tagsFilter(edgeGroup.TagIDs, edgeGroup.PartialMatch, groups),
+		))
+	}
+
+	return query.Any(filters...)
+}
+
+// tagsFilter matches the environments associated with the tags directly or
+// through their group, with one of them when partialMatch is set and with all
+// of them otherwise
+func tagsFilter(tagIDs []portainer.TagID, partialMatch bool, groups []portainer.EndpointGroup) query.Filter {
+	filters := make([]query.Filter, 0, len(tagIDs))
+	for _, tagID := range tagIDs {
+		taggedGroups := make([]portainer.EndpointGroupID, 0)
+		for _, group := range groups {
+			if slices.Contains(group.TagIDs, tagID) {
+				taggedGroups = append(taggedGroups, group.ID)
+			}
+		}
+
+		filters = append(filters, query.Any(query.Contains("TagIds", tagID), query.In("GroupId", taggedGroups)))
+	}
+
+	if partialMatch {
+		return query.Any(filters...)
+	}
+
+	return query.All(filters...)
+}
+
+// filterEndpointsByHeartbeats applies the filters of the query relying on the
+// heartbeat of the edge environments
+func filterEndpointsByHeartbeats(endpoints []portainer.Endpoint, q EnvironmentsQuery, settings *portainer.Settings) []portainer.Endpoint {
+	if q.edgeCheckInPassedSeconds > 0 {
+		endpoints = filterEndpointsByCheckIn(endpoints, q.edgeCheckInPassedSeconds)
+	}
+
+	if len(q.status) > 0 {
+		endpoints = filterEndpointsByStatuses(endpoints, q.status, settings)
+	}
+
+	return endpoints
+}
+
+func filterEndpointsByCheckIn(endpoints []portainer.Endpoint, passedSeconds int) []portainer.Endpoint {
+	return filter(endpoints, func(endpoint portainer.Endpoint) bool {
+		// ignore non-edge endpoints
+		if !endpointutils.IsEdgeEndpoint(&endpoint) {
+			return true
+		}
+
+		// filter out endpoints that have never checked in
+		if endpoint.LastCheckInDate == 0 {
+			return false
+		}
+
+		return time.Now().Unix()-endpoint.LastCheckInDate < int64(passedSeconds)
+	})
+}
@@ -4,6 +4,7 @@ import (
 	"net/http"
 	"strings"
 	"fmt"
+	"github.com/portainer/portainer/api/http/handler/auditlogs"
 	"github.com/portainer/portainer/api/http/handler/auth"
 	"github.com/portainer/portainer/api/http/handler/backup"
 	"github.com/portainer/portainer/api/http/handler/customtemplates"
@@ -44,6 +45,7 @@ import (
 
 // Handler is a collection of all the service handlers.
 type Handler struct {
+	AuditLogHandler        *auditlogs.Handler
 	AuthHandler            *auth.Handler
 	BackupHandler          *backup.Handler
 	CustomTemplatesHandler *customtemplates.Handler
@@ -106,6 +108,8 @@ type Handler struct {
 // @in header
 // @name Authorization
 
+// @tag.name audit
+// @tag.description Query the audit log
 // @tag.name auth
 // @tag.description Authenticate against Portainer HTTP API
 // @tag.name backup
@@ -178,6 +182,8 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	switch {
 	case strings.HasPrefix(r.URL.Path, "/api/endpoints") && strings.Contains(r.URL.Path, "/edge/"):
 		h.EndpointEdgeHandler.ServeHTTP(w, r)
+	case strings.HasPrefix(r.URL.Path, "/api/audit"):
+		http.StripPrefix("/api", h.AuditLogHandler).ServeHTTP(w, r)
 	case strings.HasPrefix(r.URL.Path, "/api/auth"):
 		http.StripPrefix("/api", h.AuthHandler).ServeHTTP(w, r)
 	case strings.HasPrefix(r.URL.Path, "/api/backup"):
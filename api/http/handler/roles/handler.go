@@ -5,6 +5,7 @@ import (
 
 	"github.com/portainer/portainer/api/dataservices"
 	"github.com/portainer/portainer/api/http/security"
+	"github.com/portainer/portainer/api/internal/authorization"
 	httperror "github.com/portainer/portainer/pkg/libhttp/error"
 
 	"github.com/gorilla/mux"
@@ -13,7 +14,8 @@ import (
 // Handler is the HTTP handler used to handle role operations.
 type Handler struct {
 	*mux.Router
-	DataStore dataservices.DataStore
+	AuthorizationService *authorization.Service
+	DataStore            dataservices.DataStore
 }
 
 // NewHandler creates a handler to manage role operations.
@@ -23,6 +25,14 @@ func NewHandler(bouncer security.BouncerService) *Handler {
 	}
 	h.Handle("/roles",
 		bouncer.AdminAccess(httperror.LoggerHandler(h.roleList))).Methods(http.MethodGet)
+	h.Handle("/roles",
+		bouncer.AdminAccess(httperror.LoggerHandler(h.roleCreate))).Methods(http.MethodPost)
+	h.Handle("/roles/{id}",
+		bouncer.AdminAccess(httperror.LoggerHandler(h.roleInspect))).Methods(http.MethodGet)
+	h.Handle("/roles/{id}",
+		bouncer.AdminAccess(httperror.LoggerHandler(h.roleUpdate))).Methods(http.MethodPut)
+	h.Handle("/roles/{id}",
+		bouncer.AdminAccess(httperror.LoggerHandler(h.roleDelete))).Methods(http.MethodDelete)
 
 	return h
 }
@@ -0,0 +1,123 @@
+package roles
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	portainer "github.com/portainer/portainer/api"
+	"github.com/portainer/portainer/api/dataservices"
+	"github.com/portainer/portainer/api/internal/authorization"
+	httperror "github.com/portainer/portainer/pkg/libhttp/error"
+	"github.com/portainer/portainer/pkg/libhttp/request"
+	"github.com/portainer/portainer/pkg/libhttp/response"
+)
+
+var (
+	errInvalidName     = errors.New("invalid role name: cannot be empty")
+	errInvalidPriority = errors.New("invalid role priority: must be at least 1")
+	errBuiltInRole     = errors.New("the built-in roles cannot be changed")
+)
+
+type roleCreatePayload struct {
+	// Role name
+	Name string `validate:"required" example:"Deployer"`
+	// Role description
+	Description string `example:"Manages the stacks of an environment"`
+	// Authorizations granted by the role
+	Authorizations portainer.Authorizations `validate:"required"`
+	// When several roles apply to a user in an environment, the one with the highest priority applies
+	Priority int `validate:"required" example:"5"`
+}
+
+func (payload *roleCreatePayload) Validate(r *http.Request) error {
+	if strings.TrimSpace(payload.Name) == "" {
+		return errInvalidName
+	}
+
+	if payload.Priority < 1 {
+		return errInvalidPriority
+	}
+
+	return validateAuthorizations(payload.Authorizations)
+}
+
+// validateAuthorizations verifies that a role is built from known authorizations
+func validateAuthorizations(authorizations portainer.Authorizations) error {
+	for a := range authorizations {
+		if !authorization.IsRoleAuthorization(a) {
+			return fmt.Errorf("invalid role authorization: %s", a)
+		}
+	}
+
+	return nil
+}
+
+// @id RoleCreate
+// @summary Create a role
+// @description Create a role from a set of authorizations. The role can then be assigned
+// @description to users and teams by the access policies of environments and environment groups.
+// @description **Access policy**: administrator
+// @tags roles
+// @security ApiKeyAuth
+// @security jwt
+// @accept json
+// @produce json
+// @param body body roleCreatePayload true "Role details"
+// @success 200 {object} portainer.Role "Success"
+// @failure 400 "Invalid request"
+// @failure 409 "A role with the same name already exists"
+// @failure 500 "Server error"
+// @router /roles [post]
+func (handler *Handler) roleCreate(w http.ResponseWriter, r *http.Request) *httperror.HandlerError {
+	var payload roleCreatePayload
+	if err := request.DecodeAndValidateJSONPayload(r, &payload); err != nil {
+		return httperror.BadRequest("Invalid request payload", err)
+	}
+
+	role := &portainer.Role{
+		Name:           strings.TrimSpace(payload.Name),
+		Description:    payload.Description,
+		Authorizations: payload.Authorizations,
+		Priority:       payload.Priority,
+	}
+
+	if err := handler.DataStore.UpdateTxCtx(r.Context(), func(tx dataservices.DataStoreTx) error {
+		if err := checkUniqueName(tx, role); err != nil {
+			return err
+		}
+
+		return tx.Role().Create(role)
+	}); err != nil {
+		return txError(err, "Unable to persist the role inside the database")
+	}
+
+	return response.JSON(w, role)
+}
+
+// checkUniqueName verifies that no other role has the name of a role
+func checkUniqueName(tx dataservices.DataStoreTx, role *portainer.Role) error {
+	roles, err := tx.Role().ReadAll()
+	if err != nil {
+		return httperror.InternalServerError("Unable to retrieve the roles from the database", err)
+	}
+
+	for _, existing := range roles {
+		if existing.ID != role.ID && strings.EqualFold(existing.Name, role.Name) {
+			return httperror.Conflict("A role with the same name already exists", errors.New("role name is not unique"))
+		}
+	}
+
+	return nil
+}
+
+// txError returns the handler error of a transaction
+func txError(err error, message string) *httperror.HandlerError {
+	var httpErr *httperror.HandlerError
+	if errors.As(err, &httpErr) {
+		return httpErr
+	}
+
+	return httperror.InternalServerError(message, err)
+}
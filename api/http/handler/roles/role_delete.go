@@ -0,0 +1,65 @@
+package roles
+
+import (
+	"errors"
+	"net/http"
+
+	portainer "github.com/portainer/portainer/api"
+	"github.com/portainer/portainer/api/dataservices"
+	"github.com/portainer/portainer/api/internal/authorization"
+	httperror "github.com/portainer/portainer/pkg/libhttp/error"
+	"github.com/portainer/portainer/pkg/libhttp/request"
+	"github.com/portainer/portainer/pkg/libhttp/response"
+)
+
+// @id RoleDelete
+// @summary Remove a role
+// @description Remove a role. A role assigned by the access policies of environments or of
+// @description environment groups and the built-in roles cannot be removed.
+// @description **Access policy**: administrator
+// @tags roles
+// @security ApiKeyAuth
+// @security jwt
+// @param id path int true "Role identifier"
+// @success 204 "Success"
+// @failure 400 "Invalid request"
+// @failure 403 "The role is a built-in role"
+// @failure 404 "Role not found"
+// @failure 409 "The role is assigned"
+// @failure 500 "Server error"
+// @router /roles/{id} [delete]
+func (handler *Handler) roleDelete(w http.ResponseWriter, r *http.Request) *httperror.HandlerError {
+	roleID, err := request.RetrieveNumericRouteVariableValue(r, "id")
+	if err != nil {
+		return httperror.BadRequest("Invalid role identifier route variable", err)
+	}
+
+	if authorization.IsBuiltInRole(portainer.RoleID(roleID)) {
+		return httperror.Forbidden("The built-in roles cannot be removed", errBuiltInRole)
+	}
+
+	if err := handler.DataStore.UpdateTxCtx(r.Context(), func(tx dataservices.DataStoreTx) error {
+		if _, err := tx.Role().Read(portainer.RoleID(roleID)); tx.IsErrObjectNotFound(err) {
+			return httperror.NotFound("Unable to find a role with the specified identifier inside the database", err)
+		} else if err != nil {
+			return httperror.InternalServerError("Unable to find a role with the specified identifier inside the database", err)
+		}
+
+		assigned, err := authorization.RoleAssigned(tx, portainer.RoleID(roleID))
+		if err != nil {
+			return httperror.InternalServerError("Unable to retrieve the access policies from the database", err)
+		} else if assigned {
+			return httperror.Conflict("The role is assigned by access policies, remove them first", errors.New("role is assigned"))
+		}
+
+		if err := tx.Role().Delete(portainer.RoleID(roleID)); err != nil {
+			return httperror.InternalServerError("Unable to remove the role from the database", err)
+		}
+
+		return nil
+	}); err != nil {
+		return txError(err, "Unable to remove the role")
+	}
+
+	return response.Empty(w)
+}
@@ -0,0 +1,40 @@
+package roles
+
+import (
+	"net/http"
+
+	portainer "github.com/portainer/portainer/api"
+	httperror "github.com/portainer/portainer/pkg/libhttp/error"
+	"github.com/portainer/portainer/pkg/libhttp/request"
+	"github.com/portainer/portainer/pkg/libhttp/response"
+)
+
+// @id RoleInspect
+// @summary Inspect a role
+// @description Retrieve details about a role.
+// @description **Access policy**: administrator
+// @tags roles
+// @security ApiKeyAuth
+// @security jwt
+// @produce json
+// @param id path int true "Role identifier"
+// @success 200 {object} portainer.Role "Success"
+// @failure 400 "Invalid request"
+// @failure 404 "Role not found"
+// @failure 500 "Server error"
+// @router /roles/{id} [get]
+func (handler *Handler) roleInspect(w http.ResponseWriter, r *http.Request) *httperror.HandlerError {
+	roleID, err := request.RetrieveNumericRouteVariableValue(r, "id")
+	if err != nil {
+		return httperror.BadRequest("Invalid role identifier route variable", err)
+	}
+
+	role, err := handler.DataStore.Role().Read(portainer.RoleID(roleID))
+	if handler.DataStore.IsErrObjectNotFound(err) {
+		return httperror.NotFound("Unable to find a role with the specified identifier inside the database", err)
+	} else if err != nil {
+		return httperror.InternalServerError("Unable to find a role with the specified identifier inside the database", err)
+	}
+
+	return response.JSON(w, role)
+}
@@ -0,0 +1,134 @@
+package roles
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	portainer "github.com/portainer/portainer/api"
+	"github.com/portainer/portainer/api/datastore"
+	"github.com/portainer/portainer/api/internal/authorization"
+	"github.com/portainer/portainer/api/internal/testhelpers"
+
+	"github.com/segmentio/encoding/json"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRoleCRUD(t *testing.T) {
+	is := require.New(t)
+
+	_, store := datastore.MustNewTestStore(t, true, true)
+
+	h := NewHandler(testhelpers.NewTestRequestBouncer())
+	h.DataStore = store
+	h.AuthorizationService = authorization.NewService(store)
+
+	serve := func(method, url string, payload any) *httptest.ResponseRecorder {
+		body, err := json.Marshal(payload)
+		is.NoError(err)
+
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, httptest.NewRequest(method, url, bytes.NewReader(body)))
+
+		return rr
+	}
+
+	rr := serve(http.MethodPost, "/roles", roleCreatePayload{
+		Name:           "Deployer",
+		Authorizations: portainer.Authorizations{portainer.OperationPortainerStackCreate: true, portainer.OperationPortainerStackUpdate: true},
+		Priority:       5,
+	})
+	is.Equal(http.StatusOK, rr.Code, rr.Body.String())
+
+	var role portainer.Role
+	is.NoError(json.NewDecoder(rr.Body).Decode(&role))
+
+	t.Run("the roles are not given the identifiers of the built-in roles", func(t *testing.T) {
+		require.False(t, authorization.IsBuiltInRole(role.ID))
+	})
+
+	t.Run("the built-in roles cannot be changed", func(t *testing.T) {
+		is := require.New(t)
+
+		is.NoError(store.Role().Update(1, &portainer.Role{ID: 1, Name: "Environment administrator", Priority: 1}))
+
+		rr := serve(http.MethodPut, "/roles/1", roleUpdatePayload{
+			Authorizations: portainer.Authorizations{portainer.OperationPortainerStackList: true},
+		})
+		is.Equal(http.StatusForbidden, rr.Code)
+
+		rr = serve(http.MethodDelete, "/roles/1", nil)
+		is.Equal(http.StatusForbidden, rr.Code)
+
+		builtInRole, err := store.Role().Read(1)
+		is.NoError(err)
+		is.Empty(builtInRole.Authorizations)
+	})
+
+	t.Run("the roles are built from the known authorizations", func(t *testing.T) {
+		rr := serve(http.MethodPost, "/roles", roleCreatePayload{
+			Name:           "Invalid",
+			Authorizations: portainer.Authorizations{"DockerContainerTeleport": true},
+			Priority:       5,
+		})
+		require.Equal(t, http.StatusBadRequest, rr.Code)
+	})
+
+	t.Run("the role names are unique", func(t *testing.T) {
+		rr := serve(http.MethodPost, "/roles", roleCreatePayload{
+			Name:           "deployer",
+			Authorizations: portainer.Authorizations{portainer.OperationPortainerStackList: true},
+			Priority:       5,
+		})
+		require.Equal(t, http.StatusConflict, rr.Code)
+	})
+
+	// the role is assigned to a team on an environment group
+	user := &portainer.User{ID: 2, Username: "standard", Role: portainer.StandardUserRole}
+	is.NoError(store.User().Create(user))
+
+	team := &portainer.Team{Name: "deployers"}
+	is.NoError(store.Team().Create(team))
+	is.NoError(store.TeamMembership().Create(&portainer.TeamMembership{UserID: user.ID, TeamID: team.ID, Role: portainer.TeamMember}))
+
+	group := &portainer.EndpointGroup{
+		Name:               "production",
+		UserAccessPolicies: portainer.UserAccessPolicies{},
+		TeamAccessPolicies: portainer.TeamAccessPolicies{team.ID: {RoleID: role.ID}},
+	}
+	is.NoError(store.EndpointGroup().Create(group))
+	is.NoError(store.Endpoint().Create(&portainer.Endpoint{ID: 1, Name: "prod", GroupID: group.ID}))
+
+	t.Run("updating a role updates the authorizations of its users", func(t *testing.T) {
+		is := require.New(t)
+
+		rr := serve(http.MethodPut, fmt.Sprintf("/roles/%d", role.ID), roleUpdatePayload{
+			Authorizations: portainer.Authorizations{portainer.OperationPortainerStackList: true},
+		})
+		is.Equal(http.StatusOK, rr.Code, rr.Body.String())
+
+		updatedUser, err := store.User().Read(user.ID)
+		is.NoError(err)
+		is.Equal(portainer.Authorizations{portainer.OperationPortainerStackList: true}, updatedUser.EndpointAuthorizations[1])
+	})
+
+	t.Run("an assigned role cannot be removed", func(t *testing.T) {
+		rr := serve(http.MethodDelete, fmt.Sprintf("/roles/%d", role.ID), nil)
+		require.Equal(t, http.StatusConflict, rr.Code)
+	})
+
+	t.Run("an unassigned role is removed", func(t *testing.T) {
+		is := require.New(t)
+
+		group.TeamAccessPolicies = portainer.TeamAccessPolicies{}
+		is.NoError(store.EndpointGroup().Update(group.ID, group))
+
+		rr := serve(http.MethodDelete, fmt.Sprintf("/roles/%d", role.ID), nil)
+		is.Equal(http.StatusNoContent, rr.Code)
+
+		rr = serve(http.MethodGet, fmt.Sprintf("/roles/%d", role.ID), nil)
+		is.Equal(http.StatusNotFound, rr.Code)
+	})
+}
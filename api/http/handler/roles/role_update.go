@@ -0,0 +1,116 @@
+package roles
+
+import (
+	"net/http"
+	"strings"
+
+	portainer "github.com/portainer/portainer/api"
+	"github.com/portainer/portainer/api/dataservices"
+	"github.com/portainer/portainer/api/internal/authorization"
+	httperror "github.com/portainer/portainer/pkg/libhttp/error"
+	"github.com/portainer/portainer/pkg/libhttp/request"
+	"github.com/portainer/portainer/pkg/libhttp/response"
+)
+
+type roleUpdatePayload struct {
+	// Role name
+	Name *string `example:"Deployer"`
+	// Role description
+	Description *string `example:"Manages the stacks of an environment"`
+	// Authorizations granted by the role, they replace the current ones
+	Authorizations portainer.Authorizations
+	// When several roles apply to a user in an environment, the one with the highest priority applies
+	Priority *int `example:"5"`
+}
+
+func (payload *roleUpdatePayload) Validate(r *http.Request) error {
+	if payload.Name != nil && strings.TrimSpace(*payload.Name) == "" {
+		return errInvalidName
+	}
+
+	if payload.Priority != nil && *payload.Priority < 1 {
+		return errInvalidPriority
+	}
+
+	return validateAuthorizations(payload.Authorizations)
+}
+
+// @id RoleUpdate
+// @summary Update a role
+// @description Update a role. The authorizations of the users the role is assigned to,
+// @description directly or through their teams, are updated. The built-in roles cannot be updated.
+// @description **Access policy**: administrator
+// @tags roles
+// @security ApiKeyAuth
+// @security jwt
+// @accept json
+// @produce json
+// @param id path int true "Role identifier"
+// @param body body roleUpdatePayload true "Role details"
+// @success 200 {object} portainer.Role "Success"
+// @failure 400 "Invalid request"
+// @failure 403 "The role is a built-in role"
+// @failure 404 "Role not found"
+// @failure 409 "A role with the same name already exists"
+// @failure 500 "Server error"
+// @router /roles/{id} [put]
+func (handler *Handler) roleUpdate(w http.ResponseWriter, r *http.Request) *httperror.HandlerError {
+	roleID, err := request.RetrieveNumericRouteVariableValue(r, "id")
+	if err != nil {
+		return httperror.BadRequest("Invalid role identifier route variable", err)
+	}
+
+	if authorization.IsBuiltInRole(portainer.RoleID(roleID)) {
+		return httperror.Forbidden("The built-in roles cannot be updated", errBuiltInRole)
+	}
+
+	var payload roleUpdatePayload
+	if err := request.DecodeAndValidateJSONPayload(r, &payload); err != nil {
+		return httperror.BadRequest("Invalid request payload", err)
+	}
+
+	var role *portainer.Role
+
+	if err := handler.DataStore.UpdateTxCtx(r.Context(), func(tx dataservices.DataStoreTx) error {
+		role, err = tx.Role().Read(portainer.RoleID(roleID))
+		if tx.IsErrObjectNotFound(err) {
+			return httperror.NotFound("Unable to find a role with the specified identifier inside the database", err)
+		} else if err != nil {
+			return httperror.InternalServerError("Unable to find a role with the specified identifier inside the database", err)
+		}
+
+		if payload.Name != nil {
+			role.Name = strings.TrimSpace(*payload.Name)
+
+			if err := checkUniqueName(tx, role); err != nil {
+				return err
+			}
+		}
+
+		if payload.Description != nil {
+			role.Description = *payload.Description
+		}
+
+		if payload.Authorizations != nil {
+			role.Authorizations = payload.Authorizations
+		}
+
+		if payload.Priority != nil {
+			role.Priority = *payload.Priority
+		}
+
+		if err := tx.Role().Update(role.ID, role); err != nil {
+			return httperror.InternalServerError("Unable to persist the role changes inside the database", err)
+		}
+
+		if err := handler.AuthorizationService.UpdateRoleUsersAuthorizations(tx, role.ID); err != nil {
+			return httperror.InternalServerError("Unable to update the authorizations of the users of the role", err)
+		}
+
+		return nil
+	}); err != nil {
+		return txError(err, "Unable to update the role")
+	}
+
+	return response.JSON(w, role)
+}
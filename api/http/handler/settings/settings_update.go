@@ -53,6 +53,8 @@ type settingsUpdatePayload struct {
 	TrustOnFirstConnect *bool `example:"false"`
 	// EnforceEdgeID makes Portainer store the Edge ID instead of accepting anyone
 	EnforceEdgeID *bool `example:"false"`
+	// Whether the internal users must log in with a TOTP second factor
+	RequireTwoFactor *bool `example:"false"`
 	// EdgePortainerURL is the URL that is exposed to edge agents
 	EdgePortainerURL *string `json:"EdgePortainerURL"`
 }
@@ -123,7 +125,7 @@ func (handler *Handler) settingsUpdate(w http.ResponseWriter, r *http.Request) *
 	}
 
 	var settings *portainer.Settings
-	if err = handler.DataStore.UpdateTx(func(tx dataservices.DataStoreTx) error {
+	if err = handler.DataStore.UpdateTxCtx(r.Context(), func(tx dataservices.DataStoreTx) error {
 		settings, err = handler.updateSettings(tx, payload)
 
 		return err
@@ -208,6 +210,7 @@ func (handler *Handler) updateSettings(tx dataservices.DataStoreTx, payload sett
 	settings.EnableEdgeComputeFeatures = *cmp.Or(payload.EnableEdgeComputeFeatures, &settings.EnableEdgeComputeFeatures)
 	settings.TrustOnFirstConnect = *cmp.Or(payload.TrustOnFirstConnect, &settings.TrustOnFirstConnect)
 	settings.EnforceEdgeID = *cmp.Or(payload.EnforceEdgeID, &settings.EnforceEdgeID)
+	settings.RequireTwoFactor = *cmp.Or(payload.RequireTwoFactor, &settings.RequireTwoFactor)
 	settings.EdgePortainerURL = *cmp.Or(payload.EdgePortainerURL, &settings.EdgePortainerURL)
 
 	if payload.SnapshotInterval != nil && *payload.SnapshotInterval != settings.SnapshotInterval {
@@ -59,7 +59,7 @@ func (handler *Handler) checkAndCleanStackDupFromSwarm(w http.ResponseWriter, r
 
 	// stop scheduler updates of the stack before removal
 	if stack.AutoUpdate != nil {
-		deployments.StopAutoupdate(stack.ID, stack.AutoUpdate.JobID, handler.Scheduler)
+		deployments.StopAutoupdate(stack.ID, handler.Scheduler)
 	}
 
 	err = handler.DataStore.Stack().Delete(stack.ID)
@@ -114,7 +114,7 @@ func (handler *Handler) stackDelete(w http.ResponseWriter, r *http.Request) *htt
 
 	// stop scheduler updates of the stack before removal
 	if stack.AutoUpdate != nil {
-		deployments.StopAutoupdate(stack.ID, stack.AutoUpdate.JobID, handler.Scheduler)
+		deployments.StopAutoupdate(stack.ID, handler.Scheduler)
 	}
 
 	if err := handler.deleteStack(securityContext.UserID, stack, endpoint); err != nil {
@@ -322,7 +322,7 @@ func (handler *Handler) stackDeleteKubernetesByName(w http.ResponseWriter, r *ht
 
 		// stop scheduler updates of the stack before removal
 		if stack.AutoUpdate != nil {
-			deployments.StopAutoupdate(stack.ID, stack.AutoUpdate.JobID, handler.Scheduler)
+			deployments.StopAutoupdate(stack.ID, handler.Scheduler)
 		}
 
 		err = handler.deleteStack(securityContext.UserID, &stack, endpoint)
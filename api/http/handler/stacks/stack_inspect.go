@@ -5,6 +5,7 @@ import (
 
 	portainer "github.com/portainer/portainer/api"
 	httperrors "github.com/portainer/portainer/api/http/errors"
+	"github.com/portainer/portainer/api/http/etag"
 	"github.com/portainer/portainer/api/http/security"
 	"github.com/portainer/portainer/api/stacks/stackutils"
 	httperror "github.com/portainer/portainer/pkg/libhttp/error"
@@ -24,6 +25,7 @@ import (
 // @produce json
 // @param id path int true "Stack identifier"
 // @success 200 {object} portainer.Stack "Success"
+// @header 200 {string} ETag "Entity tag of the stack, to send in the If-Match header of an update"
 // @failure 400 "Invalid request"
 // @failure 403 "Permission denied"
 // @failure 404 "Stack not found"
@@ -42,6 +44,8 @@ func (handler *Handler) stackInspect(w http.ResponseWriter, r *http.Request) *ht
 		return httperror.InternalServerError("Unable to find a stack with the specified identifier inside the database", err)
 	}
 
+	etag.Set(w, stack)
+
 	securityContext, err := security.RetrieveRestrictedRequestContext(r)
 	if err != nil {
 		return httperror.InternalServerError("Unable to retrieve info from request context", err)
@@ -1,9 +1,13 @@
 package stacks
 
 import (
+	"errors"
 	"net/http"
+	"strconv"
 
 	portainer "github.com/portainer/portainer/api"
+	"github.com/portainer/portainer/api/database/query"
+	"github.com/portainer/portainer/api/dataservices"
 	httperrors "github.com/portainer/portainer/api/http/errors"
 	"github.com/portainer/portainer/api/http/security"
 	"github.com/portainer/portainer/api/internal/authorization"
@@ -29,7 +33,14 @@ type stackListOperationFilters struct {
 // @security ApiKeyAuth
 // @security jwt
 // @param filters query string false "Filters to process on the stack list. Encoded as JSON (a map[string]string). For example, {'SwarmID': 'jpofkc0i9uo9wtx1zesuk649w'} will only return stacks that are part of the specified Swarm cluster. Available filters: EndpointID, SwarmID."
+// @param start query int false "Start searching from"
+// @param limit query int false "Limit results to this value"
+// @param cursor query string false "Start after the stack the cursor was returned for, it replaces start. The cursor of the next page is returned in the X-Next-Cursor header. It can only be used by the administrators, without the IncludeOrphanedStacks filter"
+// @param sort query string false "Sort results by this value" Enum("Name", "CreationDate")
+// @param order query string false "Order sorted results by desc/asc" Enum("asc", "desc")
 // @success 200 {array} portainer.Stack "Success"
+// @header 200 {string} X-Total-Count "The number of stacks matched by the filters"
+// @header 200 {string} X-Next-Cursor "The cursor of the next page"
 // @success 204 "Success"
 // @failure 400 "Invalid request"
 // @failure 500 "Server error"
@@ -41,20 +52,25 @@ func (handler *Handler) stackList(w http.ResponseWriter, r *http.Request) *httpe
 		return httperror.BadRequest("Invalid query parameter: filters", err)
 	}
 
-	endpoints, err := handler.DataStore.Endpoint().Endpoints()
-	if err != nil {
-		return httperror.InternalServerError("Unable to retrieve environments from database", err)
+	start, _ := request.RetrieveNumericQueryParameter(r, "start", true)
+	if start != 0 {
+		start--
 	}
 
-	stacks, err := handler.DataStore.Stack().ReadAll()
-	if err != nil {
-		return httperror.InternalServerError("Unable to retrieve stacks from the database", err)
-	}
-	stacks = filterStacks(stacks, &filters, endpoints)
+	limit, _ := request.RetrieveNumericQueryParameter(r, "limit", true)
+	cursor, _ := request.RetrieveQueryParameter(r, "cursor", true)
+	sortField, _ := request.RetrieveQueryParameter(r, "sort", true)
+	sortOrder, _ := request.RetrieveQueryParameter(r, "order", true)
 
-	resourceControls, err := handler.DataStore.ResourceControl().ReadAll()
-	if err != nil {
-		return httperror.InternalServerError("Unable to retrieve resource controls from the database", err)
+	var pageSort query.Sort
+	switch sortField {
+	case "":
+	case "Name":
+		pageSort = query.Sort{Field: "Name", Text: true, Desc: sortOrder == "desc"}
+	case "CreationDate":
+		pageSort = query.Sort{Field: "CreationDate", Desc: sortOrder == "desc"}
+	default:
+		return httperror.BadRequest("Invalid query parameter: sort", errors.New("unsupported sort field"))
 	}
 
 	securityContext, err := security.RetrieveRestrictedRequestContext(r)
@@ -62,18 +78,64 @@ func (handler *Handler) stackList(w http.ResponseWriter, r *http.Request) *httpe
 		return httperror.InternalServerError("Unable to retrieve info from request context", err)
 	}
 
-	stacks = authorization.DecorateStacks(stacks, resourceControls)
+	if !securityContext.IsAdmin && filters.IncludeOrphanedStacks {
+		return httperror.Forbidden("Permission denied to access orphaned stacks", httperrors.ErrUnauthorized)
+	}
 
-	if !securityContext.IsAdmin {
+	// The orphaned stacks and the authorizations are filtered once the stacks
+	// are read, the page is then selected in memory
+	paged := securityContext.IsAdmin && !filters.IncludeOrphanedStacks
+	if !paged && cursor != "" {
+		return httperror.BadRequest("Invalid query parameters", errors.New("the cursor cannot be used with these filters"))
+	}
+
+	var endpoints []portainer.Endpoint
+	var stacks []portainer.Stack
+	var pageInfo query.PageInfo
+	var resourceControls []portainer.ResourceControl
+	var user *portainer.User
+	err = handler.DataStore.ViewTxCtx(r.Context(), func(tx dataservices.DataStoreTx) error {
 		if filters.IncludeOrphanedStacks {
-			return httperror.Forbidden("Permission denied to access orphaned stacks", httperrors.ErrUnauthorized)
+			if endpoints, err = tx.Endpoint().Endpoints(); err != nil {
+				return httperror.InternalServerError("Unable to retrieve environments from database", err)
+			}
+		}
+
+		page := query.Page{Filter: stackListFilter(&filters, endpoints), Sort: pageSort}
+		if paged {
+			page.Offset, page.Limit, page.Cursor = start, limit, cursor
+		}
+
+		if stacks, pageInfo, err = tx.Stack().StacksPage(page); errors.Is(err, query.ErrInvalidCursor) {
+			return httperror.BadRequest("Invalid query parameter: cursor", err)
+		} else if err != nil {
+			return httperror.InternalServerError("Unable to retrieve stacks from the database", err)
 		}
 
-		user, err := handler.DataStore.User().Read(securityContext.UserID)
-		if err != nil {
-			return httperror.InternalServerError("Unable to retrieve user information from the database", err)
+		if resourceControls, err = tx.ResourceControl().ReadAll(); err != nil {
+			return httperror.InternalServerError("Unable to retrieve resource controls from the database", err)
 		}
 
+		if !securityContext.IsAdmin {
+			if user, err = tx.User().Read(securityContext.UserID); err != nil {
+				return httperror.InternalServerError("Unable to retrieve user information from the database", err)
+			}
+		}
+
+		return nil
+	})
+
+	var httpErr *httperror.HandlerError
+	if errors.As(err, &httpErr) {
+		return httpErr
+	} else if err != nil {
+		return httperror.InternalServerError("Unable to retrieve stacks from the database", err)
+	}
+
+	stacks = filterStacks(stacks, &filters, endpoints)
+	stacks = authorization.DecorateStacks(stacks, resourceControls)
+
+	if !securityContext.IsAdmin {
 		userTeamIDs := make([]portainer.TeamID, 0)
 		for _, membership := range securityContext.UserMemberships {
 			userTeamIDs = append(userTeamIDs, membership.TeamID)
@@ -82,6 +144,17 @@ func (handler *Handler) stackList(w http.ResponseWriter, r *http.Request) *httpe
 		stacks = authorization.FilterAuthorizedStacks(stacks, user, userTeamIDs)
 	}
 
+	if !paged {
+		pageInfo = query.PageInfo{Total: len(stacks)}
+		stacks = paginateStacks(stacks, start, limit)
+	}
+
+	if pageInfo.Next != "" {
+		w.Header().Set("X-Next-Cursor", pageInfo.Next)
+	}
+
+	w.Header().Set("X-Total-Count", strconv.Itoa(pageInfo.Total))
+
 	for _, stack := range stacks {
 		if stack.GitConfig != nil && stack.GitConfig.Authentication != nil && stack.GitConfig.Authentication.Password != "" {
 			// sanitize password in the http response to minimise possible security leaks
@@ -147,6 +220,47 @@ func filterStacks(stacks []portainer.Stack, filters *stackListOperationFilters,
 	return filteredStacks
 }
 
+// stackListFilter selects the candidates of filterStacks in the store, the
+// orphaned stacks sharing their name with a stack of the environment are
+// removed by filterStacks
+func stackListFilter(filters *stackListOperationFilters, endpoints []portainer.Endpoint) query.Filter {
+	if filters.EndpointID == 0 && filters.SwarmID == "" {
+		return query.All()
+	}
+
+	candidates := []query.Filter{
+		query.All(query.Equals("Type", portainer.DockerComposeStack), query.Equals("EndpointId", filters.EndpointID)),
+		query.All(query.Equals("Type", portainer.DockerSwarmStack), query.Equals("SwarmId", filters.SwarmID)),
+	}
+
+	if filters.IncludeOrphanedStacks {
+		orphanType := portainer.DockerComposeStack
+		if filters.SwarmID != "" {
+			orphanType = portainer.DockerSwarmStack
+		}
+
+		endpointIDs := make([]portainer.EndpointID, 0, len(endpoints))
+		for _, endpoint := range endpoints {
+			endpointIDs = append(endpointIDs, endpoint.ID)
+		}
+
+		candidates = append(candidates, query.All(query.Equals("Type", orphanType), query.Not(query.In("EndpointId", endpointIDs))))
+	}
+
+	return query.Any(candidates...)
+}
+
+func paginateStacks(stacks []portainer.Stack, start, limit int) []portainer.Stack {
+	if limit == 0 {
+		return stacks
+	}
+
+	start = min(max(start, 0), len(stacks))
+	end := min(start+limit, len(stacks))
+
+	return stacks[start:end]
+}
+
 func isOrphanedStack(stack portainer.Stack, endpoints []portainer.Endpoint) bool {
 	for _, endpoint := range endpoints {
 		if stack.EndpointID == endpoint.ID {
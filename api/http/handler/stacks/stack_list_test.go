@@ -1,10 +1,17 @@
 package stacks
 
 import (
+	"net/http"
+	"net/http/httptest"
 	"sort"
 	"testing"
 
 	portainer "github.com/portainer/portainer/api"
+	"github.com/portainer/portainer/api/datastore"
+	"github.com/portainer/portainer/api/http/security"
+	"github.com/portainer/portainer/api/internal/testhelpers"
+
+	"github.com/segmentio/encoding/json"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -72,3 +79,66 @@ func isEqualStacks(t *testing.T, expectStacks, actualStacks []portainer.Stack) {
 
 	assert.Equal(t, expectStackIDs, actualStackIDs)
 }
+
+func TestStackListPage(t *testing.T) {
+	is := assert.New(t)
+
+	_, store := datastore.MustNewTestStore(t, true, true)
+
+	for i, name := range []string{"env10", "env2", "env1", "env3", "env20"} {
+		is.NoError(store.Stack().Create(&portainer.Stack{ID: portainer.StackID(i + 1), Name: name, Type: portainer.DockerComposeStack, EndpointID: 1}))
+	}
+
+	user := &portainer.User{Username: "standard", Role: portainer.StandardUserRole}
+	is.NoError(store.User().Create(user))
+
+	h := NewHandler(testhelpers.NewTestRequestBouncer())
+	h.DataStore = store
+
+	list := func(query string, isAdmin bool) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/stacks?"+query, nil)
+		req = req.WithContext(security.StoreRestrictedRequestContext(req, &security.RestrictedRequestContext{UserID: user.ID, IsAdmin: isAdmin}))
+
+		rr := httptest.NewRecorder()
+		h.Router.ServeHTTP(rr, req)
+
+		return rr
+	}
+
+	names := func(rr *httptest.ResponseRecorder) []string {
+		var stacks []portainer.Stack
+		is.NoError(json.NewDecoder(rr.Body).Decode(&stacks))
+
+		names := []string{}
+		for _, stack := range stacks {
+			names = append(names, stack.Name)
+		}
+
+		return names
+	}
+
+	rr := list("sort=Name&limit=2", true)
+	is.Equal(http.StatusOK, rr.Code)
+	is.Equal("5", rr.Header().Get("X-Total-Count"))
+	is.Equal([]string{"env1", "env2"}, names(rr))
+
+	rr = list("sort=Name&limit=2&cursor="+rr.Header().Get("X-Next-Cursor"), true)
+	is.Equal(http.StatusOK, rr.Code)
+	is.Equal([]string{"env3", "env10"}, names(rr))
+
+	rr = list("sort=Name&order=desc&start=2&limit=2", true)
+	is.Equal(http.StatusOK, rr.Code)
+	is.Equal([]string{"env10", "env3"}, names(rr))
+
+	rr = list("sort=Type", true)
+	is.Equal(http.StatusBadRequest, rr.Code)
+
+	// The stacks of the other users are filtered before the page is selected
+	rr = list("sort=Name&limit=2", false)
+	is.Equal(http.StatusOK, rr.Code)
+	is.Equal("0", rr.Header().Get("X-Total-Count"))
+	is.Empty(names(rr))
+
+	rr = list("sort=Name&limit=2&cursor=abc", false)
+	is.Equal(http.StatusBadRequest, rr.Code)
+}
@@ -107,7 +107,7 @@ func (handler *Handler) stackStart(w http.ResponseWriter, r *http.Request) *http
 	}
 
 	if stack.AutoUpdate != nil && stack.AutoUpdate.Interval != "" {
-		deployments.StopAutoupdate(stack.ID, stack.AutoUpdate.JobID, handler.Scheduler)
+		deployments.StopAutoupdate(stack.ID, handler.Scheduler)
 
 		jobID, e := deployments.StartAutoupdate(stack.ID, stack.AutoUpdate.Interval, handler.Scheduler, handler.StackDeployer, handler.DataStore, handler.GitService)
 		if e != nil {
@@ -96,8 +96,8 @@ func (handler *Handler) stackStop(w http.ResponseWriter, r *http.Request) *httpe
 	}
 
 	// stop scheduler updates of the stack before stopping
-	if stack.AutoUpdate != nil && stack.AutoUpdate.JobID != "" {
-		deployments.StopAutoupdate(stack.ID, stack.AutoUpdate.JobID, handler.Scheduler)
+	if stack.AutoUpdate != nil {
+		deployments.StopAutoupdate(stack.ID, handler.Scheduler)
 		stack.AutoUpdate.JobID = ""
 	}
 
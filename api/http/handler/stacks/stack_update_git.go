@@ -126,7 +126,7 @@ func (handler *Handler) stackUpdateGit(w http.ResponseWriter, r *http.Request) *
 
 	//stop the autoupdate job if there is any
 	if stack.AutoUpdate != nil {
-		deployments.StopAutoupdate(stack.ID, stack.AutoUpdate.JobID, handler.Scheduler)
+		deployments.StopAutoupdate(stack.ID, handler.Scheduler)
 	}
 
 	//update retrieved stack data based on the payload
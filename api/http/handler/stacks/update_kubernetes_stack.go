@@ -55,7 +55,7 @@ func (handler *Handler) updateKubernetesStack(r *http.Request, stack *portainer.
 	if stack.GitConfig != nil {
 		// Stop the autoupdate job if there is any
 		if stack.AutoUpdate != nil {
-			deployments.StopAutoupdate(stack.ID, stack.AutoUpdate.JobID, handler.Scheduler)
+			deployments.StopAutoupdate(stack.ID, handler.Scheduler)
 		}
 
 		var payload kubernetesGitStackUpdatePayload
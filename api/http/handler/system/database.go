@@ -0,0 +1,57 @@
+package system
+
+import (
+	"net/http"
+
+	"github.com/portainer/portainer/api/database/postgres"
+	httperror "github.com/portainer/portainer/pkg/libhttp/error"
+	"github.com/portainer/portainer/pkg/libhttp/response"
+)
+
+// transactionMetricsSource is implemented by the connections retrying the
+// transactions aborted by the database
+type transactionMetricsSource interface {
+	TransactionMetrics() postgres.TransactionMetrics
+}
+
+// databaseHealthSource is implemented by the connections probing their server
+type databaseHealthSource interface {
+	Health() postgres.DatabaseHealth
+	PoolStats() postgres.PoolStats
+}
+
+type databaseResponse struct {
+	// Retry counters of the write transactions, only reported by PostgreSQL
+	Transactions *postgres.TransactionMetrics `json:"transactions,omitempty"`
+	// Result of the last probe of the server, only reported by PostgreSQL
+	Health *postgres.DatabaseHealth `json:"health,omitempty"`
+	// State of the connection pool, only reported by PostgreSQL
+	Pool *postgres.PoolStats `json:"pool,omitempty"`
+}
+
+// @id systemDatabase
+// @summary Retrieve the database metrics
+// @description **Access policy**: administrator
+// @security ApiKeyAuth
+// @security jwt
+// @tags system
+// @produce json
+// @success 200 {object} databaseResponse "Success"
+// @failure 500 "Server error"
+// @router /system/database [get]
+func (handler *Handler) systemDatabase(w http.ResponseWriter, r *http.Request) *httperror.HandlerError {
+	var resp databaseResponse
+
+	if source, ok := handler.dataStore.Connection().(transactionMetricsSource); ok {
+		metrics := source.TransactionMetrics()
+		resp.Transactions = &metrics
+	}
+
+	if source, ok := handler.dataStore.Connection().(databaseHealthSource); ok {
+		health, pool := source.Health(), source.PoolStats()
+		resp.Health = &health
+		resp.Pool = &pool
+	}
+
+	return response.JSON(w, resp)
+}
@@ -43,6 +43,7 @@ func NewHandler(bouncer security.BouncerService,
 	adminRouter.Use(bouncer.AdminAccess)
 
 	adminRouter.Handle("/upgrade", httperror.LoggerHandler(h.systemUpgrade)).Methods(http.MethodPost)
+	adminRouter.Handle("/database", httperror.LoggerHandler(h.systemDatabase)).Methods(http.MethodGet)
 
 	authenticatedRouter := router.PathPrefix("/").Subrouter()
 	authenticatedRouter.Use(bouncer.AuthenticatedAccess)
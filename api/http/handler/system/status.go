@@ -2,7 +2,8 @@ package system
 
 import (
 	"net/http"
-	"fmt"
+	"time"
+
 	portainer "github.com/portainer/portainer/api"
 	httperror "github.com/portainer/portainer/pkg/libhttp/error"
 	"github.com/portainer/portainer/pkg/libhttp/response"
@@ -12,6 +13,15 @@ import (
 
 type status struct {
 	*portainer.Status
+	// Database is only reported by the stores whose server is probed
+	Database *databaseStatus `json:"Database,omitempty"`
+}
+
+type databaseStatus struct {
+	// Whether the database server answered the last probe
+	Healthy bool `json:"Healthy" example:"true"`
+	// When the server started or stopped answering
+	Since time.Time `json:"Since"`
 }
 
 // @id systemStatus
@@ -23,9 +33,16 @@ type status struct {
 // @success 200 {object} status "Success"
 // @router /system/status [get]
 func (handler *Handler) systemStatus(w http.ResponseWriter, r *http.Request) *httperror.HandlerError {
-	return response.JSON(w, &status{
+	resp := &status{
 		Status: handler.status,
-	})
+	}
+
+	if source, ok := handler.dataStore.Connection().(databaseHealthSource); ok {
+		health := source.Health()
+		resp.Database = &databaseStatus{Healthy: health.Healthy, Since: health.Since}
+	}
+
+	return response.JSON(w, resp)
 }
 
 // swagger docs for deprecated route:
@@ -41,12 +58,6 @@ func (handler *Handler) systemStatus(w http.ResponseWriter, r *http.Request) *ht
 // @router /status [get]
 func (handler *Handler) statusInspectDeprecated(w http.ResponseWriter, r *http.Request) *httperror.HandlerError {
 	log.Warn().Msg("The /status endpoint is deprecated and will be removed in a future version of Portainer. Please use the /system/status endpoint instead.")
-	fmt.Println("Hello endpoint called")
-	fmt.Println("Request Details:")
-	fmt.Println("  Remote Address: %s", r.RemoteAddr)
-	fmt.Println("  Host: %s", r.Host)
-	fmt.Println("  Path: %s", r.URL.Path)
-	fmt.Println("  Method: %s", r.Method)
 
 	return handler.systemStatus(w, r)
 }
@@ -25,7 +25,9 @@ type versionResponse struct {
 	ServerVersion   string
 	ServerEdition   string `json:"ServerEdition" example:"CE/EE"`
 	DatabaseVersion string
-	Build           BuildInfo
+	// Version of the SQL schema, omitted for the BoltDB backend
+	DatabaseSchemaVersion int `json:",omitempty" example:"2"`
+	Build                 BuildInfo
 }
 
 type BuildInfo struct {
@@ -70,6 +72,13 @@ func (handler *Handler) version(w http.ResponseWriter, r *http.Request) *httperr
 		},
 	}
 
+	if v, err := handler.dataStore.Version().Version(); err == nil {
+		result.DatabaseVersion = v.SchemaVersion
+		result.DatabaseSchemaVersion = v.StoreSchemaVersion
+	} else {
+		log.Debug().Err(err).Msg("couldn't read the database version")
+	}
+
 	if isAdmin {
 		result.Build.Env = os.Environ()
 	}
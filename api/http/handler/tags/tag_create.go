@@ -45,7 +45,7 @@ func (handler *Handler) tagCreate(w http.ResponseWriter, r *http.Request) *httpe
 
 	fmt.Println("in the function")
 	var tag *portainer.Tag
-	err = handler.DataStore.UpdateTx(func(tx dataservices.DataStoreTx) error {
+	err = handler.DataStore.UpdateTxCtx(r.Context(), func(tx dataservices.DataStoreTx) error {
 		tag, err = createTag(tx, payload)
 		return err
 	})
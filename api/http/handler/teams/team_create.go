@@ -49,7 +49,7 @@ func (handler *Handler) teamCreate(w http.ResponseWriter, r *http.Request) *http
 
 	var team *portainer.Team
 
-	if err := handler.DataStore.UpdateTx(func(tx dataservices.DataStoreTx) error {
+	if err := handler.DataStore.UpdateTxCtx(r.Context(), func(tx dataservices.DataStoreTx) error {
 		var err error
 		team, err = createTeam(tx, payload)
 
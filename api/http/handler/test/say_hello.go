@@ -3,6 +3,7 @@ package test
 import (
 	"net/http"
 	"errors"
+	portainer "github.com/portainer/portainer/api"
 	"github.com/portainer/portainer/pkg/libhttp/response"
 	httperror "github.com/portainer/portainer/pkg/libhttp/error"
 	"github.com/portainer/portainer/pkg/libhttp/request"
@@ -41,7 +42,7 @@ func (h *Handler) createTable(w http.ResponseWriter, r *http.Request) *httperror
 	// Start a database transaction
 	connection := h.DataStore.Connection()
 
-	err = connection.SetServiceName(payload.Name)
+	err = connection.SetServiceName(payload.Name, portainer.KeyKindInteger)
 	if err != nil {
 		return httperror.InternalServerError("Failed to create table", err)
 	}
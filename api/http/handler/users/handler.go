@@ -23,6 +23,7 @@ var (
 
 func hideFields(user *portainer.User) {
 	user.Password = ""
+	user.TwoFactor = portainer.UserTwoFactor{Enabled: user.TwoFactor.Enabled}
 }
 
 // Handler is the HTTP handler used to handle user operations.
@@ -72,8 +73,14 @@ func NewHandler(bouncer security.BouncerService, rateLimiter *security.RateLimit
 	restrictedRouter.Handle("/users/{id}/tokens", httperror.LoggerHandler(h.userGetAccessTokens)).Methods(http.MethodGet)
 	restrictedRouter.Handle("/users/{id}/tokens", rateLimiter.LimitAccess(httperror.LoggerHandler(h.userCreateAccessToken))).Methods(http.MethodPost)
 	restrictedRouter.Handle("/users/{id}/tokens/{keyID}", httperror.LoggerHandler(h.userRemoveAccessToken)).Methods(http.MethodDelete)
+	restrictedRouter.Handle("/users/{id}/sessions", httperror.LoggerHandler(h.userListSessions)).Methods(http.MethodGet)
+	adminRouter.Handle("/users/{id}/sessions", httperror.LoggerHandler(h.userRevokeSessions)).Methods(http.MethodDelete)
+	restrictedRouter.Handle("/users/{id}/sessions/{jti}", httperror.LoggerHandler(h.userRevokeSession)).Methods(http.MethodDelete)
 	restrictedRouter.Handle("/users/{id}/memberships", httperror.LoggerHandler(h.userMemberships)).Methods(http.MethodGet)
 	authenticatedRouter.Handle("/users/{id}/passwd", rateLimiter.LimitAccess(httperror.LoggerHandler(h.userUpdatePassword))).Methods(http.MethodPut)
+	authenticatedRouter.Handle("/users/{id}/2fa", rateLimiter.LimitAccess(httperror.LoggerHandler(h.userEnableTwoFactor))).Methods(http.MethodPost)
+	authenticatedRouter.Handle("/users/{id}/2fa/confirm", rateLimiter.LimitAccess(httperror.LoggerHandler(h.userConfirmTwoFactor))).Methods(http.MethodPost)
+	adminRouter.Handle("/users/{id}/2fa", httperror.LoggerHandler(h.userResetTwoFactor)).Methods(http.MethodDelete)
 
 	publicRouter.Handle("/users/admin/check", httperror.LoggerHandler(h.adminCheck)).Methods(http.MethodGet)
 	publicRouter.Handle("/users/admin/init", httperror.LoggerHandler(h.adminInit)).Methods(http.MethodPost)
@@ -0,0 +1,262 @@
+package users
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	portainer "github.com/portainer/portainer/api"
+	httperrors "github.com/portainer/portainer/api/http/errors"
+	"github.com/portainer/portainer/api/http/security"
+	"github.com/portainer/portainer/api/totp"
+	httperror "github.com/portainer/portainer/pkg/libhttp/error"
+	"github.com/portainer/portainer/pkg/libhttp/request"
+	"github.com/portainer/portainer/pkg/libhttp/response"
+)
+
+const (
+	// twoFactorIssuer is the name the authenticator applications show for the secrets
+	twoFactorIssuer = "Portainer"
+	// recoveryCodeCount is the number of recovery codes generated on enrolment
+	recoveryCodeCount = 10
+)
+
+var (
+	errTwoFactorEnabled     = errors.New("two-factor authentication is already enabled")
+	errTwoFactorNotPending  = errors.New("no two-factor authentication enrolment in progress")
+	errTwoFactorInvalidCode = errors.New("invalid TOTP code")
+)
+
+type userEnableTwoFactorPayload struct {
+	// Current password of the user
+	Password string `example:"passwd" validate:"required"`
+}
+
+func (payload *userEnableTwoFactorPayload) Validate(r *http.Request) error {
+	if len(payload.Password) == 0 {
+		return errors.New("Invalid password")
+	}
+
+	return nil
+}
+
+type userEnableTwoFactorResponse struct {
+	// Base32 secret to enter in an authenticator application
+	Secret string `json:"secret" example:"JBSWY3DPEHPK3PXP"`
+	// otpauth URI of the secret, to display as a QR code
+	ProvisioningURI string `json:"provisioningUri" example:"otpauth://totp/Portainer:admin?issuer=Portainer&secret=JBSWY3DPEHPK3PXP"`
+}
+
+type userConfirmTwoFactorPayload struct {
+	// TOTP code generated from the secret
+	Code string `example:"123456" validate:"required"`
+}
+
+func (payload *userConfirmTwoFactorPayload) Validate(r *http.Request) error {
+	if len(payload.Code) == 0 {
+		return errors.New("Invalid code")
+	}
+
+	return nil
+}
+
+type userConfirmTwoFactorResponse struct {
+	// Single-use codes replacing the TOTP codes when the device is lost, they are only returned once
+	RecoveryCodes []string `json:"recoveryCodes" example:"abcdefgh-ijklmnop"`
+}
+
+// @id UserEnableTwoFactor
+// @summary Start the two-factor authentication enrolment of a user
+// @description Generate a TOTP secret for the current user. Two-factor authentication is enabled once a code generated from it is confirmed.
+// @description Only the users of the internal authentication can enable two-factor authentication.
+// @description **Access policy**: authenticated
+// @tags users
+// @security ApiKeyAuth
+// @security jwt
+// @accept json
+// @produce json
+// @param id path int true "User identifier"
+// @param body body userEnableTwoFactorPayload true "details"
+// @success 200 {object} userEnableTwoFactorResponse "Success"
+// @failure 400 "Invalid request"
+// @failure 403 "Permission denied"
+// @failure 404 "User not found"
+// @failure 409 "Two-factor authentication already enabled"
+// @failure 500 "Server error"
+// @router /users/{id}/2fa [post]
+func (handler *Handler) userEnableTwoFactor(w http.ResponseWriter, r *http.Request) *httperror.HandlerError {
+	var payload userEnableTwoFactorPayload
+	if err := request.DecodeAndValidateJSONPayload(r, &payload); err != nil {
+		return httperror.BadRequest("Invalid request payload", err)
+	}
+
+	user, httpErr := handler.twoFactorUser(r)
+	if httpErr != nil {
+		return httpErr
+	}
+
+	internalAuth, err := handler.usesInternalAuthentication(user.ID)
+	if err != nil {
+		return httperror.InternalServerError("Unable to determine the authentication method", err)
+	}
+
+	if !internalAuth {
+		return httperror.BadRequest("Two-factor authentication is only available to the internal users", errors.New("the user does not use the internal authentication"))
+	}
+
+	if err := handler.CryptoService.CompareHashAndData(user.Password, payload.Password); err != nil {
+		return httperror.Forbidden("Current password doesn't match", errors.New("Current password does not match the password provided. Please try again"))
+	}
+
+	if user.TwoFactor.Enabled {
+		return httperror.Conflict("Two-factor authentication is already enabled", errTwoFactorEnabled)
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return httperror.InternalServerError("Unable to generate the TOTP secret", err)
+	}
+
+	user.TwoFactor.PendingSecret = secret
+
+	if err := handler.DataStore.User().Update(user.ID, user); err != nil {
+		return httperror.InternalServerError("Unable to persist user changes inside the database", err)
+	}
+
+	return response.JSON(w, &userEnableTwoFactorResponse{
+		Secret:          secret,
+		ProvisioningURI: totp.ProvisioningURI(twoFactorIssuer, user.Username, secret),
+	})
+}
+
+// @id UserConfirmTwoFactor
+// @summary Confirm the two-factor authentication enrolment of a user
+// @description Enable two-factor authentication for the current user with a code generated from the secret of the enrolment.
+// @description The recovery codes returned replace the TOTP codes once each, they cannot be retrieved again.
+// @description **Access policy**: authenticated
+// @tags users
+// @security ApiKeyAuth
+// @security jwt
+// @accept json
+// @produce json
+// @param id path int true "User identifier"
+// @param body body userConfirmTwoFactorPayload true "details"
+// @success 200 {object} userConfirmTwoFactorResponse "Success"
+// @failure 400 "Invalid request"
+// @failure 403 "Permission denied"
+// @failure 404 "User not found"
+// @failure 422 "Invalid code"
+// @failure 500 "Server error"
+// @router /users/{id}/2fa/confirm [post]
+func (handler *Handler) userConfirmTwoFactor(w http.ResponseWriter, r *http.Request) *httperror.HandlerError {
+	var payload userConfirmTwoFactorPayload
+	if err := request.DecodeAndValidateJSONPayload(r, &payload); err != nil {
+		return httperror.BadRequest("Invalid request payload", err)
+	}
+
+	user, httpErr := handler.twoFactorUser(r)
+	if httpErr != nil {
+		return httpErr
+	}
+
+	if user.TwoFactor.PendingSecret == "" {
+		return httperror.BadRequest("No two-factor authentication enrolment in progress", errTwoFactorNotPending)
+	}
+
+	step, ok := totp.Validate(user.TwoFactor.PendingSecret, payload.Code, time.Now(), 0)
+	if !ok {
+		return httperror.NewError(http.StatusUnprocessableEntity, "Invalid code", errTwoFactorInvalidCode)
+	}
+
+	recoveryCodes, err := totp.GenerateRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		return httperror.InternalServerError("Unable to generate the recovery codes", err)
+	}
+
+	hashes := make([]string, 0, len(recoveryCodes))
+	for _, recoveryCode := range recoveryCodes {
+		hash, err := handler.CryptoService.Hash(recoveryCode)
+		if err != nil {
+			return httperror.InternalServerError("Unable to hash the recovery codes", errCryptoHashFailure)
+		}
+
+		hashes = append(hashes, hash)
+	}
+
+	user.TwoFactor = portainer.UserTwoFactor{
+		Enabled:       true,
+		Secret:        user.TwoFactor.PendingSecret,
+		RecoveryCodes: hashes,
+		LastStep:      step,
+	}
+
+	if err := handler.DataStore.User().Update(user.ID, user); err != nil {
+		return httperror.InternalServerError("Unable to persist user changes inside the database", err)
+	}
+
+	return response.JSON(w, &userConfirmTwoFactorResponse{RecoveryCodes: recoveryCodes})
+}
+
+// @id UserResetTwoFactor
+// @summary Reset the two-factor authentication of a user
+// @description Disable two-factor authentication for the specified user and remove its secret and recovery codes,
+// @description for example when the user lost the device and the recovery codes.
+// @description **Access policy**: administrator
+// @tags users
+// @security ApiKeyAuth
+// @security jwt
+// @param id path int true "User identifier"
+// @success 204 "Success"
+// @failure 400 "Invalid request"
+// @failure 403 "Permission denied"
+// @failure 404 "User not found"
+// @failure 500 "Server error"
+// @router /users/{id}/2fa [delete]
+func (handler *Handler) userResetTwoFactor(w http.ResponseWriter, r *http.Request) *httperror.HandlerError {
+	userID, err := request.RetrieveNumericRouteVariableValue(r, "id")
+	if err != nil {
+		return httperror.BadRequest("Invalid user identifier route variable", err)
+	}
+
+	user, err := handler.DataStore.User().Read(portainer.UserID(userID))
+	if handler.DataStore.IsErrObjectNotFound(err) {
+		return httperror.NotFound("Unable to find a user with the specified identifier inside the database", err)
+	} else if err != nil {
+		return httperror.InternalServerError("Unable to find a user with the specified identifier inside the database", err)
+	}
+
+	user.TwoFactor = portainer.UserTwoFactor{}
+
+	if err := handler.DataStore.User().Update(user.ID, user); err != nil {
+		return httperror.InternalServerError("Unable to persist user changes inside the database", err)
+	}
+
+	return response.Empty(w)
+}
+
+// twoFactorUser returns the user of the route, who must be the user of the
+// request: the second factor of a user is only enrolled by that user
+func (handler *Handler) twoFactorUser(r *http.Request) (*portainer.User, *httperror.HandlerError) {
+	userID, err := request.RetrieveNumericRouteVariableValue(r, "id")
+	if err != nil {
+		return nil, httperror.BadRequest("Invalid user identifier route variable", err)
+	}
+
+	tokenData, err := security.RetrieveTokenData(r)
+	if err != nil {
+		return nil, httperror.InternalServerError("Unable to retrieve user authentication token", err)
+	}
+
+	if tokenData.ID != portainer.UserID(userID) {
+		return nil, httperror.Forbidden("Permission denied to enrol the second factor of another user", httperrors.ErrUnauthorized)
+	}
+
+	user, err := handler.DataStore.User().Read(portainer.UserID(userID))
+	if handler.DataStore.IsErrObjectNotFound(err) {
+		return nil, httperror.NotFound("Unable to find a user with the specified identifier inside the database", err)
+	} else if err != nil {
+		return nil, httperror.InternalServerError("Unable to find a user with the specified identifier inside the database", err)
+	}
+
+	return user, nil
+}
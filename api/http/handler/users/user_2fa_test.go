@@ -0,0 +1,139 @@
+package users
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	portainer "github.com/portainer/portainer/api"
+	"github.com/portainer/portainer/api/apikey"
+	"github.com/portainer/portainer/api/crypto"
+	"github.com/portainer/portainer/api/datastore"
+	"github.com/portainer/portainer/api/http/security"
+	"github.com/portainer/portainer/api/jwt"
+	"github.com/portainer/portainer/api/totp"
+
+	"github.com/segmentio/encoding/json"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_userTwoFactor(t *testing.T) {
+	is := require.New(t)
+
+	_, store := datastore.MustNewTestStore(t, true, true)
+
+	cryptoService := &crypto.Service{}
+	password, err := cryptoService.Hash("s3cr3tp@ssw0rd")
+	is.NoError(err)
+
+	adminUser := &portainer.User{ID: 1, Username: "admin", Role: portainer.AdministratorRole}
+	is.NoError(store.User().Create(adminUser))
+
+	user := &portainer.User{ID: 2, Username: "standard", Role: portainer.StandardUserRole, Password: password}
+	is.NoError(store.User().Create(user))
+
+	jwtService, err := jwt.NewService("1h", store)
+	is.NoError(err)
+	apiKeyService := apikey.NewAPIKeyService(store.APIKeyRepository(), store.User())
+	requestBouncer := security.NewRequestBouncer(store, jwtService, apiKeyService)
+	rateLimiter := security.NewRateLimiter(10, 1*time.Second, 1*time.Hour)
+	passwordChecker := security.NewPasswordStrengthChecker(store.SettingsService)
+
+	h := NewHandler(requestBouncer, rateLimiter, apiKeyService, passwordChecker)
+	h.DataStore = store
+	h.CryptoService = cryptoService
+
+	adminJWT, _, err := jwtService.GenerateToken(&portainer.TokenData{ID: adminUser.ID, Username: adminUser.Username, Role: adminUser.Role})
+	is.NoError(err)
+	userJWT, _, err := jwtService.GenerateToken(&portainer.TokenData{ID: user.ID, Username: user.Username, Role: user.Role})
+	is.NoError(err)
+
+	serve := func(method, url, token, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, url, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+
+		return rr
+	}
+
+	t.Run("users cannot enrol the second factor of another user", func(t *testing.T) {
+		rr := serve(http.MethodPost, "/users/2/2fa", adminJWT, `{"password":"s3cr3tp@ssw0rd"}`)
+		is.Equal(http.StatusForbidden, rr.Code)
+	})
+
+	t.Run("the enrolment requires the password of the user", func(t *testing.T) {
+		rr := serve(http.MethodPost, "/users/2/2fa", userJWT, `{"password":"wrong"}`)
+		is.Equal(http.StatusForbidden, rr.Code)
+	})
+
+	var enrolment userEnableTwoFactorResponse
+
+	t.Run("the enrolment returns a secret to confirm", func(t *testing.T) {
+		rr := serve(http.MethodPost, "/users/2/2fa", userJWT, `{"password":"s3cr3tp@ssw0rd"}`)
+		is.Equal(http.StatusOK, rr.Code)
+
+		is.NoError(json.NewDecoder(rr.Body).Decode(&enrolment))
+		is.NotEmpty(enrolment.Secret)
+		is.Contains(enrolment.ProvisioningURI, "secret="+enrolment.Secret)
+
+		u, err := store.User().Read(user.ID)
+		is.NoError(err)
+		is.False(u.TwoFactor.Enabled, "two-factor authentication is enabled once confirmed")
+		is.Equal(enrolment.Secret, u.TwoFactor.PendingSecret)
+	})
+
+	t.Run("the confirmation requires a valid code", func(t *testing.T) {
+		rr := serve(http.MethodPost, "/users/2/2fa/confirm", userJWT, `{"code":"000000x"}`)
+		is.Equal(http.StatusUnprocessableEntity, rr.Code)
+	})
+
+	t.Run("the confirmation enables two-factor authentication and returns the recovery codes", func(t *testing.T) {
+		code, err := totp.Code(enrolment.Secret, time.Now())
+		is.NoError(err)
+
+		rr := serve(http.MethodPost, "/users/2/2fa/confirm", userJWT, `{"code":"`+code+`"}`)
+		is.Equal(http.StatusOK, rr.Code)
+
+		var confirmation userConfirmTwoFactorResponse
+		is.NoError(json.NewDecoder(rr.Body).Decode(&confirmation))
+		is.Len(confirmation.RecoveryCodes, recoveryCodeCount)
+
+		u, err := store.User().Read(user.ID)
+		is.NoError(err)
+		is.True(u.TwoFactor.Enabled)
+		is.Equal(enrolment.Secret, u.TwoFactor.Secret)
+		is.Empty(u.TwoFactor.PendingSecret)
+		is.Len(u.TwoFactor.RecoveryCodes, recoveryCodeCount)
+		is.NoError(cryptoService.CompareHashAndData(u.TwoFactor.RecoveryCodes[0], confirmation.RecoveryCodes[0]), "the recovery codes are stored hashed")
+	})
+
+	t.Run("the secrets are not returned", func(t *testing.T) {
+		rr := serve(http.MethodGet, "/users/me", userJWT, "")
+		is.Equal(http.StatusOK, rr.Code)
+
+		var u portainer.User
+		is.NoError(json.NewDecoder(rr.Body).Decode(&u))
+		is.Equal(portainer.UserTwoFactor{Enabled: true}, u.TwoFactor)
+	})
+
+	t.Run("two-factor authentication cannot be enabled twice", func(t *testing.T) {
+		rr := serve(http.MethodPost, "/users/2/2fa", userJWT, `{"password":"s3cr3tp@ssw0rd"}`)
+		is.Equal(http.StatusConflict, rr.Code)
+	})
+
+	t.Run("only the administrators reset two-factor authentication", func(t *testing.T) {
+		rr := serve(http.MethodDelete, "/users/2/2fa", userJWT, "")
+		is.Equal(http.StatusForbidden, rr.Code)
+
+		rr = serve(http.MethodDelete, "/users/2/2fa", adminJWT, "")
+		is.Equal(http.StatusNoContent, rr.Code)
+
+		u, err := store.User().Read(user.ID)
+		is.NoError(err)
+		is.Equal(portainer.UserTwoFactor{}, u.TwoFactor)
+	})
+}
@@ -56,7 +56,7 @@ func (handler *Handler) userCreate(w http.ResponseWriter, r *http.Request) *http
 
 	var user *portainer.User
 
-	if err := handler.DataStore.UpdateTx(func(tx dataservices.DataStoreTx) error {
+	if err := handler.DataStore.UpdateTxCtx(r.Context(), func(tx dataservices.DataStoreTx) error {
 		var err error
 		user, err = handler.createUser(tx, payload)
 
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"time"
 
 	portainer "github.com/portainer/portainer/api"
 	httperrors "github.com/portainer/portainer/api/http/errors"
@@ -18,6 +19,10 @@ import (
 type userAccessTokenCreatePayload struct {
 	Password    string `validate:"required" example:"password" json:"password"`
 	Description string `validate:"required" example:"github-api-key" json:"description"`
+	// Unix timestamp (UTC) the API key expires at, it never expires when omitted
+	ExpiresAt int64 `example:"1735689600" json:"expiresAt"`
+	// Restrictions of the API key, it grants all the rights of the user when omitted
+	Scope *portainer.APIKeyScope `json:"scope"`
 }
 
 func (payload *userAccessTokenCreatePayload) Validate(r *http.Request) error {
@@ -30,6 +35,20 @@ func (payload *userAccessTokenCreatePayload) Validate(r *http.Request) error {
 	if govalidator.MinStringLength(payload.Description, "128") {
 		return errors.New("invalid description: cannot be longer than 128 characters")
 	}
+	if payload.ExpiresAt != 0 && payload.ExpiresAt <= time.Now().Unix() {
+		return errors.New("invalid expiration date: must be in the future")
+	}
+	if payload.Scope != nil {
+		for authorization := range payload.Scope.Authorizations {
+			if !security.IsAPIKeyScopeAuthorization(authorization) {
+				return fmt.Errorf("invalid authorization: %s is unknown or cannot restrict an API key", authorization)
+			}
+		}
+	}
+	if payload.Scope != nil && !payload.Scope.ReadOnly && len(payload.Scope.EndpointIDs) == 0 && len(payload.Scope.Authorizations) == 0 {
+		// an empty scope does not restrict anything
+		payload.Scope = nil
+	}
 	return nil
 }
 
@@ -43,6 +62,8 @@ type accessTokenResponse struct {
 // @description Generates an API key for a user.
 // @description Only the calling user can generate a token for themselves.
 // @description Password is required only for internal authentication.
+// @description The API key can expire and be restricted to read-only requests, to some environments
+// @description or to some authorizations.
 // @description **Access policy**: restricted
 // @tags users
 // @security jwt
@@ -110,7 +131,17 @@ func (handler *Handler) userCreateAccessToken(w http.ResponseWriter, r *http.Req
 		}
 	}
 
-	rawAPIKey, apiKey, err := handler.apiKeyService.GenerateApiKey(*user, payload.Description)
+	if payload.Scope != nil {
+		for _, endpointID := range payload.Scope.EndpointIDs {
+			if _, err := handler.DataStore.Endpoint().Endpoint(endpointID); handler.DataStore.IsErrObjectNotFound(err) {
+				return httperror.BadRequest("Invalid request payload", fmt.Errorf("invalid scope: environment %d not found", endpointID))
+			} else if err != nil {
+				return httperror.InternalServerError("Unable to find an environment with the specified identifier inside the database", err)
+			}
+		}
+	}
+
+	rawAPIKey, apiKey, err := handler.apiKeyService.GenerateScopedApiKey(*user, payload.Description, payload.ExpiresAt, payload.Scope)
 	if err != nil {
 		return httperror.InternalServerError("Internal Server Error", err)
 	}
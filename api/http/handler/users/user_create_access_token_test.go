@@ -145,6 +145,14 @@ this string is longer than 128 characters and hence this will fail.
 `},
 			shouldFail: true,
 		},
+		{
+			payload:    userAccessTokenCreatePayload{Password: "password", Description: "test-token", Scope: &portainer.APIKeyScope{Authorizations: portainer.Authorizations{portainer.OperationDockerContainerRestart: true}}},
+			shouldFail: false,
+		},
+		{
+			payload:    userAccessTokenCreatePayload{Password: "password", Description: "test-token", Scope: &portainer.APIKeyScope{Authorizations: portainer.Authorizations{"DockerContainerRestartt": true}}},
+			shouldFail: true,
+		},
 	}
 
 	for _, test := range tests {
@@ -156,3 +164,90 @@ this string is longer than 128 characters and hence this will fail.
 		}
 	}
 }
+
+func Test_userCreateScopedAccessToken(t *testing.T) {
+	is := assert.New(t)
+
+	_, store := datastore.MustNewTestStore(t, true, true)
+
+	adminUser := &portainer.User{ID: 1, Username: "admin", Role: portainer.AdministratorRole}
+	is.NoError(store.User().Create(adminUser))
+
+	user := &portainer.User{ID: 2, Password: "password", Username: "standard", Role: portainer.StandardUserRole}
+	is.NoError(store.User().Create(user))
+
+	jwtService, err := jwt.NewService("1h", store)
+	is.NoError(err)
+	apiKeyService := apikey.NewAPIKeyService(store.APIKeyRepository(), store.User())
+	requestBouncer := security.NewRequestBouncer(store, jwtService, apiKeyService)
+	rateLimiter := security.NewRateLimiter(10, 1*time.Second, 1*time.Hour)
+	passwordChecker := security.NewPasswordStrengthChecker(store.SettingsService)
+
+	h := NewHandler(requestBouncer, rateLimiter, apiKeyService, passwordChecker)
+	h.DataStore = store
+	h.CryptoService = testhelpers.NewCryptoService()
+
+	jwt, _, _ := jwtService.GenerateToken(&portainer.TokenData{ID: user.ID, Username: user.Username, Role: user.Role})
+
+	t.Run("standard user generates a scoped API key that expires", func(t *testing.T) {
+		expiresAt := time.Now().Add(time.Hour).Unix()
+		data := userAccessTokenCreatePayload{
+			Password:    "password",
+			Description: "test-scoped-token",
+			ExpiresAt:   expiresAt,
+			Scope:       &portainer.APIKeyScope{ReadOnly: true},
+		}
+		payload, err := json.Marshal(data)
+		is.NoError(err)
+
+		req := httptest.NewRequest(http.MethodPost, "/users/2/tokens", bytes.NewBuffer(payload))
+		req.Header.Set("Authorization", "Bearer "+jwt)
+
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+
+		is.Equal(http.StatusCreated, rr.Code)
+
+		var resp accessTokenResponse
+		is.NoError(json.NewDecoder(rr.Body).Decode(&resp))
+		is.Equal(expiresAt, resp.APIKey.ExpiresAt)
+		is.Equal(data.Scope, resp.APIKey.Scope)
+
+		// the read-only key cannot revoke a key
+		req = httptest.NewRequest(http.MethodDelete, "/users/2/tokens/1", nil)
+		req.Header.Add("x-api-key", resp.RawAPIKey)
+
+		rr = httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+
+		is.Equal(http.StatusForbidden, rr.Code)
+	})
+
+	t.Run("standard user cannot generate an API key that already expired", func(t *testing.T) {
+		data := userAccessTokenCreatePayload{Password: "password", Description: "test-expired-token", ExpiresAt: time.Now().Add(-time.Hour).Unix()}
+		payload, err := json.Marshal(data)
+		is.NoError(err)
+
+		req := httptest.NewRequest(http.MethodPost, "/users/2/tokens", bytes.NewBuffer(payload))
+		req.Header.Set("Authorization", "Bearer "+jwt)
+
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+
+		is.Equal(http.StatusBadRequest, rr.Code)
+	})
+
+	t.Run("standard user cannot scope an API key to an unknown environment", func(t *testing.T) {
+		data := userAccessTokenCreatePayload{Password: "password", Description: "test-environment-token", Scope: &portainer.APIKeyScope{EndpointIDs: []portainer.EndpointID{42}}}
+		payload, err := json.Marshal(data)
+		is.NoError(err)
+
+		req := httptest.NewRequest(http.MethodPost, "/users/2/tokens", bytes.NewBuffer(payload))
+		req.Header.Set("Authorization", "Bearer "+jwt)
+
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+
+		is.Equal(http.StatusBadRequest, rr.Code)
+	})
+}
@@ -0,0 +1,190 @@
+package users
+
+import (
+	"cmp"
+	"net/http"
+	"slices"
+	"time"
+
+	portainer "github.com/portainer/portainer/api"
+	httperrors "github.com/portainer/portainer/api/http/errors"
+	"github.com/portainer/portainer/api/http/security"
+	httperror "github.com/portainer/portainer/pkg/libhttp/error"
+	"github.com/portainer/portainer/pkg/libhttp/request"
+	"github.com/portainer/portainer/pkg/libhttp/response"
+)
+
+// @id UserListSessions
+// @summary List the active sessions of a user
+// @description List the sessions of a user that are neither revoked nor expired, the most recent first.
+// @description Only the calling user or an administrator can list the sessions.
+// @description **Access policy**: restricted
+// @tags users
+// @security ApiKeyAuth
+// @security jwt
+// @produce json
+// @param id path int true "User identifier"
+// @success 200 {array} portainer.UserSession "Success"
+// @failure 400 "Invalid request"
+// @failure 403 "Permission denied"
+// @failure 404 "User not found"
+// @failure 500 "Server error"
+// @router /users/{id}/sessions [get]
+func (handler *Handler) userListSessions(w http.ResponseWriter, r *http.Request) *httperror.HandlerError {
+	user, herr := handler.sessionsOwner(r)
+	if herr != nil {
+		return herr
+	}
+
+	sessions, err := handler.DataStore.UserSession().SessionsByUserID(user.ID)
+	if err != nil {
+		return httperror.InternalServerError("Unable to retrieve the user sessions from the database", err)
+	}
+
+	now := time.Now().Unix()
+	sessions = slices.DeleteFunc(sessions, func(session portainer.UserSession) bool {
+		return !sessionActive(session, user, now)
+	})
+
+	slices.SortFunc(sessions, func(a, b portainer.UserSession) int {
+		return cmp.Compare(b.IssuedAt, a.IssuedAt)
+	})
+
+	return response.JSON(w, sessions)
+}
+
+// @id UserRevokeSession
+// @summary Revoke a session of a user
+// @description Revoke a session of a user, the token it was opened with is rejected by all the instances.
+// @description Only the calling user or an administrator can revoke the session.
+// @description **Access policy**: restricted
+// @tags users
+// @security ApiKeyAuth
+// @security jwt
+// @param id path int true "User identifier"
+// @param jti path string true "Token identifier of the session"
+// @success 204 "Success"
+// @failure 400 "Invalid request"
+// @failure 403 "Permission denied"
+// @failure 404 "User or session not found"
+// @failure 500 "Server error"
+// @router /users/{id}/sessions/{jti} [delete]
+func (handler *Handler) userRevokeSession(w http.ResponseWriter, r *http.Request) *httperror.HandlerError {
+	jti, err := request.RetrieveRouteVariableValue(r, "jti")
+	if err != nil {
+		return httperror.BadRequest("Invalid session identifier route variable", err)
+	}
+
+	user, herr := handler.sessionsOwner(r)
+	if herr != nil {
+		return herr
+	}
+
+	session, err := handler.DataStore.UserSession().SessionByJTI(jti)
+	if handler.DataStore.IsErrObjectNotFound(err) || (err == nil && session.UserID != user.ID) {
+		return httperror.NotFound("Unable to find a session with the specified identifier inside the database", err)
+	} else if err != nil {
+		return httperror.InternalServerError("Unable to find a session with the specified identifier inside the database", err)
+	}
+
+	if session.RevokedAt == 0 {
+		session.RevokedAt = time.Now().Unix()
+
+		if err := handler.DataStore.UserSession().Update(session.ID, session); err != nil {
+			return httperror.InternalServerError("Unable to persist the session changes inside the database", err)
+		}
+
+		handler.bouncer.InvalidateSessionCache()
+	}
+
+	return response.Empty(w)
+}
+
+// @id UserRevokeSessions
+// @summary Revoke all the sessions of a user
+// @description Revoke all the sessions of a user, the user has to log in again on every device.
+// @description **Access policy**: administrator
+// @tags users
+// @security ApiKeyAuth
+// @security jwt
+// @param id path int true "User identifier"
+// @success 204 "Success"
+// @failure 400 "Invalid request"
+// @failure 404 "User not found"
+// @failure 500 "Server error"
+// @router /users/{id}/sessions [delete]
+func (handler *Handler) userRevokeSessions(w http.ResponseWriter, r *http.Request) *httperror.HandlerError {
+	userID, err := request.RetrieveNumericRouteVariableValue(r, "id")
+	if err != nil {
+		return httperror.BadRequest("Invalid user identifier route variable", err)
+	}
+
+	user, err := handler.DataStore.User().Read(portainer.UserID(userID))
+	if handler.DataStore.IsErrObjectNotFound(err) {
+		return httperror.NotFound("Unable to find a user with the specified identifier inside the database", err)
+	} else if err != nil {
+		return httperror.InternalServerError("Unable to find a user with the specified identifier inside the database", err)
+	}
+
+	// The tokens issued without a session, such as the kubeconfig tokens, are
+	// rejected as well
+	user.TokenIssueAt = time.Now().Unix()
+
+	if err := handler.DataStore.User().Update(user.ID, user); err != nil {
+		return httperror.InternalServerError("Unable to persist user changes inside the database", err)
+	}
+
+	if err := handler.revokeUserSessions(user.ID); err != nil {
+		return httperror.InternalServerError("Unable to revoke the user sessions", err)
+	}
+
+	return response.Empty(w)
+}
+
+// sessionsOwner returns the user whose sessions are requested, once the
+// calling user is allowed to manage them
+func (handler *Handler) sessionsOwner(r *http.Request) (*portainer.User, *httperror.HandlerError) {
+	userID, err := request.RetrieveNumericRouteVariableValue(r, "id")
+	if err != nil {
+		return nil, httperror.BadRequest("Invalid user identifier route variable", err)
+	}
+
+	tokenData, err := security.RetrieveTokenData(r)
+	if err != nil {
+		return nil, httperror.InternalServerError("Unable to retrieve user authentication token", err)
+	}
+
+	if tokenData.Role != portainer.AdministratorRole && tokenData.ID != portainer.UserID(userID) {
+		return nil, httperror.Forbidden("Permission denied to manage the user sessions", httperrors.ErrUnauthorized)
+	}
+
+	user, err := handler.DataStore.User().Read(portainer.UserID(userID))
+	if handler.DataStore.IsErrObjectNotFound(err) {
+		return nil, httperror.NotFound("Unable to find a user with the specified identifier inside the database", err)
+	} else if err != nil {
+		return nil, httperror.InternalServerError("Unable to find a user with the specified identifier inside the database", err)
+	}
+
+	return user, nil
+}
+
+// revokeUserSessions marks all the sessions of a user revoked, it goes with
+// an update of the TokenIssueAt of the user that rejects their tokens
+func (handler *Handler) revokeUserSessions(userID portainer.UserID) error {
+	if err := handler.DataStore.UserSession().RevokeUserSessions(userID, time.Now().Unix()); err != nil {
+		return err
+	}
+
+	handler.bouncer.InvalidateSessionCache()
+
+	return nil
+}
+
+// sessionActive returns whether the token of a session is still accepted
+func sessionActive(session portainer.UserSession, user *portainer.User, now int64) bool {
+	if session.RevokedAt != 0 || session.IssuedAt < user.TokenIssueAt {
+		return false
+	}
+
+	return session.ExpiresAt == 0 || session.ExpiresAt > now
+}
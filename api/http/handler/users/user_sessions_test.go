@@ -0,0 +1,127 @@
+package users
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	portainer "github.com/portainer/portainer/api"
+	"github.com/portainer/portainer/api/apikey"
+	"github.com/portainer/portainer/api/datastore"
+	"github.com/portainer/portainer/api/http/security"
+	"github.com/portainer/portainer/api/jwt"
+
+	"github.com/segmentio/encoding/json"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_userSessions(t *testing.T) {
+	is := require.New(t)
+
+	_, store := datastore.MustNewTestStore(t, true, true)
+
+	adminUser := &portainer.User{ID: 1, Username: "admin", Role: portainer.AdministratorRole}
+	is.NoError(store.User().Create(adminUser))
+
+	user := &portainer.User{ID: 2, Username: "standard", Role: portainer.StandardUserRole}
+	is.NoError(store.User().Create(user))
+
+	jwtService, err := jwt.NewService("1h", store)
+	is.NoError(err)
+	apiKeyService := apikey.NewAPIKeyService(store.APIKeyRepository(), store.User())
+	requestBouncer := security.NewRequestBouncer(store, jwtService, apiKeyService)
+	rateLimiter := security.NewRateLimiter(10, 1*time.Second, 1*time.Hour)
+	passwordChecker := security.NewPasswordStrengthChecker(store.SettingsService)
+
+	h := NewHandler(requestBouncer, rateLimiter, apiKeyService, passwordChecker)
+	h.DataStore = store
+
+	now := time.Now().Unix()
+
+	// openSession issues a token and records its session
+	openSession := func(u *portainer.User) (string, string) {
+		token, exp, err := jwtService.GenerateToken(&portainer.TokenData{ID: u.ID, Username: u.Username, Role: u.Role})
+		is.NoError(err)
+
+		_, jti, _, err := jwtService.ParseAndVerifyToken(token)
+		is.NoError(err)
+
+		is.NoError(store.UserSession().Create(&portainer.UserSession{JTI: jti, UserID: u.ID, IssuedAt: now, ExpiresAt: exp.Unix()}))
+
+		return token, jti
+	}
+
+	serve := func(method, url, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, url, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+
+		return rr
+	}
+
+	adminJWT, _ := openSession(adminUser)
+	userJWT, userJTI := openSession(user)
+	otherJWT, otherJTI := openSession(user)
+
+	is.NoError(store.UserSession().Create(&portainer.UserSession{JTI: "revoked", UserID: user.ID, IssuedAt: now, ExpiresAt: now + 3600, RevokedAt: now}))
+	is.NoError(store.UserSession().Create(&portainer.UserSession{JTI: "expired", UserID: user.ID, IssuedAt: now - 7200, ExpiresAt: now - 3600}))
+
+	t.Run("a user lists their active sessions", func(t *testing.T) {
+		is := require.New(t)
+
+		rr := serve(http.MethodGet, "/users/2/sessions", userJWT)
+		is.Equal(http.StatusOK, rr.Code)
+
+		var sessions []portainer.UserSession
+		is.NoError(json.NewDecoder(rr.Body).Decode(&sessions))
+
+		jtis := make([]string, 0, len(sessions))
+		for _, session := range sessions {
+			jtis = append(jtis, session.JTI)
+		}
+		is.ElementsMatch([]string{userJTI, otherJTI}, jtis)
+	})
+
+	t.Run("a user cannot list the sessions of another user", func(t *testing.T) {
+		rr := serve(http.MethodGet, "/users/1/sessions", userJWT)
+		require.Equal(t, http.StatusForbidden, rr.Code)
+	})
+
+	t.Run("a revoked session is rejected", func(t *testing.T) {
+		is := require.New(t)
+
+		rr := serve(http.MethodDelete, "/users/2/sessions/"+otherJTI, userJWT)
+		is.Equal(http.StatusNoContent, rr.Code)
+
+		session, err := store.UserSession().SessionByJTI(otherJTI)
+		is.NoError(err)
+		is.NotZero(session.RevokedAt)
+
+		rr = serve(http.MethodGet, "/users/2/sessions", otherJWT)
+		is.Equal(http.StatusUnauthorized, rr.Code)
+	})
+
+	t.Run("a user cannot revoke the sessions of another user", func(t *testing.T) {
+		rr := serve(http.MethodDelete, "/users/2/sessions", userJWT)
+		require.Equal(t, http.StatusForbidden, rr.Code)
+	})
+
+	t.Run("an admin revokes all the sessions of a user", func(t *testing.T) {
+		is := require.New(t)
+
+		rr := serve(http.MethodDelete, "/users/2/sessions", adminJWT)
+		is.Equal(http.StatusNoContent, rr.Code)
+
+		sessions, err := store.UserSession().SessionsByUserID(user.ID)
+		is.NoError(err)
+		for _, session := range sessions {
+			is.NotZero(session.RevokedAt, session.JTI)
+		}
+
+		rr = serve(http.MethodGet, "/users/2/sessions", userJWT)
+		is.Equal(http.StatusUnauthorized, rr.Code)
+	})
+}
@@ -115,6 +115,9 @@ func (handler *Handler) userUpdate(w http.ResponseWriter, r *http.Request) *http
 		return httperror.BadRequest("Existing password field specified without new password field.", errors.New("To change the password, you must include both 'password' and 'newPassword' in your request"))
 	}
 
+	// The sessions of the user are revoked when their password or role changes
+	revokeSessions := false
+
 	if payload.NewPassword != "" {
 		// Non-admins need to supply the previous password
 		if tokenData.Role != portainer.AdministratorRole {
@@ -132,6 +135,7 @@ func (handler *Handler) userUpdate(w http.ResponseWriter, r *http.Request) *http
 			return httperror.InternalServerError("Unable to hash user password", errCryptoHashFailure)
 		}
 		user.TokenIssueAt = time.Now().Unix()
+		revokeSessions = true
 	}
 
 	if payload.Theme != nil {
@@ -143,17 +147,24 @@ func (handler *Handler) userUpdate(w http.ResponseWriter, r *http.Request) *http
 	if payload.Role != 0 {
 		user.Role = portainer.UserRole(payload.Role)
 		user.TokenIssueAt = time.Now().Unix()
+		revokeSessions = true
 	}
 
 	if err := handler.DataStore.User().Update(user.ID, user); err != nil {
 		return httperror.InternalServerError("Unable to persist user changes inside the database", err)
 	}
 
+	if revokeSessions {
+		if err := handler.revokeUserSessions(user.ID); err != nil {
+			return httperror.InternalServerError("Unable to revoke the user sessions", err)
+		}
+	}
+
 	// remove all of the users persisted API keys
 	handler.apiKeyService.InvalidateUserKeyCache(user.ID)
 
-	// hide the password field in the response payload
-	user.Password = ""
+	// hide the password and the second factor secrets in the response payload
+	hideFields(user)
 
 	return response.JSON(w, user)
 }
@@ -96,5 +96,9 @@ func (handler *Handler) userUpdatePassword(w http.ResponseWriter, r *http.Reques
 		return httperror.InternalServerError("Unable to persist user changes inside the database", err)
 	}
 
+	if err := handler.revokeUserSessions(user.ID); err != nil {
+		return httperror.InternalServerError("Unable to revoke the user sessions", err)
+	}
+
 	return response.Empty(w)
 }
@@ -2,11 +2,13 @@ package websocket
 
 import (
 	"context"
+	"errors"
 	"net"
 	"net/http"
 	"net/url"
 
 	portainer "github.com/portainer/portainer/api"
+	"github.com/portainer/portainer/api/chisel"
 	"github.com/portainer/portainer/api/crypto"
 	"github.com/portainer/portainer/api/http/security"
 	"github.com/portainer/portainer/api/logoutcontext"
@@ -18,7 +20,9 @@ import (
 
 func (handler *Handler) proxyEdgeAgentWebsocketRequest(w http.ResponseWriter, r *http.Request, params *webSocketRequestParams) error {
 	tunnelAddr, err := handler.ReverseTunnelService.TunnelAddr(params.endpoint)
-	if err != nil {
+	if errors.Is(err, chisel.ErrRemoteTunnel) && handler.TunnelForwarder != nil {
+		return handler.forwardEdgeAgentWebsocketRequest(w, r, params)
+	} else if err != nil {
 		return err
 	}
 
@@ -30,6 +34,19 @@ func (handler *Handler) proxyEdgeAgentWebsocketRequest(w http.ResponseWriter, r
 	return handler.doProxyWebsocketRequest(w, r, params, agentURL, true)
 }
 
+// forwardEdgeAgentWebsocketRequest forwards the request to the instance
+// holding the tunnel of the agent
+func (handler *Handler) forwardEdgeAgentWebsocketRequest(w http.ResponseWriter, r *http.Request, params *webSocketRequestParams) error {
+	proxy, err := handler.TunnelForwarder.NewProxy(handler.ReverseTunnelService.TunnelOwner(params.endpoint.ID))
+	if err != nil {
+		return err
+	}
+
+	proxy.ServeHTTP(w, r)
+
+	return nil
+}
+
 func (handler *Handler) proxyAgentWebsocketRequest(w http.ResponseWriter, r *http.Request, params *webSocketRequestParams) error {
 	endpointURL := params.endpoint.URL
 	if params.endpoint.Type == portainer.AgentOnKubernetesEnvironment {
@@ -1,8 +1,10 @@
 package offlinegate
 
 import (
+	"context"
 	"net/http"
 	"strings"
+	"sync"
 	"time"
 
 	httperror "github.com/portainer/portainer/pkg/libhttp/error"
@@ -32,6 +34,50 @@ func (o *OfflineGate) Lock() func() {
 	return o.lock.Unlock
 }
 
+// HealthSource reports when the database stops or starts answering again
+type HealthSource interface {
+	OnHealthChange(fn func(healthy bool))
+}
+
+// HoldWhileUnhealthy locks the gate while the database of source cannot be
+// reached, the write requests wait for it to answer again instead of failing.
+// The gate is locked once the write requests in flight are done.
+func (o *OfflineGate) HoldWhileUnhealthy(source HealthSource) {
+	var mu sync.Mutex
+	var release func()
+
+	source.OnHealthChange(func(healthy bool) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if !healthy && release == nil {
+			release = o.hold()
+		} else if healthy && release != nil {
+			release()
+			release = nil
+		}
+	})
+}
+
+// hold locks the gate in the background, the returned function unlocks it
+// or gives up locking it
+func (o *OfflineGate) hold() func() {
+	ctx, cancel := context.WithCancel(context.Background())
+	locked := make(chan bool, 1)
+
+	go func() {
+		locked <- o.lock.TryLockWithContext(ctx)
+	}()
+
+	return func() {
+		cancel()
+
+		if <-locked {
+			o.lock.Unlock()
+		}
+	}
+}
+
 // WaitingMiddleware returns an http handler that waits for the gate to be unlocked before continuing
 func (o *OfflineGate) WaitingMiddleware(timeout time.Duration, next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
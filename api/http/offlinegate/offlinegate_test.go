@@ -146,3 +146,56 @@ func Test_waitingMiddleware_mayTimeout_whenLockedForTooLong(t *testing.T) {
 
 	assert.Equal(t, http.StatusRequestTimeout, response.Result().StatusCode, "Request support to timeout waiting for the gate")
 }
+
+type healthSource struct {
+	fn func(healthy bool)
+}
+
+func (s *healthSource) OnHealthChange(fn func(healthy bool)) {
+	s.fn = fn
+}
+
+func Test_holdWhileUnhealthy_holdsWritesUntilTheDatabaseAnswers(t *testing.T) {
+	o := NewOfflineGate()
+	source := &healthSource{}
+	o.HoldWhileUnhealthy(source)
+
+	source.fn(false)
+
+	assert.Eventually(t, func() bool {
+		if !o.lock.TryLock() {
+			return true
+		}
+
+		o.lock.Unlock()
+
+		return false
+	}, time.Second, 10*time.Millisecond, "the gate should be locked while the database is unreachable")
+
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		source.fn(true)
+	}()
+
+	request := httptest.NewRequest(http.MethodPost, "/", nil)
+	response := httptest.NewRecorder()
+
+	start := time.Now()
+	o.WaitingMiddleware(5*time.Second, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("success"))
+	})).ServeHTTP(response, request)
+
+	assert.Equal(t, "success", response.Body.String())
+	assert.GreaterOrEqual(t, time.Since(start), 100*time.Millisecond, "the write request should wait for the database")
+
+	// Reads are not held
+	source.fn(false)
+	request = httptest.NewRequest(http.MethodGet, "/", nil)
+	response = httptest.NewRecorder()
+	o.WaitingMiddleware(time.Millisecond, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("success"))
+	})).ServeHTTP(response, request)
+	assert.Equal(t, "success", response.Body.String())
+
+	source.fn(true)
+}
@@ -0,0 +1,410 @@
+package forward
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	portainer "github.com/portainer/portainer/api"
+	"github.com/portainer/portainer/api/dataservices"
+	httperror "github.com/portainer/portainer/pkg/libhttp/error"
+
+	gorillacsrf "github.com/gorilla/csrf"
+	"github.com/rs/zerolog/log"
+)
+
+// SignatureHeader carries the signature of a request forwarded by another instance
+const SignatureHeader = "X-Portainer-Forward-Signature"
+
+const (
+	secretLen = 32
+	// maxClockSkew bounds the age of a signature, the nonces of the signatures
+	// are remembered for as long as they can be accepted
+	maxClockSkew = 30 * time.Second
+	nonceLen     = 16
+	// secretTTL is how long the secret is used before it is read again, the
+	// instances converge on the same secret when several of them created one
+	secretTTL = time.Minute
+	// secretRefreshInterval limits how often a request with an invalid
+	// signature reloads the secret
+	secretRefreshInterval = 10 * time.Second
+	// maxMemoryBodySize is the size above which the bodies of the forwarded
+	// requests are buffered in a temporary file to compute their digest
+	maxMemoryBodySize = 10 << 20
+)
+
+// signedHeaders are the headers covered by the signature, a forwarded request
+// is authenticated with them
+var signedHeaders = []string{"Authorization", "X-API-Key", "Cookie"}
+
+var (
+	errInvalidSignature = errors.New("invalid forwarding signature")
+	errForwardingLoop   = errors.New("the request was already forwarded by another instance")
+)
+
+type forwardedKey struct{}
+
+type bodyDigestKey struct{}
+
+// Forwarder forwards the requests of the environments whose tunnel is held by
+// another instance. The requests are signed with a secret shared through the
+// store, the instance receiving them trusts the CSRF check made by the sender.
+// A signature is accepted once.
+type Forwarder struct {
+	dataStore dataservices.DataStore
+	mu        sync.Mutex
+	secret    []byte
+	loadedAt  time.Time
+	now       func() time.Time
+
+	noncesMu sync.Mutex
+	// nonces maps the nonces of the accepted signatures to the time they expire at
+	nonces    map[string]time.Time
+	nextPurge time.Time
+}
+
+// NewForwarder creates a new instance of a Forwarder
+func NewForwarder(dataStore dataservices.DataStore) *Forwarder {
+	return &Forwarder{
+		dataStore: dataStore,
+		now:       time.Now,
+		nonces:    make(map[string]time.Time),
+	}
+}
+
+// NewProxy returns a proxy forwarding the requests to the instance reachable at
+// addr. The requests keep the path they were received with, the instance
+// serves them as if they were sent to it directly.
+func (f *Forwarder) NewProxy(addr string) (http.Handler, error) {
+	target, err := url.Parse(addr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid instance address %s: %w", addr, err)
+	}
+
+	proxy := &httputil.ReverseProxy{
+		Rewrite: func(pr *httputil.ProxyRequest) {
+			pr.Out.URL = forwardedURL(target, pr.In)
+			pr.Out.Host = target.Host
+			pr.SetXForwarded()
+
+			digest, _ := pr.In.Context().Value(bodyDigestKey{}).([]byte)
+			if err := f.sign(pr.Out, digest); err != nil {
+				log.Error().Err(err).Msg("unable to sign the forwarded request")
+			}
+		},
+		ModifyResponse: func(resp *http.Response) error {
+			// The CSRF token and cookie of the other instance are not valid on this one
+			resp.Header.Del("Set-Cookie")
+			resp.Header.Del("X-CSRF-Token")
+
+			return nil
+		},
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			httperror.WriteError(w, http.StatusBadGateway, "Unable to forward the request to the Portainer instance holding the tunnel", err)
+		},
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if IsForwarded(r) {
+			httperror.WriteError(w, http.StatusBadGateway, "The tunnel of the environment is held by another Portainer instance", errForwardingLoop)
+
+			return
+		}
+
+		// The signature covers the body, it is read before being forwarded
+		body, digest, err := bufferBody(r.Body)
+		if err != nil {
+			httperror.WriteError(w, http.StatusBadRequest, "Unable to read the request body", err)
+
+			return
+		}
+		defer body.Close()
+
+		r.Body = body
+		proxy.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), bodyDigestKey{}, digest)))
+	}), nil
+}
+
+// forwardedURL returns the URL of the request on the target instance, the
+// handlers may have stripped a prefix from the URL of the request
+func forwardedURL(target *url.URL, r *http.Request) *url.URL {
+	uri := r.URL
+	if parsed, err := url.ParseRequestURI(r.RequestURI); err == nil {
+		uri = parsed
+	}
+
+	out := *target
+	out.Path = strings.TrimSuffix(target.Path, "/") + uri.Path
+	out.RawPath = ""
+	if uri.RawPath != "" {
+		out.RawPath = strings.TrimSuffix(target.EscapedPath(), "/") + uri.RawPath
+	}
+	out.RawQuery = uri.RawQuery
+
+	return &out
+}
+
+// WithForwardedRequests verifies the signature of the requests forwarded by
+// another instance. The requests without a signature are served as is.
+func (f *Forwarder) WithForwardedRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get(SignatureHeader) == "" {
+			next.ServeHTTP(w, r)
+
+			return
+		}
+
+		body, digest, err := bufferBody(r.Body)
+		if err != nil {
+			httperror.WriteError(w, http.StatusBadRequest, "Unable to read the request body", err)
+
+			return
+		}
+		defer body.Close()
+
+		r.Body = body
+
+		if !f.verify(r, digest) {
+			httperror.WriteError(w, http.StatusForbidden, "Invalid forwarded request", errInvalidSignature)
+
+			return
+		}
+
+		r.Header.Del(SignatureHeader)
+		r = r.WithContext(context.WithValue(r.Context(), forwardedKey{}, true))
+
+		next.ServeHTTP(w, gorillacsrf.UnsafeSkipCheck(r))
+	})
+}
+
+// IsForwarded returns true when the request was forwarded by another instance
+func IsForwarded(r *http.Request) bool {
+	forwarded, _ := r.Context().Value(forwardedKey{}).(bool)
+
+	return forwarded
+}
+
+func (f *Forwarder) sign(r *http.Request, bodyDigest []byte) error {
+	secret, err := f.key(false)
+	if err != nil {
+		return err
+	}
+
+	nonce := make([]byte, nonceLen)
+	if _, err := rand.Read(nonce); err != nil {
+		return fmt.Errorf("unable to generate the signature nonce: %w", err)
+	}
+
+	timestamp := f.now().Unix()
+	encodedNonce := base64.RawURLEncoding.EncodeToString(nonce)
+	sig := signature(secret, timestamp, encodedNonce, r, bodyDigest)
+
+	r.Header.Set(SignatureHeader, strconv.FormatInt(timestamp, 10)+"."+encodedNonce+"."+base64.RawURLEncoding.EncodeToString(sig))
+
+	return nil
+}
+
+func (f *Forwarder) verify(r *http.Request, bodyDigest []byte) bool {
+	parts := strings.Split(r.Header.Get(SignatureHeader), ".")
+	if len(parts) != 3 {
+		return false
+	}
+
+	timestamp, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil || f.now().Sub(time.Unix(timestamp, 0)).Abs() > maxClockSkew {
+		return false
+	}
+
+	nonce := parts[1]
+	if decoded, err := base64.RawURLEncoding.DecodeString(nonce); err != nil || len(decoded) != nonceLen {
+		return false
+	}
+
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return false
+	}
+
+	// The secret may have been replaced by another instance
+	for _, refresh := range []bool{false, true} {
+		secret, err := f.key(refresh)
+		if err != nil {
+			log.Error().Err(err).Msg("unable to verify the forwarded request")
+
+			return false
+		}
+
+		if hmac.Equal(sig, signature(secret, timestamp, nonce, r, bodyDigest)) {
+			return f.useNonce(nonce, time.Unix(timestamp, 0).Add(maxClockSkew))
+		}
+	}
+
+	return false
+}
+
+// useNonce returns false when the nonce of a signature was already used. The
+// nonces are forgotten once their signature expired, it is rejected by then.
+func (f *Forwarder) useNonce(nonce string, expiresAt time.Time) bool {
+	f.noncesMu.Lock()
+	defer f.noncesMu.Unlock()
+
+	if now := f.now(); now.After(f.nextPurge) {
+		for n, expiry := range f.nonces {
+			if now.After(expiry) {
+				delete(f.nonces, n)
+			}
+		}
+
+		f.nextPurge = now.Add(maxClockSkew)
+	}
+
+	if _, ok := f.nonces[nonce]; ok {
+		return false
+	}
+
+	f.nonces[nonce] = expiresAt
+
+	return true
+}
+
+// signature covers the target of the request, its body and the headers
+// authenticating it
+func signature(secret []byte, timestamp int64, nonce string, r *http.Request, bodyDigest []byte) []byte {
+	mac := hmac.New(sha256.New, secret)
+	fmt.Fprintf(mac, "%d\n%s\n%s\n%s\n%s\n%x", timestamp, nonce, r.Method, r.Host, r.URL.RequestURI(), bodyDigest)
+
+	for _, name := range signedHeaders {
+		fmt.Fprintf(mac, "\n%s:%q", name, r.Header.Values(name))
+	}
+
+	return mac.Sum(nil)
+}
+
+// bufferedBody is a request body read ahead, it is kept in a temporary file
+// when it is too large to be kept in memory
+type bufferedBody struct {
+	io.Reader
+	file *os.File
+}
+
+func (b *bufferedBody) Close() error {
+	if b.file == nil {
+		return nil
+	}
+
+	b.file.Close()
+
+	if err := os.Remove(b.file.Name()); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	return nil
+}
+
+// bufferBody reads the body of a request and returns a copy of it with its
+// SHA-256 digest
+func bufferBody(body io.ReadCloser) (io.ReadCloser, []byte, error) {
+	digest := sha256.New()
+
+	if body == nil || body == http.NoBody {
+		return http.NoBody, digest.Sum(nil), nil
+	}
+	defer body.Close()
+
+	var buf bytes.Buffer
+	if _, err := io.CopyN(io.MultiWriter(&buf, digest), body, maxMemoryBodySize+1); errors.Is(err, io.EOF) {
+		return &bufferedBody{Reader: &buf}, digest.Sum(nil), nil
+	} else if err != nil {
+		return nil, nil, err
+	}
+
+	file, err := os.CreateTemp("", "portainer-forward-")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	buffered := &bufferedBody{Reader: file, file: file}
+
+	if _, err := buf.WriteTo(file); err != nil {
+		buffered.Close()
+
+		return nil, nil, err
+	}
+
+	if _, err := io.Copy(io.MultiWriter(file, digest), body); err != nil {
+		buffered.Close()
+
+		return nil, nil, err
+	}
+
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		buffered.Close()
+
+		return nil, nil, err
+	}
+
+	return buffered, digest.Sum(nil), nil
+}
+
+// key returns the forwarding secret, it is read from the store again once it
+// expired or when refresh is set
+func (f *Forwarder) key(refresh bool) ([]byte, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	age := f.now().Sub(f.loadedAt)
+	if f.secret != nil && age < secretTTL && (!refresh || age < secretRefreshInterval) {
+		return f.secret, nil
+	}
+
+	secret, err := f.loadSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	f.secret = secret
+	f.loadedAt = f.now()
+
+	return secret, nil
+}
+
+// loadSecret reads the forwarding secret, the first instance to need it creates it
+func (f *Forwarder) loadSecret() ([]byte, error) {
+	info, err := f.dataStore.TunnelServer().Info()
+	if err != nil && !f.dataStore.IsErrObjectNotFound(err) {
+		return nil, fmt.Errorf("unable to read the forwarding secret: %w", err)
+	}
+
+	if info == nil {
+		info = &portainer.TunnelServerInfo{}
+	}
+
+	if len(info.ForwardingSecret) > 0 {
+		return info.ForwardingSecret, nil
+	}
+
+	info.ForwardingSecret = make([]byte, secretLen)
+	if _, err := rand.Read(info.ForwardingSecret); err != nil {
+		return nil, fmt.Errorf("unable to generate the forwarding secret: %w", err)
+	}
+
+	if err := f.dataStore.TunnelServer().UpdateInfo(info); err != nil {
+		return nil, fmt.Errorf("unable to persist the forwarding secret: %w", err)
+	}
+
+	return info.ForwardingSecret, nil
+}
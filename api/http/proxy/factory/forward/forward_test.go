@@ -0,0 +1,213 @@
+package forward
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/portainer/portainer/api/datastore"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestForwardedRequestKeepsItsPath(t *testing.T) {
+	is := require.New(t)
+
+	_, store := datastore.MustNewTestStore(t, true, true)
+	forwarder := NewForwarder(store)
+
+	var forwarded bool
+	var requestURI string
+	owner := httptest.NewServer(forwarder.WithForwardedRequests(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		forwarded = IsForwarded(r)
+		requestURI = r.URL.RequestURI()
+
+		http.SetCookie(w, &http.Cookie{Name: "_gorilla_csrf", Value: "owner"})
+		w.Header().Set("X-CSRF-Token", "owner")
+	})))
+	defer owner.Close()
+
+	proxy, err := forwarder.NewProxy(owner.URL)
+	is.NoError(err)
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/api/endpoints/1/docker/containers/json?all=1", nil)
+	http.StripPrefix("/api/endpoints/1/docker", proxy).ServeHTTP(rec, req)
+
+	is.Equal(http.StatusOK, rec.Code)
+	is.True(forwarded)
+	is.Equal("/api/endpoints/1/docker/containers/json?all=1", requestURI)
+	is.Empty(rec.Header().Values("Set-Cookie"))
+	is.Empty(rec.Header().Get("X-CSRF-Token"))
+}
+
+func TestForwardedRequestWithInvalidSignatureIsRejected(t *testing.T) {
+	is := require.New(t)
+
+	_, store := datastore.MustNewTestStore(t, true, true)
+	forwarder := NewForwarder(store)
+
+	handler := forwarder.WithForwardedRequests(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	req := httptest.NewRequest(http.MethodGet, "/api/endpoints/1/docker/info", nil)
+	is.NoError(forwarder.sign(req, emptyBodyDigest))
+
+	// The signature does not cover another path
+	req.URL.Path = "/api/endpoints/2/docker/info"
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	is.Equal(http.StatusForbidden, rec.Code)
+
+	req = httptest.NewRequest(http.MethodGet, "/api/endpoints/1/docker/info", nil)
+	req.Header.Set(SignatureHeader, "0.invalid")
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	is.Equal(http.StatusForbidden, rec.Code)
+}
+
+func TestForwardedRequestBodyIsSigned(t *testing.T) {
+	is := require.New(t)
+
+	_, store := datastore.MustNewTestStore(t, true, true)
+	forwarder := NewForwarder(store)
+
+	var received string
+	owner := httptest.NewServer(forwarder.WithForwardedRequests(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received = string(body)
+	})))
+	defer owner.Close()
+
+	proxy, err := forwarder.NewProxy(owner.URL)
+	is.NoError(err)
+
+	rec := httptest.NewRecorder()
+	proxy.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/endpoints/1/docker/containers/create", strings.NewReader(`{"Image":"nginx"}`)))
+	is.Equal(http.StatusOK, rec.Code)
+	is.Equal(`{"Image":"nginx"}`, received, "the body is forwarded")
+
+	// The signature does not cover another body
+	digest := sha256.Sum256([]byte(`{"Image":"nginx"}`))
+	req := httptest.NewRequest(http.MethodPost, "/api/endpoints/1/docker/containers/create", strings.NewReader(`{"Image":"evil"}`))
+	is.NoError(forwarder.sign(req, digest[:]))
+
+	rec = httptest.NewRecorder()
+	forwarder.WithForwardedRequests(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})).ServeHTTP(rec, req)
+	is.Equal(http.StatusForbidden, rec.Code)
+}
+
+func TestForwardedRequestIsAcceptedOnce(t *testing.T) {
+	is := require.New(t)
+
+	_, store := datastore.MustNewTestStore(t, true, true)
+	forwarder := NewForwarder(store)
+
+	now := time.Now()
+	forwarder.now = func() time.Time { return now }
+
+	handler := forwarder.WithForwardedRequests(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	req := httptest.NewRequest(http.MethodPost, "/api/endpoints/1/docker/containers/1/stop", nil)
+	is.NoError(forwarder.sign(req, emptyBodyDigest))
+	signature := req.Header.Get(SignatureHeader)
+
+	replay := func() int {
+		req := httptest.NewRequest(http.MethodPost, "/api/endpoints/1/docker/containers/1/stop", nil)
+		req.Header.Set(SignatureHeader, signature)
+
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		return rec.Code
+	}
+
+	is.Equal(http.StatusOK, replay())
+	is.Equal(http.StatusForbidden, replay(), "a signature is accepted once")
+
+	// The nonce is forgotten once the signature expired
+	now = now.Add(2 * maxClockSkew)
+	is.Equal(http.StatusForbidden, replay())
+
+	req = httptest.NewRequest(http.MethodPost, "/api/endpoints/1/docker/containers/1/stop", nil)
+	is.NoError(forwarder.sign(req, emptyBodyDigest))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	is.Equal(http.StatusOK, rec.Code)
+	is.Len(forwarder.nonces, 1)
+}
+
+func TestForwardedRequestAuthenticationIsSigned(t *testing.T) {
+	is := require.New(t)
+
+	_, store := datastore.MustNewTestStore(t, true, true)
+	forwarder := NewForwarder(store)
+
+	handler := forwarder.WithForwardedRequests(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	for _, header := range signedHeaders {
+		req := httptest.NewRequest(http.MethodGet, "/api/endpoints/1/docker/info", nil)
+		req.Header.Set(header, "user")
+		is.NoError(forwarder.sign(req, emptyBodyDigest))
+
+		req.Header.Set(header, "admin")
+
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		is.Equal(http.StatusForbidden, rec.Code, "the signature does not cover another %s header", header)
+	}
+}
+
+func TestLargeBodyIsBufferedInATemporaryFile(t *testing.T) {
+	is := require.New(t)
+
+	content := bytes.Repeat([]byte("a"), maxMemoryBodySize+1)
+
+	body, digest, err := bufferBody(io.NopCloser(bytes.NewReader(content)))
+	is.NoError(err)
+
+	expected := sha256.Sum256(content)
+	is.Equal(expected[:], digest)
+
+	buffered, ok := body.(*bufferedBody)
+	is.True(ok)
+	is.NotNil(buffered.file)
+
+	read, err := io.ReadAll(body)
+	is.NoError(err)
+	is.Equal(content, read)
+
+	is.NoError(body.Close())
+	is.NoFileExists(buffered.file.Name())
+}
+
+func TestForwardedRequestIsNotForwardedAgain(t *testing.T) {
+	is := require.New(t)
+
+	_, store := datastore.MustNewTestStore(t, true, true)
+	forwarder := NewForwarder(store)
+
+	proxy, err := forwarder.NewProxy("http://127.0.0.1:1")
+	is.NoError(err)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/endpoints/1/docker/info", nil)
+	is.NoError(forwarder.sign(req, emptyBodyDigest))
+
+	rec := httptest.NewRecorder()
+	forwarder.WithForwardedRequests(proxy).ServeHTTP(rec, req)
+	is.Equal(http.StatusBadGateway, rec.Code)
+}
+
+// emptyBodyDigest is the digest of the requests without a body
+var emptyBodyDigest = func() []byte {
+	digest := sha256.Sum256(nil)
+
+	return digest[:]
+}()
@@ -53,6 +53,15 @@ func (manager *TokenCacheManager) RemoveUserFromCache(userID portainer.UserID) {
 	manager.mu.Unlock()
 }
 
+// Clear removes the tokens of every user from all registered caches.
+func (manager *TokenCacheManager) Clear() {
+	manager.mu.Lock()
+	for _, tc := range manager.tokenCaches {
+		tc.clear()
+	}
+	manager.mu.Unlock()
+}
+
 func (cache *tokenCache) getOrAddToken(userID portainer.UserID, tokenGetFunc func() (string, error)) (string, error) {
 	cache.mu.Lock()
 	defer cache.mu.Unlock()
@@ -76,3 +85,9 @@ func (cache *tokenCache) removeToken(userID portainer.UserID) {
 	delete(cache.userTokenCache, userID)
 	cache.mu.Unlock()
 }
+
+func (cache *tokenCache) clear() {
+	cache.mu.Lock()
+	clear(cache.userTokenCache)
+	cache.mu.Unlock()
+}
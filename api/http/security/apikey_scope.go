@@ -0,0 +1,209 @@
+package security
+
+import (
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+
+	portainer "github.com/portainer/portainer/api"
+	"github.com/portainer/portainer/api/internal/authorization"
+
+	"github.com/gorilla/mux"
+)
+
+// authorizationNames are the names of the resources in the authorizations
+// that are not derived from their path
+var authorizationNames = map[string]string{
+	"motd":     "MOTD",
+	"settings": "Settings",
+}
+
+// checkAPIKeyScope verifies that a request authenticated with a scoped API key
+// stays within the scope of the key. The requests to the Kubernetes, Azure
+// and agent APIs have no authorization, a key restricted to some
+// authorizations cannot make them.
+func checkAPIKeyScope(r *http.Request, scope *portainer.APIKeyScope) error {
+	if scope == nil {
+		return nil
+	}
+
+	if scope.ReadOnly && !readOnlyRequest(r) {
+		return ErrAPIKeyScope
+	}
+
+	if len(scope.EndpointIDs) > 0 {
+		if endpointID, ok := requestEndpointID(r); ok && !slices.Contains(scope.EndpointIDs, endpointID) {
+			return ErrAPIKeyScope
+		}
+	}
+
+	if len(scope.Authorizations) > 0 && !slices.ContainsFunc(requestAuthorizations(r), func(authorization portainer.Authorization) bool {
+		return scope.Authorizations[authorization]
+	}) {
+		return ErrAPIKeyScope
+	}
+
+	return nil
+}
+
+// IsAPIKeyScopeAuthorization returns whether an API key can be restricted to
+// an authorization: it must be known and requestAuthorizations must be able
+// to derive it from the path of a request
+func IsAPIKeyScopeAuthorization(a portainer.Authorization) bool {
+	if !authorization.IsRoleAuthorization(a) || strings.HasSuffix(string(a), "Undefined") {
+		return false
+	}
+
+	return strings.HasPrefix(string(a), "Docker") || strings.HasPrefix(string(a), "Portainer")
+}
+
+// readOnlyRequest returns whether a request does not change anything. The
+// websocket upgrades are not read-only as they attach to the containers.
+func readOnlyRequest(r *http.Request) bool {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return r.Header.Get("Upgrade") == ""
+	}
+
+	return false
+}
+
+// requestEndpointID returns the environment targeted by a request, either in
+// its path or in its endpointId route variable or query parameter
+func requestEndpointID(r *http.Request) (portainer.EndpointID, bool) {
+	segments := requestSegments(r)
+	if len(segments) > 1 && slices.Contains([]string{"endpoints", "docker", "kubernetes"}, segments[0]) {
+		if id, err := strconv.Atoi(segments[1]); err == nil {
+			return portainer.EndpointID(id), true
+		}
+	}
+
+	for _, value := range []string{mux.Vars(r)["endpointId"], r.URL.Query().Get("endpointId")} {
+		if id, err := strconv.Atoi(value); err == nil {
+			return portainer.EndpointID(id), true
+		}
+	}
+
+	return 0, false
+}
+
+// requestAuthorizations returns the names of the authorizations that can
+// match a request. They are derived from its path, for example
+// DockerContainerStart for POST /endpoints/1/docker/containers/{id}/start or
+// PortainerStackUpdate for PUT /stacks/{id}. The requests to the Kubernetes
+// and Azure APIs have no authorization.
+func requestAuthorizations(r *http.Request) []portainer.Authorization {
+	segments := requestSegments(r)
+	prefix := "Portainer"
+
+	switch {
+	case len(segments) > 3 && segments[0] == "endpoints" && segments[2] == "docker":
+		prefix, segments = "Docker", segments[3:]
+	case len(segments) > 4 && segments[0] == "endpoints" && segments[2] == "agent" && segments[3] == "docker":
+		prefix, segments = "Docker", segments[4:]
+	case len(segments) > 2 && segments[0] == "endpoints" && slices.Contains([]string{"kubernetes", "azure", "agent"}, segments[2]):
+		return nil
+	case len(segments) > 2 && segments[0] == "docker":
+		prefix, segments = "Docker", segments[2:]
+	case len(segments) > 0 && segments[0] == "kubernetes":
+		return nil
+	}
+
+	if prefix == "Docker" && len(segments) > 0 && strings.HasPrefix(segments[0], "v") {
+		if _, err := strconv.ParseFloat(segments[0][1:], 64); err == nil {
+			segments = segments[1:]
+		}
+	}
+
+	if len(segments) == 0 || segments[0] == "" {
+		return nil
+	}
+
+	resource := prefix + authorizationName(segments[0])
+
+	return []portainer.Authorization{
+		portainer.Authorization(resource + operationName(r.Method, segments[1:])),
+		portainer.Authorization(resource),
+	}
+}
+
+// operationName returns the operation of a request from the segments
+// following the resource type in its path
+func operationName(method string, segments []string) string {
+	switch {
+	case method == http.MethodDelete:
+		return "Delete"
+	case len(segments) == 0 || segments[0] == "json":
+		if method == http.MethodGet || method == http.MethodHead {
+			return "List"
+		}
+
+		if method == http.MethodPost {
+			return "Create"
+		}
+
+		return "Update"
+	case slices.Contains([]string{"create", "prune", "load", "search"}, segments[0]):
+		return capitalize(segments[0])
+	case len(segments) == 1:
+		if method == http.MethodGet || method == http.MethodHead {
+			return "Inspect"
+		}
+
+		return "Update"
+	}
+
+	// The operations on a resource are the last segment of its path, the
+	// names of the images hold slashes
+	if last := segments[len(segments)-1]; last != "json" {
+		return capitalize(last)
+	}
+
+	return "Inspect"
+}
+
+// authorizationName returns the name of a resource type in the
+// authorizations, endpoint_groups is EndpointGroup
+func authorizationName(resourceType string) string {
+	if name, ok := authorizationNames[resourceType]; ok {
+		return name
+	}
+
+	words := strings.Split(resourceType, "_")
+
+	last := words[len(words)-1]
+	switch {
+	case strings.HasSuffix(last, "ies"):
+		words[len(words)-1] = strings.TrimSuffix(last, "ies") + "y"
+	case strings.HasSuffix(last, "s"):
+		words[len(words)-1] = strings.TrimSuffix(last, "s")
+	}
+
+	var name strings.Builder
+	for _, word := range words {
+		name.WriteString(capitalize(word))
+	}
+
+	return name.String()
+}
+
+func capitalize(word string) string {
+	if word == "" {
+		return ""
+	}
+
+	return strings.ToUpper(word[:1]) + word[1:]
+}
+
+// requestSegments returns the segments of the path of a request below /api,
+// before any handler stripped a prefix of the path
+func requestSegments(r *http.Request) []string {
+	path := r.URL.Path
+	if u, err := url.ParseRequestURI(r.RequestURI); err == nil {
+		path = u.Path
+	}
+
+	return strings.Split(strings.Trim(strings.TrimPrefix(path, "/api/"), "/"), "/")
+}
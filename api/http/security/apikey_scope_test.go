@@ -0,0 +1,122 @@
+package security
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	portainer "github.com/portainer/portainer/api"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequestAuthorizations(t *testing.T) {
+	for _, tc := range []struct {
+		method, path string
+		expected     portainer.Authorization
+	}{
+		{http.MethodGet, "/api/endpoints/1/docker/containers/json", portainer.OperationDockerContainerList},
+		{http.MethodGet, "/api/endpoints/1/docker/v1.41/containers/abc/json", portainer.OperationDockerContainerInspect},
+		{http.MethodPost, "/api/endpoints/1/docker/containers/abc/start", portainer.OperationDockerContainerStart},
+		{http.MethodPost, "/api/endpoints/1/docker/containers/create", portainer.OperationDockerContainerCreate},
+		{http.MethodDelete, "/api/endpoints/1/docker/images/library/nginx:latest", portainer.OperationDockerImageDelete},
+		{http.MethodPost, "/api/endpoints/1/docker/images/library/nginx/tag", portainer.OperationDockerImageTag},
+		{http.MethodGet, "/api/endpoints/1/docker/info", portainer.OperationDockerInfo},
+		{http.MethodPut, "/api/stacks/4", portainer.OperationPortainerStackUpdate},
+		{http.MethodGet, "/api/stacks/4/file", portainer.OperationPortainerStackFile},
+		{http.MethodPost, "/api/stacks/create/standalone/string", portainer.OperationPortainerStackCreate},
+		{http.MethodGet, "/api/endpoint_groups", portainer.OperationPortainerEndpointGroupList},
+		{http.MethodGet, "/api/registries/2", portainer.OperationPortainerRegistryInspect},
+	} {
+		t.Run(tc.method+" "+tc.path, func(t *testing.T) {
+			require.Contains(t, requestAuthorizations(httptest.NewRequest(tc.method, tc.path, nil)), tc.expected)
+		})
+	}
+
+	require.Empty(t, requestAuthorizations(httptest.NewRequest(http.MethodGet, "/api/endpoints/1/kubernetes/api/v1/pods", nil)))
+}
+
+func TestCheckAPIKeyScope(t *testing.T) {
+	for _, tc := range []struct {
+		name         string
+		scope        *portainer.APIKeyScope
+		method, path string
+		allowed      bool
+	}{
+		{
+			name:    "an unscoped key is not restricted",
+			method:  http.MethodDelete,
+			path:    "/api/endpoints/2",
+			allowed: true,
+		},
+		{
+			name:    "a read-only key reads",
+			scope:   &portainer.APIKeyScope{ReadOnly: true},
+			method:  http.MethodGet,
+			path:    "/api/stacks",
+			allowed: true,
+		},
+		{
+			name:   "a read-only key does not write",
+			scope:  &portainer.APIKeyScope{ReadOnly: true},
+			method: http.MethodPost,
+			path:   "/api/endpoints/1/docker/containers/abc/stop",
+		},
+		{
+			name:    "an environment key uses its environments",
+			scope:   &portainer.APIKeyScope{EndpointIDs: []portainer.EndpointID{1, 3}},
+			method:  http.MethodPost,
+			path:    "/api/endpoints/3/docker/containers/abc/stop",
+			allowed: true,
+		},
+		{
+			name:   "an environment key does not use the other environments",
+			scope:  &portainer.APIKeyScope{EndpointIDs: []portainer.EndpointID{1, 3}},
+			method: http.MethodPost,
+			path:   "/api/stacks/create/standalone/string?endpointId=2",
+		},
+		{
+			name:    "an authorization key performs its operations",
+			scope:   &portainer.APIKeyScope{Authorizations: portainer.Authorizations{portainer.OperationDockerContainerRestart: true}},
+			method:  http.MethodPost,
+			path:    "/api/endpoints/1/docker/containers/abc/restart",
+			allowed: true,
+		},
+		{
+			name:   "an authorization key does not use the Kubernetes API",
+			scope:  &portainer.APIKeyScope{Authorizations: portainer.Authorizations{portainer.OperationDockerContainerRestart: true}},
+			method: http.MethodGet,
+			path:   "/api/endpoints/1/kubernetes/api/v1/pods",
+		},
+		{
+			name:   "an authorization key does not use the agent API",
+			scope:  &portainer.APIKeyScope{Authorizations: portainer.Authorizations{portainer.OperationDockerContainerRestart: true}},
+			method: http.MethodGet,
+			path:   "/api/endpoints/1/agent/browse/ls",
+		},
+		{
+			name:   "an authorization key does not perform the other operations",
+			scope:  &portainer.APIKeyScope{Authorizations: portainer.Authorizations{portainer.OperationDockerContainerRestart: true}},
+			method: http.MethodDelete,
+			path:   "/api/endpoints/1/docker/containers/abc",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			err := checkAPIKeyScope(httptest.NewRequest(tc.method, tc.path, nil), tc.scope)
+			if tc.allowed {
+				require.NoError(t, err)
+			} else {
+				require.ErrorIs(t, err, ErrAPIKeyScope)
+			}
+		})
+	}
+}
+
+func TestIsAPIKeyScopeAuthorization(t *testing.T) {
+	require.True(t, IsAPIKeyScopeAuthorization(portainer.OperationDockerContainerRestart))
+	require.True(t, IsAPIKeyScopeAuthorization(portainer.OperationPortainerStackUpdate))
+
+	require.False(t, IsAPIKeyScopeAuthorization("DockerContainerRestartt"))
+	require.False(t, IsAPIKeyScopeAuthorization(portainer.OperationDockerUndefined))
+	require.False(t, IsAPIKeyScopeAuthorization(portainer.EndpointResourcesAccess))
+}
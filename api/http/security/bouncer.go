@@ -2,6 +2,7 @@ package security
 
 import (
 	"net/http"
+	"slices"
 	"strings"
 	"sync"
 	"time"
@@ -10,6 +11,7 @@ import (
 	portainer "github.com/portainer/portainer/api"
 	"github.com/portainer/portainer/api/apikey"
 	"github.com/portainer/portainer/api/dataservices"
+	"github.com/portainer/portainer/api/http/audit"
 	httperrors "github.com/portainer/portainer/api/http/errors"
 	httperror "github.com/portainer/portainer/pkg/libhttp/error"
 
@@ -20,6 +22,10 @@ import (
 const apiKeyHeader = "X-API-KEY"
 const jwtTokenHeader = "Authorization"
 
+// sessionCheckInterval bounds how long a session revoked by another instance
+// is accepted when the change is not notified
+const sessionCheckInterval = 30 * time.Second
+
 type (
 	BouncerService interface {
 		PublicAccess(http.Handler) http.Handler
@@ -35,6 +41,7 @@ type (
 		JWTAuthLookup(*http.Request) (*portainer.TokenData, error)
 		TrustedEdgeEnvironmentAccess(dataservices.DataStoreTx, *portainer.Endpoint) error
 		RevokeJWT(string)
+		InvalidateSessionCache()
 	}
 
 	// RequestBouncer represents an entity that manages API request accesses
@@ -43,6 +50,9 @@ type (
 		jwtService    portainer.JWTService
 		apiKeyService apikey.APIKeyService
 		revokedJWT    sync.Map
+		// sessionChecks holds when the sessions found valid were last read from
+		// the store, keyed by JWT ID
+		sessionChecks sync.Map
 	}
 
 	// RestrictedRequestContext is a data structure containing information
@@ -118,6 +128,7 @@ func (bouncer *RequestBouncer) RestrictedAccess(h http.Handler) http.Handler {
 func (bouncer *RequestBouncer) TeamLeaderAccess(h http.Handler) http.Handler {
 	h = bouncer.mwIsTeamLeader(h)
 	h = bouncer.mwUpgradeToRestrictedRequest(h)
+	h = bouncer.mwCheckAPIKeyScope(h)
 	h = bouncer.mwAuthenticatedUser(h)
 
 	return h
@@ -130,6 +141,7 @@ func (bouncer *RequestBouncer) TeamLeaderAccess(h http.Handler) http.Handler {
 // and resource filtering.
 func (bouncer *RequestBouncer) AuthenticatedAccess(h http.Handler) http.Handler {
 	h = bouncer.mwUpgradeToRestrictedRequest(h)
+	h = bouncer.mwCheckAPIKeyScope(h)
 	h = bouncer.mwAuthenticatedUser(h)
 
 	return h
@@ -145,6 +157,10 @@ func (bouncer *RequestBouncer) AuthorizedEndpointOperation(r *http.Request, endp
 		return err
 	}
 
+	if scope := tokenData.APIKeyScope; scope != nil && len(scope.EndpointIDs) > 0 && !slices.Contains(scope.EndpointIDs, endpoint.ID) {
+		return httperrors.ErrEndpointAccessDenied
+	}
+
 	if tokenData.Role == portainer.AdministratorRole {
 		return nil
 	}
@@ -207,8 +223,11 @@ func (bouncer *RequestBouncer) TrustedEdgeEnvironmentAccess(tx dataservices.Data
 // - adding a secure handlers to the response
 // - authenticating the request with a valid token
 func (bouncer *RequestBouncer) mwAuthenticatedUser(h http.Handler) http.Handler {
+	h = bouncer.mwCheckTwoFactorEnrolment(h)
+	// The API keys are accepted again since their scope and expiry are
+	// enforced, the keys created without them keep the rights of their user
 	h = bouncer.mwAuthenticateFirst([]tokenLookup{
-		// bouncer.apiKeyLookup,
+		bouncer.apiKeyLookup,
 		// bouncer.CookieAuthLookup,
 		bouncer.JWTAuthLookup,
 	}, h)
@@ -230,6 +249,11 @@ func (bouncer *RequestBouncer) mwCheckPortainerAuthorizations(next http.Handler,
 			return
 		}
 
+		if err := checkAPIKeyScope(r, tokenData.APIKeyScope); err != nil {
+			httperror.WriteError(w, http.StatusForbidden, "Access denied", err)
+			return
+		}
+
 		if tokenData.Role == portainer.AdministratorRole {
 			next.ServeHTTP(w, r)
 			return
@@ -253,6 +277,57 @@ func (bouncer *RequestBouncer) mwCheckPortainerAuthorizations(next http.Handler,
 	})
 }
 
+// mwCheckAPIKeyScope will verify that a request authenticated with a scoped
+// API key stays within the scope of the key.
+func (bouncer *RequestBouncer) mwCheckAPIKeyScope(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tokenData, err := RetrieveTokenData(r)
+		if err != nil {
+			httperror.WriteError(w, http.StatusForbidden, "Access denied", httperrors.ErrUnauthorized)
+			return
+		}
+
+		if err := checkAPIKeyScope(r, tokenData.APIKeyScope); err != nil {
+			httperror.WriteError(w, http.StatusForbidden, "Access denied", err)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// mwCheckTwoFactorEnrolment restricts the tokens of the users who must log in
+// with a second factor to its enrolment, until they enrolled one
+func (bouncer *RequestBouncer) mwCheckTwoFactorEnrolment(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tokenData, err := RetrieveTokenData(r)
+		if err != nil || !tokenData.TwoFactorEnrolmentRequired || twoFactorEnrolmentRequest(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		user, err := bouncer.dataStore.User().Read(tokenData.ID)
+		if err != nil || !user.TwoFactor.Enabled {
+			httperror.WriteError(w, http.StatusForbidden, "Two-factor authentication enrolment required", ErrTwoFactorEnrolment)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// twoFactorEnrolmentRequest returns whether a request is part of the
+// enrolment of a second factor: /users/me, /users/{id}/passwd and
+// /users/{id}/2fa...
+func twoFactorEnrolmentRequest(r *http.Request) bool {
+	segments := requestSegments(r)
+	if len(segments) < 2 || segments[0] != "users" {
+		return false
+	}
+
+	return segments[1] == "me" || len(segments) > 2 && (segments[2] == "2fa" || segments[2] == "passwd")
+}
+
 // mwUpgradeToRestrictedRequest will enhance the current request with
 // a new RestrictedRequestContext object.
 func (bouncer *RequestBouncer) mwUpgradeToRestrictedRequest(next http.Handler) http.Handler {
@@ -370,6 +445,8 @@ func (bouncer *RequestBouncer) mwAuthenticateFirst(tokenLookups []tokenLookup, n
 
         fmt.Printf("Authentication successful for user: ID=%v, Username=%v\n", user.ID, user.Username)
         
+        audit.SetUser(r.Context(), user.ID, user.Username)
+
         ctx := StoreTokenData(r, token)
         next.ServeHTTP(w, r.WithContext(ctx))
     })
@@ -404,7 +481,7 @@ func (bouncer *RequestBouncer) CookieAuthLookup(r *http.Request) (*portainer.Tok
         return nil, err
     }
 
-    if _, ok := bouncer.revokedJWT.Load(jti); ok {
+    if bouncer.isRevoked(jti) {
         fmt.Println("Token is revoked")
         return nil, ErrRevokedJWT
     }
@@ -431,7 +508,7 @@ func (bouncer *RequestBouncer) JWTAuthLookup(r *http.Request) (*portainer.TokenD
         return nil, err
     }
 
-    if _, ok := bouncer.revokedJWT.Load(jti); ok {
+    if bouncer.isRevoked(jti) {
         fmt.Println("Token is revoked")
         return nil, ErrRevokedJWT
     }
@@ -440,25 +517,128 @@ func (bouncer *RequestBouncer) JWTAuthLookup(r *http.Request) (*portainer.TokenD
     return tokenData, nil
 }
 
+// RevokeJWT revokes the session of a token, the revocation is persisted so
+// that it survives a restart and applies to all the instances
 func (bouncer *RequestBouncer) RevokeJWT(token string) {
-	_, jti, exp, err := bouncer.jwtService.ParseAndVerifyToken(token)
+	tokenData, jti, exp, err := bouncer.jwtService.ParseAndVerifyToken(token)
 	if err != nil {
 		return
 	}
 
 	bouncer.revokedJWT.Store(jti, exp)
+	bouncer.sessionChecks.Delete(jti)
+
+	if err := bouncer.dataStore.UpdateTx(func(tx dataservices.DataStoreTx) error {
+		return revokeSession(tx, tokenData.ID, jti, exp)
+	}); err != nil {
+		log.Error().Err(err).Msg("unable to persist the revocation of the JWT")
+	}
+}
+
+// revokeSession marks the session of a token revoked, a revoked session is
+// created for the tokens issued without one such as the kubeconfig tokens
+func revokeSession(tx dataservices.DataStoreTx, userID portainer.UserID, jti string, exp time.Time) error {
+	now := time.Now().Unix()
+
+	session, err := tx.UserSession().SessionByJTI(jti)
+	if tx.IsErrObjectNotFound(err) {
+		return tx.UserSession().Create(&portainer.UserSession{
+			JTI:       jti,
+			UserID:    userID,
+			IssuedAt:  now,
+			ExpiresAt: SessionExpiry(exp),
+			RevokedAt: now,
+		})
+	} else if err != nil {
+		return err
+	}
+
+	if session.RevokedAt != 0 {
+		return nil
+	}
+
+	session.RevokedAt = now
+
+	return tx.UserSession().Update(session.ID, session)
+}
+
+// SessionExpiry returns the expiry recorded in the session of a token, 0 when
+// the token does not expire
+func SessionExpiry(exp time.Time) int64 {
+	if exp.Unix() <= 0 {
+		return 0
+	}
+
+	return exp.Unix()
+}
+
+// InvalidateSessionCache forgets the sessions found valid so that they are
+// read again from the store, it is called when the sessions are revoked
+// outside of RevokeJWT
+func (bouncer *RequestBouncer) InvalidateSessionCache() {
+	bouncer.sessionChecks.Range(func(key, _ any) bool {
+		bouncer.sessionChecks.Delete(key)
+
+		return true
+	})
+}
+
+// isRevoked returns whether the session of a token was revoked, by this
+// instance or through the store. The tokens without a session are accepted,
+// the tokens whose session cannot be read are rejected.
+func (bouncer *RequestBouncer) isRevoked(jti string) bool {
+	if _, ok := bouncer.revokedJWT.Load(jti); ok {
+		return true
+	}
+
+	if checkedAt, ok := bouncer.sessionChecks.Load(jti); ok && time.Since(checkedAt.(time.Time)) < sessionCheckInterval {
+		return false
+	}
+
+	session, err := bouncer.dataStore.UserSession().SessionByJTI(jti)
+	if err != nil && !bouncer.dataStore.IsErrObjectNotFound(err) {
+		log.Warn().Err(err).Msg("unable to retrieve the session of the JWT, rejecting it")
+
+		return true
+	}
+
+	if session != nil && session.RevokedAt != 0 {
+		bouncer.revokedJWT.Store(jti, time.Unix(session.ExpiresAt, 0))
+		bouncer.sessionChecks.Delete(jti)
+
+		return true
+	}
+
+	bouncer.sessionChecks.Store(jti, time.Now())
+
+	return false
 }
 
 func (bouncer *RequestBouncer) cleanUpExpiredJWTPass() {
+	now := time.Now()
+
 	bouncer.revokedJWT.Range(func(key, value any) bool {
-		if t := value.(time.Time); t.IsZero() {
+		// The tokens that never expire carry the zero time or the epoch
+		if t := value.(time.Time); t.Unix() <= 0 {
 			return true
-		} else if time.Now().After(t) {
+		} else if now.After(t) {
 			bouncer.revokedJWT.Delete(key)
 		}
 
 		return true
 	})
+
+	bouncer.sessionChecks.Range(func(key, value any) bool {
+		if now.Sub(value.(time.Time)) >= sessionCheckInterval {
+			bouncer.sessionChecks.Delete(key)
+		}
+
+		return true
+	})
+
+	if err := bouncer.dataStore.UserSession().DeleteExpiredSessions(now.Unix()); err != nil {
+		log.Warn().Err(err).Msg("unable to remove the expired sessions")
+	}
 }
 
 func (bouncer *RequestBouncer) cleanUpExpiredJWT() {
@@ -471,7 +651,7 @@ func (bouncer *RequestBouncer) cleanUpExpiredJWT() {
 
 // apiKeyLookup looks up an verifies an api-key by:
 // - computing the digest of the raw api-key
-// - verifying it exists in cache/database
+// - verifying it exists in cache/database and has not expired
 // - matching the key to a user (ID, Role)
 // If the key is valid/verified, the last updated time of the key is updated.
 // Successful verification of the key will return a TokenData object - since the downstream handlers
@@ -489,16 +669,33 @@ func (bouncer *RequestBouncer) apiKeyLookup(r *http.Request) (*portainer.TokenDa
 		return nil, ErrInvalidKey
 	}
 
+	if apiKey.ExpiresAt > 0 && time.Now().Unix() >= apiKey.ExpiresAt {
+		return nil, ErrExpiredKey
+	}
+
+	settings, err := bouncer.dataStore.Settings().Settings()
+	if err != nil {
+		return nil, err
+	}
+
 	tokenData := &portainer.TokenData{
-		ID:       user.ID,
-		Username: user.Username,
-		Role:     user.Role,
+		ID:          user.ID,
+		Username:    user.Username,
+		Role:        user.Role,
+		APIKeyScope: apiKey.Scope,
+		// The keys of the users who must log in with a second factor are
+		// restricted like their sessions until they enrolled one. Whether they
+		// did is read by mwCheckTwoFactorEnrolment, the cached user can be stale.
+		TwoFactorEnrolmentRequired: settings.RequireTwoFactor &&
+			(user.ID == 1 || settings.AuthenticationMethod == portainer.AuthenticationInternal),
 	}
 	if _, _, err := bouncer.jwtService.GenerateToken(tokenData); err != nil {
 		log.Debug().Err(err).Msg("Failed to generate token")
 		return nil, errors.New("failed to generate token")
 	}
 
+	audit.SetAPIKey(r.Context(), apiKey.ID)
+
 	if now := time.Now().UTC().Unix(); now-apiKey.LastUsed > 60 { // [seconds]
 		// update the last used time of the key
 		apiKey.LastUsed = now
@@ -1,6 +1,8 @@
 package security
 
 import (
+	"encoding/base64"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
@@ -381,6 +383,73 @@ func Test_apiKeyLookup(t *testing.T) {
 
 		is.True(apiKeyUpdated.LastUsed > apiKey.LastUsed)
 	})
+
+	t.Run("expired api-key fails api-key lookup", func(t *testing.T) {
+		rawAPIKey, apiKey, err := apiKeyService.GenerateScopedApiKey(*user, "test", time.Now().Add(-time.Minute).Unix(), nil)
+		is.NoError(err)
+		defer apiKeyService.DeleteAPIKey(apiKey.ID)
+
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Add("x-api-key", rawAPIKey)
+
+		token, err := bouncer.apiKeyLookup(req)
+		is.Nil(token)
+		is.ErrorIs(err, ErrExpiredKey)
+	})
+
+	t.Run("scoped api-key lookup carries the scope of the key", func(t *testing.T) {
+		scope := &portainer.APIKeyScope{ReadOnly: true, EndpointIDs: []portainer.EndpointID{1}}
+		rawAPIKey, apiKey, err := apiKeyService.GenerateScopedApiKey(*user, "test", time.Now().Add(time.Hour).Unix(), scope)
+		is.NoError(err)
+		defer apiKeyService.DeleteAPIKey(apiKey.ID)
+
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Add("x-api-key", rawAPIKey)
+
+		token, err := bouncer.apiKeyLookup(req)
+		is.NoError(err)
+		is.Equal(scope, token.APIKeyScope)
+	})
+}
+
+func TestLegacyAPIKeyAuthenticates(t *testing.T) {
+	is := require.New(t)
+
+	_, store := datastore.MustNewTestStore(t, true, true)
+
+	user := &portainer.User{ID: 2, Username: "standard", Role: portainer.StandardUserRole}
+	is.NoError(store.User().Create(user))
+
+	jwtService, err := jwt.NewService("1h", store)
+	is.NoError(err)
+	apiKeyService := apikey.NewAPIKeyService(store.APIKeyRepository(), store.User())
+	bouncer := NewRequestBouncer(store, jwtService, apiKeyService)
+
+	// A key created before the keys had a scope and an expiry date
+	rawAPIKey := "ptr_" + base64.StdEncoding.EncodeToString(apikey.GenerateRandomKey(32))
+	is.NoError(store.APIKeyRepository().Create(&portainer.APIKey{
+		UserID:      user.ID,
+		Description: "legacy",
+		Prefix:      rawAPIKey[:7],
+		DateCreated: time.Now().Unix(),
+		Digest:      apiKeyService.HashRaw(rawAPIKey),
+	}))
+
+	for _, method := range []string{http.MethodGet, http.MethodPost, http.MethodDelete} {
+		req := httptest.NewRequest(method, "/", nil)
+		req.Header.Add("x-api-key", rawAPIKey)
+
+		var tokenData *portainer.TokenData
+		rr := httptest.NewRecorder()
+		bouncer.AuthenticatedAccess(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			tokenData, _ = RetrieveTokenData(r)
+		})).ServeHTTP(rr, req)
+
+		is.Equal(http.StatusOK, rr.Code, method)
+		is.NotNil(tokenData)
+		is.Equal(user.ID, tokenData.ID)
+		is.Nil(tokenData.APIKeyScope)
+	}
 }
 
 func Test_ShouldSkipCSRFCheck(t *testing.T) {
@@ -527,3 +596,186 @@ func TestJWTRevocation(t *testing.T) {
 
 	require.Equal(t, 1, revokeLen())
 }
+
+func TestJWTRevocationIsShared(t *testing.T) {
+	_, store := datastore.MustNewTestStore(t, true, true)
+
+	jwtService, err := jwt.NewService("1h", store)
+	require.NoError(t, err)
+
+	err = store.User().Create(&portainer.User{ID: 1})
+	require.NoError(t, err)
+
+	token, _, err := jwtService.GenerateToken(&portainer.TokenData{ID: 1})
+	require.NoError(t, err)
+
+	apiKeyService := apikey.NewAPIKeyService(nil, nil)
+
+	// Two instances sharing the store
+	a := NewRequestBouncer(store, jwtService, apiKeyService)
+	b := NewRequestBouncer(store, jwtService, apiKeyService)
+
+	r, err := http.NewRequest(http.MethodGet, "url", nil)
+	require.NoError(t, err)
+
+	r.Header.Add(jwtTokenHeader, "Bearer "+token)
+
+	_, err = b.JWTAuthLookup(r)
+	require.NoError(t, err)
+
+	a.RevokeJWT(token)
+
+	// b read the session before it was revoked
+	b.InvalidateSessionCache()
+
+	_, err = b.JWTAuthLookup(r)
+	require.ErrorIs(t, err, ErrRevokedJWT)
+
+	// The revocation survives a restart
+	c := NewRequestBouncer(store, jwtService, apiKeyService)
+
+	_, err = c.JWTAuthLookup(r)
+	require.ErrorIs(t, err, ErrRevokedJWT)
+}
+
+// failingSessionStore fails to read the sessions while failing is set
+type failingSessionStore struct {
+	dataservices.DataStore
+	failing bool
+}
+
+func (s *failingSessionStore) UserSession() dataservices.UserSessionService {
+	return failingSessionService{UserSessionService: s.DataStore.UserSession(), store: s}
+}
+
+type failingSessionService struct {
+	dataservices.UserSessionService
+	store *failingSessionStore
+}
+
+func (s failingSessionService) SessionByJTI(jti string) (*portainer.UserSession, error) {
+	if s.store.failing {
+		return nil, errors.New("the database is unavailable")
+	}
+
+	return s.UserSessionService.SessionByJTI(jti)
+}
+
+func TestJWTRejectedWhenTheSessionCannotBeRead(t *testing.T) {
+	_, store := datastore.MustNewTestStore(t, true, true)
+
+	jwtService, err := jwt.NewService("1h", store)
+	require.NoError(t, err)
+
+	err = store.User().Create(&portainer.User{ID: 1})
+	require.NoError(t, err)
+
+	token, _, err := jwtService.GenerateToken(&portainer.TokenData{ID: 1})
+	require.NoError(t, err)
+
+	failingStore := &failingSessionStore{DataStore: store, failing: true}
+	bouncer := NewRequestBouncer(failingStore, jwtService, apikey.NewAPIKeyService(nil, nil))
+
+	r, err := http.NewRequest(http.MethodGet, "url", nil)
+	require.NoError(t, err)
+
+	r.Header.Add(jwtTokenHeader, "Bearer "+token)
+
+	_, err = bouncer.JWTAuthLookup(r)
+	require.ErrorIs(t, err, ErrRevokedJWT)
+
+	// The rejection is not cached
+	failingStore.failing = false
+
+	_, err = bouncer.JWTAuthLookup(r)
+	require.NoError(t, err)
+}
+
+func TestTwoFactorEnrolment(t *testing.T) {
+	_, store := datastore.MustNewTestStore(t, true, true)
+
+	jwtService, err := jwt.NewService("1h", store)
+	require.NoError(t, err)
+
+	user := &portainer.User{ID: 1, Role: portainer.AdministratorRole}
+	err = store.User().Create(user)
+	require.NoError(t, err)
+
+	token, _, err := jwtService.GenerateToken(&portainer.TokenData{ID: 1, Role: portainer.AdministratorRole, TwoFactorEnrolmentRequired: true})
+	require.NoError(t, err)
+
+	bouncer := NewRequestBouncer(store, jwtService, apikey.NewAPIKeyService(nil, nil))
+
+	h := bouncer.AuthenticatedAccess(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	serve := func(method, path string) int {
+		r := httptest.NewRequest(method, path, nil)
+		r.Header.Add(jwtTokenHeader, "Bearer "+token)
+
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, r)
+
+		return rr.Code
+	}
+
+	assert.Equal(t, http.StatusOK, serve(http.MethodGet, "/api/users/me"))
+	assert.Equal(t, http.StatusOK, serve(http.MethodPost, "/api/users/1/2fa"))
+	assert.Equal(t, http.StatusOK, serve(http.MethodPost, "/api/users/1/2fa/confirm"))
+	assert.Equal(t, http.StatusForbidden, serve(http.MethodGet, "/api/stacks"))
+	assert.Equal(t, http.StatusForbidden, serve(http.MethodGet, "/api/users/1"))
+
+	// The token is not restricted anymore once the user enrolled a second factor
+	user.TwoFactor.Enabled = true
+	err = store.User().Update(user.ID, user)
+	require.NoError(t, err)
+
+	assert.Equal(t, http.StatusOK, serve(http.MethodGet, "/api/stacks"))
+}
+
+func TestTwoFactorEnrolmentAppliesToAPIKeys(t *testing.T) {
+	_, store := datastore.MustNewTestStore(t, true, true)
+
+	user := &portainer.User{ID: 2, Username: "standard", Role: portainer.StandardUserRole}
+	err := store.User().Create(user)
+	require.NoError(t, err)
+
+	settings, err := store.Settings().Settings()
+	require.NoError(t, err)
+	settings.AuthenticationMethod = portainer.AuthenticationInternal
+	settings.RequireTwoFactor = true
+	err = store.Settings().UpdateSettings(settings)
+	require.NoError(t, err)
+
+	jwtService, err := jwt.NewService("1h", store)
+	require.NoError(t, err)
+	apiKeyService := apikey.NewAPIKeyService(store.APIKeyRepository(), store.User())
+	bouncer := NewRequestBouncer(store, jwtService, apiKeyService)
+
+	rawAPIKey, _, err := apiKeyService.GenerateApiKey(*user, "test")
+	require.NoError(t, err)
+
+	h := bouncer.AuthenticatedAccess(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	serve := func(method, path string) int {
+		r := httptest.NewRequest(method, path, nil)
+		r.Header.Add("x-api-key", rawAPIKey)
+
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, r)
+
+		return rr.Code
+	}
+
+	assert.Equal(t, http.StatusOK, serve(http.MethodPost, "/api/users/2/2fa"))
+	assert.Equal(t, http.StatusForbidden, serve(http.MethodGet, "/api/stacks"))
+
+	user.TwoFactor.Enabled = true
+	err = store.User().Update(user.ID, user)
+	require.NoError(t, err)
+
+	assert.Equal(t, http.StatusOK, serve(http.MethodGet, "/api/stacks"))
+}
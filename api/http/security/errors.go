@@ -4,4 +4,7 @@ import "errors"
 
 var (
 	ErrAuthorizationRequired = errors.New("Authorization required for this operation")
+	ErrExpiredKey            = errors.New("the API key has expired")
+	ErrAPIKeyScope           = errors.New("the operation is outside the scope of the API key")
+	ErrTwoFactorEnrolment    = errors.New("the user must enable two-factor authentication")
 )
@@ -2,6 +2,7 @@ package security
 
 import (
 	portainer "github.com/portainer/portainer/api"
+	"github.com/portainer/portainer/api/database/query"
 )
 
 // FilterUserTeams filters teams based on user role.
@@ -109,6 +110,32 @@ func FilterEndpoints(endpoints []portainer.Endpoint, groups []portainer.Endpoint
 	return endpoints[:n]
 }
 
+// EndpointsAccessFilter returns the filter selecting the environments(endpoints)
+// kept by FilterEndpoints, the store evaluates it while listing them
+func EndpointsAccessFilter(groups []portainer.EndpointGroup, context *RestrictedRequestContext) query.Filter {
+	if context.IsAdmin {
+		return query.All()
+	}
+
+	authorizedGroupIDs := make([]portainer.EndpointGroupID, 0)
+	for _, group := range groups {
+		if authorizedEndpointGroupAccess(&group, context.UserID, context.UserMemberships) {
+			authorizedGroupIDs = append(authorizedGroupIDs, group.ID)
+		}
+	}
+
+	filters := []query.Filter{
+		query.In("GroupId", authorizedGroupIDs),
+		query.HasKey("UserAccessPolicies", context.UserID),
+	}
+
+	for _, membership := range context.UserMemberships {
+		filters = append(filters, query.HasKey("TeamAccessPolicies", membership.TeamID))
+	}
+
+	return query.Any(filters...)
+}
+
 // FilterEndpointGroups filters environment(endpoint) groups based on user role and team memberships.
 // Non administrator users only have access to authorized environment(endpoint) groups.
 func FilterEndpointGroups(endpointGroups []portainer.EndpointGroup, context *RestrictedRequestContext) []portainer.EndpointGroup {
@@ -3,6 +3,7 @@ package http
 import (
 	"context"
 	"crypto/tls"
+	"net"
 	"net/http"
 	"path/filepath"
 	"time"
@@ -12,11 +13,15 @@ import (
 	"github.com/portainer/portainer/api/adminmonitor"
 	"github.com/portainer/portainer/api/apikey"
 	"github.com/portainer/portainer/api/crypto"
+	"github.com/portainer/portainer/api/database/changefeed"
 	"github.com/portainer/portainer/api/dataservices"
+	"github.com/portainer/portainer/api/dataservices/usersession"
 	"github.com/portainer/portainer/api/docker"
 	dockerclient "github.com/portainer/portainer/api/docker/client"
+	"github.com/portainer/portainer/api/http/audit"
 	"github.com/portainer/portainer/api/http/csrf"
 	"github.com/portainer/portainer/api/http/handler"
+	"github.com/portainer/portainer/api/http/handler/auditlogs"
 	"github.com/portainer/portainer/api/http/handler/auth"
 	"github.com/portainer/portainer/api/http/handler/backup"
 	"github.com/portainer/portainer/api/http/handler/customtemplates"
@@ -56,6 +61,7 @@ import (
 	"github.com/portainer/portainer/api/http/middlewares"
 	"github.com/portainer/portainer/api/http/offlinegate"
 	"github.com/portainer/portainer/api/http/proxy"
+	"github.com/portainer/portainer/api/http/proxy/factory/forward"
 	"github.com/portainer/portainer/api/http/proxy/factory/kubernetes"
 	"github.com/portainer/portainer/api/http/security"
 	"github.com/portainer/portainer/api/internal/authorization"
@@ -77,6 +83,7 @@ import (
 // Server implements the portainer.Server interface
 type Server struct {
 	AuthorizationService        *authorization.Service
+	AuditLogger                 *audit.Logger
 	BindAddress                 string
 	BindAddressHTTPS            string
 	HTTPEnabled                 bool
@@ -98,6 +105,7 @@ type Server struct {
 	OAuthService                portainer.OAuthService
 	SwarmStackManager           portainer.SwarmStackManager
 	ProxyManager                *proxy.Manager
+	TunnelForwarder             *forward.Forwarder
 	KubernetesTokenCacheManager *kubernetes.TokenCacheManager
 	KubeClusterAccessService    k8s.KubeClusterAccessService
 	Handler                     *handler.Handler
@@ -121,12 +129,24 @@ func (server *Server) Start() error {
 	kubernetesTokenCacheManager := server.KubernetesTokenCacheManager
 
 	requestBouncer := security.NewRequestBouncer(server.DataStore, server.JWTService, server.APIKeyService)
+	if source, ok := server.DataStore.Connection().(changefeed.Source); ok {
+		// The sessions revoked by the other instances are read again from the store
+		source.ChangeFeed().Subscribe(usersession.BucketName, func(changefeed.Change) {
+			requestBouncer.InvalidateSessionCache()
+		})
+	}
 
 	rateLimiter := security.NewRateLimiter(10, 1*time.Second, 1*time.Hour)
 	offlineGate := offlinegate.NewOfflineGate()
+	if source, ok := server.DataStore.Connection().(offlinegate.HealthSource); ok {
+		offlineGate.HoldWhileUnhealthy(source)
+	}
 
 	passwordStrengthChecker := security.NewPasswordStrengthChecker(server.DataStore.Settings())
 
+	var auditLogHandler = auditlogs.NewHandler(requestBouncer)
+	auditLogHandler.DataStore = server.DataStore
+
 	var authHandler = auth.NewHandler(requestBouncer, rateLimiter, passwordStrengthChecker)
 	authHandler.DataStore = server.DataStore
 	authHandler.CryptoService = server.CryptoService
@@ -149,6 +169,7 @@ func (server *Server) Start() error {
 	)
 
 	var roleHandler = roles.NewHandler(requestBouncer)
+	roleHandler.AuthorizationService = server.AuthorizationService
 	roleHandler.DataStore = server.DataStore
 
 	var customTemplatesHandler = customtemplates.NewHandler(requestBouncer, server.DataStore, server.FileService, server.GitService)
@@ -290,6 +311,7 @@ func (server *Server) Start() error {
 	websocketHandler.SignatureService = server.SignatureService
 	websocketHandler.ReverseTunnelService = server.ReverseTunnelService
 	websocketHandler.KubernetesClientFactory = server.KubernetesClientFactory
+	websocketHandler.TunnelForwarder = server.TunnelForwarder
 
 	var webhookHandler = webhooks.NewHandler(requestBouncer)
 	webhookHandler.DataStore = server.DataStore
@@ -298,6 +320,7 @@ func (server *Server) Start() error {
 	var testHandler = test.NewHandler(requestBouncer,server.DataStore)
 
 	server.Handler = &handler.Handler{
+		AuditLogHandler:        auditLogHandler,
 		RoleHandler:            roleHandler,
 		AuthHandler:            authHandler,
 		BackupHandler:          backupHandler,
@@ -339,7 +362,7 @@ func (server *Server) Start() error {
 
 	errorLogger := NewHTTPLogger()
 
-	handler := adminMonitor.WithRedirect(offlineGate.WaitingMiddleware(time.Minute, server.Handler))
+	handler := adminMonitor.WithRedirect(offlineGate.WaitingMiddleware(time.Minute, server.AuditLogger.WithAudit(server.Handler)))
 
 	handler = middlewares.WithSlowRequestsLogger(handler)
 
@@ -348,13 +371,18 @@ func (server *Server) Start() error {
 		return errors.Wrap(err, "failed to create CSRF middleware")
 	}
 
+	if server.TunnelForwarder != nil {
+		handler = server.TunnelForwarder.WithForwardedRequests(handler)
+	}
+
 	if server.HTTPEnabled {
 		go func() {
 			log.Info().Str("bind_address", server.BindAddress).Msg("starting HTTP server")
 			httpServer := &http.Server{
-				Addr:     server.BindAddress,
-				Handler:  handler,
-				ErrorLog: errorLogger,
+				Addr:        server.BindAddress,
+				Handler:     handler,
+				ErrorLog:    errorLogger,
+				BaseContext: server.baseContext,
 			}
 
 			go shutdown(server.ShutdownCtx, httpServer)
@@ -371,6 +399,7 @@ func (server *Server) Start() error {
 		Addr:         server.BindAddressHTTPS,
 		Handler:      handler,
 		ErrorLog:     errorLogger,
+		BaseContext:  server.baseContext,
 		TLSNextProto: make(map[string]func(*http.Server, *tls.Conn, http.Handler)), // Disable HTTP/2
 	}
 
@@ -385,6 +414,16 @@ func (server *Server) Start() error {
 	return httpsServer.ListenAndServeTLS("", "")
 }
 
+// baseContext derives the request contexts from the shutdown context, so that
+// the database work of in-flight requests is aborted on shutdown
+func (server *Server) baseContext(net.Listener) context.Context {
+	if server.ShutdownCtx == nil {
+		return context.Background()
+	}
+
+	return server.ShutdownCtx
+}
+
 func shutdown(shutdownCtx context.Context, httpServer *http.Server) {
 	<-shutdownCtx.Done()
 
@@ -0,0 +1,155 @@
+package authorization
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+
+	portainer "github.com/portainer/portainer/api"
+	"github.com/portainer/portainer/api/dataservices"
+	"github.com/portainer/portainer/api/dataservices/role"
+)
+
+// ErrUnknownRole is returned when an access policy assigns a role that does not exist
+var ErrUnknownRole = errors.New("unknown role")
+
+// roleAuthorizations are the authorizations the roles are built from, the
+// ones of the default roles. The other Portainer authorizations are only
+// granted to the administrators.
+var roleAuthorizations = defaultRoleAuthorizations()
+
+func defaultRoleAuthorizations() portainer.Authorizations {
+	authorizations := portainer.Authorizations{}
+	for _, defaults := range []portainer.Authorizations{
+		DefaultEndpointAuthorizationsForEndpointAdministratorRole(),
+		DefaultEndpointAuthorizationsForHelpDeskRole(true),
+		DefaultEndpointAuthorizationsForStandardUserRole(true),
+		DefaultEndpointAuthorizationsForReadOnlyUserRole(true),
+		DefaultPortainerAuthorizations(),
+	} {
+		maps.Copy(authorizations, defaults)
+	}
+
+	return authorizations
+}
+
+// IsRoleAuthorization returns whether a role can hold an authorization
+func IsRoleAuthorization(authorization portainer.Authorization) bool {
+	_, ok := roleAuthorizations[authorization]
+
+	return ok
+}
+
+// IsBuiltInRole returns whether a role is one of the built-in roles, they
+// cannot be changed nor removed
+func IsBuiltInRole(roleID portainer.RoleID) bool {
+	return roleID > 0 && roleID <= role.LastBuiltInRoleID
+}
+
+// RoleAssigned returns whether a role is assigned to a user or a team by the
+// access policies of an environment or of an environment group
+func RoleAssigned(tx dataservices.DataStoreTx, roleID portainer.RoleID) (bool, error) {
+	userIDs, teamIDs, err := roleAssignees(tx, roleID)
+	if err != nil {
+		return false, err
+	}
+
+	return len(userIDs) > 0 || len(teamIDs) > 0, nil
+}
+
+// UpdateRoleUsersAuthorizations updates the authorizations of the users a
+// role is assigned to, directly or through their teams, once the role changed
+func (service *Service) UpdateRoleUsersAuthorizations(tx dataservices.DataStoreTx, roleID portainer.RoleID) error {
+	userIDs, teamIDs, err := roleAssignees(tx, roleID)
+	if err != nil {
+		return err
+	}
+
+	for teamID := range teamIDs {
+		memberships, err := tx.TeamMembership().TeamMembershipsByTeamID(teamID)
+		if err != nil {
+			return err
+		}
+
+		for _, membership := range memberships {
+			userIDs[membership.UserID] = true
+		}
+	}
+
+	for userID := range userIDs {
+		// the access policies of the removed users are kept
+		if err := service.updateUserAuthorizations(tx, userID); err != nil && !tx.IsErrObjectNotFound(err) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// roleAssignees returns the users and the teams a role is assigned to by the
+// access policies of the environments and of the environment groups
+func roleAssignees(tx dataservices.DataStoreTx, roleID portainer.RoleID) (map[portainer.UserID]bool, map[portainer.TeamID]bool, error) {
+	userIDs := map[portainer.UserID]bool{}
+	teamIDs := map[portainer.TeamID]bool{}
+
+	addAssignees := func(userPolicies portainer.UserAccessPolicies, teamPolicies portainer.TeamAccessPolicies) {
+		for userID, policy := range userPolicies {
+			if policy.RoleID == roleID {
+				userIDs[userID] = true
+			}
+		}
+
+		for teamID, policy := range teamPolicies {
+			if policy.RoleID == roleID {
+				teamIDs[teamID] = true
+			}
+		}
+	}
+
+	endpoints, err := tx.Endpoint().Endpoints()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for _, endpoint := range endpoints {
+		addAssignees(endpoint.UserAccessPolicies, endpoint.TeamAccessPolicies)
+	}
+
+	endpointGroups, err := tx.EndpointGroup().ReadAll()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for _, endpointGroup := range endpointGroups {
+		addAssignees(endpointGroup.UserAccessPolicies, endpointGroup.TeamAccessPolicies)
+	}
+
+	return userIDs, teamIDs, nil
+}
+
+// ValidateAccessPolicies verifies that the roles assigned by access policies
+// exist, the policies without a role grant no authorization
+func ValidateAccessPolicies(tx dataservices.DataStoreTx, userPolicies portainer.UserAccessPolicies, teamPolicies portainer.TeamAccessPolicies) error {
+	roleIDs := make([]portainer.RoleID, 0, len(userPolicies)+len(teamPolicies))
+	for _, policy := range userPolicies {
+		roleIDs = append(roleIDs, policy.RoleID)
+	}
+
+	for _, policy := range teamPolicies {
+		roleIDs = append(roleIDs, policy.RoleID)
+	}
+
+	for _, roleID := range roleIDs {
+		if roleID == 0 {
+			continue
+		}
+
+		if _, err := tx.Role().Read(roleID); tx.IsErrObjectNotFound(err) {
+			return fmt.Errorf("%w: %d", ErrUnknownRole, roleID)
+		} else if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -24,3 +24,7 @@ func Get(k portainer.EndpointID) ([]byte, bool) {
 func Del(k portainer.EndpointID) {
 	c.Del(key(k))
 }
+
+func Clear() {
+	c.Reset()
+}
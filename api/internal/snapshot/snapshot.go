@@ -27,6 +27,7 @@ type Service struct {
 	kubernetesSnapshotter     portainer.KubernetesSnapshotter
 	shutdownCtx               context.Context
 	pendingActionsService     *pendingactions.PendingActionsService
+	leader                    portainer.LeaderElector
 }
 
 // NewService creates a new instance of a service
@@ -111,6 +112,11 @@ func (service *Service) Start() {
 	go service.startSnapshotLoop()
 }
 
+// SetLeaderElector restricts the background snapshots to the leader instance
+func (service *Service) SetLeaderElector(leader portainer.LeaderElector) {
+	service.leader = leader
+}
+
 // SetSnapshotInterval sets the snapshot interval and resets the service
 func (service *Service) SetSnapshotInterval(snapshotInterval string) error {
 	interval, err := time.ParseDuration(snapshotInterval)
@@ -273,6 +279,10 @@ func (service *Service) startSnapshotLoop() {
 }
 
 func (service *Service) snapshotEndpoints() error {
+	if service.leader != nil && !service.leader.IsLeader() {
+		return nil
+	}
+
 	endpoints, err := service.dataStore.Endpoint().Endpoints()
 	if err != nil {
 		return err
@@ -1,15 +1,19 @@
 package testhelpers
 
 import (
+	"context"
+	"slices"
 	"time"
 
 	portainer "github.com/portainer/portainer/api"
 	"github.com/portainer/portainer/api/database"
+	"github.com/portainer/portainer/api/database/query"
 	"github.com/portainer/portainer/api/dataservices"
 	"github.com/portainer/portainer/api/dataservices/errors"
 )
 
 type testDatastore struct {
+	auditLog                dataservices.AuditLogService
 	customTemplate          dataservices.CustomTemplateService
 	edgeGroup               dataservices.EdgeGroupService
 	edgeJob                 dataservices.EdgeJobService
@@ -18,6 +22,7 @@ type testDatastore struct {
 	endpointGroup           dataservices.EndpointGroupService
 	endpointRelation        dataservices.EndpointRelationService
 	helmUserRepository      dataservices.HelmUserRepositoryService
+	jwtSigningKey           dataservices.JWTSigningKeyService
 	registry                dataservices.RegistryService
 	resourceControl         dataservices.ResourceControlService
 	apiKeyRepositoryService dataservices.APIKeyRepository
@@ -31,6 +36,7 @@ type testDatastore struct {
 	team                    dataservices.TeamService
 	tunnelServer            dataservices.TunnelServerService
 	user                    dataservices.UserService
+	userSession             dataservices.UserSessionService
 	version                 dataservices.VersionService
 	webhook                 dataservices.WebhookService
 	pendingActionsService   dataservices.PendingActionsService
@@ -38,15 +44,29 @@ type testDatastore struct {
 }
 
 func (d *testDatastore) Backup(path string) (string, error)                  { return "", nil }
+func (d *testDatastore) RestoreFromFile(path string) error                   { return nil }
 func (d *testDatastore) Open() (bool, error)                                 { return false, nil }
 func (d *testDatastore) Init() error                                         { return nil }
 func (d *testDatastore) Close() error                                        { return nil }
 func (d *testDatastore) UpdateTx(func(dataservices.DataStoreTx) error) error { return nil }
 func (d *testDatastore) ViewTx(func(dataservices.DataStoreTx) error) error   { return nil }
 
+func (d *testDatastore) UpdateTxCtx(context.Context, func(dataservices.DataStoreTx) error) error {
+	return nil
+}
+
+func (d *testDatastore) ViewTxCtx(context.Context, func(dataservices.DataStoreTx) error) error {
+	return nil
+}
+
+func (d *testDatastore) UpdateTxRetryable(context.Context, func(dataservices.DataStoreTx) error) error {
+	return nil
+}
+
 func (d *testDatastore) CheckCurrentEdition() error                         { return nil }
 func (d *testDatastore) MigrateData() error                                 { return nil }
 func (d *testDatastore) Rollback(force bool) error                          { return nil }
+func (d *testDatastore) AuditLog() dataservices.AuditLogService             { return d.auditLog }
 func (d *testDatastore) CustomTemplate() dataservices.CustomTemplateService { return d.customTemplate }
 func (d *testDatastore) EdgeGroup() dataservices.EdgeGroupService           { return d.edgeGroup }
 func (d *testDatastore) EdgeJob() dataservices.EdgeJobService               { return d.edgeJob }
@@ -61,6 +81,9 @@ func (d *testDatastore) EndpointRelation() dataservices.EndpointRelationService
 func (d *testDatastore) HelmUserRepository() dataservices.HelmUserRepositoryService {
 	return d.helmUserRepository
 }
+func (d *testDatastore) JWTSigningKey() dataservices.JWTSigningKeyService {
+	return d.jwtSigningKey
+}
 func (d *testDatastore) Registry() dataservices.RegistryService { return d.registry }
 func (d *testDatastore) ResourceControl() dataservices.ResourceControlService {
 	return d.resourceControl
@@ -78,6 +101,7 @@ func (d *testDatastore) TeamMembership() dataservices.TeamMembershipService { re
 func (d *testDatastore) Team() dataservices.TeamService                     { return d.team }
 func (d *testDatastore) TunnelServer() dataservices.TunnelServerService     { return d.tunnelServer }
 func (d *testDatastore) User() dataservices.UserService                     { return d.user }
+func (d *testDatastore) UserSession() dataservices.UserSessionService       { return d.userSession }
 func (d *testDatastore) Version() dataservices.VersionService               { return d.version }
 func (d *testDatastore) Webhook() dataservices.WebhookService               { return d.webhook }
 
@@ -107,13 +131,65 @@ type datastoreOption = func(d *testDatastore)
 // Will apply options before returning, opts will be applied from left to right.
 func NewDatastore(options ...datastoreOption) *testDatastore {
 	conn, _ := database.NewDatabase("boltdb", "", nil)
-	d := testDatastore{connection: conn}
+	d := testDatastore{connection: conn, jwtSigningKey: &stubJWTSigningKeyService{}}
 	for _, o := range options {
 		o(&d)
 	}
 	return &d
 }
 
+// stubJWTSigningKeyService keeps the signing keys in memory, every testDatastore
+// has one so that the JWT service can be created from it
+type stubJWTSigningKeyService struct {
+	keys []portainer.JWTSigningKey
+}
+
+func (s *stubJWTSigningKeyService) Create(key *portainer.JWTSigningKey) error {
+	key.ID = 1
+	for _, k := range s.keys {
+		key.ID = max(key.ID, k.ID+1)
+	}
+
+	if key.CreatedAt == 0 {
+		key.CreatedAt = time.Now().Unix()
+	}
+	s.keys = append(s.keys, *key)
+
+	return nil
+}
+
+func (s *stubJWTSigningKeyService) Read(ID portainer.JWTSigningKeyID) (*portainer.JWTSigningKey, error) {
+	for _, key := range s.keys {
+		if key.ID == ID {
+			return &key, nil
+		}
+	}
+
+	return nil, errors.ErrObjectNotFound
+}
+
+func (s *stubJWTSigningKeyService) ReadAll() ([]portainer.JWTSigningKey, error) {
+	return slices.Clone(s.keys), nil
+}
+
+func (s *stubJWTSigningKeyService) Update(ID portainer.JWTSigningKeyID, key *portainer.JWTSigningKey) error {
+	for i := range s.keys {
+		if s.keys[i].ID == ID {
+			s.keys[i] = *key
+		}
+	}
+
+	return nil
+}
+
+func (s *stubJWTSigningKeyService) Delete(ID portainer.JWTSigningKeyID) error {
+	s.keys = slices.DeleteFunc(s.keys, func(key portainer.JWTSigningKey) bool {
+		return key.ID == ID
+	})
+
+	return nil
+}
+
 type stubSettingsService struct {
 	settings *portainer.Settings
 }
@@ -333,6 +409,16 @@ func (s *stubEndpointService) EndpointsByTeamID(teamID portainer.TeamID) ([]port
 	return endpoints, nil
 }
 
+func (s *stubEndpointService) EndpointsPage(page query.Page) ([]portainer.Endpoint, query.PageInfo, error) {
+	return pageOf(s.endpoints, page)
+}
+
+func (s *stubEndpointService) CountEndpoints(filter query.Filter) (int, error) {
+	_, info, err := pageOf(s.endpoints, query.Page{Filter: filter})
+
+	return info.Total, err
+}
+
 // WithEndpoints option will instruct testDatastore to return provided environments(endpoints)
 func WithEndpoints(endpoints []portainer.Endpoint) datastoreOption {
 	return func(d *testDatastore) {
@@ -413,6 +499,10 @@ func (s *stubStacksService) StacksByName(name string) ([]portainer.Stack, error)
 	return result, nil
 }
 
+func (s *stubStacksService) StacksPage(page query.Page) ([]portainer.Stack, query.PageInfo, error) {
+	return pageOf(s.stacks, page)
+}
+
 func (s *stubStacksService) StackByWebhookID(webhookID string) (*portainer.Stack, error) {
 	for _, stack := range s.stacks {
 		if stack.AutoUpdate != nil && stack.AutoUpdate.Webhook == webhookID {
@@ -432,3 +522,20 @@ func WithStacks(stacks []portainer.Stack) datastoreOption {
 		d.stack = &stubStacksService{stacks: stacks}
 	}
 }
+
+// pageOf selects a page of the stubbed objects like the stores scanning their buckets do
+func pageOf[T any](objects []T, page query.Page) ([]T, query.PageInfo, error) {
+	all := make([]any, 0, len(objects))
+	for i := range objects {
+		all = append(all, &objects[i])
+	}
+
+	result := make([]T, 0)
+	info, err := query.ApplyPage(all, page, func(o any) (any, error) {
+		result = append(result, *o.(*T))
+
+		return o, nil
+	})
+
+	return result, info, err
+}
@@ -3,6 +3,8 @@ package jwt
 import (
 	"errors"
 	"fmt"
+	"strconv"
+	"sync"
 	"time"
 
 	portainer "github.com/portainer/portainer/api"
@@ -31,6 +33,13 @@ type Service struct {
 	secrets            map[scope][]byte
 	userSessionTimeout time.Duration
 	dataStore          dataservices.DataStore
+
+	// mu guards the secrets and the signing keys, which are reloaded when
+	// the keys are rotated
+	mu              sync.RWMutex
+	signingKeys     map[portainer.JWTSigningKeyID][]byte
+	currentKeyID    portainer.JWTSigningKeyID
+	signingKeysSync time.Time
 }
 
 type claims struct {
@@ -39,6 +48,8 @@ type claims struct {
 	Role                int    `json:"role"`
 	Scope               scope  `json:"scope"`
 	ForceChangePassword bool   `json:"forceChangePassword"`
+	// TwoFactorEnrolmentRequired restricts the token to the enrolment of a second factor
+	TwoFactorEnrolmentRequired bool `json:"twoFactorEnrolmentRequired,omitempty"`
 	jwt.RegisteredClaims
 }
 
@@ -47,31 +58,32 @@ var (
 	errInvalidJWTToken  = errors.New("invalid JWT token")
 )
 
-// NewService initializes a new service. It will generate a random key that will be used to sign JWT tokens.
+// NewService initializes a new service. The tokens are signed with the signing
+// key persisted in the store, which is created when it does not exist yet.
 func NewService(userSessionDuration string, dataStore dataservices.DataStore) (*Service, error) {
 	userSessionTimeout, err := time.ParseDuration(userSessionDuration)
 	if err != nil {
 		return nil, err
 	}
 
-	secret := apikey.GenerateRandomKey(keyLen)
-	if secret == nil {
-		return nil, errSecretGeneration
-	}
-
 	kubeSecret, err := getOrCreateKubeSecret(dataStore)
 	if err != nil {
 		return nil, err
 	}
 
-	return &Service{
-		map[scope][]byte{
-			defaultScope:    secret,
+	service := &Service{
+		secrets: map[scope][]byte{
 			kubeConfigScope: kubeSecret,
 		},
-		userSessionTimeout,
-		dataStore,
-	}, nil
+		userSessionTimeout: userSessionTimeout,
+		dataStore:          dataStore,
+	}
+
+	if err := service.loadSigningKeys(); err != nil {
+		return nil, err
+	}
+
+	return service, nil
 }
 
 func getOrCreateKubeSecret(dataStore dataservices.DataStore) ([]byte, error) {
@@ -113,58 +125,66 @@ func (service *Service) GenerateToken(data *portainer.TokenData) (string, time.T
 
 // ParseAndVerifyToken parses a JWT token and verify its validity. It returns an error if token is invalid.
 func (service *Service) ParseAndVerifyToken(token string) (*portainer.TokenData, string, time.Time, error) {
-    scope := parseScope(token)
-    secret, exists := service.secrets[scope]
-    if !exists || secret == nil {
-        fmt.Println("Invalid scope or missing secret")
-        return nil, "", time.Time{}, errInvalidJWTToken
-    }
-
-    parsedToken, err := jwt.ParseWithClaims(token, &claims{}, func(token *jwt.Token) (any, error) {
-        if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-            return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-        }
-        return secret, nil
-    })
-
-    if err != nil || parsedToken == nil {
-        fmt.Printf("Token parsing failed: %v\n", err)
-        return nil, "", time.Time{}, errInvalidJWTToken
-    }
-
-    cl, ok := parsedToken.Claims.(*claims)
-    if !ok || cl == nil || !parsedToken.Valid {
-        fmt.Println("Invalid claims or token is not valid")
-        return nil, "", time.Time{}, errInvalidJWTToken
-    }
-
-	fmt.Println("cl: ",cl)
-	fmt.Println("cl.userid: ",cl.UserID)
-    user, err := service.dataStore.User().Read(portainer.UserID(cl.UserID))
-    if err != nil || user == nil {
-        fmt.Println("User lookup failed")
-        return nil, "", time.Time{}, errInvalidJWTToken
-    }
-
-	fmt.Println("user",user)
-    if user.TokenIssueAt > cl.RegisteredClaims.IssuedAt.Unix() {
-        fmt.Println("Token issued before user's token issuance time")
-        return nil, "", time.Time{}, errInvalidJWTToken
-    }
-
-    if cl.ExpiresAt == nil {
-        cl.ExpiresAt = &jwt.NumericDate{Time: time.Unix(0, 0)}
-    }
-
-    return &portainer.TokenData{
-        ID:                  portainer.UserID(cl.UserID),
-        Username:            cl.Username,
-        Role:                portainer.UserRole(cl.Role),
-        Token:               token,
-        ForceChangePassword: cl.ForceChangePassword,
-    }, cl.ID, cl.ExpiresAt.Time, nil
+	scope := parseScope(token)
+
+	parsedToken, err := jwt.ParseWithClaims(token, &claims{}, func(token *jwt.Token) (any, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+
+		return service.verificationKey(scope, token.Header)
+	})
+	if err != nil || parsedToken == nil {
+		return nil, "", time.Time{}, errInvalidJWTToken
+	}
+
+	cl, ok := parsedToken.Claims.(*claims)
+	if !ok || cl == nil || !parsedToken.Valid {
+		return nil, "", time.Time{}, errInvalidJWTToken
+	}
+
+	user, err := service.dataStore.User().Read(portainer.UserID(cl.UserID))
+	if err != nil || user == nil {
+		return nil, "", time.Time{}, errInvalidJWTToken
+	}
+
+	if cl.IssuedAt == nil || user.TokenIssueAt > cl.IssuedAt.Unix() {
+		return nil, "", time.Time{}, errInvalidJWTToken
+	}
+
+	if cl.ExpiresAt == nil {
+		cl.ExpiresAt = &jwt.NumericDate{Time: time.Unix(0, 0)}
+	}
+
+	return &portainer.TokenData{
+		ID:                         portainer.UserID(cl.UserID),
+		Username:                   cl.Username,
+		Role:                       portainer.UserRole(cl.Role),
+		Token:                      token,
+		ForceChangePassword:        cl.ForceChangePassword,
+		TwoFactorEnrolmentRequired: cl.TwoFactorEnrolmentRequired,
+	}, cl.ID, cl.ExpiresAt.Time, nil
 }
 
+// verificationKey returns the secret verifying a token of the given scope. The
+// tokens of the default scope name their signing key in the kid header.
+func (service *Service) verificationKey(scope scope, header map[string]any) ([]byte, error) {
+	if scope != defaultScope {
+		service.mu.RLock()
+		defer service.mu.RUnlock()
+
+		secret, ok := service.secrets[scope]
+		if !ok || secret == nil {
+			return nil, fmt.Errorf("invalid scope: %v", scope)
+		}
+
+		return secret, nil
+	}
+
+	kid, _ := header["kid"].(string)
+
+	return service.signingKey(kid)
+}
 
 // Parse a JWT token, fallback to defaultScope if no scope is present in the JWT
 func parseScope(token string) scope {
@@ -186,7 +206,11 @@ func (service *Service) SetUserSessionDuration(userSessionDuration time.Duration
 }
 
 func (service *Service) generateSignedToken(data *portainer.TokenData, expiresAt time.Time, scope scope) (string, error) {
+	service.mu.RLock()
 	secret, found := service.secrets[scope]
+	keyID := service.currentKeyID
+	service.mu.RUnlock()
+
 	if !found {
 		return "", fmt.Errorf("invalid scope: %v", scope)
 	}
@@ -207,11 +231,12 @@ func (service *Service) generateSignedToken(data *portainer.TokenData, expiresAt
 	}
 
 	cl := claims{
-		UserID:              int(data.ID),
-		Username:            data.Username,
-		Role:                int(data.Role),
-		Scope:               scope,
-		ForceChangePassword: data.ForceChangePassword,
+		UserID:                     int(data.ID),
+		Username:                   data.Username,
+		Role:                       int(data.Role),
+		Scope:                      scope,
+		ForceChangePassword:        data.ForceChangePassword,
+		TwoFactorEnrolmentRequired: data.TwoFactorEnrolmentRequired,
 		RegisteredClaims: jwt.RegisteredClaims{
 			ID:        uuid.String(),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
@@ -225,6 +250,9 @@ func (service *Service) generateSignedToken(data *portainer.TokenData, expiresAt
 	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, cl)
+	if scope == defaultScope {
+		token.Header["kid"] = strconv.Itoa(int(keyID))
+	}
 
 	return token.SignedString(secret)
 }
@@ -1,6 +1,7 @@
 package jwt
 
 import (
+	"strconv"
 	"testing"
 	"time"
 
@@ -111,3 +112,79 @@ func TestExpiration(t *testing.T) {
 	_, _, _, err = service.ParseAndVerifyToken(tokenString)
 	require.Error(t, err)
 }
+
+func TestSigningKeysSharedAcrossInstances(t *testing.T) {
+	_, store := datastore.MustNewTestStore(t, true, false)
+
+	err := store.User().Create(&portainer.User{ID: 1})
+	require.NoError(t, err)
+
+	first, err := NewService("1h", store)
+	require.NoError(t, err)
+
+	second, err := NewService("1h", store)
+	require.NoError(t, err)
+
+	keys, err := store.JWTSigningKey().ReadAll()
+	require.NoError(t, err)
+	require.Len(t, keys, 1)
+
+	tokenString, _, err := first.GenerateToken(&portainer.TokenData{Username: "User", ID: 1, Role: 1})
+	require.NoError(t, err)
+
+	token, _, err := new(jwt.Parser).ParseUnverified(tokenString, &claims{})
+	require.NoError(t, err)
+	assert.Equal(t, strconv.Itoa(int(keys[0].ID)), token.Header["kid"])
+
+	_, _, _, err = second.ParseAndVerifyToken(tokenString)
+	require.NoError(t, err)
+}
+
+func TestSigningKeyRotation(t *testing.T) {
+	_, store := datastore.MustNewTestStore(t, true, false)
+
+	err := store.User().Create(&portainer.User{ID: 1})
+	require.NoError(t, err)
+
+	service, err := NewService("1h", store)
+	require.NoError(t, err)
+
+	other, err := NewService("1h", store)
+	require.NoError(t, err)
+
+	data := &portainer.TokenData{Username: "User", ID: 1, Role: 1}
+
+	oldest, _, err := service.GenerateToken(data)
+	require.NoError(t, err)
+
+	// The key is not due yet
+	require.NoError(t, service.RotateSigningKeyIfDue(time.Hour))
+	keys, err := store.JWTSigningKey().ReadAll()
+	require.NoError(t, err)
+	require.Len(t, keys, 1)
+
+	require.NoError(t, service.RotateSigningKey())
+
+	previous, _, err := service.GenerateToken(data)
+	require.NoError(t, err)
+
+	// The previous key is still accepted, and the new one is picked up by the other instance
+	_, _, _, err = service.ParseAndVerifyToken(oldest)
+	require.NoError(t, err)
+
+	other.signingKeysSync = time.Time{}
+	_, _, _, err = other.ParseAndVerifyToken(previous)
+	require.NoError(t, err)
+
+	require.NoError(t, service.RotateSigningKey())
+
+	keys, err = store.JWTSigningKey().ReadAll()
+	require.NoError(t, err)
+	require.Len(t, keys, 2)
+
+	_, _, _, err = service.ParseAndVerifyToken(previous)
+	require.NoError(t, err)
+
+	_, _, _, err = service.ParseAndVerifyToken(oldest)
+	require.ErrorIs(t, err, errInvalidJWTToken)
+}
@@ -0,0 +1,225 @@
+package jwt
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"time"
+
+	portainer "github.com/portainer/portainer/api"
+	"github.com/portainer/portainer/api/apikey"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// DefaultSigningKeyRotationInterval is the age at which the signing key is replaced
+	DefaultSigningKeyRotationInterval = 30 * 24 * time.Hour
+
+	// signingKeysRefreshInterval limits how often a token signed with an unknown
+	// key reloads the keys from the store
+	signingKeysRefreshInterval = 10 * time.Second
+)
+
+var errUnknownSigningKey = errors.New("unknown JWT signing key")
+
+// loadSigningKeys reads the signing keys shared by the instances using the
+// store, the first instance to start creates the initial key
+func (service *Service) loadSigningKeys() error {
+	keys, err := service.readSigningKeys()
+	if err != nil {
+		return err
+	}
+
+	if len(keys) == 0 {
+		key, err := service.createSigningKey()
+		if err != nil {
+			return err
+		}
+
+		keys = append(keys, *key)
+	}
+
+	service.setSigningKeys(keys)
+
+	// The keys are encrypted at rest along with the rest of an encrypted store
+	if connection := service.dataStore.Connection(); connection != nil && !connection.IsEncryptedStore() {
+		log.Warn().Msg("the JWT signing keys are stored unencrypted, load a secret key to encrypt the store")
+	}
+
+	return nil
+}
+
+// readSigningKeys returns the signing keys of the store, newest first
+func (service *Service) readSigningKeys() ([]portainer.JWTSigningKey, error) {
+	keys, err := service.dataStore.JWTSigningKey().ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("unable to read the JWT signing keys: %w", err)
+	}
+
+	slices.SortFunc(keys, func(a, b portainer.JWTSigningKey) int {
+		return cmp.Compare(b.ID, a.ID)
+	})
+
+	return keys, nil
+}
+
+func (service *Service) createSigningKey() (*portainer.JWTSigningKey, error) {
+	secret := apikey.GenerateRandomKey(keyLen)
+	if secret == nil {
+		return nil, errSecretGeneration
+	}
+
+	key := &portainer.JWTSigningKey{Secret: secret}
+	if err := service.dataStore.JWTSigningKey().Create(key); err != nil {
+		return nil, fmt.Errorf("unable to persist the JWT signing key: %w", err)
+	}
+
+	return key, nil
+}
+
+// setSigningKeys signs the new tokens with the newest key, and accepts the
+// tokens signed with it or with the key it replaced
+func (service *Service) setSigningKeys(keys []portainer.JWTSigningKey) {
+	accepted := make(map[portainer.JWTSigningKeyID][]byte, 2)
+	for _, key := range keys[:min(2, len(keys))] {
+		accepted[key.ID] = key.Secret
+	}
+
+	service.mu.Lock()
+	defer service.mu.Unlock()
+
+	service.secrets[defaultScope] = keys[0].Secret
+	service.currentKeyID = keys[0].ID
+	service.signingKeys = accepted
+	service.signingKeysSync = time.Now()
+}
+
+// signingKey returns the secret of the signing key named by the kid header of a
+// token. A key that is not known yet may have been created by another instance,
+// the keys are then reloaded from the store.
+func (service *Service) signingKey(kid string) ([]byte, error) {
+	id, err := strconv.Atoi(kid)
+	if err != nil {
+		return nil, errUnknownSigningKey
+	}
+
+	service.mu.Lock()
+	secret, ok := service.signingKeys[portainer.JWTSigningKeyID(id)]
+	refresh := !ok && time.Since(service.signingKeysSync) >= signingKeysRefreshInterval
+	if refresh {
+		service.signingKeysSync = time.Now()
+	}
+	service.mu.Unlock()
+
+	if ok {
+		return secret, nil
+	}
+
+	if !refresh {
+		return nil, errUnknownSigningKey
+	}
+
+	keys, err := service.readSigningKeys()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(keys) == 0 {
+		return nil, errUnknownSigningKey
+	}
+
+	service.setSigningKeys(keys)
+
+	service.mu.RLock()
+	defer service.mu.RUnlock()
+
+	if secret, ok := service.signingKeys[portainer.JWTSigningKeyID(id)]; ok {
+		return secret, nil
+	}
+
+	return nil, errUnknownSigningKey
+}
+
+// RotateSigningKeyIfDue replaces the signing key once it is older than maxAge.
+// The keys are read from the store first, an instance picks up the key created
+// by another instance instead of replacing it again. The key is never replaced
+// in Docker Desktop extension mode, where the tokens do not expire.
+func (service *Service) RotateSigningKeyIfDue(maxAge time.Duration) error {
+	if maxAge <= 0 {
+		maxAge = DefaultSigningKeyRotationInterval
+	}
+
+	keys, err := service.readSigningKeys()
+	if err != nil {
+		return err
+	}
+
+	settings, err := service.dataStore.Settings().Settings()
+	if err != nil {
+		return fmt.Errorf("failed fetching settings from db: %w", err)
+	}
+
+	if len(keys) > 0 && (settings.IsDockerDesktopExtension || time.Since(time.Unix(keys[0].CreatedAt, 0)) < maxAge) {
+		service.setSigningKeys(keys)
+
+		return nil
+	}
+
+	return service.RotateSigningKey()
+}
+
+// ReloadSigningKeys reads the signing keys from the store, to pick up a key
+// rotated by another instance
+func (service *Service) ReloadSigningKeys() error {
+	keys, err := service.readSigningKeys()
+	if err != nil {
+		return err
+	}
+
+	if len(keys) > 0 {
+		service.setSigningKeys(keys)
+	}
+
+	return nil
+}
+
+// RotateSigningKey creates a new signing key. The key it replaces keeps being
+// accepted until the next rotation so that the sessions it signed survive, the
+// older keys are deleted.
+func (service *Service) RotateSigningKey() error {
+	keys, err := service.readSigningKeys()
+	if err != nil {
+		return err
+	}
+
+	key, err := service.createSigningKey()
+	if err != nil {
+		return err
+	}
+
+	for i := range keys {
+		if i > 0 {
+			if err := service.dataStore.JWTSigningKey().Delete(keys[i].ID); err != nil {
+				return fmt.Errorf("unable to delete the JWT signing key %d: %w", keys[i].ID, err)
+			}
+
+			continue
+		}
+
+		if keys[i].RetiredAt == 0 {
+			keys[i].RetiredAt = key.CreatedAt
+			if err := service.dataStore.JWTSigningKey().Update(keys[i].ID, &keys[i]); err != nil {
+				return fmt.Errorf("unable to retire the JWT signing key %d: %w", keys[i].ID, err)
+			}
+		}
+	}
+
+	service.setSigningKeys(append([]portainer.JWTSigningKey{*key}, keys[:min(1, len(keys))]...))
+
+	log.Info().Int("kid", int(key.ID)).Msg("JWT signing key rotated")
+
+	return nil
+}
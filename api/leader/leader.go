@@ -0,0 +1,96 @@
+package leader
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// campaignInterval is how often the leader confirms that it still holds the
+	// lock and the other instances try to acquire it
+	campaignInterval = 5 * time.Second
+
+	releaseTimeout = 5 * time.Second
+)
+
+// Lock is held by at most one of the instances sharing the database
+type Lock interface {
+	// TryAcquire acquires the lock or confirms that it is still held, it
+	// returns false while another instance holds it
+	TryAcquire(ctx context.Context) (bool, error)
+	// Release gives the lock up when it is held
+	Release(ctx context.Context) error
+}
+
+// Elector elects the instance running the singleton background jobs. The
+// instance holding the lock is the leader, another instance acquires it and
+// takes over when the leader stops or dies.
+type Elector struct {
+	lock   Lock
+	leader atomic.Bool
+}
+
+// NewElector creates an elector campaigning for lock
+func NewElector(lock Lock) *Elector {
+	return &Elector{lock: lock}
+}
+
+// IsLeader returns true when this instance is the leader
+func (elector *Elector) IsLeader() bool {
+	return elector.leader.Load()
+}
+
+// Start campaigns for the leadership until ctx is done. The first campaign
+// completes before Start returns so that the leader is known at startup.
+func (elector *Elector) Start(ctx context.Context) {
+	elector.campaign(ctx)
+
+	go elector.run(ctx)
+}
+
+func (elector *Elector) run(ctx context.Context) {
+	ticker := time.NewTicker(campaignInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			elector.resign()
+
+			return
+		case <-ticker.C:
+			elector.campaign(ctx)
+		}
+	}
+}
+
+func (elector *Elector) campaign(ctx context.Context) {
+	held, err := elector.lock.TryAcquire(ctx)
+	if err != nil {
+		log.Warn().Err(err).Msg("unable to acquire the leader lock")
+
+		held = false
+	}
+
+	if elector.leader.Swap(held) != held {
+		log.Info().Bool("leader", held).Msg("leadership changed")
+	}
+}
+
+// resign releases the lock so that another instance takes over without
+// waiting for it to expire
+func (elector *Elector) resign() {
+	if !elector.leader.Swap(false) {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), releaseTimeout)
+	defer cancel()
+
+	if err := elector.lock.Release(ctx); err != nil {
+		log.Warn().Err(err).Msg("unable to release the leader lock")
+	}
+}
@@ -0,0 +1,100 @@
+package leader
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+
+	portainer "github.com/portainer/portainer/api"
+	"github.com/portainer/portainer/api/dataservices"
+)
+
+const (
+	// LeaseBucketName is the bucket holding the leases
+	LeaseBucketName = "leader_leases"
+
+	leaseKey = "leader"
+
+	// leaseDuration leaves the leader a few campaigns to renew its lease
+	leaseDuration = 3 * campaignInterval
+)
+
+// lease is the record of the instance holding the lock
+type lease struct {
+	Holder    string `json:"holder"`
+	ExpiresAt int64  `json:"expiresAt"`
+}
+
+// Lease is a lock stored in the database, for the backends without advisory
+// locks. It expires when its holder stops renewing it.
+type Lease struct {
+	connection portainer.Connection
+	holder     string
+	now        func() time.Time
+}
+
+// NewLease creates a lease stored through connection
+func NewLease(connection portainer.Connection) (*Lease, error) {
+	if err := connection.SetServiceName(LeaseBucketName, portainer.KeyKindString); err != nil {
+		return nil, err
+	}
+
+	holder := make([]byte, 8)
+	_, _ = rand.Read(holder)
+
+	return &Lease{
+		connection: connection,
+		holder:     hex.EncodeToString(holder),
+		now:        time.Now,
+	}, nil
+}
+
+// TryAcquire takes the lease when it is free or expired, and renews it when it is held
+func (l *Lease) TryAcquire(ctx context.Context) (bool, error) {
+	acquired := false
+
+	err := l.connection.UpdateTxRetryable(ctx, func(tx portainer.Transaction) error {
+		acquired = false
+
+		var current lease
+		err := tx.GetObject(LeaseBucketName, []byte(leaseKey), &current)
+		if err != nil && !dataservices.IsErrObjectNotFound(err) {
+			return err
+		}
+
+		now := l.now()
+		if current.Holder != l.holder && current.ExpiresAt > now.UnixMilli() {
+			return nil
+		}
+
+		acquired = true
+
+		return tx.UpdateObject(LeaseBucketName, []byte(leaseKey), &lease{
+			Holder:    l.holder,
+			ExpiresAt: now.Add(leaseDuration).UnixMilli(),
+		})
+	})
+
+	return acquired, err
+}
+
+// Release deletes the lease when it is held
+func (l *Lease) Release(ctx context.Context) error {
+	return l.connection.UpdateTxRetryable(ctx, func(tx portainer.Transaction) error {
+		var current lease
+		if err := tx.GetObject(LeaseBucketName, []byte(leaseKey), &current); err != nil {
+			if dataservices.IsErrObjectNotFound(err) {
+				return nil
+			}
+
+			return err
+		}
+
+		if current.Holder != l.holder {
+			return nil
+		}
+
+		return tx.DeleteObject(LeaseBucketName, []byte(leaseKey))
+	})
+}
@@ -0,0 +1,55 @@
+package leader
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/portainer/portainer/api/database/boltdb"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLeaseTakeOver(t *testing.T) {
+	connection := &boltdb.DbConnection{Path: t.TempDir()}
+	require.NoError(t, connection.Open())
+	defer connection.Close()
+
+	now := time.Now()
+	clock := func() time.Time { return now }
+
+	first, err := NewLease(connection)
+	require.NoError(t, err)
+	first.now = clock
+
+	second, err := NewLease(connection)
+	require.NoError(t, err)
+	second.now = clock
+
+	ctx := context.Background()
+
+	held, err := first.TryAcquire(ctx)
+	require.NoError(t, err)
+	require.True(t, held)
+
+	held, err = second.TryAcquire(ctx)
+	require.NoError(t, err)
+	require.False(t, held, "the lease is held by the first instance")
+
+	// The first instance stops renewing its lease
+	now = now.Add(leaseDuration + time.Second)
+
+	held, err = second.TryAcquire(ctx)
+	require.NoError(t, err)
+	require.True(t, held, "the expired lease is taken over")
+
+	held, err = first.TryAcquire(ctx)
+	require.NoError(t, err)
+	require.False(t, held)
+
+	require.NoError(t, second.Release(ctx))
+
+	held, err = first.TryAcquire(ctx)
+	require.NoError(t, err)
+	require.True(t, held, "the released lease is free")
+}
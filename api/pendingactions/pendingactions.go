@@ -16,6 +16,7 @@ type PendingActionsService struct {
 	kubeFactory *kubecli.ClientFactory
 	dataStore   dataservices.DataStore
 	mu          sync.Mutex
+	leader      portainer.LeaderElector
 }
 
 var handlers = make(map[string]portainer.PendingActionHandler)
@@ -54,11 +55,48 @@ func (service *PendingActionsService) Create(action portainer.PendingAction) err
 	return service.dataStore.PendingActions().Create(&action)
 }
 
+// SetLeaderElector restricts the execution of the pending actions to the
+// leader instance, so that an action is not executed by several instances
+func (service *PendingActionsService) SetLeaderElector(leader portainer.LeaderElector) {
+	service.leader = leader
+}
+
 func (service *PendingActionsService) Execute(id portainer.EndpointID) {
+	if !service.isLeader() {
+		return
+	}
+
 	// Run in a goroutine to avoid blocking the main thread due to db tx	=
 	go service.execute(id)
 }
 
+// ExecuteAll executes the pending actions of every environment that is up,
+// including the ones whose execution was skipped by the other instances
+func (service *PendingActionsService) ExecuteAll() error {
+	if !service.isLeader() {
+		return nil
+	}
+
+	pendingActions, err := service.dataStore.PendingActions().ReadAll()
+	if err != nil {
+		return fmt.Errorf("failed to retrieve pending actions: %w", err)
+	}
+
+	executed := make(map[portainer.EndpointID]bool)
+	for _, pendingAction := range pendingActions {
+		if !executed[pendingAction.EndpointID] {
+			executed[pendingAction.EndpointID] = true
+			service.execute(pendingAction.EndpointID)
+		}
+	}
+
+	return nil
+}
+
+func (service *PendingActionsService) isLeader() bool {
+	return service.leader == nil || service.leader.IsLeader()
+}
+
 func (service *PendingActionsService) execute(environmentID portainer.EndpointID) {
 	service.mu.Lock()
 	defer service.mu.Unlock()
@@ -132,6 +132,28 @@ type (
 		SecretKeyName             *string
 		LogLevel                  *string
 		LogMode                   *string
+		DBType                    *string
+		DBHost                    *string
+		DBPort                    *string
+		DBUser                    *string
+		DBName                    *string
+		DBSSLMode                 *string
+		DBPasswordFile            *string
+		DBDSN                     *string
+		DBStatementTimeout        *time.Duration
+		DBIsolation               *string
+		JWTKeyRotationInterval    *time.Duration
+		DBReplicaDSNs             *[]string
+		DBReplicaMaxLag           *time.Duration
+		DBMaxOpenConns            *int
+		DBMaxIdleConns            *int
+		DBConnMaxLifetime         *time.Duration
+		DBConnMaxIdleTime         *time.Duration
+		DBHealthCheckInterval     *time.Duration
+		ClusterAddr               *string
+		AuditLogRetention         *time.Duration
+		MigrateStoreFrom          *string
+		MigrateStoreTo            *string
 	}
 
 	// CustomTemplateVariableDefinition
@@ -883,6 +905,39 @@ type (
 	// RoleID represents a role identifier
 	RoleID int
 
+	// AuditLogID represents an audit log record identifier
+	AuditLogID int
+
+	// AuditLog records a request that changed, or attempted to change, the state
+	// of Portainer or of an environment. The records are never updated.
+	AuditLog struct {
+		ID AuditLogID `json:"Id" example:"1"`
+		// Unix timestamp (UTC) of the request
+		Timestamp int64 `json:"Timestamp" example:"1700000000"`
+		// The user the request was authenticated as, 0 for the anonymous requests
+		UserID   UserID `json:"UserId,omitempty" example:"1"`
+		Username string `json:"Username,omitempty" example:"admin"`
+		// The API key the request was authenticated with, 0 for the other requests
+		APIKeyID   APIKeyID   `json:"ApiKeyId,omitempty" example:"1"`
+		EndpointID EndpointID `json:"EndpointId,omitempty" example:"1"`
+		// The resource the request applies to, such as stacks or containers
+		ResourceType string `json:"ResourceType,omitempty" example:"containers"`
+		ResourceID   string `json:"ResourceId,omitempty" example:"my-container"`
+		// Action is create, update or delete, or the operation named by the path such as start
+		Action     string `json:"Action" example:"start"`
+		Method     string `json:"Method" example:"POST"`
+		Path       string `json:"Path" example:"/api/endpoints/1/docker/containers/my-container/start"`
+		StatusCode int    `json:"StatusCode" example:"204"`
+		// Outcome is success when the status code is lower than 400, failure otherwise
+		Outcome  AuditOutcome `json:"Outcome" example:"success"`
+		ClientIP string       `json:"ClientIp,omitempty" example:"10.0.0.2"`
+		// Request summarizes the request body, the secrets it holds are redacted
+		Request string `json:"Request,omitempty"`
+	}
+
+	// AuditOutcome is the outcome of an audited request
+	AuditOutcome string
+
 	// APIKeyID represents an API key identifier
 	APIKeyID int
 
@@ -895,6 +950,51 @@ type (
 		DateCreated int64    `json:"dateCreated"`      // Unix timestamp (UTC) when the API key was created
 		LastUsed    int64    `json:"lastUsed"`         // Unix timestamp (UTC) when the API key was last used
 		Digest      string   `json:"digest,omitempty"` // Digest represents SHA256 hash of the raw API key
+		// ExpiresAt is the Unix timestamp (UTC) the API key expires at, 0 when it never expires
+		ExpiresAt int64 `json:"expiresAt,omitempty"`
+		// Scope restricts the operations the API key can be used for
+		Scope *APIKeyScope `json:"scope,omitempty"`
+	}
+
+	// APIKeyScope restricts the rights an API key grants to its owner. The
+	// restrictions add up, the empty ones do not apply.
+	APIKeyScope struct {
+		// ReadOnly restricts the API key to the requests that do not change anything
+		ReadOnly bool `json:"readOnly,omitempty"`
+		// EndpointIDs restricts the API key to these environments
+		EndpointIDs []EndpointID `json:"endpointIds,omitempty"`
+		// Authorizations restricts the API key to these operations
+		Authorizations Authorizations `json:"authorizations,omitempty"`
+	}
+
+	// JWTSigningKeyID represents a JWT signing key identifier, it is sent as the kid header of the tokens
+	JWTSigningKeyID int
+
+	// JWTSigningKey represents a secret used to sign the JWT tokens. It is
+	// shared by every Portainer instance using the same store.
+	JWTSigningKey struct {
+		ID        JWTSigningKeyID `json:"id"`
+		Secret    []byte          `json:"secret"`
+		CreatedAt int64           `json:"createdAt"` // Unix timestamp (UTC) when the key was created
+		RetiredAt int64           `json:"retiredAt"` // Unix timestamp (UTC) when a newer key replaced it, 0 for the current key
+	}
+
+	// UserSessionID represents a user session identifier
+	UserSessionID int
+
+	// UserSession records a JWT issued to a user. A revoked session is kept
+	// until its token expires so that every instance rejects the token.
+	UserSession struct {
+		ID     UserSessionID `json:"id" example:"1"`
+		JTI    string        `json:"jti" example:"1f4d5b5e-3f2c-4b8e-9a3a-6f8f1d4c2b7a"`
+		UserID UserID        `json:"userId" example:"1"`
+		// Unix timestamps (UTC) of the issue and of the expiry of the token, the expiry is 0 for the tokens that never expire
+		IssuedAt  int64  `json:"issuedAt"`
+		ExpiresAt int64  `json:"expiresAt"`
+		ClientIP  string `json:"clientIp" example:"10.0.0.2"`
+		UserAgent string `json:"userAgent"`
+		// Unix timestamp (UTC) of the revocation of the token, 0 while it is valid
+		RevokedAt int64 `json:"revokedAt,omitempty"`
 	}
 
 	// Schedule represents a scheduled job.
@@ -979,6 +1079,8 @@ type (
 		TrustOnFirstConnect bool `json:"TrustOnFirstConnect" example:"false"`
 		// EnforceEdgeID makes Portainer store the Edge ID instead of accepting anyone
 		EnforceEdgeID bool `json:"EnforceEdgeID" example:"false"`
+		// Whether the internal users must log in with a TOTP second factor
+		RequireTwoFactor bool `json:"RequireTwoFactor" example:"false"`
 		// Container environment parameter AGENT_SECRET
 		AgentSecret string `json:"AgentSecret"`
 		// EdgePortainerURL is the URL that is exposed to edge agents
@@ -1277,6 +1379,10 @@ type (
 		Role                UserRole
 		ForceChangePassword bool
 		Token               string
+		// APIKeyScope is the scope of the API key the request was authenticated with
+		APIKeyScope *APIKeyScope
+		// TwoFactorEnrolmentRequired restricts the token to the enrolment of a second factor
+		TwoFactorEnrolmentRequired bool
 	}
 
 	// TunnelDetails represents information associated to a tunnel
@@ -1287,9 +1393,23 @@ type (
 		Credentials  string
 	}
 
+	// TunnelRoute represents the entry of a tunnel in the registry shared by the
+	// Portainer instances using the same store
+	TunnelRoute struct {
+		EndpointID EndpointID
+		// Internal address of the instance holding the chisel session of the agent, empty until the agent connects
+		Owner       string
+		Port        int
+		Credentials string
+		// Unix timestamp (UTC) of the last update of the entry
+		UpdatedAt int64
+	}
+
 	// TunnelServerInfo represents information associated to the tunnel server
 	TunnelServerInfo struct {
 		PrivateKeySeed string `json:"PrivateKeySeed"`
+		// Secret signing the requests the instances forward to each other
+		ForwardingSecret []byte `json:"ForwardingSecret,omitempty"`
 	}
 
 	// User represents a user account
@@ -1303,6 +1423,8 @@ type (
 		TokenIssueAt  int64             `json:"TokenIssueAt" example:"1"`
 		ThemeSettings UserThemeSettings `json:"ThemeSettings"`
 		UseCache      bool              `json:"UseCache" example:"true"`
+		// TOTP second factor of the user, its secrets are never returned by the API
+		TwoFactor UserTwoFactor `json:"TwoFactor"`
 
 		// Deprecated fields
 
@@ -1336,6 +1458,33 @@ type (
 		Color string `json:"color" example:"dark" enums:"dark,light,highcontrast,auto"`
 	}
 
+	// UserTwoFactor represents the TOTP second factor of an internal user. The
+	// secrets are stored like the rest of the user: encrypted with the store
+	// key when one is loaded, in plaintext otherwise. They are not encrypted
+	// on their own as an unencrypted store has no key to encrypt them with,
+	// and whoever reads it also reads the JWT signing keys and can sign
+	// tokens without a second factor.
+	UserTwoFactor struct {
+		// Whether the user logs in with a TOTP code
+		Enabled bool `json:"Enabled" example:"true"`
+		// Base32 secret the codes are generated from
+		Secret string `json:"Secret,omitempty" swaggerignore:"true"`
+		// Secret awaiting the confirmation of the enrolment
+		PendingSecret string `json:"PendingSecret,omitempty" swaggerignore:"true"`
+		// Hashes of the recovery codes that were not used
+		RecoveryCodes []string `json:"RecoveryCodes,omitempty" swaggerignore:"true"`
+		// Time step of the last code used, a code is accepted once
+		LastStep int64 `json:"LastStep,omitempty" swaggerignore:"true"`
+		// SHA256 digest of the token of the login awaiting a code
+		LoginChallenge string `json:"LoginChallenge,omitempty" swaggerignore:"true"`
+		// Unix timestamp (UTC) the login awaiting a code expires at
+		LoginChallengeExpiresAt int64 `json:"LoginChallengeExpiresAt,omitempty" swaggerignore:"true"`
+		// Number of codes tried for the login awaiting a code
+		LoginAttempts int `json:"LoginAttempts,omitempty" swaggerignore:"true"`
+		// Whether the password given for the login awaiting a code must be changed
+		LoginForceChangePassword bool `json:"LoginForceChangePassword,omitempty" swaggerignore:"true"`
+	}
+
 	// Webhook represents a url webhook that can be used to update a service
 	Webhook struct {
 		// Webhook Identifier
@@ -1544,6 +1693,12 @@ type (
 		CreateSnapshot(endpoint *Endpoint) (*KubernetesSnapshot, error)
 	}
 
+	// LeaderElector tells whether this instance runs the background jobs that
+	// must run on a single instance when several instances share the database
+	LeaderElector interface {
+		IsLeader() bool
+	}
+
 	// LDAPService represents a service used to authenticate users against a LDAP/AD
 	LDAPService interface {
 		AuthenticateUser(username, password string, settings *LDAPSettings) error
@@ -1567,6 +1722,7 @@ type (
 		Config(endpointID EndpointID) TunnelDetails
 		TunnelAddr(endpoint *Endpoint) (string, error)
 		UpdateLastActivity(endpointID EndpointID)
+		TunnelOwner(endpointID EndpointID) string
 		KeepTunnelAlive(endpointID EndpointID, ctx context.Context, maxKeepAlive time.Duration)
 	}
 
@@ -1633,6 +1789,8 @@ const (
 	DefaultHelmRepositoryURL = "https://charts.bitnami.com/bitnami"
 	// DefaultUserSessionTimeout represents the default timeout after which the user session is cleared
 	DefaultUserSessionTimeout = "8h"
+	// DefaultAuditLogRetention is how long the audit log records are kept
+	DefaultAuditLogRetention = "2160h"
 	// DefaultUserSessionTimeout represents the default timeout after which the user session is cleared
 	DefaultKubeconfigExpiry = "0"
 	// DefaultKubectlShellImage represents the default image and tag for the kubectl shell
@@ -1754,6 +1912,13 @@ const (
 	SnapshotJobType = 2
 )
 
+const (
+	// AuditSuccess is the outcome of the requests answered with a status code lower than 400
+	AuditSuccess AuditOutcome = "success"
+	// AuditFailure is the outcome of the other requests
+	AuditFailure AuditOutcome = "failure"
+)
+
 const (
 	_ MembershipRole = iota
 	// TeamLeader represents a leader role inside a team
@@ -6,6 +6,8 @@ import (
 	"sync"
 	"time"
 
+	portainer "github.com/portainer/portainer/api"
+
 	"github.com/pkg/errors"
 	"github.com/robfig/cron/v3"
 	"github.com/rs/zerolog/log"
@@ -15,6 +17,7 @@ type Scheduler struct {
 	crontab    *cron.Cron
 	activeJobs map[cron.EntryID]context.CancelFunc
 	mu         sync.Mutex
+	leader     portainer.LeaderElector
 }
 
 type PermanentError struct {
@@ -127,3 +130,29 @@ func (s *Scheduler) StartJobEvery(duration time.Duration, job func() error) stri
 
 	return strconv.Itoa(int(*entryID))
 }
+
+// SetLeaderElector restricts the singleton jobs to the leader instance
+func (s *Scheduler) SetLeaderElector(leader portainer.LeaderElector) {
+	s.mu.Lock()
+	s.leader = leader
+	s.mu.Unlock()
+}
+
+// StartSingletonJobEvery schedules a new periodic job like StartJobEvery. When
+// several instances share the database, the job only runs on the leader.
+func (s *Scheduler) StartSingletonJobEvery(duration time.Duration, job func() error) string {
+	return s.StartJobEvery(duration, func() error {
+		if !s.isLeader() {
+			return nil
+		}
+
+		return job()
+	})
+}
+
+func (s *Scheduler) isLeader() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.leader == nil || s.leader.IsLeader()
+}
@@ -138,3 +138,36 @@ func Test_StartJobEvery_Concurrently(t *testing.T) {
 
 	<-ctx.Done()
 }
+
+type testLeader struct {
+	leader atomic.Bool
+}
+
+func (l *testLeader) IsLeader() bool {
+	return l.leader.Load()
+}
+
+func Test_SingletonJobRunsOnLeaderOnly(t *testing.T) {
+	s := NewScheduler(context.Background())
+	defer s.Shutdown()
+
+	leader := &testLeader{}
+	s.SetLeaderElector(leader)
+
+	var runs atomic.Int64
+	ch := make(chan struct{})
+	s.StartSingletonJobEvery(jobInterval, func() error {
+		runs.Add(1)
+		close(ch)
+
+		return NewPermanentError(errors.New("done"))
+	})
+
+	<-time.After(2 * jobInterval)
+	assert.Equal(t, int64(0), runs.Load(), "job shouldn't run while the instance is not the leader")
+
+	leader.leader.Store(true)
+
+	<-ch
+	assert.Equal(t, int64(1), runs.Load())
+}
@@ -1,6 +1,7 @@
 package deployments
 
 import (
+	"sync"
 	"time"
 
 	portainer "github.com/portainer/portainer/api"
@@ -11,25 +12,105 @@ import (
 	"github.com/rs/zerolog/log"
 )
 
+// autoupdateJob is the job polling a stack in the scheduler of this instance
+type autoupdateJob struct {
+	jobID    string
+	interval string
+}
+
+// autoupdateJobs holds the polling jobs by stack. The job ID saved in a stack is
+// only meaningful to the instance that scheduled it, every instance schedules
+// the jobs and the leader runs them.
+var autoupdateJobs = struct {
+	sync.Mutex
+	jobs map[portainer.StackID]autoupdateJob
+}{jobs: make(map[portainer.StackID]autoupdateJob)}
+
 func StartAutoupdate(stackID portainer.StackID, interval string, scheduler *scheduler.Scheduler, stackDeployer StackDeployer, datastore dataservices.DataStore, gitService portainer.GitService) (jobID string, e *httperror.HandlerError) {
 	d, err := time.ParseDuration(interval)
 	if err != nil {
 		return "", httperror.BadRequest("Unable to parse stack's auto update interval", err)
 	}
 
-	jobID = scheduler.StartJobEvery(d, func() error {
+	autoupdateJobs.Lock()
+	defer autoupdateJobs.Unlock()
+
+	stopAutoupdateJob(stackID, scheduler)
+
+	jobID = scheduler.StartSingletonJobEvery(d, func() error {
 		return RedeployWhenChanged(stackID, stackDeployer, datastore, gitService)
 	})
 
+	autoupdateJobs.jobs[stackID] = autoupdateJob{jobID: jobID, interval: interval}
+
 	return jobID, nil
 }
 
-func StopAutoupdate(stackID portainer.StackID, jobID string, scheduler *scheduler.Scheduler) {
-	if jobID == "" {
+func StopAutoupdate(stackID portainer.StackID, scheduler *scheduler.Scheduler) {
+	autoupdateJobs.Lock()
+	defer autoupdateJobs.Unlock()
+
+	stopAutoupdateJob(stackID, scheduler)
+}
+
+func stopAutoupdateJob(stackID portainer.StackID, scheduler *scheduler.Scheduler) {
+	job, ok := autoupdateJobs.jobs[stackID]
+	if !ok {
 		return
 	}
 
-	if err := scheduler.StopJob(jobID); err != nil {
+	delete(autoupdateJobs.jobs, stackID)
+
+	if err := scheduler.StopJob(job.jobID); err != nil {
 		log.Warn().Int("stack_id", int(stackID)).Msg("could not stop the job for the stack")
 	}
 }
+
+// SyncAutoupdate schedules or stops the polling of a stack changed by another instance
+func SyncAutoupdate(stackID portainer.StackID, scheduler *scheduler.Scheduler, stackDeployer StackDeployer, datastore dataservices.DataStore, gitService portainer.GitService) {
+	stack, err := datastore.Stack().Read(stackID)
+	if dataservices.IsErrObjectNotFound(err) {
+		StopAutoupdate(stackID, scheduler)
+
+		return
+	} else if err != nil {
+		log.Warn().Err(err).Int("stack_id", int(stackID)).Msg("unable to read the changed stack")
+
+		return
+	}
+
+	syncAutoupdate(stack, scheduler, stackDeployer, datastore, gitService)
+}
+
+func syncAutoupdate(stack *portainer.Stack, scheduler *scheduler.Scheduler, stackDeployer StackDeployer, datastore dataservices.DataStore, gitService portainer.GitService) {
+	interval := autoupdateInterval(stack)
+	if interval == "" {
+		StopAutoupdate(stack.ID, scheduler)
+
+		return
+	}
+
+	autoupdateJobs.Lock()
+	job, ok := autoupdateJobs.jobs[stack.ID]
+	autoupdateJobs.Unlock()
+
+	if ok && job.interval == interval {
+		return
+	}
+
+	if _, e := StartAutoupdate(stack.ID, interval, scheduler, stackDeployer, datastore, gitService); e != nil {
+		log.Warn().Err(e.Err).Int("stack_id", int(stack.ID)).Msg("unable to schedule the stack auto update")
+	}
+}
+
+// autoupdateInterval returns the polling interval of a stack, or an empty
+// string when the stack is not polled. A stopped stack is not polled, its job
+// is stopped by the instance stopping it and its status is what the other
+// instances and the restarted ones see.
+func autoupdateInterval(stack *portainer.Stack) string {
+	if stack.AutoUpdate == nil || stack.Status == portainer.StackStatusInactive {
+		return ""
+	}
+
+	return stack.AutoUpdate.Interval
+}
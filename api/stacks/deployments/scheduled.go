@@ -1,14 +1,14 @@
 package deployments
 
 import (
-	"time"
-
 	"github.com/pkg/errors"
 	portainer "github.com/portainer/portainer/api"
 	"github.com/portainer/portainer/api/dataservices"
 	"github.com/portainer/portainer/api/scheduler"
 )
 
+// StartStackSchedules schedules the polling of the stacks when the instance
+// starts. The job IDs are not saved, every instance schedules its own jobs.
 func StartStackSchedules(scheduler *scheduler.Scheduler, stackdeployer StackDeployer, datastore dataservices.DataStore, gitService portainer.GitService) error {
 	stacks, err := datastore.Stack().RefreshableStacks()
 	if err != nil {
@@ -16,19 +16,45 @@ func StartStackSchedules(scheduler *scheduler.Scheduler, stackdeployer StackDepl
 	}
 
 	for _, stack := range stacks {
-		d, err := time.ParseDuration(stack.AutoUpdate.Interval)
-		if err != nil {
-			return errors.Wrap(err, "Unable to parse auto update interval")
+		interval := autoupdateInterval(&stack)
+		if interval == "" {
+			continue
 		}
-		stackID := stack.ID // to be captured by the scheduled function
-		jobID := scheduler.StartJobEvery(d, func() error {
-			return RedeployWhenChanged(stackID, stackdeployer, datastore, gitService)
-		})
-
-		stack.AutoUpdate.JobID = jobID
-		if err := datastore.Stack().Update(stack.ID, &stack); err != nil {
-			return errors.Wrap(err, "failed to update stack job id")
+
+		if _, e := StartAutoupdate(stack.ID, interval, scheduler, stackdeployer, datastore, gitService); e != nil {
+			return errors.Wrap(e.Err, "Unable to parse auto update interval")
 		}
 	}
 	return nil
 }
+
+// SyncStackSchedules schedules the polling of every stack that is not polled
+// yet and stops the polling of the others, after the stacks were changed by
+// another instance
+func SyncStackSchedules(scheduler *scheduler.Scheduler, stackdeployer StackDeployer, datastore dataservices.DataStore, gitService portainer.GitService) error {
+	stacks, err := datastore.Stack().RefreshableStacks()
+	if err != nil {
+		return errors.Wrap(err, "failed to fetch refreshable stacks")
+	}
+
+	polled := make(map[portainer.StackID]struct{}, len(stacks))
+	for _, stack := range stacks {
+		if autoupdateInterval(&stack) != "" {
+			polled[stack.ID] = struct{}{}
+		}
+	}
+
+	autoupdateJobs.Lock()
+	for stackID := range autoupdateJobs.jobs {
+		if _, ok := polled[stackID]; !ok {
+			stopAutoupdateJob(stackID, scheduler)
+		}
+	}
+	autoupdateJobs.Unlock()
+
+	for i := range stacks {
+		syncAutoupdate(&stacks[i], scheduler, stackdeployer, datastore, gitService)
+	}
+
+	return nil
+}
@@ -0,0 +1,46 @@
+package deployments
+
+import (
+	"testing"
+
+	portainer "github.com/portainer/portainer/api"
+	"github.com/portainer/portainer/api/datastore"
+	"github.com/portainer/portainer/api/scheduler"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_StartStackSchedules(t *testing.T) {
+	is := require.New(t)
+
+	_, store := datastore.MustNewTestStore(t, true, true)
+
+	s := scheduler.NewScheduler(nil)
+	t.Cleanup(func() { s.Shutdown() })
+
+	stacks := []*portainer.Stack{
+		{ID: 1, Name: "active", Status: portainer.StackStatusActive, AutoUpdate: &portainer.AutoUpdateSettings{Interval: "1h"}},
+		{ID: 2, Name: "stopped", Status: portainer.StackStatusInactive, AutoUpdate: &portainer.AutoUpdateSettings{Interval: "1h"}},
+		{ID: 3, Name: "webhook", Status: portainer.StackStatusActive, AutoUpdate: &portainer.AutoUpdateSettings{Webhook: "webhook"}},
+	}
+	for _, stack := range stacks {
+		is.NoError(store.Stack().Create(stack))
+		t.Cleanup(func() { StopAutoupdate(stack.ID, s) })
+	}
+
+	is.NoError(StartStackSchedules(s, nil, store, nil))
+
+	autoupdateJobs.Lock()
+	_, active := autoupdateJobs.jobs[1]
+	_, stopped := autoupdateJobs.jobs[2]
+	_, webhook := autoupdateJobs.jobs[3]
+	autoupdateJobs.Unlock()
+
+	is.True(active)
+	is.False(stopped, "a stopped stack is not polled")
+	is.False(webhook)
+
+	stack, err := store.Stack().Read(1)
+	is.NoError(err)
+	is.Empty(stack.AutoUpdate.JobID, "the job ID of an instance is not saved")
+}
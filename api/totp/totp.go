@@ -0,0 +1,161 @@
+// Package totp implements the time-based one-time passwords of RFC 6238 used
+// as a second authentication factor, with the defaults of the authenticator
+// applications: HMAC-SHA1, 6 digits and a 30 seconds period.
+package totp
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	// period is the lifetime of a code
+	period = 30
+	// digits is the length of a code
+	digits = 6
+	// skew is the number of periods a code is accepted before and after its own,
+	// it absorbs the clock drift of the devices
+	skew = 1
+	// secretSize is the size of the secrets in bytes, as recommended by RFC 4226
+	secretSize = 20
+	// recoveryCodeSize is the size of the recovery codes in bytes
+	recoveryCodeSize = 10
+)
+
+var (
+	encoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+	ErrInvalidSecret = errors.New("invalid TOTP secret")
+)
+
+// GenerateSecret generates a random secret encoded in base32
+func GenerateSecret() (string, error) {
+	secret := make([]byte, secretSize)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+
+	return encoding.EncodeToString(secret), nil
+}
+
+// ProvisioningURI returns the otpauth URI of a secret, the authenticator
+// applications enrol it by scanning its QR code
+func ProvisioningURI(issuer, account, secret string) string {
+	query := url.Values{}
+	query.Set("secret", secret)
+	query.Set("issuer", issuer)
+	query.Set("algorithm", "SHA1")
+	query.Set("digits", fmt.Sprint(digits))
+	query.Set("period", fmt.Sprint(period))
+
+	return (&url.URL{
+		Scheme:   "otpauth",
+		Host:     "totp",
+		Path:     "/" + issuer + ":" + account,
+		RawQuery: query.Encode(),
+	}).String()
+}
+
+// Code returns the code of a secret at a time
+func Code(secret string, t time.Time) (string, error) {
+	key, err := decodeSecret(secret)
+	if err != nil {
+		return "", err
+	}
+
+	return code(key, step(t)), nil
+}
+
+// Validate verifies a code at a time and returns the time step it belongs to.
+// The codes of the steps up to lastStep were already used and are rejected so
+// that a code cannot be replayed.
+func Validate(secret, passcode string, t time.Time, lastStep int64) (int64, bool) {
+	key, err := decodeSecret(secret)
+	if err != nil {
+		return 0, false
+	}
+
+	passcode = strings.TrimSpace(passcode)
+	if len(passcode) != digits {
+		return 0, false
+	}
+
+	current := step(t)
+	for s := current - skew; s <= current+skew; s++ {
+		if s <= lastStep {
+			continue
+		}
+
+		if subtle.ConstantTimeCompare([]byte(code(key, s)), []byte(passcode)) == 1 {
+			return s, true
+		}
+	}
+
+	return 0, false
+}
+
+// GenerateRecoveryCodes generates single-use codes that replace the TOTP codes
+// when the device holding the secret is lost
+func GenerateRecoveryCodes(count int) ([]string, error) {
+	codes := make([]string, 0, count)
+
+	for range count {
+		b := make([]byte, recoveryCodeSize)
+		if _, err := rand.Read(b); err != nil {
+			return nil, err
+		}
+
+		c := strings.ToLower(encoding.EncodeToString(b))
+		codes = append(codes, c[:8]+"-"+c[8:])
+	}
+
+	return codes, nil
+}
+
+// NormalizeRecoveryCode returns a recovery code as it was generated, the users
+// may type it in upper case or without its dash
+func NormalizeRecoveryCode(recoveryCode string) string {
+	c := strings.ToLower(strings.ReplaceAll(strings.TrimSpace(recoveryCode), "-", ""))
+	if len(c) != 16 {
+		return c
+	}
+
+	return c[:8] + "-" + c[8:]
+}
+
+func decodeSecret(secret string) ([]byte, error) {
+	key, err := encoding.DecodeString(strings.ToUpper(strings.TrimRight(secret, "=")))
+	if err != nil || len(key) == 0 {
+		return nil, ErrInvalidSecret
+	}
+
+	return key, nil
+}
+
+func step(t time.Time) int64 {
+	return t.Unix() / period
+}
+
+// code computes the HOTP code of RFC 4226 of a counter
+func code(key []byte, counter int64) string {
+	msg := make([]byte, 8)
+	binary.BigEndian.PutUint64(msg, uint64(counter))
+
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg)
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	return fmt.Sprintf("%0*d", digits, value%1_000_000)
+}
@@ -0,0 +1,74 @@
+package totp
+
+import (
+	"encoding/base32"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// rfcSecret is the SHA1 secret of the test vectors of RFC 6238
+var rfcSecret = base32.StdEncoding.EncodeToString([]byte("12345678901234567890"))
+
+func TestCode(t *testing.T) {
+	// the last 6 digits of the 8 digits codes of RFC 6238
+	for unix, expected := range map[int64]string{
+		59:          "287082",
+		1111111109:  "081804",
+		1111111111:  "050471",
+		1234567890:  "005924",
+		2000000000:  "279037",
+		20000000000: "353130",
+	} {
+		code, err := Code(rfcSecret, time.Unix(unix, 0))
+		require.NoError(t, err)
+		require.Equal(t, expected, code, unix)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	is := require.New(t)
+
+	secret, err := GenerateSecret()
+	is.NoError(err)
+
+	now := time.Now()
+	code, err := Code(secret, now)
+	is.NoError(err)
+
+	s, ok := Validate(secret, code, now, 0)
+	is.True(ok)
+
+	_, ok = Validate(secret, code, now.Add(period*time.Second), 0)
+	is.True(ok, "the previous code is accepted")
+
+	_, ok = Validate(secret, code, now.Add(3*period*time.Second), 0)
+	is.False(ok, "the old codes are rejected")
+
+	_, ok = Validate(secret, code, now, s)
+	is.False(ok, "a code is accepted once")
+
+	_, ok = Validate("not base32!", code, now, 0)
+	is.False(ok)
+}
+
+func TestProvisioningURI(t *testing.T) {
+	uri := ProvisioningURI("Portainer", "admin", "JBSWY3DPEHPK3PXP")
+
+	require.True(t, strings.HasPrefix(uri, "otpauth://totp/Portainer:admin?"), uri)
+	require.Contains(t, uri, "secret=JBSWY3DPEHPK3PXP")
+	require.Contains(t, uri, "issuer=Portainer")
+}
+
+func TestRecoveryCodes(t *testing.T) {
+	codes, err := GenerateRecoveryCodes(10)
+	require.NoError(t, err)
+	require.Len(t, codes, 10)
+
+	for _, code := range codes {
+		require.Len(t, code, 17)
+		require.Equal(t, code, NormalizeRecoveryCode(strings.ToUpper(strings.ReplaceAll(code, "-", ""))))
+	}
+}
@@ -13,6 +13,10 @@ func (h *HandlerError) Error() string {
 	return h.Message
 }
 
+func (h *HandlerError) Unwrap() error {
+	return h.Err
+}
+
 func NewError(statusCode int, message string, err error) *HandlerError {
 	return &HandlerError{
 		StatusCode: statusCode,